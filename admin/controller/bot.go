@@ -50,11 +50,15 @@ type RegisterBot struct {
 
 type GetBotConfRes struct {
 	Data struct {
-		Base  *conf.BaseConf  `json:"base"`
-		Audio *conf.AudioConf `json:"audio"`
-		LLM   *conf.LLMConf   `json:"llm"`
-		Photo *conf.PhotoConf `json:"photo"`
-		Video *conf.VideoConf `json:"video"`
+		Base      *conf.BaseConf      `json:"base"`
+		Audio     *conf.AudioConf     `json:"audio"`
+		LLM       *conf.LLMConf       `json:"llm"`
+		Photo     *conf.PhotoConf     `json:"photo"`
+		Video     *conf.VideoConf     `json:"video"`
+		Provider  *conf.ProviderConf  `json:"provider"`
+		Quota     *conf.QuotaConf     `json:"quota"`
+		RBAC      *conf.RBACConf      `json:"rbac"`
+		RateLimit *conf.RateLimitConf `json:"rate_limit"`
 	} `json:"data"`
 }
 
@@ -393,11 +397,15 @@ func GetBotConf(w http.ResponseWriter, r *http.Request) {
 	}
 
 	res := map[string]map[string]any{
-		"base":  make(map[string]any),
-		"audio": make(map[string]any),
-		"llm":   make(map[string]any),
-		"photo": make(map[string]any),
-		"video": make(map[string]any),
+		"base":       make(map[string]any),
+		"audio":      make(map[string]any),
+		"llm":        make(map[string]any),
+		"photo":      make(map[string]any),
+		"video":      make(map[string]any),
+		"provider":   make(map[string]any),
+		"quota":      make(map[string]any),
+		"rbac":       make(map[string]any),
+		"rate_limit": make(map[string]any),
 	}
 	for k, v := range CompareFlagsWithStructTags(httpRes.Data.Base) {
 		res["base"][k] = v
@@ -414,6 +422,18 @@ func GetBotConf(w http.ResponseWriter, r *http.Request) {
 	for k, v := range CompareFlagsWithStructTags(httpRes.Data.Video) {
 		res["video"][k] = v
 	}
+	for k, v := range CompareFlagsWithStructTags(httpRes.Data.Provider) {
+		res["provider"][k] = v
+	}
+	for k, v := range CompareFlagsWithStructTags(httpRes.Data.Quota) {
+		res["quota"][k] = v
+	}
+	for k, v := range CompareFlagsWithStructTags(httpRes.Data.RBAC) {
+		res["rbac"][k] = v
+	}
+	for k, v := range CompareFlagsWithStructTags(httpRes.Data.RateLimit) {
+		res["rate_limit"][k] = v
+	}
 
 	utils.Success(ctx, w, r, res)
 }
@@ -444,6 +464,32 @@ func AddUserToken(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func UpdateUserPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodPost,
+		strings.TrimSuffix(botInfo.Address, "/")+"/user/plan/update", r.Body))
+	if err != nil {
+		logger.ErrorCtx(ctx, "update user plan error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.ErrorCtx(ctx, "copy response body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
+
 func GetBotUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	botInfo, err := getBot(r)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	adminUtils "github.com/yincongcyincong/MuseBot/admin/utils"
@@ -14,6 +15,7 @@ import (
 )
 
 type RagInfo struct {
+	KB       string `json:"kb"`
 	FileName string `json:"file_name"`
 	Content  string `json:"content"`
 }
@@ -33,7 +35,7 @@ func ListRagFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodGet, strings.TrimSuffix(botInfo.Address, "/")+
-		fmt.Sprintf("/rag/list?page=%s&page_size=%s&name=%s", r.FormValue("page"), r.FormValue("pageSize"), r.FormValue("name")), bytes.NewBuffer(nil)))
+		fmt.Sprintf("/rag/list?page=%s&page_size=%s&name=%s&kb=%s", r.FormValue("page"), r.FormValue("pageSize"), r.FormValue("name"), r.FormValue("kb")), bytes.NewBuffer(nil)))
 	if err != nil {
 		logger.ErrorCtx(ctx, "get bot user error", "err", err)
 		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
@@ -59,7 +61,7 @@ func GetRagFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodGet,
-		strings.TrimSuffix(botInfo.Address, "/")+"/rag/get?file_name="+r.FormValue("file_name"), bytes.NewBuffer(nil)))
+		strings.TrimSuffix(botInfo.Address, "/")+"/rag/get?file_name="+r.FormValue("file_name")+"&kb="+r.FormValue("kb"), bytes.NewBuffer(nil)))
 	if err != nil {
 		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
 		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
@@ -85,7 +87,7 @@ func DeleteRagFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodGet,
-		strings.TrimSuffix(botInfo.Address, "/")+"/rag/delete?file_name="+r.FormValue("file_name"), bytes.NewBuffer(nil)))
+		strings.TrimSuffix(botInfo.Address, "/")+"/rag/delete?file_name="+r.FormValue("file_name")+"&kb="+r.FormValue("kb"), bytes.NewBuffer(nil)))
 	if err != nil {
 		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
 		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
@@ -110,8 +112,122 @@ func CreateRagFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req := GetRequest(ctx, http.MethodPost, strings.TrimSuffix(botInfo.Address, "/")+"/rag/create", r.Body)
+	// multipart upload keeps its boundary
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+	}
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(req)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.ErrorCtx(ctx, "copy response body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
+
+func ListKnowledgeBases(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodGet,
+		strings.TrimSuffix(botInfo.Address, "/")+"/rag/kb/list", bytes.NewBuffer(nil)))
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.ErrorCtx(ctx, "copy response body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
+
+func ReindexRagFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
 	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodGet,
-		strings.TrimSuffix(botInfo.Address, "/")+"/rag/create", r.Body))
+		strings.TrimSuffix(botInfo.Address, "/")+"/rag/reindex?file_name="+url.QueryEscape(r.FormValue("file_name"))+
+			"&kb="+url.QueryEscape(r.FormValue("kb")), bytes.NewBuffer(nil)))
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.ErrorCtx(ctx, "copy response body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
+
+func ExportRagFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodGet,
+		strings.TrimSuffix(botInfo.Address, "/")+"/rag/export?kb="+url.QueryEscape(r.URL.Query().Get("kb")), bytes.NewBuffer(nil)))
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != "" {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.ErrorCtx(ctx, "copy response body error", "err", err)
+		return
+	}
+}
+
+func ImportRagFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	req := GetRequest(ctx, http.MethodPost,
+		strings.TrimSuffix(botInfo.Address, "/")+"/rag/import?kb="+url.QueryEscape(r.URL.Query().Get("kb")), r.Body)
+	req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(req)
 	if err != nil {
 		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
 		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
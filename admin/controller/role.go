@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	adminUtils "github.com/yincongcyincong/MuseBot/admin/utils"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func GetBotUserRoles(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(r.Context(), "parse form error", "err", err)
+		utils.Failure(r.Context(), w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	proxyRoleRequest(w, r, http.MethodGet, fmt.Sprintf("/user/role/list?page=%s&page_size=%s&subject_type=%s",
+		r.FormValue("page"), r.FormValue("pageSize"), r.FormValue("subjectType")), bytes.NewBuffer(nil))
+}
+
+func UpdateBotUserRole(w http.ResponseWriter, r *http.Request) {
+	proxyRoleRequest(w, r, http.MethodPost, "/user/role/update", r.Body)
+}
+
+func GetBotRolePolicies(w http.ResponseWriter, r *http.Request) {
+	proxyRoleRequest(w, r, http.MethodGet, "/user/policy/get", bytes.NewBuffer(nil))
+}
+
+func UpdateBotRolePolicy(w http.ResponseWriter, r *http.Request) {
+	proxyRoleRequest(w, r, http.MethodPost, "/user/policy/update", r.Body)
+}
+
+func DeleteBotRolePolicy(w http.ResponseWriter, r *http.Request) {
+	proxyRoleRequest(w, r, http.MethodPost, "/user/policy/delete", r.Body)
+}
+
+// proxyRoleRequest forward role request to bot and copy response back.
+func proxyRoleRequest(w http.ResponseWriter, r *http.Request, method, path string, body io.Reader) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, method,
+		strings.TrimSuffix(botInfo.Address, "/")+path, body))
+	if err != nil {
+		logger.ErrorCtx(ctx, "request bot role error", "err", err, "path", path)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.ErrorCtx(ctx, "copy response body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
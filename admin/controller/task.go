@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yincongcyincong/MuseBot/admin/db"
+	adminUtils "github.com/yincongcyincong/MuseBot/admin/utils"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+// ListTaskRuns forward page query of task runs to bot
+func ListTaskRuns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot user error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	targetURL := strings.TrimSuffix(botInfo.Address, "/") +
+		fmt.Sprintf("/task/list?page=%s&page_size=%s&user_id=%s&status=%s", r.FormValue("page"), r.FormValue("pageSize"),
+			url.QueryEscape(r.FormValue("user_id")), url.QueryEscape(r.FormValue("status")))
+	proxyTaskRequest(w, r, botInfo, targetURL)
+}
+
+// GetTaskRun forward query of task run and its steps to bot, id is bot id so run id is passed as run_id
+func GetTaskRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	targetURL := strings.TrimSuffix(botInfo.Address, "/") + "/task/get?id=" + url.QueryEscape(r.FormValue("run_id"))
+	proxyTaskRequest(w, r, botInfo, targetURL)
+}
+
+// CancelTaskRun forward cancel of task run to bot
+func CancelTaskRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get bot conf error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	targetURL := strings.TrimSuffix(botInfo.Address, "/") + "/task/cancel?id=" + url.QueryEscape(r.FormValue("run_id"))
+	proxyTaskRequest(w, r, botInfo, targetURL)
+}
+
+func proxyTaskRequest(w http.ResponseWriter, r *http.Request, botInfo *db.Bot, targetURL string) {
+	ctx := r.Context()
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(GetRequest(ctx, http.MethodGet, targetURL, bytes.NewBuffer(nil)))
+	if err != nil {
+		logger.ErrorCtx(ctx, "request task error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.ErrorCtx(ctx, "copy response body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
@@ -53,6 +53,12 @@ func main() {
 	mux.HandleFunc("/bot/user/mode/update", controller.RequireLogin(controller.UpdateUserMode))
 	mux.HandleFunc("/bot/user/insert/records", controller.RequireLogin(controller.InsertUserRecord))
 	mux.HandleFunc("/bot/add/token", controller.RequireLogin(controller.AddUserToken))
+	mux.HandleFunc("/bot/user/plan/update", controller.RequireLogin(controller.UpdateUserPlan))
+	mux.HandleFunc("/bot/user/role/list", controller.RequireLogin(controller.GetBotUserRoles))
+	mux.HandleFunc("/bot/user/role/update", controller.RequireLogin(controller.UpdateBotUserRole))
+	mux.HandleFunc("/bot/user/policy/get", controller.RequireLogin(controller.GetBotRolePolicies))
+	mux.HandleFunc("/bot/user/policy/update", controller.RequireLogin(controller.UpdateBotRolePolicy))
+	mux.HandleFunc("/bot/user/policy/delete", controller.RequireLogin(controller.DeleteBotRolePolicy))
 	mux.HandleFunc("/bot/online", controller.RequireLogin(controller.GetAllOnlineBot))
 	mux.HandleFunc("/bot/mcp/get", controller.RequireLogin(controller.GetBotMCPConf))
 	mux.HandleFunc("/bot/mcp/update", controller.RequireLogin(controller.UpdateBotMCPConf))
@@ -66,11 +72,18 @@ func main() {
 	mux.HandleFunc("/bot/rag/delete", controller.RequireLogin(controller.DeleteRagFile))
 	mux.HandleFunc("/bot/rag/create", controller.RequireLogin(controller.CreateRagFile))
 	mux.HandleFunc("/bot/rag/get", controller.RequireLogin(controller.GetRagFile))
+	mux.HandleFunc("/bot/rag/kb/list", controller.RequireLogin(controller.ListKnowledgeBases))
+	mux.HandleFunc("/bot/rag/reindex", controller.RequireLogin(controller.ReindexRagFile))
+	mux.HandleFunc("/bot/rag/export", controller.RequireLogin(controller.ExportRagFile))
+	mux.HandleFunc("/bot/rag/import", controller.RequireLogin(controller.ImportRagFile))
 	mux.HandleFunc("/bot/cron/list", controller.RequireLogin(controller.ListCrons))
 	mux.HandleFunc("/bot/cron/delete", controller.RequireLogin(controller.DeleteCron))
 	mux.HandleFunc("/bot/cron/create", controller.RequireLogin(controller.CreateCron))
 	mux.HandleFunc("/bot/cron/update/status", controller.RequireLogin(controller.UpdateCronStatus))
 	mux.HandleFunc("/bot/cron/update", controller.RequireLogin(controller.UpdateCron))
+	mux.HandleFunc("/bot/task/list", controller.RequireLogin(controller.ListTaskRuns))
+	mux.HandleFunc("/bot/task/get", controller.RequireLogin(controller.GetTaskRun))
+	mux.HandleFunc("/bot/task/cancel", controller.RequireLogin(controller.CancelTaskRun))
 
 	mux.HandleFunc("/user/login", controller.UserLogin)
 	mux.HandleFunc("/user/me", controller.RequireLogin(controller.GetCurrentUserHandler))
@@ -13,6 +13,8 @@ import {
     ScrollText,
     DatabaseIcon,
     Timer,
+    ListChecks,
+    ShieldCheck,
 } from "lucide-react";
 import {useTranslation} from "react-i18next";
 
@@ -27,7 +29,9 @@ export default function Sidebar() {
         { path: "/bot", label: t("bots"), icon: Bot },
         { path: "/mcp", label: t("mcp"), icon: Database },
         { path: "/cron", label: t("cron"), icon: Timer },
+        { path: "/task", label: t("task"), icon: ListChecks },
         { path: "/users", label: t("bot_users"), icon: UserCircle },
+        { path: "/role", label: t("role"), icon: ShieldCheck },
         { path: "/chats", label: t("bot_chats"), icon: MessageCircle },
         { path: "/communicate", label: t("chat"), icon: MessageSquare },
         { path: "/rag", label: t("rag"), icon: DatabaseIcon },
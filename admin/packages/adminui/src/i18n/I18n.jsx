@@ -20,6 +20,8 @@ i18n
                     day: "Days",
                     message_new_num: "Message New Number",
                     user_new_num: "User New Number",
+                    total_cost: "Total Spend",
+                    cost_new: "Spend",
 
                     user_manage: "User Management",
                     add_user: "Add User",
@@ -92,6 +94,10 @@ i18n
                     rag: "RAG",
                     rag_manage: "RAG Management",
                     add_file: "Add File",
+                    reindex_all: "Reindex All",
+                    index_status: "Index Status",
+                    export_kb: "Export",
+                    import_kb: "Import",
                     file_name: "File Name",
                     rich_text: "Rich Text",
 
@@ -113,6 +119,47 @@ i18n
                     json_edit: "Json Edit",
                     service_name: "Service Name",
                     config_json: "Config Json",
+
+                    task: "Task",
+                    task_run_manage: "Task Run Management",
+                    task_content: "Task",
+                    task_loop: "Loop",
+                    task_error: "Error",
+                    task_detail: "Detail",
+                    task_result: "Result",
+                    task_depends_on: "Depends On",
+                    task_cancelling: "Task run is cancelling",
+                    no_task_runs: "No Task Runs",
+                    task_status_running: "Running",
+                    task_status_done: "Done",
+                    task_status_failed: "Failed",
+                    task_status_cancelled: "Cancelled",
+                    task_status_interrupted: "Interrupted",
+                    task_status_pending: "Pending",
+                    all: "All",
+
+                    role: "Role",
+                    role_manage: "Role Management",
+                    role_policies: "Role Policies",
+                    role_bindings: "Role Assignments",
+                    default_role: "Default Role",
+                    add_role_policy: "Add Role Policy",
+                    edit_role_policy: "Edit Role Policy",
+                    assign_role: "Assign Role",
+                    role_commands: "Commands",
+                    role_mcp_servers: "MCP Servers",
+                    role_providers: "Providers",
+                    role_models: "Models",
+                    role_pattern_placeholder: "comma separated, * matches all, xxx* matches prefix",
+                    role_subject_type: "Subject Type",
+                    role_subject_id: "Subject ID",
+                    role_subject_id_placeholder: "User ID or Group ID",
+                    role_subject_user: "User",
+                    role_subject_group: "Group",
+                    role_none: "No Role",
+                    no_role_policies: "No Role Policies",
+                    no_role_bindings: "No Role Assignments",
+                    save_success: "Saved",
                 }
             },
             zh: {
@@ -128,6 +175,8 @@ i18n
                     day: "天",
                     message_new_num: "新增消息数",
                     user_new_num: "新增用户数",
+                    total_cost: "总花费",
+                    cost_new: "花费",
 
                     user_manage: "用户管理",
                     add_user: "添加用户",
@@ -200,6 +249,10 @@ i18n
                     rag: "RAG",
                     rag_manage: "RAG 管理",
                     add_file: "添加文件",
+                    reindex_all: "全部重建索引",
+                    index_status: "索引状态",
+                    export_kb: "导出",
+                    import_kb: "导入",
                     file_name: "文件名",
                     rich_text: "富文本",
 
@@ -222,6 +275,46 @@ i18n
                     config_json: "配置json",
                     no_cron_tasks: "没有定时任务",
 
+                    task: "任务",
+                    task_run_manage: "任务执行管理",
+                    task_content: "任务内容",
+                    task_loop: "轮次",
+                    task_error: "错误",
+                    task_detail: "详情",
+                    task_result: "结果",
+                    task_depends_on: "依赖",
+                    task_cancelling: "任务正在取消",
+                    no_task_runs: "没有任务记录",
+                    task_status_running: "执行中",
+                    task_status_done: "已完成",
+                    task_status_failed: "失败",
+                    task_status_cancelled: "已取消",
+                    task_status_interrupted: "已中断",
+                    task_status_pending: "等待中",
+                    all: "全部",
+
+                    role: "角色",
+                    role_manage: "角色管理",
+                    role_policies: "角色策略",
+                    role_bindings: "角色分配",
+                    default_role: "默认角色",
+                    add_role_policy: "添加角色策略",
+                    edit_role_policy: "编辑角色策略",
+                    assign_role: "分配角色",
+                    role_commands: "命令",
+                    role_mcp_servers: "MCP 服务",
+                    role_providers: "模型厂商",
+                    role_models: "模型",
+                    role_pattern_placeholder: "逗号分隔，* 匹配全部，xxx* 按前缀匹配",
+                    role_subject_type: "对象类型",
+                    role_subject_id: "对象 ID",
+                    role_subject_id_placeholder: "用户 ID 或群组 ID",
+                    role_subject_user: "用户",
+                    role_subject_group: "群组",
+                    role_none: "无角色",
+                    no_role_policies: "没有角色策略",
+                    no_role_bindings: "没有角色分配",
+                    save_success: "保存成功",
                 }
             }
         },
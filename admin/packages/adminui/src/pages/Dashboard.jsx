@@ -11,7 +11,7 @@ import {
     Tooltip
 } from "chart.js";
 import {Line} from "react-chartjs-2";
-import {Bot, ClipboardList, Users} from "lucide-react";
+import {Bot, ClipboardList, Users, Wallet} from "lucide-react";
 import { useTranslation } from 'react-i18next';
 
 ChartJS.register(
@@ -87,7 +87,12 @@ export default function DashboardPage() {
         return parts.join(' ');
     }
 
-    const buildChartData = (dayCountArray, color = "rgb(59 130 246)") => {
+    const formatCost = (cost, currency) => {
+        if (cost === undefined || cost === null) return "-";
+        return `${Number(cost).toFixed(4)} ${currency || ""}`.trim();
+    };
+
+    const buildChartData = (dayCountArray, color = "rgb(59 130 246)", valueKey = "new_count") => {
         if (!dayCountArray || dayCountArray.length === 0) {
             return {
                 labels: [],
@@ -101,7 +106,7 @@ export default function DashboardPage() {
             labels: sorted.map(item => formatHourMinute(item.date)),
             datasets: [
                 {
-                    data: sorted.map(item => item.new_count),
+                    data: sorted.map(item => item[valueKey]),
                     fill: false,
                     borderColor: color,
                     backgroundColor: color,
@@ -173,6 +178,16 @@ export default function DashboardPage() {
                                 {loading ? "Loading..." : formatDurationFromTimestamp(dashboardData?.start_time)}
                             </div>
                         </div>
+
+                        <div className="flex-1 bg-white rounded shadow p-4 text-center flex flex-col items-center">
+                            <div className="text-gray-500 mb-2 flex items-center justify-center space-x-2">
+                                <Wallet className="text-amber-600 w-6 h-6"/>
+                                <span>{t("total_cost")}</span>
+                            </div>
+                            <div className="text-3xl font-semibold text-amber-700">
+                                {loading ? "Loading..." : formatCost(dashboardData?.total_cost, dashboardData?.currency)}
+                            </div>
+                        </div>
                     </div>
 
                     <div className="mb-6">
@@ -216,6 +231,20 @@ export default function DashboardPage() {
                                 />
                             )}
                         </div>
+
+                        <div className="bg-white rounded shadow p-4">
+                            <h3 className="text-center font-semibold mb-2 text-gray-800">
+                                {t("cost_new")}{dashboardData?.currency ? ` (${dashboardData.currency})` : ""}
+                            </h3>
+                            {loading || !dashboardData ? (
+                                <div className="text-center text-gray-500 py-16">Loading chart...</div>
+                            ) : (
+                                <Line
+                                    data={buildChartData(dashboardData.cost_day_count, "rgb(217 119 6)", "cost")}
+                                    options={chartOptions}
+                                />
+                            )}
+                        </div>
                     </div>
                 </>
             )}
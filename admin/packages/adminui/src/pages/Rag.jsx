@@ -103,6 +103,66 @@ function Rag() {
         }
     };
 
+    // 重建索引, fileName 为空时重建全部文件
+    const handleReindex = async (name = "") => {
+        try {
+            const params = new URLSearchParams({id: botId, file_name: name});
+            const res = await fetch(`/bot/rag/reindex?${params.toString()}`, {method: "POST"});
+            const data = await res.json();
+            if (data.code === 0) {
+                showToast(`${data.data.total} file(s) queued for indexing`, "success");
+                await fetchRagList();
+            } else {
+                showToast(data.message);
+            }
+        } catch (err) {
+            showToast("Failed to reindex: " + err.message);
+        }
+    };
+
+    // 导出知识库为 zip, 包含源文件, 分块和向量
+    const handleExport = () => {
+        const params = new URLSearchParams({id: botId});
+        window.location.href = `/bot/rag/export?${params.toString()}`;
+    };
+
+    // 导入 zip, embedder 相同时直接复用向量
+    const handleImport = async (e) => {
+        const file = e.target.files[0];
+        e.target.value = "";
+        if (!file) {
+            return;
+        }
+        try {
+            const formData = new FormData();
+            formData.append("file", file);
+            const params = new URLSearchParams({id: botId});
+            const res = await fetch(`/bot/rag/import?${params.toString()}`, {method: "POST", body: formData});
+            const data = await res.json();
+            if (data.code === 0) {
+                showToast(`${data.data.files} file(s), ${data.data.chunks} chunk(s) imported`, "success");
+                await fetchRagList();
+            } else {
+                showToast(data.message);
+            }
+        } catch (err) {
+            showToast("Failed to import: " + err.message);
+        }
+    };
+
+    const statusClass = (status) => {
+        switch (status) {
+            case "done":
+                return "bg-green-100 text-green-800";
+            case "failed":
+                return "bg-red-100 text-red-800";
+            case "indexing":
+                return "bg-blue-100 text-blue-800";
+            default:
+                return "bg-gray-100 text-gray-800";
+        }
+    };
+
     const handleDeleteClick = (fileName) => {
         setFileToDelete(fileName);
         setConfirmVisible(true);
@@ -143,12 +203,30 @@ function Rag() {
 
             <div className="flex justify-between items-center mb-6">
                 <h2 className="text-2xl font-bold text-gray-800">{t("rag_manage")}</h2>
-                <button
-                    onClick={handleAdd}
-                    className="px-4 py-2 bg-blue-600 text-white rounded-lg shadow hover:bg-blue-700"
-                >
-                    {t("add_file")}
-                </button>
+                <div className="space-x-2">
+                    <button
+                        onClick={() => handleReindex()}
+                        className="px-4 py-2 bg-gray-600 text-white rounded-lg shadow hover:bg-gray-700"
+                    >
+                        {t("reindex_all")}
+                    </button>
+                    <button
+                        onClick={handleExport}
+                        className="px-4 py-2 bg-gray-600 text-white rounded-lg shadow hover:bg-gray-700"
+                    >
+                        {t("export_kb")}
+                    </button>
+                    <label className="px-4 py-2 bg-gray-600 text-white rounded-lg shadow hover:bg-gray-700 cursor-pointer">
+                        {t("import_kb")}
+                        <input type="file" accept=".zip" className="hidden" onChange={handleImport}/>
+                    </label>
+                    <button
+                        onClick={handleAdd}
+                        className="px-4 py-2 bg-blue-600 text-white rounded-lg shadow hover:bg-blue-700"
+                    >
+                        {t("add_file")}
+                    </button>
+                </div>
             </div>
 
             <div className="flex space-x-4 mb-6 max-w-4xl flex-wrap items-end">
@@ -167,7 +245,7 @@ function Rag() {
                 <table className="min-w-full bg-white divide-y divide-gray-200">
                     <thead className="bg-gray-50">
                     <tr>
-                        {[t("file_name"), t("create_time"), t("update_time"), t("action")].map(title => (
+                        {[t("file_name"), t("index_status"), t("create_time"), t("update_time"), t("action")].map(title => (
                             <th
                                 key={title}
                                 className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider"
@@ -182,6 +260,18 @@ function Rag() {
                         ragList.map(file => (
                             <tr key={file.id} className="hover:bg-gray-50">
                                 <td className="px-6 py-4 text-sm text-gray-800">{file.file_name}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">
+                                    <span
+                                        className={`px-2 py-1 rounded text-xs ${statusClass(file.status)}`}
+                                        title={file.error_msg}
+                                    >
+                                        {file.status}
+                                        {file.retry_times > 0 && ` (${file.retry_times})`}
+                                    </span>
+                                    {file.error_msg && (
+                                        <div className="text-xs text-red-600 mt-1 max-w-xs truncate">{file.error_msg}</div>
+                                    )}
+                                </td>
                                 <td className="px-6 py-4 text-sm text-gray-800">
                                     {new Date(file.create_time * 1000).toLocaleString()}
                                 </td>
@@ -195,6 +285,12 @@ function Rag() {
                                     >
                                         Edit
                                     </button>
+                                    <button
+                                        onClick={() => handleReindex(file.file_name)}
+                                        className="text-gray-600 hover:underline"
+                                    >
+                                        Reindex
+                                    </button>
                                     <button
                                         onClick={() => handleDeleteClick(file.file_name)}
                                         className="text-red-600 hover:underline"
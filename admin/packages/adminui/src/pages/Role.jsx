@@ -0,0 +1,438 @@
+import React, { useEffect, useState } from "react";
+import Pagination from "../components/Pagination";
+import BotSelector from "../components/BotSelector";
+import Modal from "../components/Modal";
+import ConfirmModal from "../components/ConfirmModal.jsx";
+import InputField from "../components/InputField.jsx";
+import Toast from "../components/Toast.jsx";
+import {useTranslation} from "react-i18next";
+
+const subjectTypes = ["user", "group"];
+const policyFields = ["commands", "mcp_servers", "providers", "models"];
+
+const initialPolicy = {
+    name: "",
+    commands: "",
+    mcp_servers: "",
+    providers: "",
+    models: "",
+};
+
+const initialBinding = {
+    subject_type: "user",
+    subject_id: "",
+    role: "",
+};
+
+// policy lists are edited as comma separated patterns
+const splitPatterns = (value) => value.split(",").map(v => v.trim()).filter(v => v !== "");
+
+function RolePage() {
+    const [botId, setBotId] = useState(null);
+    const [policies, setPolicies] = useState([]);
+    const [defaultRole, setDefaultRole] = useState("");
+    const [bindings, setBindings] = useState([]);
+    const [subjectTypeSearch, setSubjectTypeSearch] = useState("");
+    const [page, setPage] = useState(1);
+    const [pageSize] = useState(10);
+    const [total, setTotal] = useState(0);
+
+    const [editingPolicy, setEditingPolicy] = useState(null);
+    const [isNewPolicy, setIsNewPolicy] = useState(false);
+    const [policyToDelete, setPolicyToDelete] = useState(null);
+    const [editingBinding, setEditingBinding] = useState(null);
+
+    const [toast, setToast] = useState({show: false, message: "", type: "error"});
+    const showToast = (message, type = "error") => {
+        setToast({show: true, message, type});
+    };
+
+    const { t } = useTranslation();
+
+    useEffect(() => {
+        if (botId !== null) {
+            fetchPolicies();
+        }
+    }, [botId]);
+
+    useEffect(() => {
+        if (botId !== null) {
+            fetchBindings();
+        }
+    }, [botId, page, subjectTypeSearch]);
+
+    const fetchPolicies = async () => {
+        try {
+            const res = await fetch(`/bot/user/policy/get?id=${botId}`);
+            const data = await res.json();
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            setPolicies(data.data.list || []);
+            setDefaultRole(data.data.default_role || "");
+        } catch (err) {
+            showToast("Failed to fetch role policies: " + err.message);
+        }
+    };
+
+    const fetchBindings = async () => {
+        try {
+            const params = new URLSearchParams({
+                id: botId,
+                page,
+                pageSize,
+                subjectType: subjectTypeSearch,
+            });
+            const res = await fetch(`/bot/user/role/list?${params.toString()}`);
+            const data = await res.json();
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            setBindings(data.data.list || []);
+            setTotal(data.data.total || 0);
+        } catch (err) {
+            showToast("Failed to fetch role bindings: " + err.message);
+        }
+    };
+
+    const postJson = async (path, body) => {
+        const res = await fetch(`${path}?id=${botId}`, {
+            method: "POST",
+            headers: {"Content-Type": "application/json"},
+            body: JSON.stringify(body),
+        });
+        return res.json();
+    };
+
+    const openPolicy = (policy) => {
+        if (policy) {
+            const form = {name: policy.name};
+            policyFields.forEach(field => {
+                form[field] = (policy[field] || []).join(", ");
+            });
+            setEditingPolicy(form);
+            setIsNewPolicy(false);
+        } else {
+            setEditingPolicy(initialPolicy);
+            setIsNewPolicy(true);
+        }
+    };
+
+    const savePolicy = async () => {
+        if (editingPolicy.name.trim() === "") {
+            showToast(t("fields_required"));
+            return;
+        }
+        const policy = {name: editingPolicy.name.trim()};
+        policyFields.forEach(field => {
+            policy[field] = splitPatterns(editingPolicy[field]);
+        });
+
+        try {
+            const data = await postJson("/bot/user/policy/update", policy);
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            showToast(t("save_success"), "success");
+            setEditingPolicy(null);
+            await fetchPolicies();
+        } catch (err) {
+            showToast("Failed to save role policy: " + err.message);
+        }
+    };
+
+    const deletePolicy = async () => {
+        try {
+            const data = await postJson("/bot/user/policy/delete", {name: policyToDelete});
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            setPolicyToDelete(null);
+            await fetchPolicies();
+        } catch (err) {
+            showToast("Failed to delete role policy: " + err.message);
+        }
+    };
+
+    const saveBinding = async () => {
+        if (editingBinding.subject_id.trim() === "") {
+            showToast(t("fields_required"));
+            return;
+        }
+        try {
+            const data = await postJson("/bot/user/role/update", {
+                ...editingBinding,
+                subject_id: editingBinding.subject_id.trim(),
+            });
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            showToast(t("save_success"), "success");
+            setEditingBinding(null);
+            await fetchBindings();
+        } catch (err) {
+            showToast("Failed to assign role: " + err.message);
+        }
+    };
+
+    const formatTime = (ts) => (ts ? new Date(ts * 1000).toLocaleString() : "-");
+
+    return (
+        <div className="p-6 bg-gray-100 min-h-screen">
+            {toast.show && (
+                <Toast
+                    message={toast.message}
+                    type={toast.type}
+                    onClose={() => setToast({...toast, show: false})}
+                />
+            )}
+            <div className="flex justify-between items-center mb-6">
+                <h2 className="text-2xl font-bold text-gray-800">{t("role_manage")}</h2>
+            </div>
+
+            <div className="flex space-x-4 mb-6 max-w-4xl flex-wrap items-end">
+                <div className="flex-1 min-w-[200px]">
+                    <BotSelector
+                        value={botId}
+                        onChange={(bot) => {
+                            setBotId(bot.id);
+                            setPage(1);
+                            setSubjectTypeSearch("");
+                        }}
+                    />
+                </div>
+            </div>
+
+            <div className="flex justify-between items-center mb-3">
+                <h3 className="text-lg font-semibold text-gray-800">
+                    {t("role_policies")}
+                    <span className="ml-3 text-sm font-normal text-gray-500">{t("default_role")}: {defaultRole || "-"}</span>
+                </h3>
+                <button
+                    onClick={() => openPolicy(null)}
+                    className="bg-blue-600 text-white px-4 py-2 rounded hover:bg-blue-700"
+                >
+                    {t("add_role_policy")}
+                </button>
+            </div>
+
+            <div className="overflow-x-auto rounded-lg shadow mb-8">
+                <table className="min-w-full bg-white divide-y divide-gray-200">
+                    <thead className="bg-gray-50">
+                    <tr>
+                        {[t("role"), ...policyFields.map(field => t("role_" + field)), t("action")].map(title => (
+                            <th
+                                key={title}
+                                className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider"
+                            >
+                                {title}
+                            </th>
+                        ))}
+                    </tr>
+                    </thead>
+                    <tbody className="divide-y divide-gray-100">
+                    {policies.length > 0 ? (
+                        policies.map(policy => (
+                            <tr key={policy.name} className="hover:bg-gray-50">
+                                <td className="px-6 py-4 text-sm font-medium text-gray-800">{policy.name}</td>
+                                {policyFields.map(field => (
+                                    <td key={field} className="px-6 py-4 text-sm text-gray-600 max-w-xs truncate">
+                                        {(policy[field] || []).join(", ") || "-"}
+                                    </td>
+                                ))}
+                                <td className="px-6 py-4 text-sm space-x-2 whitespace-nowrap">
+                                    <button onClick={() => openPolicy(policy)} className="text-blue-600 hover:underline">
+                                        {t("edit")}
+                                    </button>
+                                    <button onClick={() => setPolicyToDelete(policy.name)} className="text-red-600 hover:underline">
+                                        {t("delete")}
+                                    </button>
+                                </td>
+                            </tr>
+                        ))
+                    ) : (
+                        <tr>
+                            <td colSpan={6} className="text-center py-6 text-gray-500">
+                                {t("no_role_policies")}
+                            </td>
+                        </tr>
+                    )}
+                    </tbody>
+                </table>
+            </div>
+
+            <div className="flex justify-between items-end mb-3">
+                <div className="flex items-end space-x-4">
+                    <h3 className="text-lg font-semibold text-gray-800">{t("role_bindings")}</h3>
+                    <select
+                        value={subjectTypeSearch}
+                        onChange={(e) => {
+                            setSubjectTypeSearch(e.target.value);
+                            setPage(1);
+                        }}
+                        className="px-4 py-2 border border-gray-300 rounded shadow-sm focus:outline-none focus:ring focus:border-blue-400"
+                    >
+                        <option value="">{t("all")}</option>
+                        {subjectTypes.map(type => (
+                            <option key={type} value={type}>{t("role_subject_" + type)}</option>
+                        ))}
+                    </select>
+                </div>
+                <button
+                    onClick={() => setEditingBinding(initialBinding)}
+                    className="bg-blue-600 text-white px-4 py-2 rounded hover:bg-blue-700"
+                >
+                    {t("assign_role")}
+                </button>
+            </div>
+
+            <div className="overflow-x-auto rounded-lg shadow">
+                <table className="min-w-full bg-white divide-y divide-gray-200">
+                    <thead className="bg-gray-50">
+                    <tr>
+                        {[t("id"), t("role_subject_type"), t("role_subject_id"), t("role"), t("update_time"), t("action")].map(title => (
+                            <th
+                                key={title}
+                                className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider"
+                            >
+                                {title}
+                            </th>
+                        ))}
+                    </tr>
+                    </thead>
+                    <tbody className="divide-y divide-gray-100">
+                    {bindings.length > 0 ? (
+                        bindings.map(binding => (
+                            <tr key={binding.id} className="hover:bg-gray-50">
+                                <td className="px-6 py-4 text-sm text-gray-800">{binding.id}</td>
+                                <td className="px-6 py-4 text-sm text-gray-600">{t("role_subject_" + binding.subject_type)}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{binding.subject_id}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{binding.role}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{formatTime(binding.update_time)}</td>
+                                <td className="px-6 py-4 text-sm space-x-2 whitespace-nowrap">
+                                    <button
+                                        onClick={() => setEditingBinding({
+                                            subject_type: binding.subject_type,
+                                            subject_id: binding.subject_id,
+                                            role: binding.role,
+                                        })}
+                                        className="text-blue-600 hover:underline"
+                                    >
+                                        {t("edit")}
+                                    </button>
+                                </td>
+                            </tr>
+                        ))
+                    ) : (
+                        <tr>
+                            <td colSpan={6} className="text-center py-6 text-gray-500">
+                                {t("no_role_bindings")}
+                            </td>
+                        </tr>
+                    )}
+                    </tbody>
+                </table>
+            </div>
+
+            <Pagination page={page} pageSize={pageSize} total={total} onPageChange={setPage} />
+
+            <Modal
+                visible={editingPolicy !== null}
+                onClose={() => setEditingPolicy(null)}
+                title={isNewPolicy ? t("add_role_policy") : t("edit_role_policy")}
+            >
+                {editingPolicy && (
+                    <div className="space-y-4">
+                        <InputField
+                            label={t("role")}
+                            name="name"
+                            value={editingPolicy.name}
+                            readOnly={!isNewPolicy}
+                            onChange={(e) => setEditingPolicy({...editingPolicy, name: e.target.value})}
+                            placeholder="member"
+                        />
+                        {policyFields.map(field => (
+                            <InputField
+                                key={field}
+                                label={t("role_" + field)}
+                                name={field}
+                                value={editingPolicy[field]}
+                                onChange={(e) => setEditingPolicy({...editingPolicy, [field]: e.target.value})}
+                                placeholder={t("role_pattern_placeholder")}
+                            />
+                        ))}
+                        <div className="flex justify-end">
+                            <button
+                                onClick={savePolicy}
+                                className="bg-blue-600 text-white px-4 py-2 rounded hover:bg-blue-700"
+                            >
+                                {t("save")}
+                            </button>
+                        </div>
+                    </div>
+                )}
+            </Modal>
+
+            <Modal visible={editingBinding !== null} onClose={() => setEditingBinding(null)} title={t("assign_role")}>
+                {editingBinding && (
+                    <div className="space-y-4">
+                        <div>
+                            <label className="block text-sm font-medium text-gray-700">{t("role_subject_type")}</label>
+                            <select
+                                value={editingBinding.subject_type}
+                                onChange={(e) => setEditingBinding({...editingBinding, subject_type: e.target.value})}
+                                className="w-full px-3 py-2 border border-gray-300 rounded bg-white text-gray-700"
+                            >
+                                {subjectTypes.map(type => (
+                                    <option key={type} value={type}>{t("role_subject_" + type)}</option>
+                                ))}
+                            </select>
+                        </div>
+                        <InputField
+                            label={t("role_subject_id")}
+                            name="subject_id"
+                            value={editingBinding.subject_id}
+                            onChange={(e) => setEditingBinding({...editingBinding, subject_id: e.target.value})}
+                            placeholder={t("role_subject_id_placeholder")}
+                        />
+                        <div>
+                            <label className="block text-sm font-medium text-gray-700">{t("role")}</label>
+                            <select
+                                value={editingBinding.role}
+                                onChange={(e) => setEditingBinding({...editingBinding, role: e.target.value})}
+                                className="w-full px-3 py-2 border border-gray-300 rounded bg-white text-gray-700"
+                            >
+                                <option value="">{t("role_none")}</option>
+                                {policies.map(policy => (
+                                    <option key={policy.name} value={policy.name}>{policy.name}</option>
+                                ))}
+                            </select>
+                        </div>
+                        <div className="flex justify-end">
+                            <button
+                                onClick={saveBinding}
+                                className="bg-blue-600 text-white px-4 py-2 rounded hover:bg-blue-700"
+                            >
+                                {t("save")}
+                            </button>
+                        </div>
+                    </div>
+                )}
+            </Modal>
+
+            <ConfirmModal
+                visible={policyToDelete !== null}
+                message={t("delete") + " " + (policyToDelete || "")}
+                onConfirm={deletePolicy}
+                onCancel={() => setPolicyToDelete(null)}
+            />
+        </div>
+    );
+}
+
+export default RolePage;
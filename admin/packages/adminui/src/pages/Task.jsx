@@ -0,0 +1,250 @@
+import React, { useEffect, useState } from "react";
+import Pagination from "../components/Pagination";
+import BotSelector from "../components/BotSelector";
+import ReactMarkdown from 'react-markdown';
+import Modal from "../components/Modal";
+import Toast from "../components/Toast.jsx";
+import {useTranslation} from "react-i18next";
+
+const taskStatuses = ["running", "done", "failed", "cancelled"];
+
+function TaskPage() {
+    const [botId, setBotId] = useState(null);
+    const [userIdSearch, setUserIdSearch] = useState("");
+    const [statusSearch, setStatusSearch] = useState("");
+    const [runs, setRuns] = useState([]);
+    const [page, setPage] = useState(1);
+    const [pageSize] = useState(10);
+    const [total, setTotal] = useState(0);
+
+    const [detail, setDetail] = useState(null);
+
+    const [toast, setToast] = useState({show: false, message: "", type: "error"});
+    const showToast = (message, type = "error") => {
+        setToast({show: true, message, type});
+    };
+
+    const { t } = useTranslation();
+
+    useEffect(() => {
+        if (botId !== null) {
+            fetchTaskRuns();
+        }
+    }, [botId, page, userIdSearch, statusSearch]);
+
+    const fetchTaskRuns = async () => {
+        try {
+            const params = new URLSearchParams({
+                id: botId,
+                page,
+                pageSize,
+            });
+            if (userIdSearch.trim() !== "") {
+                params.append("user_id", userIdSearch.trim());
+            }
+            if (statusSearch !== "") {
+                params.append("status", statusSearch);
+            }
+            const res = await fetch(`/bot/task/list?${params.toString()}`);
+            const data = await res.json();
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            setRuns(data.data.list || []);
+            setTotal(data.data.total || 0);
+        } catch (err) {
+            showToast("Failed to fetch task runs: " + err.message);
+        }
+    };
+
+    const openDetail = async (runId) => {
+        try {
+            const res = await fetch(`/bot/task/get?id=${botId}&run_id=${runId}`);
+            const data = await res.json();
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            setDetail(data.data);
+        } catch (err) {
+            showToast("Failed to fetch task run: " + err.message);
+        }
+    };
+
+    const cancelRun = async (runId) => {
+        try {
+            const res = await fetch(`/bot/task/cancel?id=${botId}&run_id=${runId}`);
+            const data = await res.json();
+            if (data.code !== 0) {
+                showToast(data.message || t("request_error"));
+                return;
+            }
+            showToast(t("task_cancelling"), "success");
+            await fetchTaskRuns();
+        } catch (err) {
+            showToast("Failed to cancel task run: " + err.message);
+        }
+    };
+
+    const formatTime = (ts) => (ts ? new Date(ts * 1000).toLocaleString() : "-");
+
+    return (
+        <div className="p-6 bg-gray-100 min-h-screen">
+            {toast.show && (
+                <Toast
+                    message={toast.message}
+                    type={toast.type}
+                    onClose={() => setToast({...toast, show: false})}
+                />
+            )}
+            <div className="flex justify-between items-center mb-6">
+                <h2 className="text-2xl font-bold text-gray-800">{t("task_run_manage")}</h2>
+            </div>
+
+            <div className="flex space-x-4 mb-6 max-w-4xl flex-wrap items-end">
+                <div className="flex-1 min-w-[200px]">
+                    <BotSelector
+                        value={botId}
+                        onChange={(bot) => {
+                            setBotId(bot.id);
+                            setPage(1);
+                            setUserIdSearch("");
+                            setStatusSearch("");
+                        }}
+                    />
+                </div>
+
+                <div className="flex-1 min-w-[200px]">
+                    <label className="block font-medium text-gray-700 mb-1">{t("search_user_id")}:</label>
+                    <input
+                        type="text"
+                        value={userIdSearch}
+                        onChange={(e) => {
+                            setUserIdSearch(e.target.value);
+                            setPage(1);
+                        }}
+                        placeholder={t("user_id_placeholder")}
+                        className="w-full px-4 py-2 border border-gray-300 rounded shadow-sm focus:outline-none focus:ring focus:border-blue-400"
+                    />
+                </div>
+
+                <div className="flex-1 min-w-[200px]">
+                    <label className="block font-medium text-gray-700 mb-1">{t("status")}:</label>
+                    <select
+                        value={statusSearch}
+                        onChange={(e) => {
+                            setStatusSearch(e.target.value);
+                            setPage(1);
+                        }}
+                        className="w-full px-4 py-2 border border-gray-300 rounded shadow-sm focus:outline-none focus:ring focus:border-blue-400"
+                    >
+                        <option value="">{t("all")}</option>
+                        {taskStatuses.map(status => (
+                            <option key={status} value={status}>{t("task_status_" + status)}</option>
+                        ))}
+                    </select>
+                </div>
+            </div>
+
+            <div className="overflow-x-auto rounded-lg shadow">
+                <table className="min-w-full bg-white divide-y divide-gray-200">
+                    <thead className="bg-gray-50">
+                    <tr>
+                        {[t("id"), t("user_id"), t("task_content"), t("status"), t("task_loop"), t("token"), t("task_error"), t("create_time"), t("update_time"), t("action")].map(title => (
+                            <th
+                                key={title}
+                                className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider"
+                            >
+                                {title}
+                            </th>
+                        ))}
+                    </tr>
+                    </thead>
+                    <tbody className="divide-y divide-gray-100">
+                    {runs.length > 0 ? (
+                        runs.map(run => (
+                            <tr key={run.id} className="hover:bg-gray-50">
+                                <td className="px-6 py-4 text-sm text-gray-800">{run.id}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{run.user_id}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800 max-w-xs truncate" title={run.content}>{run.content}</td>
+                                <td className="px-6 py-4 text-sm text-gray-600">{t("task_status_" + run.status)}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{run.loop}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{run.token}</td>
+                                <td className="px-6 py-4 text-sm text-red-600 max-w-xs truncate" title={run.error_msg}>{run.error_msg || "-"}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{formatTime(run.create_time)}</td>
+                                <td className="px-6 py-4 text-sm text-gray-800">{formatTime(run.update_time)}</td>
+                                <td className="px-6 py-4 text-sm space-x-2 whitespace-nowrap">
+                                    <button
+                                        onClick={() => openDetail(run.id)}
+                                        className="text-blue-600 hover:underline"
+                                    >
+                                        {t("task_detail")}
+                                    </button>
+                                    {run.status === "running" && (
+                                        <button
+                                            onClick={() => cancelRun(run.id)}
+                                            className="text-red-600 hover:underline"
+                                        >
+                                            {t("cancel")}
+                                        </button>
+                                    )}
+                                </td>
+                            </tr>
+                        ))
+                    ) : (
+                        <tr>
+                            <td colSpan={10} className="text-center py-6 text-gray-500">
+                                {t("no_task_runs")}
+                            </td>
+                        </tr>
+                    )}
+                    </tbody>
+                </table>
+            </div>
+
+            <Pagination page={page} pageSize={pageSize} total={total} onPageChange={setPage} />
+
+            <Modal visible={detail !== null} onClose={() => setDetail(null)} title={detail ? `${t("task_detail")} #${detail.run.id}` : ""}>
+                {detail && (
+                    <div className="max-h-[70vh] overflow-y-auto space-y-4">
+                        <div className="text-sm text-gray-800 whitespace-pre-wrap">{detail.run.content}</div>
+                        {detail.steps.map(step => (
+                            <div key={step.id} className="border border-gray-200 rounded p-3">
+                                <div className="flex justify-between text-sm font-medium text-gray-800">
+                                    <span>[{step.loop}] #{step.step_id} {step.name}</span>
+                                    <span>{t("task_status_" + step.status)} · {t("token")}: {step.token}</span>
+                                </div>
+                                <div className="text-sm text-gray-600 mt-1">{step.description}</div>
+                                {step.depends_on && step.depends_on.length > 0 && (
+                                    <div className="text-xs text-gray-500 mt-1">{t("task_depends_on")}: {step.depends_on.join(", ")}</div>
+                                )}
+                                {step.error_msg && (
+                                    <div className="text-sm text-red-600 mt-1">{step.error_msg}</div>
+                                )}
+                                {step.result && (
+                                    <div className="prose prose-sm max-w-none mt-2">
+                                        <ReactMarkdown>{step.result}</ReactMarkdown>
+                                    </div>
+                                )}
+                                {step.tool_calls && step.tool_calls !== "null" && step.tool_calls !== "[]" && (
+                                    <pre className="bg-gray-50 text-xs p-2 mt-2 rounded overflow-x-auto">{step.tool_calls}</pre>
+                                )}
+                            </div>
+                        ))}
+                        {detail.run.result && (
+                            <div>
+                                <div className="text-sm font-medium text-gray-800 mb-1">{t("task_result")}</div>
+                                <div className="prose prose-sm max-w-none">
+                                    <ReactMarkdown>{detail.run.result}</ReactMarkdown>
+                                </div>
+                            </div>
+                        )}
+                    </div>
+                )}
+            </Modal>
+        </div>
+    );
+}
+
+export default TaskPage;
@@ -14,6 +14,8 @@ import Log from "../pages/Log.jsx";
 import Communicate from "../pages/Communicate.jsx";
 import Rag from "../pages/Rag.jsx";
 import Cron from "../pages/Cron.jsx";
+import Task from "../pages/Task.jsx";
+import Role from "../pages/Role.jsx";
 
 export default function Router() {
     const { isAuthenticated, isLoading } = useUser();
@@ -44,6 +46,8 @@ export default function Router() {
                     <Route path="chats" element={<BotChat />} />
                     <Route path="mcp" element={<MCP />} />
                     <Route path="cron" element={<Cron />} />
+                    <Route path="task" element={<Task />} />
+                    <Route path="role" element={<Role />} />
                     <Route path="communicate" element={<Communicate />} />
                     <Route path="rag" element={<Rag />} />
                     <Route path="log" element={<Log />} />
@@ -73,6 +73,12 @@ type BaseConf struct {
 	Character         string `json:"character"`
 	SmartMode         bool   `json:"smart_mode"`
 	ContextExpireTime int    `json:"context_expire_time"`
+	ContextStore      string `json:"context_store"`
+	RedisAddr         string `json:"redis_addr"`
+	RedisPassword     string `json:"redis_password"`
+	RedisDB           int    `json:"redis_db"`
+	SummaryThreshold  int    `json:"summary_threshold"`
+	SummaryKeepPairs  int    `json:"summary_keep_pairs"`
 	Powered           string `json:"powered"`
 	SendMcpRes        bool   `json:"send_mcp_res"`
 	SendMcpMediaToLLM bool   `json:"send_mcp_media_to_llm"`
@@ -128,6 +134,12 @@ func InitConf() {
 	flag.StringVar(&BaseConfInfo.QQOneBotHttpServer, "qq_one_bot_http_server", "http://127.0.0.1:3000", "onebot http server")
 	flag.BoolVar(&BaseConfInfo.SmartMode, "smart_mode", false, "Smart mode")
 	flag.IntVar(&BaseConfInfo.ContextExpireTime, "context_expire_time", 86400, "Context expire time")
+	flag.StringVar(&BaseConfInfo.ContextStore, "context_store", "memory", "context store: memory sql redis")
+	flag.StringVar(&BaseConfInfo.RedisAddr, "redis_addr", "127.0.0.1:6379", "redis addr")
+	flag.StringVar(&BaseConfInfo.RedisPassword, "redis_password", "", "redis password")
+	flag.IntVar(&BaseConfInfo.RedisDB, "redis_db", 0, "redis db")
+	flag.IntVar(&BaseConfInfo.SummaryThreshold, "summary_threshold", 0, "summarize older conversation when context token exceed it, 0 means disable")
+	flag.IntVar(&BaseConfInfo.SummaryKeepPairs, "summary_keep_pairs", 4, "latest qa pairs which are not summarized")
 
 	flag.StringVar(&BaseConfInfo.DeepseekToken, "deepseek_token", "", "deepseek auth token")
 	flag.StringVar(&BaseConfInfo.OpenAIToken, "openai_token", "", "openai auth token")
@@ -185,6 +197,10 @@ func InitConf() {
 	InitToolsConf()
 	InitRagConf()
 	InitRegisterConf()
+	InitProviderConf()
+	InitQuotaConf()
+	InitRBACConf()
+	InitRateLimitConf()
 
 	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
 	flag.Parse()
@@ -417,6 +433,30 @@ func InitConf() {
 		BaseConfInfo.ContextExpireTime, _ = strconv.Atoi(os.Getenv("CONTEXT_EXPIRE_TIME"))
 	}
 
+	if os.Getenv("CONTEXT_STORE") != "" {
+		BaseConfInfo.ContextStore = os.Getenv("CONTEXT_STORE")
+	}
+
+	if os.Getenv("REDIS_ADDR") != "" {
+		BaseConfInfo.RedisAddr = os.Getenv("REDIS_ADDR")
+	}
+
+	if os.Getenv("REDIS_PASSWORD") != "" {
+		BaseConfInfo.RedisPassword = os.Getenv("REDIS_PASSWORD")
+	}
+
+	if os.Getenv("REDIS_DB") != "" {
+		BaseConfInfo.RedisDB, _ = strconv.Atoi(os.Getenv("REDIS_DB"))
+	}
+
+	if os.Getenv("SUMMARY_THRESHOLD") != "" {
+		BaseConfInfo.SummaryThreshold, _ = strconv.Atoi(os.Getenv("SUMMARY_THRESHOLD"))
+	}
+
+	if os.Getenv("SUMMARY_KEEP_PAIRS") != "" {
+		BaseConfInfo.SummaryKeepPairs, _ = strconv.Atoi(os.Getenv("SUMMARY_KEEP_PAIRS"))
+	}
+
 	if os.Getenv("POWERED") != "" {
 		BaseConfInfo.Powered = os.Getenv("POWERED")
 	}
@@ -460,6 +500,10 @@ func InitConf() {
 	EnvToolsConf()
 	EnvVideoConf()
 	EnvRegisterConf()
+	EnvProviderConf()
+	EnvQuotaConf()
+	EnvRBACConf()
+	EnvRateLimitConf()
 
 	logConf(*allowedUserIds, *allowedGroupIds)
 	SaveConf()
@@ -538,6 +582,12 @@ func logConf(allowedUserIds, allowedGroupIds string) {
 	logger.Info("CONF", "Powered", BaseConfInfo.Powered)
 	logger.Info("CONF", "Character", BaseConfInfo.Character)
 	logger.Info("CONF", "ContextExpireTime", BaseConfInfo.ContextExpireTime)
+	logger.Info("CONF", "ContextStore", BaseConfInfo.ContextStore)
+	logger.Info("CONF", "RedisAddr", BaseConfInfo.RedisAddr)
+	logger.Info("CONF", "RedisPassword", BaseConfInfo.RedisPassword)
+	logger.Info("CONF", "RedisDB", BaseConfInfo.RedisDB)
+	logger.Info("CONF", "SummaryThreshold", BaseConfInfo.SummaryThreshold)
+	logger.Info("CONF", "SummaryKeepPairs", BaseConfInfo.SummaryKeepPairs)
 	logger.Info("CONF", "SendMcpRes", BaseConfInfo.SendMcpRes)
 	logger.Info("CONF", "DefaultModel", BaseConfInfo.DefaultModel)
 	logger.Info("CONF", "LLMRetryTimes", BaseConfInfo.LLMRetryTimes)
@@ -569,11 +619,21 @@ func logConf(allowedUserIds, allowedGroupIds string) {
 	logger.Info("RAG_CONF", "EmbeddingType", RagConfInfo.EmbeddingType)
 	logger.Info("RAG_CONF", "KnowledgePath", RagConfInfo.KnowledgePath)
 	logger.Info("RAG_CONF", "VectorDBType", RagConfInfo.VectorDBType)
+	logger.Info("RAG_CONF", "Mode", RagConfInfo.Mode)
+	logger.Info("RAG_CONF", "EmbeddingURL", RagConfInfo.EmbeddingURL)
+	logger.Info("RAG_CONF", "EmbeddingModel", RagConfInfo.EmbeddingModel)
+	logger.Info("RAG_CONF", "EmbeddingBatchSize", RagConfInfo.EmbeddingBatchSize)
 	logger.Info("RAG_CONF", "ChromaURL", RagConfInfo.ChromaURL)
 	logger.Info("RAG_CONF", "ChromaSpace", RagConfInfo.Space)
 	logger.Info("RAG_CONF", "MilvusURL", RagConfInfo.MilvusURL)
 	logger.Info("RAG_CONF", "WeaviateURL", RagConfInfo.WeaviateURL)
 	logger.Info("RAG_CONF", "WeaviateScheme", RagConfInfo.WeaviateScheme)
+	logger.Info("RAG_CONF", "KnowledgeBases", RagConfInfo.KnowledgeBases)
+	logger.Info("RAG_CONF", "RetryTimes", RagConfInfo.RetryTimes)
+	logger.Info("RAG_CONF", "IngestTimeout", RagConfInfo.IngestTimeout)
+	logger.Info("RAG_CONF", "TopK", RagConfInfo.TopK)
+	logger.Info("RAG_CONF", "MinScore", RagConfInfo.MinScore)
+	logger.Info("RAG_CONF", "Rerank", RagConfInfo.Rerank)
 
 	logger.Info("PHOTO_CONF", "ReqKey", PhotoConfInfo.ReqKey)
 	logger.Info("PHOTO_CONF", "ModelVersion", PhotoConfInfo.ModelVersion)
@@ -619,6 +679,28 @@ func logConf(allowedUserIds, allowedGroupIds string) {
 	logger.Info("REGISTER_CONF", "EtcdUsername", RegisterConfInfo.EtcdUsername)
 	logger.Info("REGISTER_CONF", "EtcdPassword", RegisterConfInfo.EtcdPassword)
 
+	logger.Info("PROVIDER_CONF", "CustomProviders", ProviderConfInfo.CustomProviders)
+	logger.Info("PROVIDER_CONF", "TxtFallback", ProviderConfInfo.TxtFallback)
+	logger.Info("PROVIDER_CONF", "ImgFallback", ProviderConfInfo.ImgFallback)
+	logger.Info("PROVIDER_CONF", "VideoFallback", ProviderConfInfo.VideoFallback)
+	logger.Info("PROVIDER_CONF", "RecFallback", ProviderConfInfo.RecFallback)
+	logger.Info("PROVIDER_CONF", "TTSFallback", ProviderConfInfo.TTSFallback)
+	logger.Info("PROVIDER_CONF", "Pricing", ProviderConfInfo.Pricing)
+	logger.Info("PROVIDER_CONF", "Currency", ProviderConfInfo.Currency)
+
+	logger.Info("QUOTA_CONF", "QuotaPlans", QuotaConfInfo.QuotaPlans)
+	logger.Info("QUOTA_CONF", "DefaultQuotaPlan", QuotaConfInfo.DefaultQuotaPlan)
+	logger.Info("QUOTA_CONF", "GroupQuotaPlan", QuotaConfInfo.GroupQuotaPlan)
+	logger.Info("QUOTA_CONF", "QuotaWarnRatio", QuotaConfInfo.QuotaWarnRatio)
+
+	logger.Info("RBAC_CONF", "RolePolicies", RBACConfInfo.RolePolicies)
+	logger.Info("RBAC_CONF", "DefaultRole", RBACConfInfo.DefaultRole)
+
+	logger.Info("RATE_LIMIT_CONF", "UserRateLimit", RateLimitConfInfo.UserRateLimit)
+	logger.Info("RATE_LIMIT_CONF", "GroupRateLimit", RateLimitConfInfo.GroupRateLimit)
+	logger.Info("RATE_LIMIT_CONF", "CommandRateLimits", RateLimitConfInfo.CommandRateLimits)
+	logger.Info("RATE_LIMIT_CONF", "ProviderRateLimits", RateLimitConfInfo.ProviderRateLimits)
+
 	logger.Info("LLM_CONF", "FrequencyPenalty", LLMConfInfo.FrequencyPenalty)
 	logger.Info("LLM_CONF", "MaxTokens", LLMConfInfo.MaxTokens)
 	logger.Info("LLM_CONF", "PresencePenalty", LLMConfInfo.PresencePenalty)
@@ -629,6 +711,10 @@ func logConf(allowedUserIds, allowedGroupIds string) {
 	logger.Info("LLM_CONF", "TopLogProbs", LLMConfInfo.TopLogProbs)
 
 	logger.Info("TOOLS_CONF", "McpConfPath", *ToolsConfInfo.McpConfPath)
+	logger.Info("TOOLS_CONF", "TaskParallelism", ToolsConfInfo.TaskParallelism)
+	logger.Info("TOOLS_CONF", "McpPolicies", ToolsConfInfo.McpPolicies)
+	logger.Info("TOOLS_CONF", "McpApprovalTimeout", ToolsConfInfo.McpApprovalTimeout)
+	logger.Info("TOOLS_CONF", "NativeTools", ToolsConfInfo.NativeTools)
 }
 
 func GetAbsPath(relPath string) string {
@@ -697,6 +783,10 @@ func loadConf() bool {
 		logger.Error("Failed to transfer map to rag conf", "err", err)
 		return false
 	}
+	err = ParseKnowledgeBases()
+	if err != nil {
+		logger.Error("parse knowledge bases fail", "err", err)
+	}
 
 	err = TransferMapToConf(AllConf["video"].(map[string]interface{}), VideoConfInfo)
 	if err != nil {
@@ -715,6 +805,63 @@ func loadConf() bool {
 		logger.Error("Failed to transfer map to tools conf", "err", err)
 		return false
 	}
+	err = ParseMcpPolicies()
+	if err != nil {
+		logger.Error("Failed to parse mcp policies", "err", err)
+	}
+
+	// provider conf is not saved by old version
+	if providerConf, ok := AllConf["provider"].(map[string]interface{}); ok {
+		err = TransferMapToConf(providerConf, ProviderConfInfo)
+		if err != nil {
+			logger.Error("Failed to transfer map to provider conf", "err", err)
+			return false
+		}
+	}
+	err = ParseCustomProviders()
+	if err != nil {
+		logger.Error("Failed to parse custom providers", "err", err)
+	}
+	err = ParsePricing()
+	if err != nil {
+		logger.Error("Failed to parse pricing", "err", err)
+	}
+
+	if quotaConf, ok := AllConf["quota"].(map[string]interface{}); ok {
+		err = TransferMapToConf(quotaConf, QuotaConfInfo)
+		if err != nil {
+			logger.Error("Failed to transfer map to quota conf", "err", err)
+			return false
+		}
+	}
+	err = ParseQuotaPlans()
+	if err != nil {
+		logger.Error("Failed to parse quota plans", "err", err)
+	}
+
+	if rbacConf, ok := AllConf["rbac"].(map[string]interface{}); ok {
+		err = TransferMapToConf(rbacConf, RBACConfInfo)
+		if err != nil {
+			logger.Error("Failed to transfer map to rbac conf", "err", err)
+			return false
+		}
+	}
+	err = ParseRolePolicies()
+	if err != nil {
+		logger.Error("Failed to parse role policies", "err", err)
+	}
+
+	if rateLimitConf, ok := AllConf["rate_limit"].(map[string]interface{}); ok {
+		err = TransferMapToConf(rateLimitConf, RateLimitConfInfo)
+		if err != nil {
+			logger.Error("Failed to transfer map to rate limit conf", "err", err)
+			return false
+		}
+	}
+	err = ParseRateLimits()
+	if err != nil {
+		logger.Error("Failed to parse rate limits", "err", err)
+	}
 
 	return true
 }
@@ -728,6 +875,10 @@ func SaveConf() {
 	AllConf["video"] = VideoConfInfo
 	AllConf["register"] = RegisterConfInfo
 	AllConf["tools"] = ToolsConfInfo
+	AllConf["provider"] = ProviderConfInfo
+	AllConf["quota"] = QuotaConfInfo
+	AllConf["rbac"] = RBACConfInfo
+	AllConf["rate_limit"] = RateLimitConfInfo
 
 	fileName := getSaveConf(map[string]string{
 		"bot_name":  BaseConfInfo.BotName,
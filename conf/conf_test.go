@@ -1,7 +1,9 @@
 package conf
 
 import (
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -77,6 +79,9 @@ func TestInitConf_AllEnvVars(t *testing.T) {
 	os.Setenv("CHUNK_OVERLAP", "50")
 
 	os.Setenv("MCP_CONF_PATH", "./conf/mcp/mcp.json")
+	os.Setenv("TASK_PARALLELISM", "4")
+	os.Setenv("MCP_APPROVAL_TIMEOUT", "60")
+	os.Setenv("NATIVE_TOOLS", "current_time,calculator")
 
 	os.Setenv("TELEGRAM_BOT_TOKEN", "test_bot_token")
 	os.Setenv("DEEPSEEK_TOKEN", "test_deepseek_token")
@@ -149,6 +154,9 @@ func TestInitConf_AllEnvVars(t *testing.T) {
 	assertInt(t, RagConfInfo.ChunkOverlap, 50, "ChunkOverlap")
 
 	assertEqual(t, *ToolsConfInfo.McpConfPath, "./conf/mcp/mcp.json", "MCP_CONF_PATH")
+	assertInt(t, ToolsConfInfo.TaskParallelism, 4, "TASK_PARALLELISM")
+	assertInt(t, ToolsConfInfo.McpApprovalTimeout, 60, "MCP_APPROVAL_TIMEOUT")
+	assertEqual(t, ToolsConfInfo.NativeTools, "current_time,calculator", "NATIVE_TOOLS")
 
 	assertEqual(t, VideoConfInfo.VolVideoModel, "model-v1", "VOL_VIDEO_MODEL")
 	assertEqual(t, VideoConfInfo.Radio, "radio-123", "RADIO")
@@ -184,3 +192,207 @@ func assertFloatEqual(t *testing.T, got, expected float64, field string) {
 		t.Errorf("%s expected %.2f, got %.2f", field, expected, got)
 	}
 }
+
+func TestRBACAllow(t *testing.T) {
+	defer func() {
+		RBACConfInfo.RolePolicies = ""
+		RBACConfInfo.Policies = nil
+	}()
+
+	if !RBACConfInfo.Allow("guest", "command", "video") {
+		t.Errorf("everything should be allowed without policies")
+	}
+	if RBACConfInfo.Allow("", "command", "learn") {
+		t.Errorf("learn should be denied without policies")
+	}
+
+	RBACConfInfo.RolePolicies = `[{"name":"admin","commands":["*"],"mcp_servers":["*"],"providers":["*"],"models":["*"]},
+		{"name":"guest","commands":["chat","help"],"providers":["deepseek"],"models":["deepseek-*"]},
+		{"name":"editor","commands":["chat","learn"]}]`
+	if err := ParseRolePolicies(); err != nil {
+		t.Fatalf("ParseRolePolicies failed: %v", err)
+	}
+
+	cases := []struct {
+		role, resource, name string
+		want                 bool
+	}{
+		{"admin", "command", "video", true},
+		{"guest", "command", "chat", true},
+		{"guest", "command", "video", false},
+		{"guest", "mcp", "github", false},
+		{"guest", "provider", "openai", false},
+		{"guest", "model", "deepseek-chat", true},
+		{"unknown", "command", "chat", false},
+		{"", "command", "chat", false},
+		{"admin", "command", "learn", false},
+		{"editor", "command", "learn", true},
+		{"", "command", "learn", false},
+	}
+	for _, c := range cases {
+		if got := RBACConfInfo.Allow(c.role, c.resource, c.name); got != c.want {
+			t.Errorf("Allow(%s, %s, %s) = %v, want %v", c.role, c.resource, c.name, got, c.want)
+		}
+	}
+
+	// empty role is default role
+	RBACConfInfo.DefaultRole = "guest"
+	if !RBACConfInfo.Allow("", "command", "chat") || RBACConfInfo.Allow("", "command", "video") {
+		t.Errorf("empty role should be allowed like default role")
+	}
+	RBACConfInfo.DefaultRole = ""
+
+	if err := SetRolePolicy(&RolePolicy{Name: "member", Commands: []string{"*"}}); err != nil {
+		t.Fatalf("SetRolePolicy failed: %v", err)
+	}
+	if err := DeleteRolePolicy("guest"); err != nil {
+		t.Fatalf("DeleteRolePolicy failed: %v", err)
+	}
+	if err := ParseRolePolicies(); err != nil || len(RBACConfInfo.Policies) != 3 || RBACConfInfo.Policies["member"] == nil {
+		t.Errorf("role policies not rebuilt: %s", RBACConfInfo.RolePolicies)
+	}
+}
+
+func TestRolePolicyConcurrent(t *testing.T) {
+	defer func() {
+		RBACConfInfo.RolePolicies = ""
+		RBACConfInfo.Policies = nil
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				name := fmt.Sprintf("role%d", j%10)
+				if i%2 == 0 {
+					_ = SetRolePolicy(&RolePolicy{Name: name, Commands: []string{"*"}})
+					_ = DeleteRolePolicy(name)
+				} else {
+					RBACConfInfo.Allow(name, "command", "chat")
+					RBACConfInfo.GetPolicy(name)
+					RBACConfInfo.ListPolicies()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMcpAction(t *testing.T) {
+	defer func() {
+		ToolsConfInfo.McpPolicies = ""
+		ToolsConfInfo.Policies = nil
+	}()
+
+	if ToolsConfInfo.McpAction("mysql", "mysql_query") != "allow" {
+		t.Errorf("everything should be allowed without policies")
+	}
+
+	ToolsConfInfo.McpPolicies = `[{"server":"mysql","tools":["mysql_query"],"action":"allow"},{"server":"mysql","action":"deny"},
+		{"server":"mcp-server-*","tools":["run_*"],"action":"approve"}]`
+	if err := ParseMcpPolicies(); err != nil {
+		t.Fatalf("ParseMcpPolicies failed: %v", err)
+	}
+
+	cases := []struct {
+		server, tool, want string
+	}{
+		{"mysql", "mysql_query", "allow"},
+		{"mysql", "mysql_execute", "deny"},
+		{"mcp-server-commands", "run_command", "approve"},
+		{"mcp-server-commands", "list_files", "allow"},
+		{"playwright", "browser_navigate", "allow"},
+	}
+	for _, c := range cases {
+		if got := ToolsConfInfo.McpAction(c.server, c.tool); got != c.want {
+			t.Errorf("McpAction(%s, %s) = %s, want %s", c.server, c.tool, got, c.want)
+		}
+	}
+
+	ToolsConfInfo.McpPolicies = `[{"server":"mysql","action":"ask"}]`
+	if err := ParseMcpPolicies(); err == nil {
+		t.Errorf("unknown action should be invalid")
+	}
+}
+
+func TestNativeToolEnabled(t *testing.T) {
+	tools := &ToolsConf{NativeTools: "current_time, search_*"}
+	if !tools.NativeToolEnabled("current_time") || !tools.NativeToolEnabled("search_records") {
+		t.Errorf("listed tools should be enabled")
+	}
+	if tools.NativeToolEnabled("http_fetch") {
+		t.Errorf("unlisted tool should be disabled")
+	}
+	if (&ToolsConf{}).NativeToolEnabled("current_time") {
+		t.Errorf("no tool should be enabled by empty native_tools")
+	}
+	if !(&ToolsConf{NativeTools: "*"}).NativeToolEnabled("create_cron") {
+		t.Errorf("* should enable all tools")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("30/m")
+	if err != nil || limit.Rate != 0.5 || limit.Burst != 30 {
+		t.Errorf("unexpected limit: %+v, err: %v", limit, err)
+	}
+
+	limit, err = ParseRateLimit("100/h:10")
+	if err != nil || limit.Burst != 10 {
+		t.Errorf("unexpected limit: %+v, err: %v", limit, err)
+	}
+
+	limit, err = ParseRateLimit("")
+	if err != nil || limit != nil {
+		t.Errorf("empty spec should be no limit: %+v, err: %v", limit, err)
+	}
+
+	for _, spec := range []string{"10", "10/w", "0/m", "10/m:0"} {
+		if _, err := ParseRateLimit(spec); err == nil {
+			t.Errorf("spec %s should be invalid", spec)
+		}
+	}
+
+	commands, err := parseNamedRateLimits("chat=20/m, photo=5/h:2")
+	if err != nil || len(commands) != 2 || commands["photo"].Burst != 2 {
+		t.Errorf("unexpected command limits: %+v, err: %v", commands, err)
+	}
+}
+
+func TestParseKnowledgeBases(t *testing.T) {
+	RagConfInfo.KnowledgePath = "/data/knowledge"
+	RagConfInfo.Space = "MuseBot"
+	RagConfInfo.KnowledgeBases = `[{"name":"support","path":"/data/support"},{"name":"engineering","path":"/data/eng","space":"eng"},{"name":"default","path":"/tmp"}]`
+	if err := ParseKnowledgeBases(); err != nil {
+		t.Fatalf("parse knowledge bases fail: %v", err)
+	}
+
+	if len(RagConfInfo.KBs) != 3 {
+		t.Errorf("unexpected knowledge bases: %+v", RagConfInfo.KBs)
+	}
+
+	kb := RagConfInfo.GetKnowledgeBase("")
+	if kb == nil || kb.Path != "/data/knowledge" || kb.Space != "MuseBot" {
+		t.Errorf("default knowledge base should come from knowledge_path and space: %+v", kb)
+	}
+
+	if kb = RagConfInfo.GetKnowledgeBase("support"); kb == nil || kb.Space != "support" {
+		t.Errorf("space should default to name: %+v", kb)
+	}
+
+	if kb = RagConfInfo.GetKnowledgeBase("engineering"); kb == nil || kb.Space != "eng" {
+		t.Errorf("unexpected engineering knowledge base: %+v", kb)
+	}
+
+	if RagConfInfo.GetKnowledgeBase("unknown") != nil {
+		t.Errorf("unknown knowledge base should be nil")
+	}
+
+	RagConfInfo.KnowledgeBases = "not json"
+	if err := ParseKnowledgeBases(); err == nil {
+		t.Errorf("invalid json should fail")
+	}
+	RagConfInfo.KnowledgeBases = ""
+}
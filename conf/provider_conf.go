@@ -0,0 +1,139 @@
+package conf
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+// CustomProvider is one OpenAI-compatible endpoint, such as vLLM, LM Studio or an in-house gateway.
+type CustomProvider struct {
+	Name         string   `json:"name"`
+	BaseURL      string   `json:"base_url"`
+	Token        string   `json:"token"`
+	DefaultModel string   `json:"default_model"`
+	Models       []string `json:"models"`       // model allow-list, empty means any model
+	Proxy        string   `json:"proxy"`        // use llm_proxy if empty
+	Capabilities []string `json:"capabilities"` // text image rec tools vision json_schema json_object, text by default
+}
+
+// ModelPrice is price of one provider model, empty Model match all models of provider.
+type ModelPrice struct {
+	Provider    string  `json:"provider"`
+	Model       string  `json:"model"`
+	Input       float64 `json:"input"`        // per 1M input token
+	Output      float64 `json:"output"`       // per 1M output token
+	Image       float64 `json:"image"`        // per image
+	VideoSecond float64 `json:"video_second"` // per second of video
+	TTSChar     float64 `json:"tts_char"`     // per 1M tts character
+}
+
+type ProviderConf struct {
+	// CustomProviders json array of CustomProvider
+	CustomProviders string `json:"custom_providers"`
+
+	Providers []*CustomProvider `json:"-"`
+
+	// fallback providers tried in order when chosen provider fail, format: provider[:model],provider[:model]
+	TxtFallback   string `json:"txt_fallback"`
+	ImgFallback   string `json:"img_fallback"`
+	VideoFallback string `json:"video_fallback"`
+	RecFallback   string `json:"rec_fallback"`
+	TTSFallback   string `json:"tts_fallback"`
+
+	// Pricing json array of ModelPrice
+	Pricing  string        `json:"pricing"`
+	Currency string        `json:"currency"`
+	Prices   []*ModelPrice `json:"-"`
+}
+
+var (
+	ProviderConfInfo = new(ProviderConf)
+)
+
+func InitProviderConf() {
+	flag.StringVar(&ProviderConfInfo.CustomProviders, "custom_providers", "",
+		`openai compatible providers: [{"name":"vllm","base_url":"http://127.0.0.1:8000/v1","default_model":"qwen"}]`)
+	flag.StringVar(&ProviderConfInfo.TxtFallback, "txt_fallback", "", "text fallback providers: gemini:gemini-2.5-flash,openai")
+	flag.StringVar(&ProviderConfInfo.ImgFallback, "img_fallback", "", "image fallback providers")
+	flag.StringVar(&ProviderConfInfo.VideoFallback, "video_fallback", "", "video fallback providers")
+	flag.StringVar(&ProviderConfInfo.RecFallback, "rec_fallback", "", "recognize fallback providers")
+	flag.StringVar(&ProviderConfInfo.TTSFallback, "tts_fallback", "", "tts fallback providers")
+	flag.StringVar(&ProviderConfInfo.Pricing, "pricing", "",
+		`model prices: [{"provider":"openai","model":"gpt-4o","input":2.5,"output":10}]`)
+	flag.StringVar(&ProviderConfInfo.Currency, "currency", "USD", "currency of pricing")
+}
+
+func EnvProviderConf() {
+	if os.Getenv("CUSTOM_PROVIDERS") != "" {
+		ProviderConfInfo.CustomProviders = os.Getenv("CUSTOM_PROVIDERS")
+	}
+
+	if os.Getenv("TXT_FALLBACK") != "" {
+		ProviderConfInfo.TxtFallback = os.Getenv("TXT_FALLBACK")
+	}
+
+	if os.Getenv("IMG_FALLBACK") != "" {
+		ProviderConfInfo.ImgFallback = os.Getenv("IMG_FALLBACK")
+	}
+
+	if os.Getenv("VIDEO_FALLBACK") != "" {
+		ProviderConfInfo.VideoFallback = os.Getenv("VIDEO_FALLBACK")
+	}
+
+	if os.Getenv("REC_FALLBACK") != "" {
+		ProviderConfInfo.RecFallback = os.Getenv("REC_FALLBACK")
+	}
+
+	if os.Getenv("TTS_FALLBACK") != "" {
+		ProviderConfInfo.TTSFallback = os.Getenv("TTS_FALLBACK")
+	}
+
+	if os.Getenv("PRICING") != "" {
+		ProviderConfInfo.Pricing = os.Getenv("PRICING")
+	}
+
+	if os.Getenv("CURRENCY") != "" {
+		ProviderConfInfo.Currency = os.Getenv("CURRENCY")
+	}
+
+	err := ParseCustomProviders()
+	if err != nil {
+		logger.Error("parse custom providers fail", "err", err)
+	}
+
+	err = ParsePricing()
+	if err != nil {
+		logger.Error("parse pricing fail", "err", err)
+	}
+}
+
+// ParseCustomProviders parse custom_providers into Providers.
+func ParseCustomProviders() error {
+	providers := make([]*CustomProvider, 0)
+	if ProviderConfInfo.CustomProviders != "" {
+		err := json.Unmarshal([]byte(ProviderConfInfo.CustomProviders), &providers)
+		if err != nil {
+			return err
+		}
+	}
+
+	ProviderConfInfo.Providers = providers
+	return nil
+}
+
+// ParsePricing parse pricing into Prices.
+func ParsePricing() error {
+	prices := make([]*ModelPrice, 0)
+	if ProviderConfInfo.Pricing != "" {
+		err := json.Unmarshal([]byte(ProviderConfInfo.Pricing), &prices)
+		if err != nil {
+			return err
+		}
+	}
+
+	ProviderConfInfo.Prices = prices
+	return nil
+}
@@ -0,0 +1,84 @@
+package conf
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strconv"
+
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+// QuotaLimit limit usage of one capability in a period.
+type QuotaLimit struct {
+	Capability string  `json:"capability"` // text(token) image video cost
+	Period     string  `json:"period"`     // day week month
+	Limit      float64 `json:"limit"`
+}
+
+type QuotaPlan struct {
+	Name   string        `json:"name"`
+	Limits []*QuotaLimit `json:"limits"`
+}
+
+type QuotaConf struct {
+	// QuotaPlans json array of QuotaPlan
+	QuotaPlans       string  `json:"quota_plans"`
+	DefaultQuotaPlan string  `json:"default_quota_plan"`
+	GroupQuotaPlan   string  `json:"group_quota_plan"`
+	QuotaWarnRatio   float64 `json:"quota_warn_ratio"`
+
+	Plans map[string]*QuotaPlan `json:"-"`
+}
+
+var (
+	QuotaConfInfo = new(QuotaConf)
+)
+
+func InitQuotaConf() {
+	flag.StringVar(&QuotaConfInfo.QuotaPlans, "quota_plans", "",
+		`quota plans: [{"name":"free","limits":[{"capability":"text","period":"day","limit":100000},{"capability":"image","period":"day","limit":5}]}]`)
+	flag.StringVar(&QuotaConfInfo.DefaultQuotaPlan, "default_quota_plan", "", "quota plan of user who has no plan")
+	flag.StringVar(&QuotaConfInfo.GroupQuotaPlan, "group_quota_plan", "", "quota plan shared by all members of a group")
+	flag.Float64Var(&QuotaConfInfo.QuotaWarnRatio, "quota_warn_ratio", 0.8, "warn user when usage reach this ratio of quota")
+}
+
+func EnvQuotaConf() {
+	if os.Getenv("QUOTA_PLANS") != "" {
+		QuotaConfInfo.QuotaPlans = os.Getenv("QUOTA_PLANS")
+	}
+
+	if os.Getenv("DEFAULT_QUOTA_PLAN") != "" {
+		QuotaConfInfo.DefaultQuotaPlan = os.Getenv("DEFAULT_QUOTA_PLAN")
+	}
+
+	if os.Getenv("GROUP_QUOTA_PLAN") != "" {
+		QuotaConfInfo.GroupQuotaPlan = os.Getenv("GROUP_QUOTA_PLAN")
+	}
+
+	if os.Getenv("QUOTA_WARN_RATIO") != "" {
+		QuotaConfInfo.QuotaWarnRatio, _ = strconv.ParseFloat(os.Getenv("QUOTA_WARN_RATIO"), 64)
+	}
+
+	err := ParseQuotaPlans()
+	if err != nil {
+		logger.Error("parse quota plans fail", "err", err)
+	}
+}
+
+// ParseQuotaPlans parse quota_plans into Plans.
+func ParseQuotaPlans() error {
+	plans := make([]*QuotaPlan, 0)
+	if QuotaConfInfo.QuotaPlans != "" {
+		err := json.Unmarshal([]byte(QuotaConfInfo.QuotaPlans), &plans)
+		if err != nil {
+			return err
+		}
+	}
+
+	QuotaConfInfo.Plans = make(map[string]*QuotaPlan, len(plans))
+	for _, plan := range plans {
+		QuotaConfInfo.Plans[plan.Name] = plan
+	}
+	return nil
+}
@@ -1,20 +1,36 @@
 package conf
 
 import (
+	"encoding/json"
 	"flag"
 	"os"
 	"strconv"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/langchaingo/embeddings"
 	"github.com/yincongcyincong/langchaingo/vectorstores"
 )
 
+// KnowledgeBase is a named corpus, files under Path are indexed into the vector collection Space.
+type KnowledgeBase struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Space string `json:"space"`
+
+	Store vectorstores.VectorStore `json:"-"`
+}
+
 type RagConf struct {
 	EmbeddingType string `json:"embedding_type"`
 	KnowledgePath string `json:"knowledge_path"`
 	VectorDBType  string `json:"vector_db_type"`
+	Mode          string `json:"rag_mode"`
+
+	EmbeddingURL       string `json:"embedding_url"`
+	EmbeddingModel     string `json:"embedding_model"`
+	EmbeddingBatchSize int    `json:"embedding_batch_size"`
 
 	ChromaURL      string `json:"chroma_url"`
 	MilvusURL      string `json:"milvus_url"`
@@ -26,6 +42,18 @@ type RagConf struct {
 	ChunkSize    int `json:"chunk_size"`
 	ChunkOverlap int `json:"chunk_overlap"`
 
+	RetryTimes    int `json:"rag_retry_times"`
+	IngestTimeout int `json:"rag_ingest_timeout"`
+
+	TopK     int     `json:"rag_top_k"`
+	MinScore float64 `json:"rag_min_score"`
+	Rerank   bool    `json:"rag_rerank"`
+
+	// KnowledgeBases json array of KnowledgeBase besides the default one
+	KnowledgeBases string `json:"knowledge_bases"`
+
+	KBs map[string]*KnowledgeBase `json:"-"`
+
 	Store          vectorstores.VectorStore `json:"-"`
 	Embedder       embeddings.Embedder      `json:"-"`
 	MilvusClient   client.Client            `json:"-"`
@@ -38,10 +66,25 @@ var (
 	DefaultSpliter = []string{"\n\n", "\n", " ", ""}
 )
 
+const (
+	// DefaultKnowledgeBase is built from knowledge_path and space.
+	DefaultKnowledgeBase = "default"
+
+	// RagModeTool llm calls search_knowledge_base tool when it needs the knowledge base.
+	RagModeTool = "tool"
+	// RagModeChain every message is answered by retrieval qa chain.
+	RagModeChain = "chain"
+)
+
 func InitRagConf() {
-	flag.StringVar(&RagConfInfo.EmbeddingType, "embedding_type", "", "embedding split api: openai gemini ernie")
+	flag.StringVar(&RagConfInfo.EmbeddingType, "embedding_type", "", "embedding split api: openai gemini ernie ollama hash")
 	flag.StringVar(&RagConfInfo.KnowledgePath, "knowledge_path", GetAbsPath("data/knowledge"), "knowledge")
-	flag.StringVar(&RagConfInfo.VectorDBType, "vector_db_type", "milvus", "vector db type: chroma weaviate milvus")
+	flag.StringVar(&RagConfInfo.VectorDBType, "vector_db_type", "milvus", "vector db type: chroma weaviate milvus local")
+	flag.StringVar(&RagConfInfo.Mode, "rag_mode", RagModeTool, "rag mode: tool chain")
+
+	flag.StringVar(&RagConfInfo.EmbeddingURL, "embedding_url", "http://localhost:11434/v1", "openai compatible embedding url for ollama")
+	flag.StringVar(&RagConfInfo.EmbeddingModel, "embedding_model", "nomic-embed-text", "embedding model for ollama")
+	flag.IntVar(&RagConfInfo.EmbeddingBatchSize, "embedding_batch_size", 32, "embedding batch size for ollama")
 
 	flag.StringVar(&RagConfInfo.ChromaURL, "chroma_url", "http://localhost:8000", "chroma url")
 	flag.StringVar(&RagConfInfo.MilvusURL, "milvus_url", "http://localhost:19530", "milvus url")
@@ -52,6 +95,16 @@ func InitRagConf() {
 	flag.IntVar(&RagConfInfo.ChunkSize, "chunk_size", 500, "rag file chunk size")
 	flag.IntVar(&RagConfInfo.ChunkOverlap, "chunk_overlap", 50, "rag file chunk overlap")
 
+	flag.IntVar(&RagConfInfo.RetryTimes, "rag_retry_times", 3, "retry times of indexing a knowledge file")
+	flag.IntVar(&RagConfInfo.IngestTimeout, "rag_ingest_timeout", 600, "timeout seconds of indexing a knowledge file")
+
+	flag.IntVar(&RagConfInfo.TopK, "rag_top_k", 3, "rag chunks injected into prompt")
+	flag.Float64Var(&RagConfInfo.MinScore, "rag_min_score", 0, "rag min score of vector similarity and normalized keyword score, 0-1")
+	flag.BoolVar(&RagConfInfo.Rerank, "rag_rerank", false, "rerank retrieved chunks by llm")
+
+	flag.StringVar(&RagConfInfo.KnowledgeBases, "knowledge_bases", "",
+		`named knowledge bases besides default: [{"name":"support","path":"./data/support","space":"support"}]`)
+
 }
 
 func EnvRagConf() {
@@ -67,6 +120,22 @@ func EnvRagConf() {
 		RagConfInfo.VectorDBType = os.Getenv("VECTOR_DB_TYPE")
 	}
 
+	if os.Getenv("RAG_MODE") != "" {
+		RagConfInfo.Mode = os.Getenv("RAG_MODE")
+	}
+
+	if os.Getenv("EMBEDDING_URL") != "" {
+		RagConfInfo.EmbeddingURL = os.Getenv("EMBEDDING_URL")
+	}
+
+	if os.Getenv("EMBEDDING_MODEL") != "" {
+		RagConfInfo.EmbeddingModel = os.Getenv("EMBEDDING_MODEL")
+	}
+
+	if os.Getenv("EMBEDDING_BATCH_SIZE") != "" {
+		RagConfInfo.EmbeddingBatchSize, _ = strconv.Atoi(os.Getenv("EMBEDDING_BATCH_SIZE"))
+	}
+
 	if os.Getenv("CHROMA_URL") != "" {
 		RagConfInfo.ChromaURL = os.Getenv("CHROMA_URL")
 	}
@@ -94,4 +163,78 @@ func EnvRagConf() {
 	if os.Getenv("CHUNK_OVERLAP") != "" {
 		RagConfInfo.ChunkOverlap, _ = strconv.Atoi(os.Getenv("CHUNK_OVERLAP"))
 	}
+
+	if os.Getenv("RAG_RETRY_TIMES") != "" {
+		RagConfInfo.RetryTimes, _ = strconv.Atoi(os.Getenv("RAG_RETRY_TIMES"))
+	}
+
+	if os.Getenv("RAG_INGEST_TIMEOUT") != "" {
+		RagConfInfo.IngestTimeout, _ = strconv.Atoi(os.Getenv("RAG_INGEST_TIMEOUT"))
+	}
+
+	if os.Getenv("RAG_TOP_K") != "" {
+		RagConfInfo.TopK, _ = strconv.Atoi(os.Getenv("RAG_TOP_K"))
+	}
+
+	if os.Getenv("RAG_MIN_SCORE") != "" {
+		RagConfInfo.MinScore, _ = strconv.ParseFloat(os.Getenv("RAG_MIN_SCORE"), 64)
+	}
+
+	if os.Getenv("RAG_RERANK") != "" {
+		RagConfInfo.Rerank = os.Getenv("RAG_RERANK") == "true"
+	}
+
+	if os.Getenv("KNOWLEDGE_BASES") != "" {
+		RagConfInfo.KnowledgeBases = os.Getenv("KNOWLEDGE_BASES")
+	}
+
+	err := ParseKnowledgeBases()
+	if err != nil {
+		logger.Error("parse knowledge bases fail", "err", err)
+	}
+}
+
+// ParseKnowledgeBases parse knowledge_bases into KBs, the default knowledge base is always present.
+func ParseKnowledgeBases() error {
+	kbs := make([]*KnowledgeBase, 0)
+	if RagConfInfo.KnowledgeBases != "" {
+		err := json.Unmarshal([]byte(RagConfInfo.KnowledgeBases), &kbs)
+		if err != nil {
+			return err
+		}
+	}
+
+	RagConfInfo.KBs = make(map[string]*KnowledgeBase, len(kbs)+1)
+	for _, kb := range kbs {
+		if kb.Name == "" || kb.Name == DefaultKnowledgeBase {
+			continue
+		}
+		if kb.Path == "" {
+			kb.Path = GetAbsPath("data/" + kb.Name)
+		}
+		if kb.Space == "" {
+			kb.Space = kb.Name
+		}
+		RagConfInfo.KBs[kb.Name] = kb
+	}
+
+	RagConfInfo.KBs[DefaultKnowledgeBase] = &KnowledgeBase{
+		Name:  DefaultKnowledgeBase,
+		Path:  RagConfInfo.KnowledgePath,
+		Space: RagConfInfo.Space,
+	}
+	return nil
+}
+
+// GetKnowledgeBase get knowledge base by name, empty name means default, nil if not exist.
+func (r *RagConf) GetKnowledgeBase(name string) *KnowledgeBase {
+	if name == "" {
+		name = DefaultKnowledgeBase
+	}
+	return r.KBs[name]
+}
+
+// UseChain every message goes through retrieval qa chain instead of search_knowledge_base tool.
+func (r *RagConf) UseChain() bool {
+	return r.Store != nil && r.Mode == RagModeChain
 }
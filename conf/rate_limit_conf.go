@@ -0,0 +1,196 @@
+package conf
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+// RateLimit is a token bucket, Rate tokens are put into bucket per second and bucket hold Burst tokens at most.
+type RateLimit struct {
+	Spec  string
+	Rate  float64
+	Burst int
+}
+
+type RateLimitConf struct {
+	// rate limit format is count/unit[:burst], unit is s m h d, such as 20/m or 100/h:10
+	UserRateLimit  string `json:"user_rate_limit"`
+	GroupRateLimit string `json:"group_rate_limit"`
+	// CommandRateLimits limit of each user per command class, such as chat=20/m,photo=5/h,video=2/d,mcp=10/m
+	CommandRateLimits string `json:"command_rate_limits"`
+	// ProviderRateLimits limit of all users per provider, such as openai=60/m:10,gemini=30/m
+	ProviderRateLimits string `json:"provider_rate_limits"`
+
+	// limits are replaced by http handlers while robots read them, access them by methods holding lock
+	User     *RateLimit            `json:"-"`
+	Group    *RateLimit            `json:"-"`
+	Command  map[string]*RateLimit `json:"-"`
+	Provider map[string]*RateLimit `json:"-"`
+	lock     sync.RWMutex
+}
+
+var (
+	RateLimitConfInfo = new(RateLimitConf)
+)
+
+func InitRateLimitConf() {
+	flag.StringVar(&RateLimitConfInfo.UserRateLimit, "user_rate_limit", "", "rate limit of each user, such as 20/m or 100/h:10")
+	flag.StringVar(&RateLimitConfInfo.GroupRateLimit, "group_rate_limit", "", "rate limit of each group chat, such as 60/m")
+	flag.StringVar(&RateLimitConfInfo.CommandRateLimits, "command_rate_limits", "", "rate limit of each user per command class chat photo video mcp, such as chat=20/m,photo=5/h")
+	flag.StringVar(&RateLimitConfInfo.ProviderRateLimits, "provider_rate_limits", "", "rate limit of all users per provider, such as openai=60/m:10")
+}
+
+func EnvRateLimitConf() {
+	if os.Getenv("USER_RATE_LIMIT") != "" {
+		RateLimitConfInfo.UserRateLimit = os.Getenv("USER_RATE_LIMIT")
+	}
+
+	if os.Getenv("GROUP_RATE_LIMIT") != "" {
+		RateLimitConfInfo.GroupRateLimit = os.Getenv("GROUP_RATE_LIMIT")
+	}
+
+	if os.Getenv("COMMAND_RATE_LIMITS") != "" {
+		RateLimitConfInfo.CommandRateLimits = os.Getenv("COMMAND_RATE_LIMITS")
+	}
+
+	if os.Getenv("PROVIDER_RATE_LIMITS") != "" {
+		RateLimitConfInfo.ProviderRateLimits = os.Getenv("PROVIDER_RATE_LIMITS")
+	}
+
+	err := ParseRateLimits()
+	if err != nil {
+		logger.Error("parse rate limits fail", "err", err)
+	}
+}
+
+// ParseRateLimits parse rate limit specs of config, limits are kept when any spec is invalid.
+func ParseRateLimits() error {
+	RateLimitConfInfo.lock.Lock()
+	defer RateLimitConfInfo.lock.Unlock()
+
+	user, err := ParseRateLimit(RateLimitConfInfo.UserRateLimit)
+	if err != nil {
+		return err
+	}
+	group, err := ParseRateLimit(RateLimitConfInfo.GroupRateLimit)
+	if err != nil {
+		return err
+	}
+	command, err := parseNamedRateLimits(RateLimitConfInfo.CommandRateLimits)
+	if err != nil {
+		return err
+	}
+	providerLimits, err := parseNamedRateLimits(RateLimitConfInfo.ProviderRateLimits)
+	if err != nil {
+		return err
+	}
+
+	RateLimitConfInfo.User = user
+	RateLimitConfInfo.Group = group
+	RateLimitConfInfo.Command = command
+	RateLimitConfInfo.Provider = providerLimits
+	return nil
+}
+
+// GetUser get rate limit of each user, nil means no limit.
+func (r *RateLimitConf) GetUser() *RateLimit {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.User
+}
+
+// GetGroup get rate limit of each group chat, nil means no limit.
+func (r *RateLimitConf) GetGroup() *RateLimit {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.Group
+}
+
+// GetCommand get rate limit of command class, nil means no limit.
+func (r *RateLimitConf) GetCommand(class string) *RateLimit {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.Command[class]
+}
+
+// GetProvider get rate limit of provider, nil means no limit.
+func (r *RateLimitConf) GetProvider(t string) *RateLimit {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.Provider[t]
+}
+
+func parseNamedRateLimits(raw string) (map[string]*RateLimit, error) {
+	res := make(map[string]*RateLimit)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit: %s", item)
+		}
+		limit, err := ParseRateLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		res[strings.TrimSpace(name)] = limit
+	}
+	return res, nil
+}
+
+// ParseRateLimit parse count/unit[:burst], burst is count when not set. nil means no limit.
+func ParseRateLimit(spec string) (*RateLimit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	rateSpec, burstSpec, hasBurst := strings.Cut(spec, ":")
+	countSpec, unitSpec, ok := strings.Cut(rateSpec, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid rate limit: %s", spec)
+	}
+
+	count, err := strconv.ParseFloat(strings.TrimSpace(countSpec), 64)
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid rate limit count: %s", spec)
+	}
+
+	var unit time.Duration
+	switch strings.TrimSpace(unitSpec) {
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("invalid rate limit unit: %s", spec)
+	}
+
+	burst := int(math.Ceil(count))
+	if hasBurst {
+		burst, err = strconv.Atoi(strings.TrimSpace(burstSpec))
+		if err != nil || burst <= 0 {
+			return nil, fmt.Errorf("invalid rate limit burst: %s", spec)
+		}
+	}
+
+	return &RateLimit{
+		Spec:  spec,
+		Rate:  count / unit.Seconds(),
+		Burst: burst,
+	}, nil
+}
@@ -0,0 +1,193 @@
+package conf
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+// RolePolicy list commands, mcp servers, providers and models a role can use.
+// "*" matches everything and a trailing "*" matches by prefix.
+type RolePolicy struct {
+	Name       string   `json:"name"`
+	Commands   []string `json:"commands"`
+	MCPServers []string `json:"mcp_servers"`
+	Providers  []string `json:"providers"`
+	Models     []string `json:"models"`
+}
+
+type RBACConf struct {
+	// RolePolicies json array of RolePolicy
+	RolePolicies string `json:"role_policies"`
+	DefaultRole  string `json:"default_role"`
+
+	// Policies is replaced by http handlers while robots read it, access it by methods holding lock
+	Policies map[string]*RolePolicy `json:"-"`
+	lock     sync.RWMutex
+}
+
+var (
+	RBACConfInfo = new(RBACConf)
+
+	// RestrictedCommands commands writing shared data, denied unless a role lists them by exact name,
+	// "*" or prefix pattern doesn't grant them.
+	RestrictedCommands = []string{param.Learn}
+)
+
+func InitRBACConf() {
+	flag.StringVar(&RBACConfInfo.RolePolicies, "role_policies", "",
+		`role policies: [{"name":"admin","commands":["*"],"mcp_servers":["*"],"providers":["*"],"models":["*"]}]`)
+	flag.StringVar(&RBACConfInfo.DefaultRole, "default_role", "", "role of user and group which has no role")
+}
+
+func EnvRBACConf() {
+	if os.Getenv("ROLE_POLICIES") != "" {
+		RBACConfInfo.RolePolicies = os.Getenv("ROLE_POLICIES")
+	}
+
+	if os.Getenv("DEFAULT_ROLE") != "" {
+		RBACConfInfo.DefaultRole = os.Getenv("DEFAULT_ROLE")
+	}
+
+	err := ParseRolePolicies()
+	if err != nil {
+		logger.Error("parse role policies fail", "err", err)
+	}
+}
+
+// ParseRolePolicies parse role_policies into Policies, role_policies is rebuilt by SetRolePolicy under the lock.
+func ParseRolePolicies() error {
+	RBACConfInfo.lock.Lock()
+	defer RBACConfInfo.lock.Unlock()
+
+	policies := make([]*RolePolicy, 0)
+	if RBACConfInfo.RolePolicies != "" {
+		err := json.Unmarshal([]byte(RBACConfInfo.RolePolicies), &policies)
+		if err != nil {
+			return err
+		}
+	}
+
+	policyMap := make(map[string]*RolePolicy, len(policies))
+	for _, policy := range policies {
+		policyMap[policy.Name] = policy
+	}
+
+	RBACConfInfo.Policies = policyMap
+	return nil
+}
+
+// HasPolicies whether any role policy is configured.
+func (r *RBACConf) HasPolicies() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.Policies) > 0
+}
+
+// GetPolicy get policy of role, nil if not exist.
+func (r *RBACConf) GetPolicy(role string) *RolePolicy {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.Policies[role]
+}
+
+// ListPolicies get all policies sorted by name.
+func (r *RBACConf) ListPolicies() []*RolePolicy {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return sortedPolicies(r.Policies)
+}
+
+// Allow check role can use name of resource. everything except RestrictedCommands is allowed when
+// no policy is configured, empty role is default_role, empty or unknown role can use nothing.
+func (r *RBACConf) Allow(role, resource, name string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	restricted := resource == param.RoleResourceCommand && slices.Contains(RestrictedCommands, name)
+	if len(r.Policies) == 0 {
+		return !restricted
+	}
+	if role == "" {
+		role = r.DefaultRole
+	}
+
+	policy, ok := r.Policies[role]
+	if !ok {
+		return false
+	}
+	if restricted {
+		return slices.Contains(policy.Commands, name)
+	}
+
+	var patterns []string
+	switch resource {
+	case param.RoleResourceCommand:
+		patterns = policy.Commands
+	case param.RoleResourceMCP:
+		patterns = policy.MCPServers
+	case param.RoleResourceProvider:
+		patterns = policy.Providers
+	case param.RoleResourceModel:
+		patterns = policy.Models
+	}
+
+	for _, pattern := range patterns {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern "*" matches everything and a trailing "*" matches by prefix.
+func matchPattern(pattern, name string) bool {
+	return pattern == "*" || pattern == name ||
+		(strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")))
+}
+
+// SetRolePolicy add or replace policy of role, role_policies is rebuilt from Policies.
+func SetRolePolicy(policy *RolePolicy) error {
+	RBACConfInfo.lock.Lock()
+	defer RBACConfInfo.lock.Unlock()
+	if RBACConfInfo.Policies == nil {
+		RBACConfInfo.Policies = make(map[string]*RolePolicy)
+	}
+	RBACConfInfo.Policies[policy.Name] = policy
+	return dumpRolePolicies()
+}
+
+// DeleteRolePolicy remove policy of role.
+func DeleteRolePolicy(name string) error {
+	RBACConfInfo.lock.Lock()
+	defer RBACConfInfo.lock.Unlock()
+	delete(RBACConfInfo.Policies, name)
+	return dumpRolePolicies()
+}
+
+// dumpRolePolicies rebuild role_policies, caller holds the lock.
+func dumpRolePolicies() error {
+	data, err := json.Marshal(sortedPolicies(RBACConfInfo.Policies))
+	if err != nil {
+		return err
+	}
+	RBACConfInfo.RolePolicies = string(data)
+	return nil
+}
+
+func sortedPolicies(policyMap map[string]*RolePolicy) []*RolePolicy {
+	policies := make([]*RolePolicy, 0, len(policyMap))
+	for _, policy := range policyMap {
+		policies = append(policies, policy)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+	return policies
+}
@@ -2,8 +2,12 @@ package conf
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +16,7 @@ import (
 	"github.com/sashabaranov/go-openai"
 	"github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
 	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
 	"github.com/yincongcyincong/mcp-client-go/clients"
 	"github.com/yincongcyincong/mcp-client-go/utils"
 	"google.golang.org/genai"
@@ -27,8 +32,27 @@ type AgentInfo struct {
 	OpenRouterTools []openrouter.Tool `json:"-"`
 }
 
+// McpPolicy action of tools of mcp servers, "*" matches everything and a trailing "*" matches by prefix.
+// empty tools match all tools of the server.
+type McpPolicy struct {
+	Server string   `json:"server"`
+	Tools  []string `json:"tools"`
+	Action string   `json:"action"`
+}
+
 type ToolsConf struct {
 	McpConfPath *string `json:"mcp_conf_path"`
+	// TaskParallelism max number of plan tasks running at the same time
+	TaskParallelism int `json:"task_parallelism"`
+	// McpPolicies json array of McpPolicy, the first matched policy decides whether tool call is allowed,
+	// denied or needs approval of user. tool call matching no policy is allowed.
+	McpPolicies string `json:"mcp_policies"`
+	// McpApprovalTimeout seconds waiting for user to approve tool call, it is rejected after timeout
+	McpApprovalTimeout int `json:"mcp_approval_timeout"`
+	// NativeTools comma separated built-in go tools given to llm when use_tools is on, "*" enables all of them
+	NativeTools string `json:"native_tools"`
+
+	Policies []*McpPolicy `json:"-"`
 }
 
 var (
@@ -43,12 +67,89 @@ var (
 
 func InitToolsConf() {
 	ToolsConfInfo.McpConfPath = flag.String("mcp_conf_path", GetAbsPath("conf/mcp/mcp.json"), "mcp conf path")
+	flag.IntVar(&ToolsConfInfo.TaskParallelism, "task_parallelism", 3, "max number of independent task steps running at the same time")
+	flag.StringVar(&ToolsConfInfo.McpPolicies, "mcp_policies", "",
+		`mcp policies: [{"server":"mcp-server-commands","tools":["*"],"action":"approve"},{"server":"mysql","action":"deny"}]`)
+	flag.IntVar(&ToolsConfInfo.McpApprovalTimeout, "mcp_approval_timeout", 300, "seconds waiting for user to approve mcp tool call")
+	flag.StringVar(&ToolsConfInfo.NativeTools, "native_tools", "current_time,calculator,search_records",
+		"built-in tools: current_time,calculator,http_fetch,search_records,create_cron, * means all")
 }
 
 func EnvToolsConf() {
 	if os.Getenv("MCP_CONF_PATH") != "" {
 		*ToolsConfInfo.McpConfPath = os.Getenv("MCP_CONF_PATH")
 	}
+
+	if os.Getenv("TASK_PARALLELISM") != "" {
+		ToolsConfInfo.TaskParallelism, _ = strconv.Atoi(os.Getenv("TASK_PARALLELISM"))
+	}
+
+	if os.Getenv("MCP_POLICIES") != "" {
+		ToolsConfInfo.McpPolicies = os.Getenv("MCP_POLICIES")
+	}
+
+	if os.Getenv("MCP_APPROVAL_TIMEOUT") != "" {
+		ToolsConfInfo.McpApprovalTimeout, _ = strconv.Atoi(os.Getenv("MCP_APPROVAL_TIMEOUT"))
+	}
+
+	if os.Getenv("NATIVE_TOOLS") != "" {
+		ToolsConfInfo.NativeTools = os.Getenv("NATIVE_TOOLS")
+	}
+
+	err := ParseMcpPolicies()
+	if err != nil {
+		logger.Error("parse mcp policies fail", "err", err)
+	}
+}
+
+// ParseMcpPolicies parse mcp_policies into Policies.
+func ParseMcpPolicies() error {
+	policies := make([]*McpPolicy, 0)
+	if ToolsConfInfo.McpPolicies != "" {
+		err := json.Unmarshal([]byte(ToolsConfInfo.McpPolicies), &policies)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, policy := range policies {
+		switch policy.Action {
+		case param.McpActionAllow, param.McpActionDeny, param.McpActionApprove:
+		default:
+			return fmt.Errorf("action %s of mcp policy[%d] should be allow, deny or approve", policy.Action, i)
+		}
+	}
+
+	ToolsConfInfo.Policies = policies
+	return nil
+}
+
+// McpAction action of tool of mcp server decided by the first matched policy, allow if no policy matches.
+func (t *ToolsConf) McpAction(server, tool string) string {
+	for _, policy := range t.Policies {
+		if !matchPattern(policy.Server, server) {
+			continue
+		}
+		if len(policy.Tools) == 0 {
+			return policy.Action
+		}
+		for _, pattern := range policy.Tools {
+			if matchPattern(pattern, tool) {
+				return policy.Action
+			}
+		}
+	}
+	return param.McpActionAllow
+}
+
+// NativeToolEnabled whether built-in tool is enabled by native_tools.
+func (t *ToolsConf) NativeToolEnabled(name string) bool {
+	for _, pattern := range strings.Split(t.NativeTools, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
 }
 
 func InitTools() {
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+const (
+	MemoryStore = "memory"
+	SQLStore    = "sql"
+	RedisStore  = "redis"
+)
+
+// ConversationStore keep the latest MaxQAPair conversation of each session.
+type ConversationStore interface {
+	Append(ctx context.Context, sessionId string, aq *AQ) error
+
+	Get(ctx context.Context, sessionId string) (*MsgRecordInfo, error)
+
+	Delete(ctx context.Context, sessionId string) error
+}
+
+var ConvStore ConversationStore = NewMemoryConversationStore()
+
+// InitConversationStore choose conversation store by context_store conf.
+func InitConversationStore() {
+	switch conf.BaseConfInfo.ContextStore {
+	case SQLStore:
+		ConvStore = NewSQLConversationStore()
+	case RedisStore:
+		ConvStore = NewRedisConversationStore(conf.BaseConfInfo.RedisAddr, conf.BaseConfInfo.RedisPassword,
+			conf.BaseConfInfo.RedisDB)
+	default:
+		ConvStore = NewMemoryConversationStore()
+	}
+
+	logger.Info("conversation store initialize successfully", "store", conf.BaseConfInfo.ContextStore)
+}
+
+// loadAQs load latest conversation of session from records, oldest first.
+func loadAQs(sessionId string) ([]*AQ, error) {
+	records, err := getRecordsBySessionId(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	aqs := make([]*AQ, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		// question which is still waiting for answer
+		if records[i].Answer == "" {
+			continue
+		}
+		aqs = append(aqs, &AQ{
+			Question:   records[i].Question,
+			Answer:     records[i].Answer,
+			Content:    records[i].Content,
+			Mode:       records[i].Mode,
+			CreateTime: records[i].CreateTime,
+		})
+	}
+	return aqs, nil
+}
+
+// MemoryConversationStore keep conversation in process, load from db when session first used.
+type MemoryConversationStore struct {
+	records sync.Map
+	lock    sync.Mutex
+}
+
+func NewMemoryConversationStore() *MemoryConversationStore {
+	return &MemoryConversationStore{}
+}
+
+func (m *MemoryConversationStore) load(sessionId string) (*MsgRecordInfo, error) {
+	if msgRecord, ok := m.records.Load(sessionId); ok {
+		return msgRecord.(*MsgRecordInfo), nil
+	}
+
+	aqs, err := loadAQs(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	msgRecord, _ := m.records.LoadOrStore(sessionId, &MsgRecordInfo{
+		AQs:        aqs,
+		updateTime: time.Now().Unix(),
+	})
+	return msgRecord.(*MsgRecordInfo), nil
+}
+
+func (m *MemoryConversationStore) Append(ctx context.Context, sessionId string, aq *AQ) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	msgRecord, err := m.load(sessionId)
+	if err != nil {
+		return err
+	}
+
+	aqs := append(msgRecord.AQs, aq)
+	if len(aqs) > conf.BaseConfInfo.MaxQAPair {
+		aqs = aqs[len(aqs)-conf.BaseConfInfo.MaxQAPair:]
+	}
+	m.records.Store(sessionId, &MsgRecordInfo{
+		AQs:        aqs,
+		updateTime: time.Now().Unix(),
+	})
+	return nil
+}
+
+func (m *MemoryConversationStore) Get(ctx context.Context, sessionId string) (*MsgRecordInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.load(sessionId)
+}
+
+func (m *MemoryConversationStore) Delete(ctx context.Context, sessionId string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.records.Delete(sessionId)
+	return nil
+}
+
+// SQLConversationStore read conversation from records table directly, records are shared by all replicas.
+type SQLConversationStore struct{}
+
+func NewSQLConversationStore() *SQLConversationStore {
+	return &SQLConversationStore{}
+}
+
+// Append records table is written by InsertRecordInfo and UpdateRecordInfo already.
+func (s *SQLConversationStore) Append(ctx context.Context, sessionId string, aq *AQ) error {
+	return nil
+}
+
+func (s *SQLConversationStore) Get(ctx context.Context, sessionId string) (*MsgRecordInfo, error) {
+	aqs, err := loadAQs(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MsgRecordInfo{
+		AQs:        aqs,
+		updateTime: time.Now().Unix(),
+	}, nil
+}
+
+// Delete records are soft deleted by DeleteRecordBySessionId.
+func (s *SQLConversationStore) Delete(ctx context.Context, sessionId string) error {
+	return nil
+}
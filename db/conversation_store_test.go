@@ -0,0 +1,246 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+// fakeRedis is a local stand-in which speaks enough RESP for RedisConversationStore.
+type fakeRedis struct {
+	listener net.Listener
+	lock     sync.Mutex
+	lists    map[string][]string
+	values   map[string]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail: %v", err)
+	}
+
+	f := &fakeRedis{listener: listener, lists: make(map[string][]string), values: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err = io.WriteString(conn, f.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "EXISTS":
+		_, isList := f.lists[args[1]]
+		_, isValue := f.values[args[1]]
+		if isList || isValue {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "SET":
+		// SET key value EX seconds NX
+		if _, ok := f.values[args[1]]; ok && strings.EqualFold(args[len(args)-1], "NX") {
+			return "$-1\r\n"
+		}
+		f.values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "RPUSH":
+		f.lists[args[1]] = append(f.lists[args[1]], args[2:]...)
+		return fmt.Sprintf(":%d\r\n", len(f.lists[args[1]]))
+	case "LPUSH":
+		list := make([]string, 0, len(args)-2+len(f.lists[args[1]]))
+		for i := len(args) - 1; i >= 2; i-- {
+			list = append(list, args[i])
+		}
+		f.lists[args[1]] = append(list, f.lists[args[1]]...)
+		return fmt.Sprintf(":%d\r\n", len(f.lists[args[1]]))
+	case "LTRIM":
+		list := f.lists[args[1]]
+		start, _ := strconv.Atoi(args[2])
+		if start < 0 && len(list)+start > 0 {
+			f.lists[args[1]] = list[len(list)+start:]
+		}
+		return "+OK\r\n"
+	case "LRANGE":
+		list := f.lists[args[1]]
+		res := fmt.Sprintf("*%d\r\n", len(list))
+		for _, v := range list {
+			res += fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+		}
+		return res
+	case "EXPIRE":
+		return ":1\r\n"
+	case "DEL":
+		for _, key := range args[1:] {
+			delete(f.lists, key)
+			delete(f.values, key)
+		}
+		return fmt.Sprintf(":%d\r\n", len(args)-1)
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func TestRedisConversationStore(t *testing.T) {
+	f := newFakeRedis(t)
+	store := NewRedisConversationStore(f.listener.Addr().String(), "", 0)
+	ctx := context.Background()
+	sessionId := GetSessionId(GetChatKey("telegram", "redis", "redis"), "default")
+
+	for i := 0; i < conf.BaseConfInfo.MaxQAPair+3; i++ {
+		err := store.Append(ctx, sessionId, &AQ{Question: "Q" + strconv.Itoa(i), Answer: "A" + strconv.Itoa(i)})
+		assert.NoError(t, err)
+	}
+
+	record, err := store.Get(ctx, sessionId)
+	assert.NoError(t, err)
+	assert.Equal(t, conf.BaseConfInfo.MaxQAPair, len(record.AQs))
+	assert.Equal(t, "Q"+strconv.Itoa(conf.BaseConfInfo.MaxQAPair+2), record.AQs[len(record.AQs)-1].Question)
+
+	// another replica share the same history
+	replica := NewRedisConversationStore(f.listener.Addr().String(), "", 0)
+	record, err = replica.Get(ctx, sessionId)
+	assert.NoError(t, err)
+	assert.Equal(t, conf.BaseConfInfo.MaxQAPair, len(record.AQs))
+
+	assert.NoError(t, store.Delete(ctx, sessionId))
+	record, err = replica.Get(ctx, sessionId)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(record.AQs))
+}
+
+func TestRedisConversationStore_Load(t *testing.T) {
+	f := newFakeRedis(t)
+	store := NewRedisConversationStore(f.listener.Addr().String(), "", 0)
+	ctx := context.Background()
+	userId := "redis_load"
+	sessionId := GetSessionId(GetChatKey("telegram", "redis_load", userId), "default")
+
+	_, err := InsertRecordInfo(ctx, &Record{UserId: userId, SessionId: sessionId, Question: "Q0", Answer: "A0"})
+	assert.NoError(t, err)
+
+	// window is full without any placeholder taking a place
+	for i := 1; i < conf.BaseConfInfo.MaxQAPair; i++ {
+		assert.NoError(t, store.Append(ctx, sessionId, &AQ{Question: "Q" + strconv.Itoa(i), Answer: "A" + strconv.Itoa(i)}))
+	}
+	record, err := store.Get(ctx, sessionId)
+	assert.NoError(t, err)
+	assert.Equal(t, conf.BaseConfInfo.MaxQAPair, len(record.AQs))
+	assert.Equal(t, "Q0", record.AQs[0].Question)
+	assert.Equal(t, "Q1", record.AQs[1].Question)
+
+	// history is loaded only once
+	replica := NewRedisConversationStore(f.listener.Addr().String(), "", 0)
+	record, err = replica.Get(ctx, sessionId)
+	assert.NoError(t, err)
+	assert.Equal(t, conf.BaseConfInfo.MaxQAPair, len(record.AQs))
+	assert.Equal(t, "Q0", record.AQs[0].Question)
+}
+
+func TestMemoryConversationStore_LazyLoad(t *testing.T) {
+	ctx := context.Background()
+	userId := "lazy"
+	sessionId := GetSessionId(GetChatKey("slack", "C002", userId), "default")
+
+	_, err := InsertRecordInfo(ctx, &Record{
+		UserId:    userId,
+		SessionId: sessionId,
+		Question:  "What is MuseBot?",
+		Answer:    "A bot.",
+	})
+	assert.NoError(t, err)
+
+	// new process: history is loaded when session is first used
+	ConvStore = NewMemoryConversationStore()
+	record := GetMsgRecord(ctx, sessionId)
+	assert.NotNil(t, record)
+	assert.Equal(t, "What is MuseBot?", record.AQs[0].Question)
+
+	DeleteMsgRecord(ctx, sessionId)
+	assert.Nil(t, GetMsgRecord(ctx, sessionId))
+}
+
+func TestSQLConversationStore(t *testing.T) {
+	ctx := context.Background()
+	userId := "sql"
+	sessionId := GetSessionId(GetChatKey("discord", "D001", userId), "default")
+	ConvStore = NewSQLConversationStore()
+	defer func() {
+		ConvStore = NewMemoryConversationStore()
+	}()
+
+	_, err := InsertRecordInfo(ctx, &Record{
+		UserId:    userId,
+		SessionId: sessionId,
+		Question:  "Q1",
+		Answer:    "A1",
+	})
+	assert.NoError(t, err)
+
+	record := GetMsgRecord(ctx, sessionId)
+	assert.NotNil(t, record)
+	assert.Equal(t, 1, len(record.AQs))
+
+	DeleteMsgRecord(ctx, sessionId)
+	assert.Nil(t, GetMsgRecord(ctx, sessionId))
+}
@@ -1,10 +1,10 @@
 package db
 
 import (
-	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/mattn/go-sqlite3"
@@ -24,7 +24,9 @@ var (
 			avail_token INTEGER NOT NULL DEFAULT 0,
 			create_time INTEGER NOT NULL DEFAULT '0',
 			from_bot VARCHAR(255) NOT NULL DEFAULT '',
-			llm_config TEXT NOT NULL
+			llm_config TEXT NOT NULL,
+			cost REAL NOT NULL DEFAULT 0,
+			plan VARCHAR(100) NOT NULL DEFAULT ''
 		);
 		CREATE INDEX IF NOT EXISTS idx_users_user_id ON users(user_id);
 	`,
@@ -41,22 +43,165 @@ var (
 			token INTEGER NOT NULL DEFAULT 0,
 			mode VARCHAR(100) NOT NULL DEFAULT '',
 			record_type INTEGER NOT NULL DEFAULT 0, -- SQLite中用INTEGER代替tinyint
-			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+			from_bot VARCHAR(255) NOT NULL DEFAULT '',
+			session_id VARCHAR(255) NOT NULL DEFAULT '',
+			prompt_token INTEGER NOT NULL DEFAULT 0,
+			completion_token INTEGER NOT NULL DEFAULT 0,
+			cost REAL NOT NULL DEFAULT 0,
+			doc_ids TEXT NOT NULL DEFAULT ''
 		);
 		CREATE INDEX IF NOT EXISTS idx_records_user_id ON records(user_id);
 		CREATE INDEX IF NOT EXISTS idx_records_create_time ON records(create_time);
+		CREATE INDEX IF NOT EXISTS idx_records_session_id ON records(session_id);
+	`,
+		"sessions": `
+		CREATE TABLE IF NOT EXISTS sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_key VARCHAR(255) NOT NULL DEFAULT '',
+			name VARCHAR(100) NOT NULL DEFAULT '',
+			is_active INTEGER NOT NULL DEFAULT 0,
+			create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0',
+			is_deleted INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_sessions_chat_key ON sessions(chat_key);
+	`,
+		"summaries": `
+		CREATE TABLE IF NOT EXISTS summaries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id VARCHAR(255) NOT NULL DEFAULT '',
+			content TEXT NOT NULL,
+			last_time INTEGER NOT NULL DEFAULT '0',
+			token INTEGER NOT NULL DEFAULT 0,
+			create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0',
+			is_deleted INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_summaries_session_id ON summaries(session_id);
+	`,
+		"role_bindings": `
+		CREATE TABLE IF NOT EXISTS role_bindings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subject_type VARCHAR(20) NOT NULL DEFAULT '',
+			subject_id VARCHAR(100) NOT NULL DEFAULT '',
+			role VARCHAR(100) NOT NULL DEFAULT '',
+			create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS uniq_role_bindings_subject ON role_bindings(subject_type, subject_id, from_bot);
+	`,
+		"vectors": `
+		CREATE TABLE IF NOT EXISTS vectors (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			space VARCHAR(255) NOT NULL DEFAULT '',
+			content TEXT NOT NULL,
+			metadata TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			create_time INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_vectors_space ON vectors(space);
+	`,
+		"kb_bindings": `
+		CREATE TABLE IF NOT EXISTS kb_bindings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_key VARCHAR(255) NOT NULL DEFAULT '',
+			kb VARCHAR(100) NOT NULL DEFAULT '',
+			create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_kb_bindings_chat_key ON kb_bindings(chat_key);
+	`,
+		"rag_chunks": `
+		CREATE TABLE IF NOT EXISTS rag_chunks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kb VARCHAR(100) NOT NULL DEFAULT 'default',
+			file_name VARCHAR(255) NOT NULL DEFAULT '',
+			chunk_index INTEGER NOT NULL DEFAULT '0',
+			content TEXT NOT NULL,
+			metadata TEXT NOT NULL,
+			embedding BLOB,
+			create_time INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_rag_chunks_kb_file ON rag_chunks(kb, file_name);
 	`,
 		"rag_files": `
 		CREATE TABLE IF NOT EXISTS rag_files (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kb VARCHAR(100) NOT NULL DEFAULT 'default',
 			file_name VARCHAR(255) NOT NULL DEFAULT '',
 			file_md5 VARCHAR(255) NOT NULL DEFAULT '',
 			vector_id TEXT NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL DEFAULT 'done', -- pending indexing done failed
+			error_msg VARCHAR(1024) NOT NULL DEFAULT '',
+			retry_times INTEGER NOT NULL DEFAULT '0',
+			source VARCHAR(1024) NOT NULL DEFAULT '',
 			create_time INTEGER NOT NULL DEFAULT '0',
 			update_time INTEGER NOT NULL DEFAULT '0',
 			is_deleted INTEGER NOT NULL DEFAULT '0',
 			from_bot VARCHAR(255) NOT NULL DEFAULT ''
 		);
+	`,
+		"task_runs": `
+		CREATE TABLE IF NOT EXISTS task_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id VARCHAR(100) NOT NULL DEFAULT '',
+			chat_id VARCHAR(255) NOT NULL DEFAULT '',
+			session_id VARCHAR(255) NOT NULL DEFAULT '',
+			content TEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'running', -- running done failed cancelled
+			plan TEXT NOT NULL,
+			loop_num INTEGER NOT NULL DEFAULT '0',
+			result TEXT NOT NULL,
+			token INTEGER NOT NULL DEFAULT 0,
+			error_msg VARCHAR(1024) NOT NULL DEFAULT '',
+			create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_runs_user_id ON task_runs(user_id);
+	`,
+		"task_steps": `
+		CREATE TABLE IF NOT EXISTS task_steps (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id INTEGER NOT NULL DEFAULT '0',
+			loop_num INTEGER NOT NULL DEFAULT '0',
+			step_id VARCHAR(100) NOT NULL DEFAULT '',
+			name VARCHAR(255) NOT NULL DEFAULT '',
+			description TEXT NOT NULL,
+			depends_on TEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending running done failed
+			result TEXT NOT NULL,
+			tool_calls TEXT NOT NULL,
+			token INTEGER NOT NULL DEFAULT 0,
+			error_msg VARCHAR(1024) NOT NULL DEFAULT '',
+			create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_steps_run_id ON task_steps(run_id);
+	`,
+		"mcp_audits": `
+		CREATE TABLE IF NOT EXISTS mcp_audits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id VARCHAR(100) NOT NULL DEFAULT '',
+			chat_id VARCHAR(100) NOT NULL DEFAULT '',
+			server VARCHAR(255) NOT NULL DEFAULT '',
+			tool VARCHAR(255) NOT NULL DEFAULT '',
+			arguments TEXT NOT NULL,
+			action VARCHAR(20) NOT NULL DEFAULT '', -- deny approve
+			decision VARCHAR(20) NOT NULL DEFAULT '', -- denied pending approved rejected timeout cancelled
+			decided_by VARCHAR(100) NOT NULL DEFAULT '',
+			create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0',
+			from_bot VARCHAR(255) NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_mcp_audits_user_id ON mcp_audits(user_id);
 	`,
 		"cron": `
 		CREATE TABLE IF NOT EXISTS cron (
@@ -91,6 +236,8 @@ var (
            create_time INT(10) NOT NULL DEFAULT 0,
            from_bot VARCHAR(255) NOT NULL DEFAULT '',
            llm_config TEXT NOT NULL,
+           cost DECIMAL(20,8) NOT NULL DEFAULT 0 COMMENT 'spend in currency of pricing',
+           plan VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'quota plan name',
            
            -- 嵌入索引：idx_users_user_id
            INDEX idx_users_user_id (user_id)
@@ -111,18 +258,29 @@ var (
            mode VARCHAR(100) NOT NULL DEFAULT '',
            record_type tinyint(1) NOT NULL DEFAULT 0 COMMENT '0:text, 1:image 2:video 3: web',
            from_bot VARCHAR(255) NOT NULL DEFAULT '',
+           session_id VARCHAR(255) NOT NULL DEFAULT '',
+           prompt_token INT(10) NOT NULL DEFAULT 0,
+           completion_token INT(10) NOT NULL DEFAULT 0,
+           cost DECIMAL(20,8) NOT NULL DEFAULT 0 COMMENT 'spend in currency of pricing',
+           doc_ids TEXT NULL COMMENT 'rag documents used by answer, TEXT has no default value in mysql',
            
-           -- 嵌入索引：idx_records_user_id 和 idx_records_create_time
+           -- 嵌入索引：idx_records_user_id, idx_records_create_time 和 idx_records_session_id
            INDEX idx_records_user_id (user_id),
-           INDEX idx_records_create_time (create_time)
+           INDEX idx_records_create_time (create_time),
+           INDEX idx_records_session_id (session_id)
        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 	`,
 		// 3. rag_files 表 (无额外索引，仅PRIMARY KEY)
 		`CREATE TABLE IF NOT EXISTS rag_files (
           id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          kb VARCHAR(100) NOT NULL DEFAULT 'default' COMMENT 'knowledge base name',
           file_name VARCHAR(255) NOT NULL DEFAULT '',
           file_md5 VARCHAR(255) NOT NULL DEFAULT '',
           vector_id TEXT NOT NULL,
+          status VARCHAR(20) NOT NULL DEFAULT 'done' COMMENT 'pending indexing done failed',
+          error_msg VARCHAR(1024) NOT NULL DEFAULT '',
+          retry_times INT NOT NULL DEFAULT 0,
+          source VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'url or uploader of learned file',
           create_time INT(10) NOT NULL DEFAULT 0,
           update_time INT(10) NOT NULL DEFAULT 0,
           is_deleted INT(10) NOT NULL DEFAULT 0,
@@ -146,11 +304,191 @@ var (
           from_bot VARCHAR(255) NOT NULL DEFAULT '',
           type VARCHAR(255) NOT NULL DEFAULT '',
     	  create_by VARCHAR(255) NOT NULL DEFAULT ''
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 5. sessions 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS sessions (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          chat_key VARCHAR(255) NOT NULL DEFAULT '',
+          name VARCHAR(100) NOT NULL DEFAULT '',
+          is_active tinyint(1) NOT NULL DEFAULT 0 COMMENT '0:inactive 1:active',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          update_time INT(10) NOT NULL DEFAULT 0,
+          is_deleted INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_sessions_chat_key (chat_key)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 6. summaries 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS summaries (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          session_id VARCHAR(255) NOT NULL DEFAULT '',
+          content MEDIUMTEXT NOT NULL,
+          last_time INT(10) NOT NULL DEFAULT 0 COMMENT 'create time of the latest summarized conversation',
+          token INT(10) NOT NULL DEFAULT 0,
+          create_time INT(10) NOT NULL DEFAULT 0,
+          update_time INT(10) NOT NULL DEFAULT 0,
+          is_deleted INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_summaries_session_id (session_id)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 7. role_bindings 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS role_bindings (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          subject_type VARCHAR(20) NOT NULL DEFAULT '' COMMENT 'user or group',
+          subject_id VARCHAR(100) NOT NULL DEFAULT '',
+          role VARCHAR(100) NOT NULL DEFAULT '',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          update_time INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          UNIQUE INDEX uniq_role_bindings_subject (subject_type, subject_id, from_bot)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 8. vectors 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS vectors (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          space VARCHAR(255) NOT NULL DEFAULT '',
+          content MEDIUMTEXT NOT NULL,
+          metadata TEXT NOT NULL,
+          embedding MEDIUMBLOB NOT NULL COMMENT 'little endian float32 array',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_vectors_space (space)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 9. kb_bindings 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS kb_bindings (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          chat_key VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'platform:chat_id',
+          kb VARCHAR(100) NOT NULL DEFAULT '',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          update_time INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_kb_bindings_chat_key (chat_key)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 10. rag_chunks 表 (关键词检索)
+		`CREATE TABLE IF NOT EXISTS rag_chunks (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          kb VARCHAR(100) NOT NULL DEFAULT 'default',
+          file_name VARCHAR(255) NOT NULL DEFAULT '',
+          chunk_index INT NOT NULL DEFAULT 0,
+          content MEDIUMTEXT NOT NULL,
+          metadata TEXT NOT NULL,
+          embedding MEDIUMBLOB COMMENT 'little endian float32 array, kept for export',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_rag_chunks_kb_file (kb, file_name)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 11. task_runs 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS task_runs (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          user_id VARCHAR(100) NOT NULL DEFAULT '',
+          chat_id VARCHAR(255) NOT NULL DEFAULT '',
+          session_id VARCHAR(255) NOT NULL DEFAULT '',
+          content MEDIUMTEXT NOT NULL,
+          status VARCHAR(20) NOT NULL DEFAULT 'running' COMMENT 'running done failed cancelled',
+          plan MEDIUMTEXT NOT NULL COMMENT 'plan of the latest loop',
+          loop_num INT NOT NULL DEFAULT 0,
+          result MEDIUMTEXT NOT NULL,
+          token INT(10) NOT NULL DEFAULT 0,
+          error_msg VARCHAR(1024) NOT NULL DEFAULT '',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          update_time INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_task_runs_user_id (user_id)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 12. task_steps 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS task_steps (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          run_id INT NOT NULL DEFAULT 0,
+          loop_num INT NOT NULL DEFAULT 0,
+          step_id VARCHAR(100) NOT NULL DEFAULT '',
+          name VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'agent name',
+          description TEXT NOT NULL,
+          depends_on TEXT NOT NULL COMMENT 'json array of step ids',
+          status VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT 'pending running done failed',
+          result MEDIUMTEXT NOT NULL,
+          tool_calls MEDIUMTEXT NOT NULL COMMENT 'json array of tool calls',
+          token INT(10) NOT NULL DEFAULT 0,
+          error_msg VARCHAR(1024) NOT NULL DEFAULT '',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          update_time INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_task_steps_run_id (run_id)
+       ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	`,
+		// 13. mcp_audits 表 (嵌入索引)
+		`CREATE TABLE IF NOT EXISTS mcp_audits (
+          id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+          user_id VARCHAR(100) NOT NULL DEFAULT '',
+          chat_id VARCHAR(100) NOT NULL DEFAULT '',
+          server VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'mcp server name',
+          tool VARCHAR(255) NOT NULL DEFAULT '',
+          arguments MEDIUMTEXT NOT NULL COMMENT 'json arguments of tool call',
+          action VARCHAR(20) NOT NULL DEFAULT '' COMMENT 'deny approve',
+          decision VARCHAR(20) NOT NULL DEFAULT '' COMMENT 'denied pending approved rejected timeout cancelled',
+          decided_by VARCHAR(100) NOT NULL DEFAULT '',
+          create_time INT(10) NOT NULL DEFAULT 0,
+          update_time INT(10) NOT NULL DEFAULT 0,
+          from_bot VARCHAR(255) NOT NULL DEFAULT '',
+
+          INDEX idx_mcp_audits_user_id (user_id)
        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 	`,
 	}
 )
 
+// columnMigration column added to released table, CREATE TABLE IF NOT EXISTS doesn't add it to existing table.
+// mysql definition may be followed by other alter specifications such as index of the column.
+type columnMigration struct {
+	table  string
+	column string
+	sqlite string
+	mysql  string
+}
+
+var columnMigrations = []columnMigration{
+	{"records", "session_id", "VARCHAR(255) NOT NULL DEFAULT ''",
+		"VARCHAR(255) NOT NULL DEFAULT '', ADD INDEX idx_records_session_id (session_id)"},
+	{"records", "prompt_token", "INTEGER NOT NULL DEFAULT 0", "INT(10) NOT NULL DEFAULT 0"},
+	{"records", "completion_token", "INTEGER NOT NULL DEFAULT 0", "INT(10) NOT NULL DEFAULT 0"},
+	{"records", "cost", "REAL NOT NULL DEFAULT 0", "DECIMAL(20,8) NOT NULL DEFAULT 0 COMMENT 'spend in currency of pricing'"},
+	{"users", "cost", "REAL NOT NULL DEFAULT 0", "DECIMAL(20,8) NOT NULL DEFAULT 0 COMMENT 'spend in currency of pricing'"},
+	{"users", "plan", "VARCHAR(100) NOT NULL DEFAULT ''", "VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'quota plan name'"},
+	{"rag_files", "kb", "VARCHAR(100) NOT NULL DEFAULT 'default'",
+		"VARCHAR(100) NOT NULL DEFAULT 'default' COMMENT 'knowledge base name'"},
+	{"records", "doc_ids", "TEXT NOT NULL DEFAULT ''", "TEXT NULL COMMENT 'rag documents used by answer'"},
+	{"rag_files", "status", "VARCHAR(20) NOT NULL DEFAULT 'done'",
+		"VARCHAR(20) NOT NULL DEFAULT 'done' COMMENT 'pending indexing done failed'"},
+	{"rag_files", "error_msg", "VARCHAR(1024) NOT NULL DEFAULT ''", "VARCHAR(1024) NOT NULL DEFAULT ''"},
+	{"rag_files", "retry_times", "INTEGER NOT NULL DEFAULT '0'", "INT NOT NULL DEFAULT 0"},
+	{"rag_files", "source", "VARCHAR(1024) NOT NULL DEFAULT ''",
+		"VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'url or uploader of learned file'"},
+}
+
+// indexMigration index added to released mysql table, sqlite creates it by CREATE INDEX IF NOT EXISTS.
+type indexMigration struct {
+	table      string
+	index      string
+	definition string
+}
+
+var indexMigrations = []indexMigration{
+	{"role_bindings", "uniq_role_bindings_subject", "UNIQUE INDEX uniq_role_bindings_subject (subject_type, subject_id, from_bot)"},
+}
+
 var (
 	DB *sql.DB
 )
@@ -160,6 +498,11 @@ type DailyStat struct {
 	NewCount int    `json:"new_count"`
 }
 
+type DailyCost struct {
+	Date string  `json:"date"`
+	Cost float64 `json:"cost"`
+}
+
 func InitTable() {
 	var err error
 	if _, err = os.Stat(botUtils.GetAbsPath("data")); os.IsNotExist(err) {
@@ -191,12 +534,22 @@ func InitTable() {
 		}
 	}
 
-	InsertRecord(context.Background())
+	InitConversationStore()
 
 	logger.Info("db initialize successfully")
 }
 
 func initializeMySQLTables(db *sql.DB) error {
+	err := migrateColumns(db, "mysql")
+	if err != nil {
+		return err
+	}
+
+	err = migrateMySQLIndexes(db)
+	if err != nil {
+		return err
+	}
+
 	for i, sqlStr := range mysqlInitializeSQLs {
 		_, err := db.Exec(sqlStr)
 		if err != nil {
@@ -210,6 +563,12 @@ func initializeMySQLTables(db *sql.DB) error {
 
 // initializeSqlite3Table check table exist or not.
 func initializeSqlite3Table(db *sql.DB) error {
+	// columns are added before create sqls, which create indexes on them
+	err := migrateColumns(db, "sqlite3")
+	if err != nil {
+		return err
+	}
+
 	for tableName, createSQL := range sqlite3TableSQLs {
 		_, err := db.Exec(createSQL)
 		if err != nil {
@@ -220,3 +579,94 @@ func initializeSqlite3Table(db *sql.DB) error {
 
 	return nil
 }
+
+// migrateColumns add missing columns into existing tables, tables not exist are created with them later.
+func migrateColumns(db *sql.DB, dbType string) error {
+	for _, m := range columnMigrations {
+		columns, err := tableColumns(db, dbType, m.table)
+		if err != nil {
+			return fmt.Errorf("get columns of table %s fail: %v", m.table, err)
+		}
+		if len(columns) == 0 || columns[m.column] {
+			continue
+		}
+
+		definition := m.sqlite
+		if dbType == "mysql" {
+			definition = m.mysql
+		}
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, definition))
+		if err != nil {
+			return fmt.Errorf("add column %s.%s fail: %v", m.table, m.column, err)
+		}
+		logger.Info("add column", "table", m.table, "column", m.column)
+	}
+
+	return nil
+}
+
+// migrateMySQLIndexes add missing indexes into existing tables, tables not exist are created with them later.
+func migrateMySQLIndexes(db *sql.DB) error {
+	for _, m := range indexMigrations {
+		columns, err := tableColumns(db, "mysql", m.table)
+		if err != nil {
+			return fmt.Errorf("get columns of table %s fail: %v", m.table, err)
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		var count int
+		err = db.QueryRow(`SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`,
+			m.table, m.index).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("get index %s of table %s fail: %v", m.index, m.table, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD %s", m.table, m.definition))
+		if err != nil {
+			return fmt.Errorf("add index %s.%s fail: %v", m.table, m.index, err)
+		}
+		logger.Info("add index", "table", m.table, "index", m.index)
+	}
+
+	return nil
+}
+
+// tableColumns column names of table, empty if table doesn't exist.
+func tableColumns(db *sql.DB, dbType, table string) (map[string]bool, error) {
+	query := "SELECT name FROM pragma_table_info(?)"
+	if dbType == "mysql" {
+		query = "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	}
+
+	rows, err := db.Query(query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// likeEscaper escape wildcards of LIKE pattern with backslash, use it with likeEscape.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeEscape ESCAPE clause following LIKE, backslash is also the escape char of mysql string literal.
+func likeEscape() string {
+	if conf.BaseConfInfo.DBType == "mysql" {
+		return ` ESCAPE '\\'`
+	}
+	return ` ESCAPE '\'`
+}
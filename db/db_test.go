@@ -31,3 +31,46 @@ func TestInitializeSqlite3Table(t *testing.T) {
 		t.Errorf("Expected table name 'users', got '%s'", name)
 	}
 }
+
+func TestMigrateColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open sqlite memory DB: %v", err)
+	}
+	defer db.Close()
+
+	// tables created by old version
+	oldSQLs := []string{
+		`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id varchar(100) NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0', token INTEGER NOT NULL DEFAULT '0', avail_token INTEGER NOT NULL DEFAULT 0,
+			create_time INTEGER NOT NULL DEFAULT '0', from_bot VARCHAR(255) NOT NULL DEFAULT '', llm_config TEXT NOT NULL)`,
+		`CREATE TABLE records (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id varchar(100) NOT NULL DEFAULT '0',
+			question TEXT NOT NULL, answer TEXT NOT NULL, content TEXT NOT NULL, create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0', is_deleted INTEGER NOT NULL DEFAULT '0', token INTEGER NOT NULL DEFAULT 0,
+			mode VARCHAR(100) NOT NULL DEFAULT '', record_type INTEGER NOT NULL DEFAULT 0, from_bot VARCHAR(255) NOT NULL DEFAULT '')`,
+		`CREATE TABLE rag_files (id INTEGER PRIMARY KEY AUTOINCREMENT, file_name VARCHAR(255) NOT NULL DEFAULT '',
+			file_md5 VARCHAR(255) NOT NULL DEFAULT '', vector_id TEXT NOT NULL DEFAULT '', create_time INTEGER NOT NULL DEFAULT '0',
+			update_time INTEGER NOT NULL DEFAULT '0', is_deleted INTEGER NOT NULL DEFAULT '0', from_bot VARCHAR(255) NOT NULL DEFAULT '')`,
+	}
+	for _, oldSQL := range oldSQLs {
+		if _, err = db.Exec(oldSQL); err != nil {
+			t.Fatalf("create old table fail: %v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = initializeSqlite3Table(db); err != nil {
+			t.Fatalf("initializeSqlite3Table failed: %v", err)
+		}
+	}
+
+	for _, m := range columnMigrations {
+		columns, err := tableColumns(db, "sqlite3", m.table)
+		if err != nil {
+			t.Fatalf("tableColumns failed: %v", err)
+		}
+		if !columns[m.column] {
+			t.Errorf("column %s.%s is not added", m.table, m.column)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+// KBBinding cache chat key -> knowledge base name
+var KBBinding = sync.Map{}
+
+// GetKBChatKey knowledge base is bound to a chat, all members of a group share it.
+func GetKBChatKey(platform, chatId string) string {
+	return fmt.Sprintf("%s:%s", platform, chatId)
+}
+
+// GetKBBinding get knowledge base bound to chat, empty if not bound.
+func GetKBBinding(chatKey string) (string, error) {
+	if kb, ok := KBBinding.Load(chatKey); ok {
+		return kb.(string), nil
+	}
+
+	var kb string
+	err := DB.QueryRow(`SELECT kb FROM kb_bindings WHERE chat_key = ? and from_bot = ?`,
+		chatKey, conf.BaseConfInfo.BotName).Scan(&kb)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	KBBinding.Store(chatKey, kb)
+	return kb, nil
+}
+
+// UpdateKBBinding bind knowledge base to chat.
+func UpdateKBBinding(chatKey, kb string) error {
+	now := time.Now().Unix()
+	result, err := DB.Exec(`UPDATE kb_bindings SET kb = ?, update_time = ? WHERE chat_key = ? and from_bot = ?`,
+		kb, now, chatKey, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		_, err = DB.Exec(`INSERT INTO kb_bindings (chat_key, kb, create_time, update_time, from_bot) VALUES (?, ?, ?, ?, ?)`,
+			chatKey, kb, now, now, conf.BaseConfInfo.BotName)
+		if err != nil {
+			return err
+		}
+	}
+
+	KBBinding.Store(chatKey, kb)
+	return nil
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKBBinding(t *testing.T) {
+	chatKey := GetKBChatKey("telegram", "-100")
+
+	kb, err := GetKBBinding(chatKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "", kb)
+
+	err = UpdateKBBinding(chatKey, "support")
+	assert.NoError(t, err)
+
+	KBBinding.Delete(chatKey)
+	kb, err = GetKBBinding(chatKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "support", kb)
+
+	err = UpdateKBBinding(chatKey, "engineering")
+	assert.NoError(t, err)
+
+	KBBinding.Delete(chatKey)
+	kb, err = GetKBBinding(chatKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "engineering", kb)
+}
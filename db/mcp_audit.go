@@ -0,0 +1,112 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+const (
+	McpAuditDenied    = "denied"
+	McpAuditPending   = "pending"
+	McpAuditApproved  = "approved"
+	McpAuditRejected  = "rejected"
+	McpAuditTimeout   = "timeout"
+	McpAuditCancelled = "cancelled"
+)
+
+// McpAudit tool call which is denied or needs approval by mcp policy, arguments are json of the call.
+type McpAudit struct {
+	ID         int64  `json:"id"`
+	UserId     string `json:"user_id"`
+	ChatId     string `json:"chat_id"`
+	Server     string `json:"server"`
+	Tool       string `json:"tool"`
+	Arguments  string `json:"arguments"`
+	Action     string `json:"action"`
+	Decision   string `json:"decision"`
+	DecidedBy  string `json:"decided_by"`
+	CreateTime int64  `json:"create_time"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+func InsertMcpAudit(audit *McpAudit) (int64, error) {
+	now := time.Now().Unix()
+	result, err := DB.Exec(`INSERT INTO mcp_audits (user_id, chat_id, server, tool, arguments, action, decision, decided_by,
+                        create_time, update_time, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		audit.UserId, audit.ChatId, audit.Server, audit.Tool, audit.Arguments, audit.Action, audit.Decision, audit.DecidedBy,
+		now, now, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return 0, fmt.Errorf("insert mcp audit error: %w", err)
+	}
+
+	audit.ID, err = result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("get last insert id error: %w", err)
+	}
+	audit.CreateTime, audit.UpdateTime = now, now
+	return audit.ID, nil
+}
+
+// UpdateMcpAudit save decision of approval and who made it.
+func UpdateMcpAudit(audit *McpAudit) error {
+	audit.UpdateTime = time.Now().Unix()
+	_, err := DB.Exec(`UPDATE mcp_audits set decision = ?, decided_by = ?, update_time = ? WHERE id = ?`,
+		audit.Decision, audit.DecidedBy, audit.UpdateTime, audit.ID)
+	return err
+}
+
+// GetMcpAuditsByPage get audits from the latest one, filtered by user and decision if not empty.
+func GetMcpAuditsByPage(page, pageSize int, userId, decision string) ([]*McpAudit, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	whereSQL, args := mcpAuditWhere(userId, decision)
+	listSQL := fmt.Sprintf(`SELECT id, user_id, chat_id, server, tool, arguments, action, decision, decided_by, create_time, update_time
+		FROM mcp_audits %s ORDER BY id DESC LIMIT ? OFFSET ?`, whereSQL)
+	args = append(args, pageSize, offset)
+
+	rows, err := DB.Query(listSQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query mcp audits by page error: %w", err)
+	}
+	defer rows.Close()
+
+	audits := make([]*McpAudit, 0)
+	for rows.Next() {
+		audit := new(McpAudit)
+		if err := rows.Scan(&audit.ID, &audit.UserId, &audit.ChatId, &audit.Server, &audit.Tool, &audit.Arguments, &audit.Action,
+			&audit.Decision, &audit.DecidedBy, &audit.CreateTime, &audit.UpdateTime); err != nil {
+			return nil, fmt.Errorf("scan mcp audit row error: %w", err)
+		}
+		audits = append(audits, audit)
+	}
+	return audits, rows.Err()
+}
+
+func GetMcpAuditsCount(userId, decision string) (int, error) {
+	whereSQL, args := mcpAuditWhere(userId, decision)
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM mcp_audits "+whereSQL, args...).Scan(&count)
+	return count, err
+}
+
+func mcpAuditWhere(userId, decision string) (string, []interface{}) {
+	whereSQL := "WHERE from_bot = ?"
+	args := []interface{}{conf.BaseConfInfo.BotName}
+	if userId != "" {
+		whereSQL += " AND user_id = ?"
+		args = append(args, userId)
+	}
+	if decision != "" {
+		whereSQL += " AND decision = ?"
+		args = append(args, decision)
+	}
+	return whereSQL, args
+}
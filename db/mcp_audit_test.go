@@ -0,0 +1,30 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMcpAudit(t *testing.T) {
+	audit := &McpAudit{UserId: "audit_user", ChatId: "1", Server: "mysql", Tool: "mysql_execute",
+		Arguments: `{"sql":"drop table user"}`, Action: "approve", Decision: McpAuditPending}
+	id, err := InsertMcpAudit(audit)
+	assert.NoError(t, err)
+	assert.NotZero(t, id)
+
+	audit.Decision = McpAuditRejected
+	audit.DecidedBy = "audit_user"
+	assert.NoError(t, UpdateMcpAudit(audit))
+
+	audits, err := GetMcpAuditsByPage(1, 10, "audit_user", McpAuditRejected)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, audits)
+	assert.Equal(t, id, audits[0].ID)
+	assert.Equal(t, `{"sql":"drop table user"}`, audits[0].Arguments)
+	assert.Equal(t, "audit_user", audits[0].DecidedBy)
+
+	count, err := GetMcpAuditsCount("audit_user", McpAuditRejected)
+	assert.NoError(t, err)
+	assert.Equal(t, len(audits), count)
+}
@@ -5,13 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
-	"unicode"
 
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
 )
 
 type MsgRecordInfo struct {
@@ -20,8 +19,9 @@ type MsgRecordInfo struct {
 }
 
 type UserRecords struct {
-	UserId  string `json:"user_id"`
-	Records []*AQ  `json:"records"`
+	UserId    string `json:"user_id"`
+	SessionId string `json:"session_id"`
+	Records   []*AQ  `json:"records"`
 }
 
 type AQ struct {
@@ -34,42 +34,34 @@ type AQ struct {
 }
 
 type Record struct {
-	ID         int64  `json:"id"`
-	UserId     string `json:"user_id"`
-	Question   string `json:"question"`
-	Answer     string `json:"answer"`
-	Content    string `json:"content"`
-	Token      int    `json:"token"`
-	IsDeleted  int    `json:"is_deleted"`
-	CreateTime int64  `json:"create_time"`
-	RecordType int    `json:"record_type"`
-	Mode       string `json:"mode"`
-	UpdateTime int64  `json:"update_time"`
+	ID              int64   `json:"id"`
+	UserId          string  `json:"user_id"`
+	Question        string  `json:"question"`
+	Answer          string  `json:"answer"`
+	Content         string  `json:"content"`
+	Token           int     `json:"token"`
+	IsDeleted       int     `json:"is_deleted"`
+	CreateTime      int64   `json:"create_time"`
+	RecordType      int     `json:"record_type"`
+	Mode            string  `json:"mode"`
+	UpdateTime      int64   `json:"update_time"`
+	SessionId       string  `json:"session_id"`
+	PromptToken     int     `json:"prompt_token"`
+	CompletionToken int     `json:"completion_token"`
+	Cost            float64 `json:"cost"`
+	DocIds          string  `json:"doc_ids"` // rag documents used by answer, comma separated
 }
 
-var MsgRecord = sync.Map{}
-
-func InsertMsgRecord(ctx context.Context, userId string, aq *AQ, insertDB bool) {
-	var msgRecord *MsgRecordInfo
-	msgRecordInter, ok := MsgRecord.Load(userId)
-	if !ok {
-		msgRecord = &MsgRecordInfo{
-			AQs:        []*AQ{aq},
-			updateTime: time.Now().Unix(),
-		}
-	} else {
-		msgRecord = msgRecordInter.(*MsgRecordInfo)
-		msgRecord.AQs = append(msgRecord.AQs, aq)
-		if len(msgRecord.AQs) > conf.BaseConfInfo.MaxQAPair {
-			msgRecord.AQs = msgRecord.AQs[1:]
-		}
-		msgRecord.updateTime = time.Now().Unix()
+func InsertMsgRecord(ctx context.Context, sessionId, userId string, aq *AQ, insertDB bool) {
+	err := ConvStore.Append(ctx, sessionId, aq)
+	if err != nil {
+		logger.ErrorCtx(ctx, "append conversation fail", "sessionId", sessionId, "err", err)
 	}
-	MsgRecord.Store(userId, msgRecord)
 
 	if insertDB {
 		go InsertRecordInfo(ctx, &Record{
 			UserId:     userId,
+			SessionId:  sessionId,
 			Question:   aq.Question,
 			Answer:     aq.Answer,
 			Content:    aq.Content,
@@ -80,54 +72,41 @@ func InsertMsgRecord(ctx context.Context, userId string, aq *AQ, insertDB bool)
 	}
 }
 
-func GetMsgRecord(userId string) *MsgRecordInfo {
-	msgRecord, ok := MsgRecord.Load(userId)
-	if !ok {
+func GetMsgRecord(ctx context.Context, sessionId string) *MsgRecordInfo {
+	msgRecord, err := ConvStore.Get(ctx, sessionId)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get conversation fail", "sessionId", sessionId, "err", err)
 		return nil
 	}
-	return msgRecord.(*MsgRecordInfo)
+	if msgRecord == nil || len(msgRecord.AQs) == 0 {
+		return nil
+	}
+	return msgRecord
 }
 
-func DeleteMsgRecord(ctx context.Context, userId string) {
-	MsgRecord.Delete(userId)
-	err := DeleteRecord(userId)
+func DeleteMsgRecord(ctx context.Context, sessionId string) {
+	err := ConvStore.Delete(ctx, sessionId)
 	if err != nil {
-		logger.ErrorCtx(ctx, "Error deleting record", "err", err)
+		logger.ErrorCtx(ctx, "delete conversation fail", "sessionId", sessionId, "err", err)
 	}
-}
-
-func InsertRecord(ctx context.Context) {
-	users, err := GetUsers()
+	err = DeleteRecordBySessionId(sessionId)
 	if err != nil {
-		logger.ErrorCtx(ctx, "InsertRecord GetUsers err", "err", err)
+		logger.ErrorCtx(ctx, "Error deleting record", "err", err)
 	}
-
-	for _, user := range users {
-		records, err := getRecordsByUserId(user.UserId)
-		if err != nil {
-			logger.Error("InsertRecord GetUsers err", "err", err)
-		}
-		for i := len(records) - 1; i >= 0; i-- {
-			record := records[i]
-			InsertMsgRecord(ctx, user.UserId, &AQ{
-				Question:   record.Question,
-				Answer:     record.Answer,
-				Content:    record.Content,
-				CreateTime: record.CreateTime,
-			}, false)
-		}
+	err = DeleteSummary(sessionId)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Error deleting summary", "err", err)
 	}
-
 }
 
-// getRecordsByUserId get latest 10 records by user_id
-func getRecordsByUserId(userId string) ([]Record, error) {
+// getRecordsBySessionId get latest 10 records by session_id
+func getRecordsBySessionId(sessionId string) ([]Record, error) {
 	// construct SQL statements
-	query := fmt.Sprintf("SELECT id, user_id, question, answer, content, mode, create_time FROM records WHERE user_id =  ? " +
+	query := fmt.Sprintf("SELECT id, user_id, question, answer, content, mode, create_time FROM records WHERE session_id =  ? " +
 		"and is_deleted = 0 and record_type = 0 order by create_time desc limit ?")
 
 	// execute query
-	rows, err := DB.Query(query, userId, conf.BaseConfInfo.MaxQAPair)
+	rows, err := DB.Query(query, sessionId, conf.BaseConfInfo.MaxQAPair)
 	if err != nil {
 		return nil, err
 	}
@@ -148,8 +127,9 @@ func getRecordsByUserId(userId string) ([]Record, error) {
 
 // InsertRecordInfo insert record
 func InsertRecordInfo(ctx context.Context, record *Record) (int64, error) {
-	query := `INSERT INTO records (user_id, question, answer, content, token, create_time, is_deleted, record_type, mode, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	result, err := DB.Exec(query, record.UserId, record.Question, record.Answer, record.Content, record.Token, time.Now().Unix(), record.IsDeleted, record.RecordType, record.Mode, conf.BaseConfInfo.BotName)
+	query := `INSERT INTO records (user_id, question, answer, content, token, create_time, is_deleted, record_type, mode, from_bot, session_id, prompt_token, completion_token, cost, doc_ids) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := DB.Exec(query, record.UserId, record.Question, record.Answer, record.Content, record.Token, time.Now().Unix(), record.IsDeleted, record.RecordType, record.Mode, conf.BaseConfInfo.BotName, record.SessionId,
+		record.PromptToken, record.CompletionToken, record.Cost, record.DocIds)
 	if err != nil {
 		logger.ErrorCtx(ctx, "insertRecord err", "err", err)
 		return 0, err
@@ -160,6 +140,11 @@ func InsertRecordInfo(ctx context.Context, record *Record) (int64, error) {
 		logger.ErrorCtx(ctx, "Error update token by user", "err", err)
 	}
 
+	err = AddCost(record.UserId, record.Cost)
+	if err != nil {
+		logger.ErrorCtx(ctx, "Error update cost by user", "err", err)
+	}
+
 	return result.LastInsertId()
 }
 
@@ -170,6 +155,13 @@ func DeleteRecord(userId string) error {
 	return err
 }
 
+// DeleteRecordBySessionId delete record of one session
+func DeleteRecordBySessionId(sessionId string) error {
+	query := `UPDATE records set is_deleted = 1, update_time = ? WHERE session_id = ?`
+	_, err := DB.Exec(query, time.Now().Unix(), sessionId)
+	return err
+}
+
 func GetTokenByUserIdAndTime(userId string, start, end int64) (int, error) {
 	querySQL := `SELECT sum(token) FROM records WHERE user_id = ? and create_time >= ? and create_time <= ?`
 	row := DB.QueryRow(querySQL, userId, start, end)
@@ -187,6 +179,28 @@ func GetTokenByUserIdAndTime(userId string, start, end int64) (int, error) {
 	return user.Token, nil
 }
 
+// GetCostByUserIdAndTime get spend of user between start and end.
+func GetCostByUserIdAndTime(userId string, start, end int64) (float64, error) {
+	querySQL := `SELECT COALESCE(sum(cost), 0) FROM records WHERE user_id = ? and create_time >= ? and create_time <= ?`
+
+	var cost float64
+	err := DB.QueryRow(querySQL, userId, start, end).Scan(&cost)
+	if err != nil {
+		return 0, err
+	}
+	return cost, nil
+}
+
+// GetTotalCost get spend of all users.
+func GetTotalCost() (float64, error) {
+	var cost float64
+	err := DB.QueryRow(`SELECT COALESCE(sum(cost), 0) FROM users`).Scan(&cost)
+	if err != nil {
+		return 0, err
+	}
+	return cost, nil
+}
+
 func GetLastImageRecord(userId string) (*Record, error) {
 	query := fmt.Sprintf("SELECT id, user_id, question, answer, content FROM records WHERE user_id =  ? and record_type = ? and is_deleted = 0 order by id desc")
 
@@ -262,7 +276,8 @@ func GetRecordList(userId string, page, pageSize, isDeleted int, recordType stri
 	offset := (page - 1) * pageSize
 
 	query := `
-		SELECT id, user_id, question, answer, content, token, is_deleted, create_time, mode, update_time
+		SELECT id, user_id, question, answer, content, token, is_deleted, create_time, mode, update_time,
+		       prompt_token, completion_token, cost, COALESCE(doc_ids, '')
 		FROM records`
 	var args []interface{}
 	var conditions []string
@@ -306,7 +321,8 @@ func GetRecordList(userId string, page, pageSize, isDeleted int, recordType stri
 	var records []Record
 	for rows.Next() {
 		var r Record
-		if err := rows.Scan(&r.ID, &r.UserId, &r.Question, &r.Answer, &r.Content, &r.Token, &r.IsDeleted, &r.CreateTime, &r.Mode, &r.UpdateTime); err != nil {
+		if err := rows.Scan(&r.ID, &r.UserId, &r.Question, &r.Answer, &r.Content, &r.Token, &r.IsDeleted, &r.CreateTime, &r.Mode, &r.UpdateTime,
+			&r.PromptToken, &r.CompletionToken, &r.Cost, &r.DocIds); err != nil {
 			return nil, err
 		}
 		records = append(records, r)
@@ -374,9 +390,71 @@ func GetDailyNewRecords(days int) ([]DailyStat, error) {
 	return stats, nil
 }
 
+// GetDailyCost get spend of records grouped by time, interval is the same as GetDailyNewRecords.
+func GetDailyCost(days int) ([]DailyCost, error) {
+	var query string
+	var intervalSeconds int64
+
+	if days <= 3 {
+		intervalSeconds = 3600
+	} else if days <= 7 {
+		intervalSeconds = 3 * 3600
+	} else {
+		intervalSeconds = 86400
+	}
+
+	if conf.BaseConfInfo.DBType == "mysql" {
+		query = `
+			SELECT
+				FLOOR(create_time / ?) * ? AS time_group,
+				COALESCE(SUM(cost), 0) AS cost
+			FROM records
+			WHERE create_time >= UNIX_TIMESTAMP(DATE_SUB(NOW(), INTERVAL ? DAY))
+			GROUP BY time_group
+			ORDER BY time_group DESC;
+		`
+	} else if conf.BaseConfInfo.DBType == "sqlite3" {
+		query = `
+			SELECT
+				(create_time / ?) * ? AS time_group,
+				COALESCE(SUM(cost), 0) AS cost
+			FROM records
+			WHERE create_time >= strftime('%s', date('now', ? || ' days'))
+			GROUP BY time_group
+			ORDER BY time_group DESC;
+		`
+	} else {
+		return nil, fmt.Errorf("unsupported DBType: %s", conf.BaseConfInfo.DBType)
+	}
+
+	var rows *sql.Rows
+	var err error
+	if conf.BaseConfInfo.DBType == "sqlite3" {
+		rows, err = DB.Query(query, intervalSeconds, intervalSeconds, -days)
+	} else {
+		rows, err = DB.Query(query, intervalSeconds, intervalSeconds, days)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []DailyCost
+	for rows.Next() {
+		var stat DailyCost
+		if err := rows.Scan(&stat.Date, &stat.Cost); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
+
 func UpdateRecordInfo(record *Record) error {
 	query := `UPDATE records
-			  SET answer = ?, token = token + ?, mode = ?, update_time = ?
+			  SET answer = ?, token = token + ?, mode = ?, update_time = ?,
+			      prompt_token = prompt_token + ?, completion_token = completion_token + ?, cost = cost + ?, doc_ids = ?
 			  WHERE id = ?`
 
 	_, err := DB.Exec(query,
@@ -384,6 +462,10 @@ func UpdateRecordInfo(record *Record) error {
 		record.Token,
 		record.Mode,
 		time.Now().Unix(),
+		record.PromptToken,
+		record.CompletionToken,
+		record.Cost,
+		record.DocIds,
 		record.ID,
 	)
 	if err != nil {
@@ -397,15 +479,21 @@ func UpdateRecordInfo(record *Record) error {
 		return err
 	}
 
+	err = AddCost(record.UserId, record.Cost)
+	if err != nil {
+		logger.Error("add cost fail", "err", err)
+		return err
+	}
+
 	return nil
 }
 
-func AddRecordToken(ctx context.Context, recordID int64, userId string, token int) error {
+func AddRecordToken(ctx context.Context, recordID int64, userId string, token int, cost float64) error {
 	query := `UPDATE records
-			  SET token = token + ?, update_time = ?
+			  SET token = token + ?, cost = cost + ?, update_time = ?
 			  WHERE id = ?`
 
-	_, err := DB.Exec(query, token, time.Now().Unix(), recordID)
+	_, err := DB.Exec(query, token, cost, time.Now().Unix(), recordID)
 	if err != nil {
 		logger.ErrorCtx(ctx, "addRecordToken err", "err", err)
 		return err
@@ -417,6 +505,12 @@ func AddRecordToken(ctx context.Context, recordID int64, userId string, token in
 		return err
 	}
 
+	err = AddCost(userId, cost)
+	if err != nil {
+		logger.ErrorCtx(ctx, "add cost fail", "err", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -436,20 +530,10 @@ func AddRecordContent(recordID int64, content string) error {
 
 // EstimateTokens calculate token
 func EstimateTokens(text string) int {
-	count := 0
-	for _, r := range text {
-		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
-			count++
-		} else if unicode.IsSpace(r) {
-			continue
-		} else {
-			count++
-		}
-	}
-	return count
+	return provider.EstimateTokens(text)
 }
 
-func FilterByMaxContextFromLatest(aqs []*AQ, maxContext int) []*AQ {
+func FilterByMaxContextFromLatest(aqs []*AQ, maxContext int, countTokens func(text string) int) []*AQ {
 	n := len(aqs)
 	if n == 0 {
 		return nil
@@ -460,7 +544,7 @@ func FilterByMaxContextFromLatest(aqs []*AQ, maxContext int) []*AQ {
 
 	for i := n - 1; i >= 0; i-- {
 		totalText := aqs[i].Question + " " + aqs[i].Answer
-		tokens := EstimateTokens(totalText)
+		tokens := countTokens(totalText)
 
 		if cumulative+tokens > maxContext {
 			break
@@ -482,8 +566,8 @@ func FilterByMaxContextFromLatest(aqs []*AQ, maxContext int) []*AQ {
 func InsertUserRecords(ur *UserRecords) error {
 	query := `
 		INSERT INTO records (
-			user_id, question, answer, content, token, create_time, is_deleted, record_type, mode, from_bot
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			user_id, question, answer, content, token, create_time, is_deleted, record_type, mode, from_bot, session_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now().Unix()
@@ -500,6 +584,7 @@ func InsertUserRecords(ur *UserRecords) error {
 			param.TextRecordType,
 			"manual",
 			"",
+			ur.SessionId,
 		)
 		if err != nil {
 			return err
@@ -507,3 +592,36 @@ func InsertUserRecords(ur *UserRecords) error {
 	}
 	return nil
 }
+
+// SearchRecords get latest text records of user whose question or answer contains keyword, deleted records are skipped.
+func SearchRecords(userId, keyword string, limit int) ([]Record, error) {
+	if limit < 1 {
+		limit = 10
+	}
+
+	query := `SELECT id, user_id, question, answer, create_time, mode FROM records
+		WHERE user_id = ? AND is_deleted = 0 AND record_type IN (?, ?)`
+	args := []interface{}{userId, param.TextRecordType, param.TalkRecordType}
+	if keyword != "" {
+		query += " AND (question LIKE ? OR answer LIKE ?)"
+		args = append(args, "%"+keyword+"%", "%"+keyword+"%")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.ID, &r.UserId, &r.Question, &r.Answer, &r.CreateTime, &r.Mode); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"os"
 	"strconv"
-	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/yincongcyincong/MuseBot/conf"
@@ -26,12 +26,13 @@ func setup() {
 
 func TestInsertMsgRecord(t *testing.T) {
 	userId := "1"
-	MsgRecord = sync.Map{} // 清理数据
+	sessionId := GetSessionId(GetChatKey("telegram", userId, userId), "default")
+	ConvStore = NewMemoryConversationStore() // 清理数据
 
 	aq := &AQ{Question: "What is Go?", Answer: "A programming language."}
-	InsertMsgRecord(context.Background(), userId, aq, false)
+	InsertMsgRecord(context.Background(), sessionId, userId, aq, false)
 
-	record := GetMsgRecord(userId)
+	record := GetMsgRecord(context.Background(), sessionId)
 	assert.NotNil(t, record, "Record should not be nil")
 	assert.Equal(t, 1, len(record.AQs), "Should have 1 AQ pair")
 	assert.Equal(t, "What is Go?", record.AQs[0].Question)
@@ -39,37 +40,57 @@ func TestInsertMsgRecord(t *testing.T) {
 
 func TestInsertMsgRecord_ExceedLimit(t *testing.T) {
 	userId := "1"
-	MsgRecord = sync.Map{}
+	sessionId := GetSessionId(GetChatKey("telegram", userId, userId), "default")
+	ConvStore = NewMemoryConversationStore()
 
 	for i := 0; i < conf.BaseConfInfo.MaxQAPair+5; i++ {
 		aq := &AQ{Question: "Q" + strconv.Itoa(i), Answer: "A" + strconv.Itoa(i)}
-		InsertMsgRecord(context.Background(), userId, aq, false)
+		InsertMsgRecord(context.Background(), sessionId, userId, aq, false)
 	}
 
-	record := GetMsgRecord(userId)
+	record := GetMsgRecord(context.Background(), sessionId)
 	assert.NotNil(t, record, "Record should not be nil")
 	assert.Equal(t, conf.BaseConfInfo.MaxQAPair, len(record.AQs), "Should keep max limit AQ pairs")
 }
 
 func TestDeleteMsgRecord(t *testing.T) {
 	userId := "1"
-	MsgRecord = sync.Map{} // 清理数据
+	sessionId := GetSessionId(GetChatKey("telegram", userId, userId), "default")
+	ConvStore = NewMemoryConversationStore() // 清理数据
 
 	aq := &AQ{Question: "Test Q", Answer: "Test A"}
-	InsertMsgRecord(context.Background(), userId, aq, false)
-	DeleteMsgRecord(context.Background(), userId)
+	InsertMsgRecord(context.Background(), sessionId, userId, aq, false)
+	DeleteMsgRecord(context.Background(), sessionId)
 
-	record := GetMsgRecord(userId)
+	record := GetMsgRecord(context.Background(), sessionId)
 	assert.Nil(t, record, "Record should be deleted")
 }
 
+func TestMsgRecord_IsolatedBySession(t *testing.T) {
+	userId := "1"
+	dmSession := GetSessionId(GetChatKey("telegram", userId, userId), "default")
+	groupSession := GetSessionId(GetChatKey("slack", "C001", userId), "default")
+	ConvStore = NewMemoryConversationStore() // 清理数据
+
+	InsertMsgRecord(context.Background(), dmSession, userId, &AQ{Question: "DM Q", Answer: "DM A"}, false)
+	InsertMsgRecord(context.Background(), groupSession, userId, &AQ{Question: "Group Q", Answer: "Group A"}, false)
+	DeleteMsgRecord(context.Background(), groupSession)
+
+	assert.Nil(t, GetMsgRecord(context.Background(), groupSession), "Group record should be deleted")
+	record := GetMsgRecord(context.Background(), dmSession)
+	assert.NotNil(t, record, "DM record should be kept")
+	assert.Equal(t, "DM Q", record.AQs[0].Question)
+}
+
 func TestInsertRecordInfoAndGetRecords(t *testing.T) {
 
 	userId := "12345"
+	sessionId := GetSessionId(GetChatKey("telegram", userId, userId), "default")
 	InsertUser(userId, "default")
 
 	record := &Record{
 		UserId:    userId,
+		SessionId: sessionId,
 		Question:  "What is AI?",
 		Answer:    "AI is Artificial Intelligence.",
 		Content:   "extra",
@@ -78,9 +99,9 @@ func TestInsertRecordInfoAndGetRecords(t *testing.T) {
 	}
 	InsertRecordInfo(context.Background(), record)
 
-	records, err := getRecordsByUserId(userId)
+	records, err := getRecordsBySessionId(sessionId)
 	if err != nil {
-		t.Fatalf("getRecordsByUserId failed: %v", err)
+		t.Fatalf("getRecordsBySessionId failed: %v", err)
 	}
 	if len(records) != 1 {
 		t.Fatalf("expected 1 record, got %d", len(records))
@@ -89,7 +110,7 @@ func TestInsertRecordInfoAndGetRecords(t *testing.T) {
 		t.Errorf("unexpected question: %s", records[0].Question)
 	}
 
-	DeleteMsgRecord(context.Background(), userId)
+	DeleteMsgRecord(context.Background(), sessionId)
 }
 
 func TestDeleteRecord(t *testing.T) {
@@ -126,3 +147,84 @@ func TestDeleteRecord(t *testing.T) {
 		}
 	}
 }
+
+func TestRecordCost(t *testing.T) {
+	userId := "cost_user"
+	_, err := InsertUser(userId, "")
+	assert.NoError(t, err)
+
+	id, err := InsertRecordInfo(context.Background(), &Record{
+		UserId:          userId,
+		Question:        "q",
+		Answer:          "a",
+		Token:           30,
+		PromptToken:     10,
+		CompletionToken: 20,
+		Cost:            0.5,
+	})
+	assert.NoError(t, err)
+
+	err = UpdateRecordInfo(&Record{ID: id, UserId: userId, Answer: "a2", PromptToken: 1, CompletionToken: 2, Cost: 0.25})
+	assert.NoError(t, err)
+	err = AddRecordToken(context.Background(), id, userId, 100, 0.25)
+	assert.NoError(t, err)
+
+	records, err := GetRecordList(userId, 1, 10, -1, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 11, records[0].PromptToken)
+	assert.Equal(t, 22, records[0].CompletionToken)
+	assert.InDelta(t, 1.0, records[0].Cost, 1e-9)
+
+	user, err := GetUserByID(userId)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.0, user.Cost, 1e-9)
+
+	cost, err := GetCostByUserIdAndTime(userId, 0, time.Now().Unix()+1)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.0, cost, 1e-9)
+
+	daily, err := GetDailyCost(1)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, daily)
+}
+
+func TestRecordDocIds(t *testing.T) {
+	userId := "doc_ids_user"
+	_, err := InsertUser(userId, "")
+	assert.NoError(t, err)
+
+	id, err := InsertRecordInfo(context.Background(), &Record{
+		UserId:   userId,
+		Question: "q",
+		Answer:   "a",
+		DocIds:   "default/a.txt#1",
+	})
+	assert.NoError(t, err)
+
+	err = UpdateRecordInfo(&Record{ID: id, UserId: userId, Answer: "a2", DocIds: "default/a.txt#1,default/b.md#2"})
+	assert.NoError(t, err)
+
+	records, err := GetRecordList(userId, 1, 10, -1, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "default/a.txt#1,default/b.md#2", records[0].DocIds)
+}
+
+func TestSearchRecords(t *testing.T) {
+	userId := "search_records_user"
+	for _, q := range []string{"deploy musebot with docker", "what is the weather"} {
+		_, err := InsertRecordInfo(context.Background(), &Record{UserId: userId, Question: q, Answer: "answer of " + q})
+		assert.NoError(t, err)
+	}
+
+	records, err := SearchRecords(userId, "docker", 10)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "deploy musebot with docker", records[0].Question)
+
+	records, err = SearchRecords(userId, "", 1)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "what is the weather", records[0].Question)
+}
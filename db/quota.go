@@ -0,0 +1,70 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+// QuotaPeriodStart get start time of the period which t is in, week starts on monday.
+func QuotaPeriodStart(period string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch period {
+	case param.QuotaWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case param.QuotaMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+// QuotaResetTime get time when quota of period is reset.
+func QuotaResetTime(period string, t time.Time) time.Time {
+	start := QuotaPeriodStart(period, t)
+	switch period {
+	case param.QuotaWeek:
+		return start.AddDate(0, 0, 7)
+	case param.QuotaMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// GetQuotaUsage get usage of capability since start.
+// usage of a user is counted when groupKey is empty, otherwise usage of all members in the group chat.
+func GetQuotaUsage(userId, groupKey, capability string, start int64) (float64, error) {
+	var field string
+	switch capability {
+	case param.QuotaText:
+		field = fmt.Sprintf("COALESCE(sum(token), 0) FROM records WHERE record_type in (%d, %d, %d)",
+			param.TextRecordType, param.TalkRecordType, param.InternalRecordType)
+	case param.QuotaImage:
+		// image uploaded by user is saved with same answer and content, it is not generated.
+		field = fmt.Sprintf("count(*) FROM records WHERE record_type = %d and answer <> content", param.ImageRecordType)
+	case param.QuotaVideo:
+		field = fmt.Sprintf("count(*) FROM records WHERE record_type = %d", param.VideoRecordType)
+	case param.QuotaCost:
+		field = "COALESCE(sum(cost), 0) FROM records WHERE 1 = 1"
+	default:
+		return 0, fmt.Errorf("unsupported quota capability: %s", capability)
+	}
+
+	querySQL := "SELECT " + field + " and create_time >= ? and "
+	arg := userId
+	if groupKey != "" {
+		querySQL += "session_id like ?" + likeEscape()
+		arg = likeEscaper.Replace(groupKey) + ":%"
+	} else {
+		querySQL += "user_id = ?"
+	}
+
+	var usage float64
+	err := DB.QueryRow(querySQL, start, arg).Scan(&usage)
+	if err != nil {
+		return 0, err
+	}
+	return usage, nil
+}
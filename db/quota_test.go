@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	// 2025-01-15 is wednesday
+	now := time.Date(2025, 1, 15, 13, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), QuotaPeriodStart(param.QuotaDay, now))
+	assert.Equal(t, time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), QuotaPeriodStart(param.QuotaWeek, now))
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), QuotaPeriodStart(param.QuotaMonth, now))
+
+	assert.Equal(t, time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC), QuotaResetTime(param.QuotaDay, now))
+	assert.Equal(t, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), QuotaResetTime(param.QuotaWeek, now))
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), QuotaResetTime(param.QuotaMonth, now))
+
+	sunday := time.Date(2025, 1, 19, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), QuotaPeriodStart(param.QuotaWeek, sunday))
+}
+
+func TestGetQuotaUsage(t *testing.T) {
+	ctx := context.Background()
+	groupKey := "quota_bot:telegram:-100"
+	start := time.Now().Add(-time.Minute).Unix()
+
+	records := []*Record{
+		{UserId: "quota_a", Question: "q", Answer: "a", Token: 100, Cost: 0.1, SessionId: groupKey + ":quota_a#default"},
+		{UserId: "quota_b", Question: "q", Answer: "a", Token: 50, Cost: 0.2, SessionId: groupKey + ":quota_b#default"},
+		{UserId: "quota_b", Token: 20, Cost: 0.05, RecordType: param.InternalRecordType, Mode: "summary", SessionId: groupKey + ":quota_b#default"},
+		{UserId: "quota_a", Question: "q", Answer: "img", Content: "", RecordType: param.ImageRecordType, SessionId: groupKey + ":quota_a#default"},
+		{UserId: "quota_a", Question: "q", Answer: "upload", Content: "upload", RecordType: param.ImageRecordType},
+		{UserId: "quota_a", Question: "q", Answer: "video", RecordType: param.VideoRecordType},
+		// underscore of group key is not a wildcard
+		{UserId: "quota_c", Question: "q", Answer: "a", Token: 1000, Cost: 1, SessionId: "quotaxbot:telegram:-100:quota_c#default"},
+	}
+	for _, record := range records {
+		_, err := InsertRecordInfo(ctx, record)
+		assert.NoError(t, err)
+	}
+
+	usage, err := GetQuotaUsage("quota_a", "", param.QuotaText, start)
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, usage)
+
+	usage, err = GetQuotaUsage("quota_a", groupKey, param.QuotaText, start)
+	assert.NoError(t, err)
+	assert.Equal(t, 170.0, usage)
+
+	usage, err = GetQuotaUsage("quota_a", "", param.QuotaImage, start)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, usage)
+
+	usage, err = GetQuotaUsage("quota_a", "", param.QuotaVideo, start)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, usage)
+
+	usage, err = GetQuotaUsage("quota_b", groupKey, param.QuotaCost, start)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.35, usage, 1e-9)
+
+	_, err = GetQuotaUsage("quota_a", "", "unknown", start)
+	assert.Error(t, err)
+}
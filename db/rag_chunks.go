@@ -0,0 +1,122 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+// RagChunk is one split chunk of knowledge file, kept for keyword search.
+type RagChunk struct {
+	ID         int64          `json:"id"`
+	KB         string         `json:"kb"`
+	FileName   string         `json:"file_name"`
+	ChunkIndex int            `json:"chunk_index"`
+	Content    string         `json:"content"`
+	Metadata   map[string]any `json:"metadata"`
+	Embedding  []float32      `json:"embedding,omitempty"`
+	CreateTime int64          `json:"create_time"`
+}
+
+// InsertRagChunks insert chunks in one transaction.
+func InsertRagChunks(chunks []*RagChunk) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO rag_chunks (kb, file_name, chunk_index, content, metadata, embedding, create_time, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, c := range chunks {
+		metadata, err := json.Marshal(c.Metadata)
+		if err != nil {
+			return err
+		}
+		c.CreateTime = time.Now().Unix()
+		result, err := stmt.Exec(c.KB, c.FileName, c.ChunkIndex, c.Content, string(metadata), encodeEmbedding(c.Embedding), c.CreateTime, conf.BaseConfInfo.BotName)
+		if err != nil {
+			return err
+		}
+		c.ID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRagChunksByKB get all chunks of knowledge base.
+func GetRagChunksByKB(kb string) ([]*RagChunk, error) {
+	rows, err := DB.Query(`SELECT id, kb, file_name, chunk_index, content, metadata, create_time FROM rag_chunks WHERE kb = ? and from_bot = ? ORDER BY id`,
+		kb, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := make([]*RagChunk, 0)
+	for rows.Next() {
+		c := new(RagChunk)
+		var metadata string
+		if err := rows.Scan(&c.ID, &c.KB, &c.FileName, &c.ChunkIndex, &c.Content, &metadata, &c.CreateTime); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(metadata), &c.Metadata); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+
+	return chunks, rows.Err()
+}
+
+// GetRagChunksByFileName get chunks of knowledge file with embeddings.
+func GetRagChunksByFileName(kb, fileName string) ([]*RagChunk, error) {
+	rows, err := DB.Query(`SELECT id, kb, file_name, chunk_index, content, metadata, embedding, create_time FROM rag_chunks WHERE kb = ? and file_name = ? and from_bot = ? ORDER BY id`,
+		kb, fileName, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := make([]*RagChunk, 0)
+	for rows.Next() {
+		c := new(RagChunk)
+		var metadata string
+		var embedding []byte
+		if err := rows.Scan(&c.ID, &c.KB, &c.FileName, &c.ChunkIndex, &c.Content, &metadata, &embedding, &c.CreateTime); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(metadata), &c.Metadata); err != nil {
+			return nil, err
+		}
+		if len(embedding) > 0 {
+			c.Embedding = decodeEmbedding(embedding)
+		}
+		chunks = append(chunks, c)
+	}
+
+	return chunks, rows.Err()
+}
+
+// GetRagChunksVersion count and max id of chunks, changes whenever chunks of kb are inserted or deleted.
+func GetRagChunksVersion(kb string) (int64, int64, error) {
+	var count, maxId int64
+	err := DB.QueryRow(`SELECT COUNT(*), COALESCE(MAX(id), 0) FROM rag_chunks WHERE kb = ? and from_bot = ?`,
+		kb, conf.BaseConfInfo.BotName).Scan(&count, &maxId)
+	return count, maxId, err
+}
+
+// DeleteRagChunksByFileName delete all chunks of knowledge file.
+func DeleteRagChunksByFileName(kb, fileName string) error {
+	_, err := DB.Exec(`DELETE FROM rag_chunks WHERE kb = ? and file_name = ? and from_bot = ?`,
+		kb, fileName, conf.BaseConfInfo.BotName)
+	return err
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRagChunks(t *testing.T) {
+	kb := "chunk_test"
+	err := InsertRagChunks([]*RagChunk{
+		{KB: kb, FileName: "a.md", ChunkIndex: 1, Content: "error code E1001", Metadata: map[string]any{"file_name": "a.md"}, Embedding: []float32{0.5, -0.5}},
+		{KB: kb, FileName: "a.md", ChunkIndex: 2, Content: "set chunk_size to 500", Metadata: map[string]any{"file_name": "a.md"}},
+		{KB: kb, FileName: "b.md", ChunkIndex: 1, Content: "hello", Metadata: map[string]any{"file_name": "b.md"}},
+	})
+	assert.NoError(t, err)
+
+	chunks, err := GetRagChunksByKB(kb)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, "error code E1001", chunks[0].Content)
+	assert.Equal(t, "a.md", chunks[0].Metadata["file_name"])
+
+	fileChunks, err := GetRagChunksByFileName(kb, "a.md")
+	assert.NoError(t, err)
+	assert.Len(t, fileChunks, 2)
+	assert.Equal(t, []float32{0.5, -0.5}, fileChunks[0].Embedding)
+	assert.Nil(t, fileChunks[1].Embedding)
+
+	count, maxId, err := GetRagChunksVersion(kb)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, chunks[2].ID, maxId)
+
+	_, err = InsertRagFile(kb, "a.md", "md5-a")
+	assert.NoError(t, err)
+	err = DeleteRagFileByFileName(kb, "a.md")
+	assert.NoError(t, err)
+
+	chunks, err = GetRagChunksByKB(kb)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "b.md", chunks[0].FileName)
+}
@@ -1,26 +1,45 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/yincongcyincong/MuseBot/conf"
 )
 
+const (
+	RagFileStatusPending  = "pending"
+	RagFileStatusIndexing = "indexing"
+	RagFileStatusDone     = "done"
+	RagFileStatusFailed   = "failed"
+)
+
 type RagFiles struct {
 	ID         int64  `json:"id"`
+	KB         string `json:"kb"`
 	FileName   string `json:"file_name"`
 	FileMd5    string `json:"file_md5"`
 	VectorId   string `json:"vector_id"`
+	Status     string `json:"status"`
+	ErrorMsg   string `json:"error_msg"`
+	RetryTimes int    `json:"retry_times"`
+	Source     string `json:"source"`
 	UpdateTime int64  `json:"update_time"`
 	CreateTime int    `json:"create_time"`
 	IsDeleted  int    `json:"is_deleted"`
 }
 
-func InsertRagFile(fileName, fileMd5 string) (int64, error) {
+func InsertRagFile(kb, fileName, fileMd5 string) (int64, error) {
+	return InsertRagFileWithStatus(kb, fileName, fileMd5, RagFileStatusDone, "")
+}
+
+func InsertRagFileWithStatus(kb, fileName, fileMd5, status, source string) (int64, error) {
 	// insert data
-	insertSQL := `INSERT INTO rag_files (file_name, file_md5, create_time, update_time, vector_id, from_bot) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := DB.Exec(insertSQL, fileName, fileMd5, time.Now().Unix(), time.Now().Unix(), "", conf.BaseConfInfo.BotName)
+	insertSQL := `INSERT INTO rag_files (kb, file_name, file_md5, create_time, update_time, vector_id, status, error_msg, source, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := DB.Exec(insertSQL, kb, fileName, fileMd5, time.Now().Unix(), time.Now().Unix(), "", status, "", source, conf.BaseConfInfo.BotName)
 	if err != nil {
 		return 0, err
 	}
@@ -33,9 +52,9 @@ func InsertRagFile(fileName, fileMd5 string) (int64, error) {
 	return id, nil
 }
 
-func GetRagFileByFileMd5(fileMd5 string) ([]*RagFiles, error) {
-	querySQL := `SELECT id, file_name, file_md5, update_time, create_time FROM rag_files WHERE file_md5 = ? and is_deleted = 0 and from_bot = ?`
-	rows, err := DB.Query(querySQL, fileMd5, conf.BaseConfInfo.BotName)
+func GetRagFileByFileMd5(kb, fileMd5 string) ([]*RagFiles, error) {
+	querySQL := `SELECT id, kb, file_name, file_md5, status, update_time, create_time FROM rag_files WHERE kb = ? and file_md5 = ? and is_deleted = 0 and from_bot = ?`
+	rows, err := DB.Query(querySQL, kb, fileMd5, conf.BaseConfInfo.BotName)
 
 	if err != nil {
 		return nil, err
@@ -45,7 +64,7 @@ func GetRagFileByFileMd5(fileMd5 string) ([]*RagFiles, error) {
 	var ragFiles []*RagFiles
 	for rows.Next() {
 		var ragFile RagFiles
-		if err := rows.Scan(&ragFile.ID, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime); err != nil {
+		if err := rows.Scan(&ragFile.ID, &ragFile.KB, &ragFile.FileName, &ragFile.FileMd5, &ragFile.Status, &ragFile.UpdateTime, &ragFile.CreateTime); err != nil {
 			return nil, err
 		}
 		ragFiles = append(ragFiles, &ragFile)
@@ -58,9 +77,9 @@ func GetRagFileByFileMd5(fileMd5 string) ([]*RagFiles, error) {
 	return ragFiles, nil
 }
 
-func GetRagFileByFileName(fileName string) ([]*RagFiles, error) {
-	querySQL := `SELECT id, file_name, file_md5, update_time, create_time, vector_id FROM rag_files WHERE file_name = ? and is_deleted = 0 and from_bot = ?`
-	rows, err := DB.Query(querySQL, fileName, conf.BaseConfInfo.BotName)
+func GetRagFileByFileName(kb, fileName string) ([]*RagFiles, error) {
+	querySQL := `SELECT id, kb, file_name, file_md5, update_time, create_time, vector_id, status, source FROM rag_files WHERE kb = ? and file_name = ? and is_deleted = 0 and from_bot = ?`
+	rows, err := DB.Query(querySQL, kb, fileName, conf.BaseConfInfo.BotName)
 
 	if err != nil {
 		return nil, err
@@ -70,7 +89,7 @@ func GetRagFileByFileName(fileName string) ([]*RagFiles, error) {
 	var ragFiles []*RagFiles
 	for rows.Next() {
 		var ragFile RagFiles
-		if err := rows.Scan(&ragFile.ID, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId); err != nil {
+		if err := rows.Scan(&ragFile.ID, &ragFile.KB, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId, &ragFile.Status, &ragFile.Source); err != nil {
 			return nil, err
 		}
 		ragFiles = append(ragFiles, &ragFile)
@@ -83,10 +102,110 @@ func GetRagFileByFileName(fileName string) ([]*RagFiles, error) {
 	return ragFiles, nil
 }
 
-func DeleteRagFileByFileName(fileName string) error {
-	query := `UPDATE rag_files set is_deleted = 1 WHERE file_name = ? and from_bot = ?`
-	_, err := DB.Exec(query, fileName, conf.BaseConfInfo.BotName)
-	return err
+// GetRagFilesByDir get rag files under dir of knowledge base, dir is relative to the knowledge base dir.
+func GetRagFilesByDir(kb, dir string) ([]*RagFiles, error) {
+	querySQL := `SELECT id, kb, file_name, file_md5, update_time, create_time, vector_id, status, source FROM rag_files WHERE kb = ? and file_name LIKE ?` +
+		likeEscape() + ` and is_deleted = 0 and from_bot = ?`
+	rows, err := DB.Query(querySQL, kb, likeEscaper.Replace(strings.TrimSuffix(dir, "/"))+"/%", conf.BaseConfInfo.BotName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ragFiles []*RagFiles
+	for rows.Next() {
+		var ragFile RagFiles
+		if err := rows.Scan(&ragFile.ID, &ragFile.KB, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId, &ragFile.Status, &ragFile.Source); err != nil {
+			return nil, err
+		}
+		ragFiles = append(ragFiles, &ragFile)
+	}
+	return ragFiles, rows.Err()
+}
+
+func DeleteRagFileByFileName(kb, fileName string) error {
+	query := `UPDATE rag_files set is_deleted = 1 WHERE kb = ? and file_name = ? and from_bot = ?`
+	_, err := DB.Exec(query, kb, fileName, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return err
+	}
+	return DeleteRagChunksByFileName(kb, fileName)
+}
+
+// GetRagFileById get rag file by id, nil if not exist or deleted.
+func GetRagFileById(id int64) (*RagFiles, error) {
+	f := new(RagFiles)
+	err := DB.QueryRow(`SELECT id, kb, file_name, file_md5, vector_id, status, error_msg, retry_times, source, update_time, create_time FROM rag_files WHERE id = ? and is_deleted = 0`, id).
+		Scan(&f.ID, &f.KB, &f.FileName, &f.FileMd5, &f.VectorId, &f.Status, &f.ErrorMsg, &f.RetryTimes, &f.Source, &f.UpdateTime, &f.CreateTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// UpdateRagFileStatus update ingestion status, vector ids, error and retry times of rag file,
+// deleted record is not updated and 0 is returned as rows affected.
+func UpdateRagFileStatus(ragFile *RagFiles) (int64, error) {
+	if len(ragFile.ErrorMsg) > 1024 {
+		ragFile.ErrorMsg = ragFile.ErrorMsg[:1024]
+	}
+	query := `UPDATE rag_files set status = ?, vector_id = ?, error_msg = ?, retry_times = ?, update_time = ? WHERE id = ? and is_deleted = 0`
+	result, err := DB.Exec(query, ragFile.Status, ragFile.VectorId, ragFile.ErrorMsg, ragFile.RetryTimes, time.Now().Unix(), ragFile.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetRagFilesByStatus get rag files of status in all knowledge bases.
+func GetRagFilesByStatus(statuses ...string) ([]*RagFiles, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{conf.BaseConfInfo.BotName}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	querySQL := `SELECT id, kb, file_name, file_md5, vector_id, status, source FROM rag_files WHERE is_deleted = 0 and from_bot = ? and status IN (?` +
+		strings.Repeat(", ?", len(statuses)-1) + `)`
+	rows, err := DB.Query(querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ragFiles []*RagFiles
+	for rows.Next() {
+		f := new(RagFiles)
+		if err := rows.Scan(&f.ID, &f.KB, &f.FileName, &f.FileMd5, &f.VectorId, &f.Status, &f.Source); err != nil {
+			return nil, err
+		}
+		ragFiles = append(ragFiles, f)
+	}
+	return ragFiles, rows.Err()
+}
+
+// GetRagFilesByKB get rag files of knowledge base in status.
+func GetRagFilesByKB(kb, status string) ([]*RagFiles, error) {
+	rows, err := DB.Query(`SELECT id, kb, file_name, file_md5, vector_id, status, source, update_time, create_time FROM rag_files WHERE kb = ? and status = ? and is_deleted = 0 and from_bot = ? ORDER BY id`,
+		kb, status, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ragFiles []*RagFiles
+	for rows.Next() {
+		f := new(RagFiles)
+		if err := rows.Scan(&f.ID, &f.KB, &f.FileName, &f.FileMd5, &f.VectorId, &f.Status, &f.Source, &f.UpdateTime, &f.CreateTime); err != nil {
+			return nil, err
+		}
+		ragFiles = append(ragFiles, f)
+	}
+	return ragFiles, rows.Err()
 }
 
 func DeleteRagFileByVectorId(fileName string) error {
@@ -95,13 +214,13 @@ func DeleteRagFileByVectorId(fileName string) error {
 	return err
 }
 
-func UpdateVectorIdByFileMd5(fileMd5, vectorId string) error {
-	query := `UPDATE rag_files set vector_id = ? WHERE file_md5 = ? and from_bot = ?`
-	_, err := DB.Exec(query, vectorId, fileMd5, conf.BaseConfInfo.BotName)
+func UpdateVectorIdByFileMd5(kb, fileMd5, vectorId string) error {
+	query := `UPDATE rag_files set vector_id = ? WHERE kb = ? and file_md5 = ? and from_bot = ?`
+	_, err := DB.Exec(query, vectorId, kb, fileMd5, conf.BaseConfInfo.BotName)
 	return err
 }
 
-func GetRagFilesByPage(page, pageSize int, name string) ([]RagFiles, error) {
+func GetRagFilesByPage(page, pageSize int, name, kb string) ([]RagFiles, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -120,9 +239,14 @@ func GetRagFilesByPage(page, pageSize int, name string) ([]RagFiles, error) {
 		args = append(args, "%"+name+"%")
 	}
 
+	if kb != "" {
+		whereSQL += " AND kb = ?"
+		args = append(args, kb)
+	}
+
 	// 查询数据
 	listSQL := fmt.Sprintf(`
-		SELECT id, file_name, file_md5, vector_id, update_time, create_time, is_deleted
+		SELECT id, kb, file_name, file_md5, vector_id, status, error_msg, retry_times, source, update_time, create_time, is_deleted
 		FROM rag_files %s
 		ORDER BY id DESC
 		LIMIT ? OFFSET ?`, whereSQL)
@@ -138,7 +262,8 @@ func GetRagFilesByPage(page, pageSize int, name string) ([]RagFiles, error) {
 	var files []RagFiles
 	for rows.Next() {
 		var f RagFiles
-		if err := rows.Scan(&f.ID, &f.FileName, &f.FileMd5, &f.VectorId, &f.UpdateTime, &f.CreateTime, &f.IsDeleted); err != nil {
+		if err := rows.Scan(&f.ID, &f.KB, &f.FileName, &f.FileMd5, &f.VectorId, &f.Status, &f.ErrorMsg, &f.RetryTimes,
+			&f.Source, &f.UpdateTime, &f.CreateTime, &f.IsDeleted); err != nil {
 			return nil, err
 		}
 		files = append(files, f)
@@ -147,7 +272,7 @@ func GetRagFilesByPage(page, pageSize int, name string) ([]RagFiles, error) {
 	return files, nil
 }
 
-func GetRagFilesCount(name string) (int, error) {
+func GetRagFilesCount(name, kb string) (int, error) {
 	whereSQL := "WHERE is_deleted = 0 and from_bot = ?"
 	args := []interface{}{conf.BaseConfInfo.BotName}
 
@@ -156,6 +281,11 @@ func GetRagFilesCount(name string) (int, error) {
 		args = append(args, "%"+name+"%")
 	}
 
+	if kb != "" {
+		whereSQL += " AND kb = ?"
+		args = append(args, kb)
+	}
+
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM rag_files %s", whereSQL)
 
 	var count int
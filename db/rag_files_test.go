@@ -6,6 +6,7 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
 )
 
 func TestInsertAndGetRagFile(t *testing.T) {
@@ -14,18 +15,18 @@ func TestInsertAndGetRagFile(t *testing.T) {
 	fileMd5 := "abc123"
 
 	// 插入
-	id, err := InsertRagFile(fileName, fileMd5)
+	id, err := InsertRagFile(conf.DefaultKnowledgeBase, fileName, fileMd5)
 	assert.NoError(t, err)
 	assert.NotZero(t, id)
 
 	// 按 md5 查询
-	files, err := GetRagFileByFileMd5(fileMd5)
+	files, err := GetRagFileByFileMd5(conf.DefaultKnowledgeBase, fileMd5)
 	assert.NoError(t, err)
 	assert.Len(t, files, 1)
 	assert.Equal(t, fileName, files[0].FileName)
 
 	// 按 fileName 查询
-	files2, err := GetRagFileByFileName(fileName)
+	files2, err := GetRagFileByFileName(conf.DefaultKnowledgeBase, fileName)
 	assert.NoError(t, err)
 	assert.Len(t, files2, 1)
 	assert.Equal(t, fileMd5, files2[0].FileMd5)
@@ -36,38 +37,38 @@ func TestUpdateAndDeleteRagFile(t *testing.T) {
 	fileMd5 := "abc123"
 
 	// 插入
-	_, err := InsertRagFile(fileName, fileMd5)
+	_, err := InsertRagFile(conf.DefaultKnowledgeBase, fileName, fileMd5)
 	assert.NoError(t, err)
 
 	// 更新 vector_id
 	newVector := "vec-1"
-	err = UpdateVectorIdByFileMd5(fileMd5, newVector)
+	err = UpdateVectorIdByFileMd5(conf.DefaultKnowledgeBase, fileMd5, newVector)
 	assert.NoError(t, err)
 
-	files, err := GetRagFileByFileName(fileName)
+	files, err := GetRagFileByFileName(conf.DefaultKnowledgeBase, fileName)
 	assert.NoError(t, err)
 	assert.Equal(t, newVector, files[0].VectorId)
 
 	// 删除 by fileName
-	err = DeleteRagFileByFileName(fileName)
+	err = DeleteRagFileByFileName(conf.DefaultKnowledgeBase, fileName)
 	assert.NoError(t, err)
 
-	files, err = GetRagFileByFileName(fileName)
+	files, err = GetRagFileByFileName(conf.DefaultKnowledgeBase, fileName)
 	assert.NoError(t, err)
 	assert.Len(t, files, 0)
 
 	// 再插入一个
-	_, err = InsertRagFile("b.txt", "def456")
+	_, err = InsertRagFile(conf.DefaultKnowledgeBase, "b.txt", "def456")
 	assert.NoError(t, err)
 
 	// 删除 by vectorId
-	err = UpdateVectorIdByFileMd5("def456", "vec-2")
+	err = UpdateVectorIdByFileMd5(conf.DefaultKnowledgeBase, "def456", "vec-2")
 	assert.NoError(t, err)
 
 	err = DeleteRagFileByVectorId("vec-2")
 	assert.NoError(t, err)
 
-	files, err = GetRagFileByFileName("b.txt")
+	files, err = GetRagFileByFileName(conf.DefaultKnowledgeBase, "b.txt")
 	assert.NoError(t, err)
 	assert.Len(t, files, 0)
 }
@@ -77,13 +78,103 @@ func TestInsertTimeStamps(t *testing.T) {
 	fileMd5 := "time123"
 
 	// 插入
-	_, err := InsertRagFile(fileName, fileMd5)
+	_, err := InsertRagFile(conf.DefaultKnowledgeBase, fileName, fileMd5)
 	assert.NoError(t, err)
 
-	files, err := GetRagFileByFileMd5(fileMd5)
+	files, err := GetRagFileByFileMd5(conf.DefaultKnowledgeBase, fileMd5)
 	assert.NoError(t, err)
 	assert.Len(t, files, 1)
 
 	now := time.Now().Unix()
 	assert.LessOrEqual(t, files[0].UpdateTime, now)
 }
+
+func TestRagFileKnowledgeBase(t *testing.T) {
+	_, err := InsertRagFile("support", "kb.txt", "kb123")
+	assert.NoError(t, err)
+
+	files, err := GetRagFileByFileName(conf.DefaultKnowledgeBase, "kb.txt")
+	assert.NoError(t, err)
+	assert.Len(t, files, 0)
+
+	files, err = GetRagFileByFileName("support", "kb.txt")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "support", files[0].KB)
+
+	count, err := GetRagFilesCount("kb.txt", "support")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = GetRagFilesCount("kb.txt", conf.DefaultKnowledgeBase)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestRagFileStatus(t *testing.T) {
+	id, err := InsertRagFileWithStatus(conf.DefaultKnowledgeBase, "status.pdf", "status123", RagFileStatusPending, "https://example.com/status.pdf")
+	assert.NoError(t, err)
+
+	files, err := GetRagFilesByStatus(RagFileStatusPending, RagFileStatusIndexing)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "status.pdf", files[0].FileName)
+
+	affected, err := UpdateRagFileStatus(&RagFiles{ID: id, Status: RagFileStatusFailed, ErrorMsg: "timeout", RetryTimes: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	f, err := GetRagFileById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, RagFileStatusFailed, f.Status)
+	assert.Equal(t, "timeout", f.ErrorMsg)
+	assert.Equal(t, 3, f.RetryTimes)
+	assert.Equal(t, "https://example.com/status.pdf", f.Source)
+
+	list, err := GetRagFilesByPage(1, 10, "status.pdf", conf.DefaultKnowledgeBase)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, RagFileStatusFailed, list[0].Status)
+
+	err = DeleteRagFileByFileName(conf.DefaultKnowledgeBase, "status.pdf")
+	assert.NoError(t, err)
+	f, err = GetRagFileById(id)
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+
+	// deleted record is not updated by job finished later
+	affected, err = UpdateRagFileStatus(&RagFiles{ID: id, Status: RagFileStatusDone})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+
+	id, err = InsertRagFile(conf.DefaultKnowledgeBase, "done.txt", "done123")
+	assert.NoError(t, err)
+	f, err = GetRagFileById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, RagFileStatusDone, f.Status)
+
+	files, err = GetRagFilesByKB(conf.DefaultKnowledgeBase, RagFileStatusDone)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+	assert.Equal(t, "done.txt", files[len(files)-1].FileName)
+}
+
+func TestGetRagFilesByDir(t *testing.T) {
+	for _, fileName := range []string{"dir_test/a.txt", "dir_test/sub/b.txt", "dir_test.txt", "dirXtest/c.txt", "dir_test2/d.txt"} {
+		_, err := InsertRagFile("dir", fileName, fileName)
+		assert.NoError(t, err)
+	}
+
+	files, err := GetRagFilesByDir("dir", "dir_test")
+	assert.NoError(t, err)
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.FileName)
+	}
+	// _ is not a wildcard
+	assert.ElementsMatch(t, []string{"dir_test/a.txt", "dir_test/sub/b.txt"}, names)
+
+	files, err = GetRagFilesByDir("dir", "dir_test/sub/")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
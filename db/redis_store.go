@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+const (
+	redisConvKeyPrefix = "musebot:conversation:"
+	// redisLoadedKeyPrefix mark session whose conversation is loaded from db, the list may be empty
+	redisLoadedKeyPrefix = "musebot:conversation_loaded:"
+)
+
+// RedisConversationStore keep conversation in redis, so replicas share the same context.
+type RedisConversationStore struct {
+	Client *redis.Client
+}
+
+func NewRedisConversationStore(addr, password string, db int) *RedisConversationStore {
+	return &RedisConversationStore{
+		Client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+	}
+}
+
+func (r *RedisConversationStore) key(sessionId string) string {
+	return redisConvKeyPrefix + sessionId
+}
+
+func (r *RedisConversationStore) loadedKey(sessionId string) string {
+	return redisLoadedKeyPrefix + sessionId
+}
+
+func (r *RedisConversationStore) expire() time.Duration {
+	return time.Duration(conf.BaseConfInfo.ContextExpireTime) * time.Second
+}
+
+// load put conversation of session into redis if it is not loaded. only the replica which sets the loaded
+// marker loads it, and conversation from db is pushed before the ones appended by other replicas meanwhile.
+func (r *RedisConversationStore) load(ctx context.Context, sessionId string) error {
+	ok, err := r.Client.SetNX(ctx, r.loadedKey(sessionId), 1, r.expire()).Result()
+	if err != nil || !ok {
+		return err
+	}
+	// marker expires a little earlier than the list, the list is still valid
+	exist, err := r.Client.Exists(ctx, r.key(sessionId)).Result()
+	if err != nil || exist > 0 {
+		return err
+	}
+
+	aqs, err := loadAQs(sessionId)
+	if err != nil {
+		r.Client.Del(ctx, r.loadedKey(sessionId))
+		return err
+	}
+	if len(aqs) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(aqs))
+	for i := len(aqs) - 1; i >= 0; i-- {
+		b, err := json.Marshal(aqs[i])
+		if err != nil {
+			r.Client.Del(ctx, r.loadedKey(sessionId))
+			return err
+		}
+		values = append(values, string(b))
+	}
+
+	_, err = r.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, r.key(sessionId), values...)
+		pipe.LTrim(ctx, r.key(sessionId), int64(-conf.BaseConfInfo.MaxQAPair), -1)
+		pipe.Expire(ctx, r.key(sessionId), r.expire())
+		return nil
+	})
+	return err
+}
+
+func (r *RedisConversationStore) Append(ctx context.Context, sessionId string, aq *AQ) error {
+	err := r.load(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(aq)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, r.key(sessionId), string(b))
+		pipe.LTrim(ctx, r.key(sessionId), int64(-conf.BaseConfInfo.MaxQAPair), -1)
+		pipe.Expire(ctx, r.key(sessionId), r.expire())
+		pipe.Expire(ctx, r.loadedKey(sessionId), r.expire())
+		return nil
+	})
+	return err
+}
+
+func (r *RedisConversationStore) Get(ctx context.Context, sessionId string) (*MsgRecordInfo, error) {
+	err := r.load(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := r.Client.LRange(ctx, r.key(sessionId), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	msgRecord := &MsgRecordInfo{
+		AQs:        make([]*AQ, 0, len(values)),
+		updateTime: time.Now().Unix(),
+	}
+	for _, value := range values {
+		aq := new(AQ)
+		if err := json.Unmarshal([]byte(value), aq); err != nil {
+			return nil, err
+		}
+		msgRecord.AQs = append(msgRecord.AQs, aq)
+	}
+
+	return msgRecord, nil
+}
+
+func (r *RedisConversationStore) Delete(ctx context.Context, sessionId string) error {
+	return r.Client.Del(ctx, r.key(sessionId), r.loadedKey(sessionId)).Err()
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+// RoleBinding assign a role to a user or a group chat.
+type RoleBinding struct {
+	ID          int64  `json:"id"`
+	SubjectType string `json:"subject_type"` // user group
+	SubjectId   string `json:"subject_id"`
+	Role        string `json:"role"`
+	CreateTime  int64  `json:"create_time"`
+	UpdateTime  int64  `json:"update_time"`
+}
+
+// GetRole get role of user or group, empty if not assigned.
+func GetRole(subjectType, subjectId string) (string, error) {
+	var role string
+	err := DB.QueryRow(`SELECT role FROM role_bindings WHERE subject_type = ? and subject_id = ? and from_bot = ?`,
+		subjectType, subjectId, conf.BaseConfInfo.BotName).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+// UpdateRole assign role to user or group by upsert, binding is removed when role is empty.
+func UpdateRole(subjectType, subjectId, role string) error {
+	if role == "" {
+		_, err := DB.Exec(`DELETE FROM role_bindings WHERE subject_type = ? and subject_id = ? and from_bot = ?`,
+			subjectType, subjectId, conf.BaseConfInfo.BotName)
+		return err
+	}
+
+	query := `INSERT INTO role_bindings (subject_type, subject_id, role, create_time, update_time, from_bot) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (subject_type, subject_id, from_bot) DO UPDATE SET role = excluded.role, update_time = excluded.update_time`
+	if conf.BaseConfInfo.DBType == "mysql" {
+		query = `INSERT INTO role_bindings (subject_type, subject_id, role, create_time, update_time, from_bot) VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE role = VALUES(role), update_time = VALUES(update_time)`
+	}
+
+	now := time.Now().Unix()
+	_, err := DB.Exec(query, subjectType, subjectId, role, now, now, conf.BaseConfInfo.BotName)
+	return err
+}
+
+func GetRoleBindingsByPage(page, pageSize int, subjectType string) ([]*RoleBinding, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	whereSQL := "WHERE from_bot = ?"
+	args := []interface{}{conf.BaseConfInfo.BotName}
+	if subjectType != "" {
+		whereSQL += " and subject_type = ?"
+		args = append(args, subjectType)
+	}
+
+	listSQL := fmt.Sprintf(`
+		SELECT id, subject_type, subject_id, role, create_time, update_time
+		FROM role_bindings %s
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, whereSQL)
+	args = append(args, pageSize, offset)
+
+	rows, err := DB.Query(listSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bindings := make([]*RoleBinding, 0)
+	for rows.Next() {
+		b := new(RoleBinding)
+		if err := rows.Scan(&b.ID, &b.SubjectType, &b.SubjectId, &b.Role, &b.CreateTime, &b.UpdateTime); err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, b)
+	}
+
+	return bindings, rows.Err()
+}
+
+func GetRoleBindingCount(subjectType string) (int, error) {
+	whereSQL := "WHERE from_bot = ?"
+	args := []interface{}{conf.BaseConfInfo.BotName}
+	if subjectType != "" {
+		whereSQL += " and subject_type = ?"
+		args = append(args, subjectType)
+	}
+
+	var total int
+	err := DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM role_bindings %s", whereSQL), args...).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func TestRoleBinding(t *testing.T) {
+	role, err := GetRole(param.RoleSubjectUser, "role_user")
+	assert.NoError(t, err)
+	assert.Equal(t, "", role)
+
+	assert.NoError(t, UpdateRole(param.RoleSubjectUser, "role_user", "member"))
+	assert.NoError(t, UpdateRole(param.RoleSubjectUser, "role_user", "admin"))
+	assert.NoError(t, UpdateRole(param.RoleSubjectGroup, "-100", "guest"))
+
+	role, err = GetRole(param.RoleSubjectUser, "role_user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", role)
+
+	bindings, err := GetRoleBindingsByPage(1, 10, param.RoleSubjectUser)
+	assert.NoError(t, err)
+	assert.Len(t, bindings, 1)
+
+	total, err := GetRoleBindingCount("")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	assert.NoError(t, UpdateRole(param.RoleSubjectUser, "role_user", ""))
+	role, err = GetRole(param.RoleSubjectUser, "role_user")
+	assert.NoError(t, err)
+	assert.Equal(t, "", role)
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+// Session is a named conversation thread inside one chat of one user.
+type Session struct {
+	ID         int64  `json:"id"`
+	ChatKey    string `json:"chat_key"`
+	Name       string `json:"name"`
+	IsActive   int    `json:"is_active"` // 0:inactive 1:active
+	CreateTime int64  `json:"create_time"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+// GetChatKey conversation is isolated by bot, platform, chat and user.
+func GetChatKey(platform, chatId, userId string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", conf.BaseConfInfo.BotName, platform, chatId, userId)
+}
+
+// GetSessionId session id is chat key with thread name.
+func GetSessionId(chatKey, thread string) string {
+	return chatKey + "#" + thread
+}
+
+// GetActiveSessionId get session id of the active thread in chat.
+func GetActiveSessionId(chatKey string) string {
+	return GetSessionId(chatKey, GetActiveThread(chatKey))
+}
+
+// GetActiveThread get active thread name, default thread if user never switch.
+// it is read from db every time, the thread may be switched by other replicas.
+func GetActiveThread(chatKey string) string {
+	thread := param.DefaultThread
+	querySQL := `SELECT name FROM sessions WHERE chat_key = ? and is_active = 1 and is_deleted = 0 and from_bot = ?`
+	err := DB.QueryRow(querySQL, chatKey, conf.BaseConfInfo.BotName).Scan(&thread)
+	if err != nil && err != sql.ErrNoRows {
+		return param.DefaultThread
+	}
+
+	return thread
+}
+
+// GetSessionByName get thread by name, return nil if not exist.
+func GetSessionByName(chatKey, name string) (*Session, error) {
+	querySQL := `SELECT id, chat_key, name, is_active, create_time, update_time FROM sessions
+		WHERE chat_key = ? and name = ? and is_deleted = 0 and from_bot = ?`
+
+	s := new(Session)
+	err := DB.QueryRow(querySQL, chatKey, name, conf.BaseConfInfo.BotName).Scan(&s.ID, &s.ChatKey,
+		&s.Name, &s.IsActive, &s.CreateTime, &s.UpdateTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSessionsByChatKey get all threads of chat.
+func GetSessionsByChatKey(chatKey string) ([]*Session, error) {
+	querySQL := `SELECT id, chat_key, name, is_active, create_time, update_time FROM sessions
+		WHERE chat_key = ? and is_deleted = 0 and from_bot = ? ORDER BY id ASC`
+
+	rows, err := DB.Query(querySQL, chatKey, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		s := new(Session)
+		if err := rows.Scan(&s.ID, &s.ChatKey, &s.Name, &s.IsActive, &s.CreateTime, &s.UpdateTime); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// InsertSession create thread if not exist.
+func InsertSession(chatKey, name string) error {
+	s, err := GetSessionByName(chatKey, name)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	insertSQL := `INSERT INTO sessions (chat_key, name, is_active, create_time, update_time, is_deleted, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = DB.Exec(insertSQL, chatKey, name, 0, now, now, 0, conf.BaseConfInfo.BotName)
+	return err
+}
+
+// SwitchSession mark thread as the active one of chat in one transaction, so readers never see no active thread.
+func SwitchSession(chatKey, name string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	_, err = tx.Exec(`UPDATE sessions SET is_active = 0, update_time = ? WHERE chat_key = ? and from_bot = ?`,
+		now, chatKey, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE sessions SET is_active = 1, update_time = ? WHERE chat_key = ? and name = ? and is_deleted = 0 and from_bot = ?`,
+		now, chatKey, name, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func TestSessionSwitch(t *testing.T) {
+	chatKey := GetChatKey("telegram", "-1001", "789")
+
+	assert.Equal(t, param.DefaultThread, GetActiveThread(chatKey))
+
+	err := InsertSession(chatKey, param.DefaultThread)
+	assert.NoError(t, err)
+	err = InsertSession(chatKey, "travel")
+	assert.NoError(t, err)
+	// insert again should not duplicate thread
+	err = InsertSession(chatKey, "travel")
+	assert.NoError(t, err)
+
+	sessions, err := GetSessionsByChatKey(chatKey)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(sessions))
+
+	err = SwitchSession(chatKey, "travel")
+	assert.NoError(t, err)
+	assert.Equal(t, GetSessionId(chatKey, "travel"), GetActiveSessionId(chatKey))
+
+	// thread switched by other replica is read from db
+	_, err = DB.Exec(`UPDATE sessions SET is_active = 0 WHERE chat_key = ?`, chatKey)
+	assert.NoError(t, err)
+	_, err = DB.Exec(`UPDATE sessions SET is_active = 1 WHERE chat_key = ? and name = ?`, chatKey, param.DefaultThread)
+	assert.NoError(t, err)
+	assert.Equal(t, param.DefaultThread, GetActiveThread(chatKey))
+
+	s, err := GetSessionByName(chatKey, "not_exist")
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+// Summary is the rolling summary of older conversation in one session.
+type Summary struct {
+	ID         int64  `json:"id"`
+	SessionId  string `json:"session_id"`
+	Content    string `json:"content"`
+	LastTime   int64  `json:"last_time"` // create time of the latest summarized conversation
+	Token      int    `json:"token"`
+	CreateTime int64  `json:"create_time"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+// GetSummary get summary of session, return nil if session is never summarized.
+func GetSummary(sessionId string) (*Summary, error) {
+	querySQL := `SELECT id, session_id, content, last_time, token, create_time, update_time FROM summaries
+		WHERE session_id = ? and is_deleted = 0 and from_bot = ?`
+
+	s := new(Summary)
+	err := DB.QueryRow(querySQL, sessionId, conf.BaseConfInfo.BotName).Scan(&s.ID, &s.SessionId, &s.Content,
+		&s.LastTime, &s.Token, &s.CreateTime, &s.UpdateTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpsertSummary replace summary of session.
+func UpsertSummary(summary *Summary) error {
+	old, err := GetSummary(summary.SessionId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if old == nil {
+		insertSQL := `INSERT INTO summaries (session_id, content, last_time, token, create_time, update_time, is_deleted, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err = DB.Exec(insertSQL, summary.SessionId, summary.Content, summary.LastTime, summary.Token, now, now, 0,
+			conf.BaseConfInfo.BotName)
+		return err
+	}
+
+	updateSQL := `UPDATE summaries SET content = ?, last_time = ?, token = ?, update_time = ? WHERE id = ?`
+	_, err = DB.Exec(updateSQL, summary.Content, summary.LastTime, summary.Token, now, old.ID)
+	return err
+}
+
+// DeleteSummary delete summary of one session
+func DeleteSummary(sessionId string) error {
+	query := `UPDATE summaries set is_deleted = 1, update_time = ? WHERE session_id = ? and from_bot = ?`
+	_, err := DB.Exec(query, time.Now().Unix(), sessionId, conf.BaseConfInfo.BotName)
+	return err
+}
+
+// FilterBySummary drop conversation which is already condensed into summary.
+func FilterBySummary(aqs []*AQ, summary *Summary) []*AQ {
+	if summary == nil {
+		return aqs
+	}
+
+	result := make([]*AQ, 0, len(aqs))
+	for _, aq := range aqs {
+		if aq.CreateTime > summary.LastTime {
+			result = append(result, aq)
+		}
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	ctx := context.Background()
+	sessionId := GetSessionId(GetChatKey("telegram", "S001", "summary"), "default")
+
+	s, err := GetSummary(sessionId)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	err = UpsertSummary(&Summary{SessionId: sessionId, Content: "user is planning a trip", LastTime: 100})
+	assert.NoError(t, err)
+	err = UpsertSummary(&Summary{SessionId: sessionId, Content: "user is planning a trip to Tokyo", LastTime: 200})
+	assert.NoError(t, err)
+
+	s, err = GetSummary(sessionId)
+	assert.NoError(t, err)
+	assert.Equal(t, "user is planning a trip to Tokyo", s.Content)
+	assert.Equal(t, int64(200), s.LastTime)
+
+	aqs := FilterBySummary([]*AQ{
+		{Question: "Q1", CreateTime: 100},
+		{Question: "Q2", CreateTime: 200},
+		{Question: "Q3", CreateTime: 300},
+	}, s)
+	assert.Equal(t, 1, len(aqs))
+	assert.Equal(t, "Q3", aqs[0].Question)
+
+	// clear conversation also reset summary
+	DeleteMsgRecord(ctx, sessionId)
+	s, err = GetSummary(sessionId)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
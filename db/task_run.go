@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+const (
+	TaskRunStatusRunning   = "running"
+	TaskRunStatusDone      = "done"
+	TaskRunStatusFailed    = "failed"
+	TaskRunStatusCancelled = "cancelled"
+	// TaskRunStatusInterrupted is not saved, it is shown for running run which is not touched by any process
+	TaskRunStatusInterrupted = "interrupted"
+
+	TaskStepStatusPending = "pending"
+	TaskStepStatusRunning = "running"
+	TaskStepStatusDone    = "done"
+	TaskStepStatusFailed  = "failed"
+)
+
+// TaskRun one /task execution, plan is the raw plan of the latest loop.
+type TaskRun struct {
+	ID         int64  `json:"id"`
+	UserId     string `json:"user_id"`
+	ChatId     string `json:"chat_id"`
+	SessionId  string `json:"session_id"`
+	Content    string `json:"content"`
+	Status     string `json:"status"`
+	Plan       string `json:"plan"`
+	Loop       int    `json:"loop"`
+	Result     string `json:"result"`
+	Token      int    `json:"token"`
+	ErrorMsg   string `json:"error_msg"`
+	CreateTime int64  `json:"create_time"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+// TaskStep one step of a plan in task run, tool calls are json of the tools called by the step.
+type TaskStep struct {
+	ID          int64    `json:"id"`
+	RunId       int64    `json:"run_id"`
+	Loop        int      `json:"loop"`
+	StepId      string   `json:"step_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	DependsOn   []string `json:"depends_on"`
+	Status      string   `json:"status"`
+	Result      string   `json:"result"`
+	ToolCalls   string   `json:"tool_calls"`
+	Token       int      `json:"token"`
+	ErrorMsg    string   `json:"error_msg"`
+	CreateTime  int64    `json:"create_time"`
+	UpdateTime  int64    `json:"update_time"`
+}
+
+const taskRunSelectFields = "id, user_id, chat_id, session_id, content, status, plan, loop_num, result, token, error_msg, create_time, update_time"
+
+func InsertTaskRun(run *TaskRun) (int64, error) {
+	now := time.Now().Unix()
+	result, err := DB.Exec(`INSERT INTO task_runs (user_id, chat_id, session_id, content, status, plan, loop_num, result, token, error_msg,
+                       create_time, update_time, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.UserId, run.ChatId, run.SessionId, run.Content, run.Status, run.Plan, run.Loop, run.Result, run.Token, run.ErrorMsg,
+		now, now, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return 0, fmt.Errorf("insert task run error: %w", err)
+	}
+
+	run.ID, err = result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("get last insert id error: %w", err)
+	}
+	run.CreateTime, run.UpdateTime = now, now
+	return run.ID, nil
+}
+
+// UpdateTaskRun save status, plan, loop, result, token and error of task run.
+// running status is not written over run cancelled by other process.
+func UpdateTaskRun(run *TaskRun) error {
+	if len(run.ErrorMsg) > 1024 {
+		run.ErrorMsg = run.ErrorMsg[:1024]
+	}
+	run.UpdateTime = time.Now().Unix()
+	whereSQL := "WHERE id = ?"
+	args := []interface{}{run.Status, run.Plan, run.Loop, run.Result, run.Token, run.ErrorMsg, run.UpdateTime, run.ID}
+	if run.Status == TaskRunStatusRunning {
+		whereSQL += " and status = ?"
+		args = append(args, TaskRunStatusRunning)
+	}
+	_, err := DB.Exec(`UPDATE task_runs set status = ?, plan = ?, loop_num = ?, result = ?, token = ?, error_msg = ?, update_time = ? `+whereSQL,
+		args...)
+	return err
+}
+
+// ClaimTaskRun mark run as running to resume it, false if it is done or running in other process.
+// running run not updated since staleTime was interrupted and can be claimed again.
+func ClaimTaskRun(id, staleTime int64) (bool, error) {
+	result, err := DB.Exec(`UPDATE task_runs set status = ?, error_msg = '', update_time = ? WHERE id = ? and from_bot = ?
+		and (status not in (?, ?) or update_time < ?)`,
+		TaskRunStatusRunning, time.Now().Unix(), id, conf.BaseConfInfo.BotName, TaskRunStatusRunning, TaskRunStatusDone, staleTime)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// TouchTaskRun refresh update time of running run, false if it is not running any more.
+func TouchTaskRun(id int64) (bool, error) {
+	result, err := DB.Exec(`UPDATE task_runs set update_time = ? WHERE id = ? and status = ?`,
+		time.Now().Unix(), id, TaskRunStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CancelTaskRun mark running run as cancelled, process executing it stops when it touches the run.
+// false if it is not running or it was interrupted before staleTime.
+func CancelTaskRun(id, staleTime int64) (bool, error) {
+	result, err := DB.Exec(`UPDATE task_runs set status = ?, error_msg = ?, update_time = ? WHERE id = ? and from_bot = ?
+		and status = ? and update_time >= ?`,
+		TaskRunStatusCancelled, context.Canceled.Error(), time.Now().Unix(), id, conf.BaseConfInfo.BotName,
+		TaskRunStatusRunning, staleTime)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetTaskRun get task run by id, nil if not exist.
+func GetTaskRun(id int64) (*TaskRun, error) {
+	run := new(TaskRun)
+	err := DB.QueryRow(fmt.Sprintf("SELECT %s FROM task_runs WHERE id = ? and from_bot = ?", taskRunSelectFields),
+		id, conf.BaseConfInfo.BotName).Scan(&run.ID, &run.UserId, &run.ChatId, &run.SessionId, &run.Content, &run.Status,
+		&run.Plan, &run.Loop, &run.Result, &run.Token, &run.ErrorMsg, &run.CreateTime, &run.UpdateTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetTaskRunsByPage get task runs from the latest one, filtered by user and status if not empty.
+func GetTaskRunsByPage(page, pageSize int, userId, status string) ([]*TaskRun, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	whereSQL, args := taskRunWhere(userId, status)
+	listSQL := fmt.Sprintf(`SELECT %s FROM task_runs %s ORDER BY id DESC LIMIT ? OFFSET ?`, taskRunSelectFields, whereSQL)
+	args = append(args, pageSize, offset)
+
+	rows, err := DB.Query(listSQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query task runs by page error: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*TaskRun, 0)
+	for rows.Next() {
+		run := new(TaskRun)
+		if err := rows.Scan(&run.ID, &run.UserId, &run.ChatId, &run.SessionId, &run.Content, &run.Status,
+			&run.Plan, &run.Loop, &run.Result, &run.Token, &run.ErrorMsg, &run.CreateTime, &run.UpdateTime); err != nil {
+			return nil, fmt.Errorf("scan task run row error: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func GetTaskRunsCount(userId, status string) (int, error) {
+	whereSQL, args := taskRunWhere(userId, status)
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM task_runs "+whereSQL, args...).Scan(&count)
+	return count, err
+}
+
+func taskRunWhere(userId, status string) (string, []interface{}) {
+	whereSQL := "WHERE from_bot = ?"
+	args := []interface{}{conf.BaseConfInfo.BotName}
+	if userId != "" {
+		whereSQL += " AND user_id = ?"
+		args = append(args, userId)
+	}
+	if status != "" {
+		whereSQL += " AND status = ?"
+		args = append(args, status)
+	}
+	return whereSQL, args
+}
+
+func InsertTaskStep(step *TaskStep) (int64, error) {
+	dependsOn, err := json.Marshal(step.DependsOn)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Unix()
+	result, err := DB.Exec(`INSERT INTO task_steps (run_id, loop_num, step_id, name, description, depends_on, status, result, tool_calls, token,
+                        error_msg, create_time, update_time, from_bot) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		step.RunId, step.Loop, step.StepId, step.Name, step.Description, string(dependsOn), step.Status, step.Result, step.ToolCalls,
+		step.Token, step.ErrorMsg, now, now, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return 0, fmt.Errorf("insert task step error: %w", err)
+	}
+
+	step.ID, err = result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("get last insert id error: %w", err)
+	}
+	step.CreateTime, step.UpdateTime = now, now
+	return step.ID, nil
+}
+
+// UpdateTaskStep save status, result, tool calls, token and error of task step.
+func UpdateTaskStep(step *TaskStep) error {
+	if len(step.ErrorMsg) > 1024 {
+		step.ErrorMsg = step.ErrorMsg[:1024]
+	}
+	step.UpdateTime = time.Now().Unix()
+	_, err := DB.Exec(`UPDATE task_steps set status = ?, result = ?, tool_calls = ?, token = ?, error_msg = ?, update_time = ? WHERE id = ?`,
+		step.Status, step.Result, step.ToolCalls, step.Token, step.ErrorMsg, step.UpdateTime, step.ID)
+	return err
+}
+
+// GetTaskSteps get steps of task run in order of loop and plan.
+func GetTaskSteps(runId int64) ([]*TaskStep, error) {
+	rows, err := DB.Query(`SELECT id, run_id, loop_num, step_id, name, description, depends_on, status, result, tool_calls, token, error_msg,
+       create_time, update_time FROM task_steps WHERE run_id = ? and from_bot = ? ORDER BY id`, runId, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := make([]*TaskStep, 0)
+	for rows.Next() {
+		step := new(TaskStep)
+		var dependsOn string
+		if err := rows.Scan(&step.ID, &step.RunId, &step.Loop, &step.StepId, &step.Name, &step.Description, &dependsOn,
+			&step.Status, &step.Result, &step.ToolCalls, &step.Token, &step.ErrorMsg, &step.CreateTime, &step.UpdateTime); err != nil {
+			return nil, err
+		}
+		if dependsOn != "" {
+			if err := json.Unmarshal([]byte(dependsOn), &step.DependsOn); err != nil {
+				return nil, err
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRun(t *testing.T) {
+	run := &TaskRun{UserId: "task_user", ChatId: "1", Content: "research go", Status: TaskRunStatusRunning}
+	id, err := InsertTaskRun(run)
+	assert.NoError(t, err)
+	assert.NotZero(t, id)
+
+	run.Plan = `{"plan":[]}`
+	run.Loop = 1
+	run.Status = TaskRunStatusDone
+	run.Result = "go is a language"
+	run.Token = 10
+	assert.NoError(t, UpdateTaskRun(run))
+
+	got, err := GetTaskRun(id)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskRunStatusDone, got.Status)
+	assert.Equal(t, 1, got.Loop)
+	assert.Equal(t, "go is a language", got.Result)
+
+	got, err = GetTaskRun(-1)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	runs, err := GetTaskRunsByPage(1, 10, "task_user", TaskRunStatusDone)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, runs)
+	assert.Equal(t, id, runs[0].ID)
+
+	count, err := GetTaskRunsCount("task_user", TaskRunStatusDone)
+	assert.NoError(t, err)
+	assert.Equal(t, len(runs), count)
+}
+
+func TestClaimTaskRun(t *testing.T) {
+	run := &TaskRun{UserId: "task_user", Content: "research go", Status: TaskRunStatusFailed}
+	id, err := InsertTaskRun(run)
+	assert.NoError(t, err)
+	staleTime := time.Now().Add(-time.Minute).Unix()
+
+	// only one process claims the run
+	claimed, err := ClaimTaskRun(id, staleTime)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = ClaimTaskRun(id, staleTime)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	running, err := TouchTaskRun(id)
+	assert.NoError(t, err)
+	assert.True(t, running)
+
+	// run cancelled by other process stops on next touch, running status is not written over it
+	cancelled, err := CancelTaskRun(id, staleTime)
+	assert.NoError(t, err)
+	assert.True(t, cancelled)
+	running, err = TouchTaskRun(id)
+	assert.NoError(t, err)
+	assert.False(t, running)
+	run.Status = TaskRunStatusRunning
+	assert.NoError(t, UpdateTaskRun(run))
+	got, err := GetTaskRun(id)
+	assert.NoError(t, err)
+	assert.Equal(t, TaskRunStatusCancelled, got.Status)
+
+	// interrupted run is claimed again
+	claimed, err = ClaimTaskRun(id, staleTime)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	_, err = DB.Exec(`UPDATE task_runs SET update_time = ? WHERE id = ?`, staleTime-1, id)
+	assert.NoError(t, err)
+	cancelled, err = CancelTaskRun(id, staleTime)
+	assert.NoError(t, err)
+	assert.False(t, cancelled)
+	claimed, err = ClaimTaskRun(id, staleTime)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestTaskStep(t *testing.T) {
+	run := &TaskRun{UserId: "task_user", Content: "research go", Status: TaskRunStatusRunning}
+	runId, err := InsertTaskRun(run)
+	assert.NoError(t, err)
+
+	step := &TaskStep{RunId: runId, StepId: "2", Name: "search", Description: "b", DependsOn: []string{"1"},
+		Status: TaskStepStatusPending}
+	_, err = InsertTaskStep(step)
+	assert.NoError(t, err)
+
+	step.Status = TaskStepStatusDone
+	step.Result = "result of b"
+	step.ToolCalls = `[{"name":"search"}]`
+	assert.NoError(t, UpdateTaskStep(step))
+
+	steps, err := GetTaskSteps(runId)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 1)
+	assert.Equal(t, []string{"1"}, steps[0].DependsOn)
+	assert.Equal(t, TaskStepStatusDone, steps[0].Status)
+	assert.Equal(t, "result of b", steps[0].Result)
+	assert.Equal(t, `[{"name":"search"}]`, steps[0].ToolCalls)
+}
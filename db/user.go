@@ -20,6 +20,9 @@ type User struct {
 	AvailToken   int              `json:"avail_token"`
 	LLMConfig    string           `json:"llm_config"`
 	LLMConfigRaw *param.LLMConfig `json:"llm_config_raw"`
+	Cost         float64          `json:"cost"`
+	Plan         string           `json:"plan"`
+	Role         string           `json:"role"` // resolved from role bindings, not saved in users
 }
 
 // InsertUser insert user data
@@ -50,12 +53,12 @@ func InsertUser(userId string, llmConfig string) (int64, error) {
 // GetUserByID get user by userId
 func GetUserByID(userId string) (*User, error) {
 	// select one use base on name
-	querySQL := `SELECT id, user_id, llm_config, token, avail_token, update_time, create_time FROM users WHERE user_id = ?`
+	querySQL := `SELECT id, user_id, llm_config, token, avail_token, update_time, create_time, cost, plan FROM users WHERE user_id = ?`
 	row := DB.QueryRow(querySQL, userId)
 
 	// scan row get result
 	var user User
-	err := row.Scan(&user.ID, &user.UserId, &user.LLMConfig, &user.Token, &user.AvailToken, &user.UpdateTime, &user.CreateTime)
+	err := row.Scan(&user.ID, &user.UserId, &user.LLMConfig, &user.Token, &user.AvailToken, &user.UpdateTime, &user.CreateTime, &user.Cost, &user.Plan)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// 如果没有找到数据，返回 nil
@@ -112,12 +115,29 @@ func AddAvailToken(userId string, token int) error {
 	return err
 }
 
+// UpdateUserPlan update quota plan of user
+func UpdateUserPlan(userId string, plan string) error {
+	updateSQL := `UPDATE users SET plan = ?, update_time = ? WHERE user_id = ?`
+	_, err := DB.Exec(updateSQL, plan, time.Now().Unix(), userId)
+	return err
+}
+
 func AddToken(userId string, token int) error {
 	updateSQL := `UPDATE users SET token = token + ?, update_time = ? WHERE user_id = ?`
 	_, err := DB.Exec(updateSQL, token, time.Now().Unix(), userId)
 	return err
 }
 
+// AddCost add spend of user
+func AddCost(userId string, cost float64) error {
+	if cost == 0 {
+		return nil
+	}
+	updateSQL := `UPDATE users SET cost = cost + ?, update_time = ? WHERE user_id = ?`
+	_, err := DB.Exec(updateSQL, cost, time.Now().Unix(), userId)
+	return err
+}
+
 func GetUserByPage(page, pageSize int, userId string) ([]User, error) {
 	if page < 1 {
 		page = 1
@@ -140,7 +160,7 @@ func GetUserByPage(page, pageSize int, userId string) ([]User, error) {
 
 	// 查询数据
 	listSQL := fmt.Sprintf(`
-		SELECT id, user_id, llm_config, token, update_time, avail_token, create_time
+		SELECT id, user_id, llm_config, token, update_time, avail_token, create_time, cost, plan
 		FROM users %s
 		ORDER BY id DESC
 		LIMIT ? OFFSET ?`, whereSQL)
@@ -155,7 +175,7 @@ func GetUserByPage(page, pageSize int, userId string) ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.UserId, &u.LLMConfig, &u.Token, &u.UpdateTime, &u.AvailToken, &u.CreateTime); err != nil {
+		if err := rows.Scan(&u.ID, &u.UserId, &u.LLMConfig, &u.Token, &u.UpdateTime, &u.AvailToken, &u.CreateTime, &u.Cost, &u.Plan); err != nil {
 			return nil, err
 		}
 		if u.LLMConfig != "" {
@@ -257,3 +277,13 @@ func GetCtxUserInfo(ctx context.Context) *User {
 
 	return nil
 }
+
+// WithLLMConfig copy user info of ctx with another llm config, the user in db is not changed.
+func WithLLMConfig(ctx context.Context, llmConf *param.LLMConfig) context.Context {
+	userInfo := new(User)
+	if old := GetCtxUserInfo(ctx); old != nil {
+		*userInfo = *old
+	}
+	userInfo.LLMConfigRaw = llmConf
+	return context.WithValue(ctx, "user_info", userInfo)
+}
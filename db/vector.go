@@ -0,0 +1,114 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+// Vector is one embedded chunk of local vector store.
+type Vector struct {
+	ID         int64          `json:"id"`
+	Space      string         `json:"space"`
+	Content    string         `json:"content"`
+	Metadata   map[string]any `json:"metadata"`
+	Embedding  []float32      `json:"embedding"`
+	CreateTime int64          `json:"create_time"`
+}
+
+// InsertVectors insert vectors in one transaction, ids are set after insert.
+func InsertVectors(vectors []*Vector) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO vectors (space, content, metadata, embedding, create_time, from_bot) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, v := range vectors {
+		metadata, err := json.Marshal(v.Metadata)
+		if err != nil {
+			return err
+		}
+		v.CreateTime = time.Now().Unix()
+		result, err := stmt.Exec(v.Space, v.Content, string(metadata), encodeEmbedding(v.Embedding), v.CreateTime, conf.BaseConfInfo.BotName)
+		if err != nil {
+			return err
+		}
+		v.ID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetVectorsBySpace get all vectors of space.
+func GetVectorsBySpace(space string) ([]*Vector, error) {
+	rows, err := DB.Query(`SELECT id, space, content, metadata, embedding, create_time FROM vectors WHERE space = ? and from_bot = ?`,
+		space, conf.BaseConfInfo.BotName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vectors := make([]*Vector, 0)
+	for rows.Next() {
+		v := new(Vector)
+		var metadata string
+		var embedding []byte
+		if err := rows.Scan(&v.ID, &v.Space, &v.Content, &metadata, &embedding, &v.CreateTime); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(metadata), &v.Metadata); err != nil {
+			return nil, err
+		}
+		v.Embedding = decodeEmbedding(embedding)
+		vectors = append(vectors, v)
+	}
+
+	return vectors, rows.Err()
+}
+
+// DeleteVectorsByIds delete vectors by ids.
+func DeleteVectorsByIds(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, conf.BaseConfInfo.BotName)
+
+	placeholders := strings.TrimRight(strings.Repeat("?,", len(ids)), ",")
+	_, err := DB.Exec(`DELETE FROM vectors WHERE id in (`+placeholders+`) and from_bot = ?`, args...)
+	return err
+}
+
+func encodeEmbedding(embedding []float32) []byte {
+	buf := make([]byte, 4*len(embedding))
+	for i, f := range embedding {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeEmbedding(buf []byte) []float32 {
+	embedding := make([]float32, len(buf)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return embedding
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertGetDeleteVectors(t *testing.T) {
+	space := "vector_test"
+	vectors := []*Vector{
+		{Space: space, Content: "hello", Metadata: map[string]any{"file_name": "a.txt"}, Embedding: []float32{0.1, 0.2, 0.3}},
+		{Space: space, Content: "world", Metadata: map[string]any{"file_name": "b.txt"}, Embedding: []float32{-1, 0, 1}},
+	}
+
+	// 插入
+	err := InsertVectors(vectors)
+	assert.NoError(t, err)
+	assert.NotZero(t, vectors[0].ID)
+	assert.NotZero(t, vectors[1].ID)
+
+	// 按 space 查询
+	res, err := GetVectorsBySpace(space)
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "hello", res[0].Content)
+	assert.Equal(t, "a.txt", res[0].Metadata["file_name"])
+	assert.Equal(t, []float32{0.1, 0.2, 0.3}, res[0].Embedding)
+
+	// 删除
+	err = DeleteVectorsByIds([]int64{vectors[0].ID})
+	assert.NoError(t, err)
+
+	res, err = GetVectorsBySpace(space)
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "world", res[0].Content)
+}
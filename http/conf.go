@@ -14,8 +14,10 @@ import (
 	"time"
 
 	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
 	"github.com/yincongcyincong/MuseBot/utils"
 	"github.com/yincongcyincong/mcp-client-go/clients"
 	mcpParam "github.com/yincongcyincong/mcp-client-go/clients/param"
@@ -37,6 +39,10 @@ func GetCommand(w http.ResponseWriter, r *http.Request) {
 	res += CompareFlagsWithStructTags(conf.RagConfInfo)
 	res += CompareFlagsWithStructTags(conf.VideoConfInfo)
 	res += CompareFlagsWithStructTags(conf.ToolsConfInfo)
+	res += CompareFlagsWithStructTags(conf.ProviderConfInfo)
+	res += CompareFlagsWithStructTags(conf.QuotaConfInfo)
+	res += CompareFlagsWithStructTags(conf.RBACConfInfo)
+	res += CompareFlagsWithStructTags(conf.RateLimitConfInfo)
 
 	utils.Success(ctx, w, r, res)
 }
@@ -66,6 +72,37 @@ func UpdateConf(w http.ResponseWriter, r *http.Request) {
 		err = utils.SetStructFieldByJSONTag(conf.RagConfInfo, updateConfParam.Key, updateConfParam.Value)
 	case "video":
 		err = utils.SetStructFieldByJSONTag(conf.VideoConfInfo, updateConfParam.Key, updateConfParam.Value)
+	case "provider":
+		err = utils.SetStructFieldByJSONTag(conf.ProviderConfInfo, updateConfParam.Key, updateConfParam.Value)
+		if err == nil {
+			err = conf.ParseCustomProviders()
+		}
+		if err == nil {
+			err = conf.ParsePricing()
+		}
+		if err == nil {
+			provider.LoadCustomProviders()
+		}
+	case "quota":
+		err = utils.SetStructFieldByJSONTag(conf.QuotaConfInfo, updateConfParam.Key, updateConfParam.Value)
+		if err == nil {
+			err = conf.ParseQuotaPlans()
+		}
+	case "rbac":
+		err = utils.SetStructFieldByJSONTag(conf.RBACConfInfo, updateConfParam.Key, updateConfParam.Value)
+		if err == nil {
+			err = conf.ParseRolePolicies()
+		}
+	case "rate_limit":
+		err = utils.SetStructFieldByJSONTag(conf.RateLimitConfInfo, updateConfParam.Key, updateConfParam.Value)
+		if err == nil {
+			err = conf.ParseRateLimits()
+		}
+	case "tools":
+		err = utils.SetStructFieldByJSONTag(conf.ToolsConfInfo, updateConfParam.Key, updateConfParam.Value)
+		if err == nil {
+			err = conf.ParseMcpPolicies()
+		}
 	default:
 		logger.ErrorCtx(ctx, "update conf error", "type", updateConfParam.Type)
 		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
@@ -91,6 +128,10 @@ func GetConf(w http.ResponseWriter, r *http.Request) {
 	res["photo"] = conf.PhotoConfInfo
 	res["rag"] = conf.RagConfInfo
 	res["video"] = conf.VideoConfInfo
+	res["provider"] = conf.ProviderConfInfo
+	res["quota"] = conf.QuotaConfInfo
+	res["rbac"] = conf.RBACConfInfo
+	res["rate_limit"] = conf.RateLimitConfInfo
 
 	utils.Success(ctx, w, r, res)
 }
@@ -355,3 +396,37 @@ func CompareFlagsWithStructTags(cfg interface{}) string {
 
 	return res
 }
+
+// GetMcpAudits list tool calls denied or waiting for approval by mcp policies.
+func GetMcpAudits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	page := utils.ParseInt(r.FormValue("page"))
+	pageSize := utils.ParseInt(r.FormValue("page_size"))
+	userId := r.FormValue("user_id")
+	decision := r.FormValue("decision")
+
+	audits, err := db.GetMcpAuditsByPage(page, pageSize, userId, decision)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get mcp audits error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	total, err := db.GetMcpAuditsCount(userId, decision)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get mcp audits count error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, map[string]interface{}{
+		"list":  audits,
+		"total": total,
+	})
+}
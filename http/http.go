@@ -51,6 +51,12 @@ func (p *HTTPServer) Start() {
 		mux.Handle("/metrics", promhttp.Handler())
 
 		mux.HandleFunc("/user/token/add", AddUserToken)
+		mux.HandleFunc("/user/plan/update", UpdateUserPlan)
+		mux.HandleFunc("/user/role/list", GetUserRoles)
+		mux.HandleFunc("/user/role/update", UpdateUserRole)
+		mux.HandleFunc("/user/policy/get", GetRolePolicies)
+		mux.HandleFunc("/user/policy/update", UpdateRolePolicy)
+		mux.HandleFunc("/user/policy/delete", DeleteRolePolicy)
 
 		mux.HandleFunc("/conf/update", UpdateConf)
 		mux.HandleFunc("/conf/get", GetConf)
@@ -64,6 +70,7 @@ func (p *HTTPServer) Start() {
 		mux.HandleFunc("/mcp/disable", DisableMCPConf)
 		mux.HandleFunc("/mcp/delete", DeleteMCPConf)
 		mux.HandleFunc("/mcp/sync", SyncMCPConf)
+		mux.HandleFunc("/mcp/audit/list", GetMcpAudits)
 
 		mux.HandleFunc("/user/list", GetUsers)
 		mux.HandleFunc("/user/insert/record", InsertUserRecords)
@@ -74,6 +81,10 @@ func (p *HTTPServer) Start() {
 		mux.HandleFunc("/rag/create", CreateRagFile)
 		mux.HandleFunc("/rag/get", GetRagFileContent)
 		mux.HandleFunc("/rag/clear", ClearAllVectorData)
+		mux.HandleFunc("/rag/kb/list", GetKnowledgeBases)
+		mux.HandleFunc("/rag/reindex", ReindexRagFile)
+		mux.HandleFunc("/rag/export", ExportRagFile)
+		mux.HandleFunc("/rag/import", ImportRagFile)
 
 		mux.HandleFunc("/pong", PongHandler)
 		mux.HandleFunc("/dashboard", DashboardHandler)
@@ -90,6 +101,10 @@ func (p *HTTPServer) Start() {
 		mux.HandleFunc("/cron/delete", DeleteCron)
 		mux.HandleFunc("/cron/list", GetCrons)
 
+		mux.HandleFunc("/task/list", GetTaskRuns)
+		mux.HandleFunc("/task/get", GetTaskRun)
+		mux.HandleFunc("/task/cancel", CancelTask)
+
 		mux.HandleFunc("/image", imageHandler)
 
 		wrappedMux := WithRequestContext(mux)
@@ -1,9 +1,13 @@
 package http
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/yincongcyincong/MuseBot/conf"
@@ -15,11 +19,17 @@ import (
 )
 
 type RagFile struct {
+	KB       string `json:"kb"`
 	FileName string `json:"file_name"`
 	Content  string `json:"content"`
 }
 
 func CreateRagFile(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		UploadRagFile(w, r)
+		return
+	}
+
 	ctx := r.Context()
 	ragFile := &RagFile{}
 	err := utils.HandleJsonBody(r, ragFile)
@@ -29,10 +39,29 @@ func CreateRagFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := conf.RagConfInfo.KnowledgePath + "/" + ragFile.FileName
+	kb := conf.RagConfInfo.GetKnowledgeBase(ragFile.KB)
+	if kb == nil {
+		logger.ErrorCtx(ctx, "knowledge base not exist", "kb", ragFile.KB)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, "knowledge base not exist")
+		return
+	}
+
+	path, err := rag.KnowledgeFilePath(kb, ragFile.FileName)
+	if err != nil {
+		logger.ErrorCtx(ctx, "file name error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
 	_, err = os.Stat(path)
 	fileNotExist := os.IsNotExist(err)
 
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		logger.ErrorCtx(ctx, "create dir error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
 	err = os.WriteFile(path, []byte(ragFile.Content), 0644)
 	if err != nil {
 		logger.ErrorCtx(ctx, "write file error", "err", err)
@@ -40,8 +69,8 @@ func CreateRagFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if fileNotExist && conf.RagConfInfo.Store == nil {
-		_, err = db.InsertRagFile(ragFile.FileName, "")
+	if fileNotExist && kb.Store == nil {
+		_, err = db.InsertRagFile(kb.Name, ragFile.FileName, "")
 		if err != nil {
 			logger.ErrorCtx(ctx, "delete dir error", "err", err)
 			utils.Failure(ctx, w, r, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
@@ -52,6 +81,93 @@ func CreateRagFile(w http.ResponseWriter, r *http.Request) {
 	utils.Success(ctx, w, r, nil)
 }
 
+// UploadRagFile learn multipart file into knowledge base, file_name is the uploaded name under learn dir if empty.
+func UploadRagFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse multipart form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		logger.ErrorCtx(ctx, "get form file error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		logger.ErrorCtx(ctx, "read form file error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	kb := conf.RagConfInfo.GetKnowledgeBase(r.FormValue("kb"))
+	if kb == nil {
+		logger.ErrorCtx(ctx, "knowledge base not exist", "kb", r.FormValue("kb"))
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, "knowledge base not exist")
+		return
+	}
+
+	fileName := r.FormValue("file_name")
+	if fileName == "" {
+		fileName = rag.LearnUploadName(header.Filename, "")
+	}
+
+	if kb.Store == nil {
+		path, err := rag.KnowledgeFilePath(kb, fileName)
+		if err != nil {
+			logger.ErrorCtx(ctx, "file name error", "err", err)
+			utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+			return
+		}
+		_, err = os.Stat(path)
+		fileNotExist := os.IsNotExist(err)
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			logger.ErrorCtx(ctx, "create dir error", "err", err)
+			utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+			return
+		}
+		err = os.WriteFile(path, content, 0644)
+		if err != nil {
+			logger.ErrorCtx(ctx, "write file error", "err", err)
+			utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+			return
+		}
+
+		if fileNotExist {
+			_, err = db.InsertRagFile(kb.Name, fileName, "")
+			if err != nil {
+				logger.ErrorCtx(ctx, "insert rag file error", "err", err)
+				utils.Failure(ctx, w, r, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
+				return
+			}
+		}
+		utils.Success(ctx, w, r, map[string]interface{}{
+			"file_name": fileName,
+		})
+		return
+	}
+
+	chunks, err := rag.LearnFile(ctx, kb, fileName, content, "upload:"+header.Filename)
+	if err != nil {
+		logger.ErrorCtx(ctx, "learn file error", "file", fileName, "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, map[string]interface{}{
+		"file_name": fileName,
+		"chunks":    chunks,
+	})
+}
+
 func GetRagFileContent(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	err := r.ParseForm()
@@ -68,7 +184,19 @@ func GetRagFileContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := conf.RagConfInfo.KnowledgePath + "/" + name
+	kb := conf.RagConfInfo.GetKnowledgeBase(r.FormValue("kb"))
+	if kb == nil {
+		logger.ErrorCtx(ctx, "knowledge base not exist", "kb", r.FormValue("kb"))
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, "knowledge base not exist")
+		return
+	}
+
+	path, err := rag.KnowledgeFilePath(kb, name)
+	if err != nil {
+		logger.ErrorCtx(ctx, "file name error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
 	file, err := os.Open(path)
 	if err != nil {
 		logger.ErrorCtx(ctx, "open file error", "err", err)
@@ -101,15 +229,28 @@ func DeleteRagFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	fileName := r.FormValue("file_name")
-	err = os.Remove(conf.RagConfInfo.KnowledgePath + "/" + fileName)
+	kb := conf.RagConfInfo.GetKnowledgeBase(r.FormValue("kb"))
+	if kb == nil {
+		logger.ErrorCtx(ctx, "knowledge base not exist", "kb", r.FormValue("kb"))
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, "knowledge base not exist")
+		return
+	}
+
+	path, err := rag.KnowledgeFilePath(kb, fileName)
+	if err != nil {
+		logger.ErrorCtx(ctx, "file name error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	err = os.Remove(path)
 	if err != nil {
 		logger.ErrorCtx(ctx, "delete file error", "err", err)
 		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
 		return
 	}
 
-	if conf.RagConfInfo.Store == nil {
-		err = db.DeleteRagFileByFileName(fileName)
+	if kb.Store == nil {
+		err = db.DeleteRagFileByFileName(kb.Name, fileName)
 		if err != nil {
 			logger.ErrorCtx(ctx, "delete dir error", "err", err)
 			utils.Failure(ctx, w, r, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
@@ -131,15 +272,16 @@ func GetRagFile(w http.ResponseWriter, r *http.Request) {
 	page := utils.ParseInt(r.FormValue("page"))
 	pageSize := utils.ParseInt(r.FormValue("page_size"))
 	name := r.FormValue("name")
+	kb := r.FormValue("kb")
 
-	ragFiles, err := db.GetRagFilesByPage(page, pageSize, name)
+	ragFiles, err := db.GetRagFilesByPage(page, pageSize, name, kb)
 	if err != nil {
 		logger.ErrorCtx(ctx, "get user error", "err", err)
 		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
 		return
 	}
 
-	total, err := db.GetRagFilesCount(name)
+	total, err := db.GetRagFilesCount(name, kb)
 	if err != nil {
 		logger.ErrorCtx(ctx, "get user count error", "err", err)
 		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBWriteFail, err)
@@ -159,7 +301,7 @@ func ClearAllVectorData(w http.ResponseWriter, r *http.Request) {
 		page := 1
 		pageSize := 10
 		for {
-			ragFiles, err := db.GetRagFilesByPage(page, pageSize, "")
+			ragFiles, err := db.GetRagFilesByPage(page, pageSize, "", r.FormValue("kb"))
 			if err != nil {
 				logger.ErrorCtx(ctx, "get user error", "err", err)
 				utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
@@ -167,14 +309,18 @@ func ClearAllVectorData(w http.ResponseWriter, r *http.Request) {
 			}
 
 			for _, ragFile := range ragFiles {
-				err = db.DeleteRagFileByFileName(ragFile.FileName)
+				err = db.DeleteRagFileByFileName(ragFile.KB, ragFile.FileName)
 				if err != nil {
 					logger.ErrorCtx(ctx, "delete dir error", "err", err)
 					utils.Failure(ctx, w, r, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
 					return
 				}
 
-				err = rag.DeleteStoreData(ctx, ragFile.VectorId)
+				kb := conf.RagConfInfo.GetKnowledgeBase(ragFile.KB)
+				if kb == nil || kb.Store == nil {
+					continue
+				}
+				err = rag.DeleteStoreData(ctx, kb, ragFile.VectorId)
 				if err != nil {
 					logger.ErrorCtx(ctx, "delete dir error", "err", err)
 					utils.Failure(ctx, w, r, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
@@ -188,3 +334,130 @@ func ClearAllVectorData(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// ReindexRagFile index file again, all files of knowledge base if file_name is empty, all knowledge bases if kb is empty too.
+func ReindexRagFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	fileName := r.FormValue("file_name")
+	kbs := make([]*conf.KnowledgeBase, 0)
+	if r.FormValue("kb") == "" && fileName == "" {
+		for _, kb := range conf.RagConfInfo.KBs {
+			kbs = append(kbs, kb)
+		}
+	} else {
+		kb := conf.RagConfInfo.GetKnowledgeBase(r.FormValue("kb"))
+		if kb == nil {
+			logger.ErrorCtx(ctx, "knowledge base not exist", "kb", r.FormValue("kb"))
+			utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, "knowledge base not exist")
+			return
+		}
+		kbs = append(kbs, kb)
+	}
+
+	total := 0
+	for _, kb := range kbs {
+		if kb.Store == nil {
+			continue
+		}
+		num, err := rag.Reindex(ctx, kb, fileName)
+		total += num
+		if err != nil {
+			logger.ErrorCtx(ctx, "reindex error", "kb", kb.Name, "err", err)
+			utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+			return
+		}
+	}
+
+	utils.Success(ctx, w, r, map[string]interface{}{
+		"total": total,
+	})
+}
+
+// ExportRagFile download zip bundle of knowledge base, it can be imported by /rag/import of another bot.
+func ExportRagFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	kb := conf.RagConfInfo.GetKnowledgeBase(r.FormValue("kb"))
+	if kb == nil {
+		logger.ErrorCtx(ctx, "knowledge base not exist", "kb", r.FormValue("kb"))
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, "knowledge base not exist")
+		return
+	}
+
+	// bundle is built in memory, so error can still be returned as json
+	buf := new(bytes.Buffer)
+	err = rag.ExportKnowledgeBase(ctx, kb, buf)
+	if err != nil {
+		logger.ErrorCtx(ctx, "export knowledge base error", "kb", kb.Name, "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, kb.Name))
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		logger.ErrorCtx(ctx, "write bundle error", "kb", kb.Name, "err", err)
+	}
+}
+
+// ImportRagFile restore multipart zip bundle into knowledge base kb.
+func ImportRagFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse multipart form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	kb := conf.RagConfInfo.GetKnowledgeBase(r.FormValue("kb"))
+	if kb == nil || kb.Store == nil {
+		logger.ErrorCtx(ctx, "knowledge base not exist", "kb", r.FormValue("kb"))
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, "knowledge base not exist")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		logger.ErrorCtx(ctx, "get form file error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	defer file.Close()
+
+	res, err := rag.ImportKnowledgeBase(ctx, kb, file, header.Size)
+	if err != nil {
+		logger.ErrorCtx(ctx, "import knowledge base error", "kb", kb.Name, "err", err)
+		utils.Failure(ctx, w, r, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, res)
+}
+
+func GetKnowledgeBases(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	kbs := make([]*conf.KnowledgeBase, 0, len(conf.RagConfInfo.KBs))
+	for _, kb := range conf.RagConfInfo.KBs {
+		kbs = append(kbs, kb)
+	}
+	sort.Slice(kbs, func(i, j int) bool {
+		return kbs[i].Name < kbs[j].Name
+	})
+
+	utils.Success(ctx, w, r, kbs)
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+type UserRole struct {
+	SubjectType string `json:"subject_type"`
+	SubjectId   string `json:"subject_id"`
+	Role        string `json:"role"`
+}
+
+func GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	page := utils.ParseInt(r.FormValue("page"))
+	pageSize := utils.ParseInt(r.FormValue("page_size"))
+	subjectType := r.FormValue("subject_type")
+
+	bindings, err := db.GetRoleBindingsByPage(page, pageSize, subjectType)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get role bindings error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	total, err := db.GetRoleBindingCount(subjectType)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get role binding count error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, map[string]interface{}{
+		"list":  bindings,
+		"total": total,
+	})
+}
+
+// UpdateUserRole assign role to user or group, empty role remove the assignment.
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userRole := &UserRole{}
+	err := utils.HandleJsonBody(r, userRole)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse json body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	if (userRole.SubjectType != param.RoleSubjectUser && userRole.SubjectType != param.RoleSubjectGroup) || userRole.SubjectId == "" {
+		logger.ErrorCtx(ctx, "invalid role subject", "subject_type", userRole.SubjectType, "subject_id", userRole.SubjectId)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, nil)
+		return
+	}
+
+	if userRole.Role != "" && conf.RBACConfInfo.GetPolicy(userRole.Role) == nil {
+		logger.ErrorCtx(ctx, "role policy not exist", "role", userRole.Role)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, nil)
+		return
+	}
+
+	err = db.UpdateRole(userRole.SubjectType, userRole.SubjectId, userRole.Role)
+	if err != nil {
+		logger.ErrorCtx(ctx, "update role error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, "success")
+}
+
+func GetRolePolicies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	utils.Success(ctx, w, r, map[string]interface{}{
+		"list":         conf.RBACConfInfo.ListPolicies(),
+		"default_role": conf.RBACConfInfo.DefaultRole,
+	})
+}
+
+func UpdateRolePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	policy := &conf.RolePolicy{}
+	err := utils.HandleJsonBody(r, policy)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse json body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	if policy.Name == "" {
+		logger.ErrorCtx(ctx, "role name is empty")
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, errors.New("role name is empty"))
+		return
+	}
+
+	err = conf.SetRolePolicy(policy)
+	if err != nil {
+		logger.ErrorCtx(ctx, "update role policy error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, "success")
+}
+
+func DeleteRolePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	policy := &conf.RolePolicy{}
+	err := utils.HandleJsonBody(r, policy)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse json body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	err = conf.DeleteRolePolicy(policy.Name)
+	if err != nil {
+		logger.ErrorCtx(ctx, "delete role policy error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, "success")
+}
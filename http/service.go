@@ -57,11 +57,28 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	totalCost, err := db.GetTotalCost()
+	if err != nil {
+		logger.ErrorCtx(ctx, "get total cost error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	costDayCount, err := db.GetDailyCost(day)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get daily cost error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
 	utils.Success(ctx, w, r, map[string]interface{}{
 		"record_count":     recordCount,
 		"user_count":       userCount,
 		"user_day_count":   userDayCount,
 		"record_day_count": recordDayCount,
+		"total_cost":       totalCost,
+		"cost_day_count":   costDayCount,
+		"currency":         conf.ProviderConfInfo.Currency,
 		"start_time":       conf.BaseConfInfo.StartTime,
 	})
 
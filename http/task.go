@@ -0,0 +1,114 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+// GetTaskRuns list task runs, running run which is not executing is shown as interrupted.
+func GetTaskRuns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	page := utils.ParseInt(r.FormValue("page"))
+	pageSize := utils.ParseInt(r.FormValue("page_size"))
+	userId := r.FormValue("user_id")
+	status := r.FormValue("status")
+
+	runs, err := db.GetTaskRunsByPage(page, pageSize, userId, status)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get task runs error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	for _, run := range runs {
+		run.Status = llm.TaskRunStatus(run)
+	}
+
+	total, err := db.GetTaskRunsCount(userId, status)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get task runs count error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	result := map[string]interface{}{
+		"list":  runs,
+		"total": total,
+	}
+	utils.Success(ctx, w, r, result)
+}
+
+// GetTaskRun get task run with steps of all loops.
+func GetTaskRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse id error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	run, err := db.GetTaskRun(id)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get task run error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	if run == nil {
+		utils.Failure(ctx, w, r, param.CodeParamError, "task run not found", nil)
+		return
+	}
+	run.Status = llm.TaskRunStatus(run)
+
+	steps, err := db.GetTaskSteps(id)
+	if err != nil {
+		logger.ErrorCtx(ctx, "get task steps error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, map[string]interface{}{
+		"run":   run,
+		"steps": steps,
+	})
+}
+
+// CancelTask cancel task run executing in this bot.
+func CancelTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse form error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse id error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	if !llm.CancelTaskRun(id) {
+		utils.Failure(ctx, w, r, param.CodeParamError, "task run is not running", nil)
+		return
+	}
+	utils.Success(ctx, w, r, nil)
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"net/http"
 
+	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/param"
@@ -34,6 +35,37 @@ func AddUserToken(w http.ResponseWriter, r *http.Request) {
 	utils.Success(ctx, w, r, "success")
 }
 
+type UserPlan struct {
+	UserID string `json:"user_id"`
+	Plan   string `json:"plan"`
+}
+
+func UpdateUserPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userPlan := &UserPlan{}
+	err := utils.HandleJsonBody(r, userPlan)
+	if err != nil {
+		logger.ErrorCtx(ctx, "parse json body error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+
+	if _, ok := conf.QuotaConfInfo.Plans[userPlan.Plan]; userPlan.Plan != "" && !ok {
+		logger.ErrorCtx(ctx, "quota plan not exist", "plan", userPlan.Plan)
+		utils.Failure(ctx, w, r, param.CodeParamError, param.MsgParamError, nil)
+		return
+	}
+
+	err = db.UpdateUserPlan(userPlan.UserID, userPlan.Plan)
+	if err != nil {
+		logger.ErrorCtx(ctx, "update user plan error", "err", err)
+		utils.Failure(ctx, w, r, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
+		return
+	}
+
+	utils.Success(ctx, w, r, "success")
+}
+
 func GetUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	// 解析参数
@@ -124,6 +156,10 @@ func InsertUserRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if userRecords.SessionId == "" {
+		userRecords.SessionId = db.GetActiveSessionId(db.GetChatKey(param.Web, userRecords.UserId, userRecords.UserId))
+	}
+
 	err = db.InsertUserRecords(userRecords)
 	if err != nil {
 		logger.ErrorCtx(ctx, "change user mode error", "err", err)
@@ -132,7 +168,7 @@ func InsertUserRecords(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, aq := range userRecords.Records {
-		db.InsertMsgRecord(ctx, userRecords.UserId, aq, false)
+		db.InsertMsgRecord(ctx, userRecords.SessionId, userRecords.UserId, aq, false)
 	}
 
 	utils.Success(ctx, w, r, "success")
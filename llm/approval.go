@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+const approvalArgumentsShowLen = 500
+
+var (
+	ErrApprovalNotExist = errors.New("approval not exist or already decided")
+	ErrApprovalNotOwner = errors.New("approval is requested by other user")
+)
+
+// ToolApprover post approval prompt of tool call with buttons to the requesting user, it is set by robot package.
+// false if it can't post the prompt, the prompt is sent as message with reply commands instead.
+var ToolApprover func(ctx context.Context, audit *db.McpAudit) bool
+
+// pendingApprovals audit id -> tool call waiting for approval in this process
+var pendingApprovals = sync.Map{}
+
+type toolApproval struct {
+	userId   string
+	decision chan *approvalDecision
+}
+
+// approvalDecision decision and the user who made it.
+type approvalDecision struct {
+	decision string
+	userId   string
+}
+
+// DecideToolApproval approve or reject tool call waiting for approval, only the requesting user can decide it.
+func DecideToolApproval(id int64, userId string, approved bool) error {
+	value, ok := pendingApprovals.Load(id)
+	if !ok {
+		return ErrApprovalNotExist
+	}
+	approval := value.(*toolApproval)
+	if userId == "" || approval.userId != userId {
+		return ErrApprovalNotOwner
+	}
+
+	decision := &approvalDecision{decision: db.McpAuditRejected, userId: userId}
+	if approved {
+		decision.decision = db.McpAuditApproved
+	}
+	select {
+	case approval.decision <- decision:
+		return nil
+	default:
+		return ErrApprovalNotExist
+	}
+}
+
+// ApprovalMessage prompt of tool call approval, arguments are truncated.
+func ApprovalMessage(audit *db.McpAudit) string {
+	arguments := []rune(audit.Arguments)
+	if len(arguments) > approvalArgumentsShowLen {
+		arguments = append(arguments[:approvalArgumentsShowLen], []rune("...")...)
+	}
+	return i18n.GetMessage("mcp_approval_request", map[string]interface{}{
+		"id":        audit.ID,
+		"server":    audit.Server,
+		"tool":      audit.Tool,
+		"arguments": string(arguments),
+	})
+}
+
+// checkMcpPolicy deny tool call or wait for user to approve it according to mcp policies,
+// denied and approval required calls are recorded in audit log.
+func (l *LLM) checkMcpPolicy(ctx context.Context, server, funcName string, property map[string]interface{}) error {
+	action := conf.ToolsConfInfo.McpAction(server, funcName)
+	if action == param.McpActionAllow {
+		return nil
+	}
+
+	arguments, err := json.Marshal(property)
+	if err != nil {
+		logger.ErrorCtx(ctx, "marshal tool arguments fail", "err", err)
+	}
+	audit := &db.McpAudit{
+		UserId:    l.UserId,
+		ChatId:    l.ChatId,
+		Server:    server,
+		Tool:      funcName,
+		Arguments: string(arguments),
+		Action:    action,
+		Decision:  db.McpAuditDenied,
+	}
+
+	if action == param.McpActionDeny {
+		logger.WarnCtx(ctx, "mcp tool denied by policy", "mcp", server, "function", funcName)
+		if _, err = db.InsertMcpAudit(audit); err != nil {
+			logger.ErrorCtx(ctx, "insert mcp audit fail", "err", err)
+		}
+		return fmt.Errorf("permission denied: tool %s of mcp server %s is denied by policy", funcName, server)
+	}
+
+	// only the requesting user can approve, call without user is denied
+	if l.UserId == "" {
+		logger.WarnCtx(ctx, "mcp tool needs approval but has no user", "mcp", server, "function", funcName)
+		if _, err = db.InsertMcpAudit(audit); err != nil {
+			logger.ErrorCtx(ctx, "insert mcp audit fail", "err", err)
+		}
+		return fmt.Errorf("permission denied: tool %s of mcp server %s needs approval of user", funcName, server)
+	}
+
+	audit.Decision = db.McpAuditPending
+	if _, err = db.InsertMcpAudit(audit); err != nil {
+		// approval is decided by audit id
+		return fmt.Errorf("request approval of tool %s fail: %w", funcName, err)
+	}
+
+	approval := &toolApproval{userId: l.UserId, decision: make(chan *approvalDecision, 1)}
+	pendingApprovals.Store(audit.ID, approval)
+	defer pendingApprovals.Delete(audit.ID)
+
+	if ToolApprover == nil || !ToolApprover(ctx, audit) {
+		l.DirectSendMsg(ApprovalMessage(audit)+"\n"+i18n.GetMessage("mcp_approval_reply", map[string]interface{}{
+			"id": audit.ID,
+		}), true)
+	}
+	logger.InfoCtx(ctx, "wait for mcp approval", "id", audit.ID, "mcp", server, "function", funcName)
+
+	var timeout <-chan time.Time
+	if conf.ToolsConfInfo.McpApprovalTimeout > 0 {
+		timer := time.NewTimer(time.Duration(conf.ToolsConfInfo.McpApprovalTimeout) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case decision := <-approval.decision:
+		audit.Decision = decision.decision
+		audit.DecidedBy = decision.userId
+	case <-timeout:
+		audit.Decision = db.McpAuditTimeout
+	case <-ctx.Done():
+		audit.Decision = db.McpAuditCancelled
+	}
+
+	if err = db.UpdateMcpAudit(audit); err != nil {
+		logger.ErrorCtx(ctx, "update mcp audit fail", "id", audit.ID, "err", err)
+	}
+	logger.InfoCtx(ctx, "mcp approval decided", "id", audit.ID, "decision", audit.Decision)
+
+	switch audit.Decision {
+	case db.McpAuditApproved:
+		return nil
+	case db.McpAuditCancelled:
+		return ctx.Err()
+	case db.McpAuditTimeout:
+		return fmt.Errorf("tool %s of mcp server %s is not approved in %d seconds", funcName, server,
+			conf.ToolsConfInfo.McpApprovalTimeout)
+	}
+	return fmt.Errorf("tool %s of mcp server %s is rejected by user", funcName, server)
+}
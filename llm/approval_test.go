@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+)
+
+func TestDecideToolApproval(t *testing.T) {
+	approval := &toolApproval{userId: "1", decision: make(chan *approvalDecision, 1)}
+	pendingApprovals.Store(int64(100), approval)
+	defer pendingApprovals.Delete(int64(100))
+
+	assert.ErrorIs(t, DecideToolApproval(101, "1", true), ErrApprovalNotExist)
+	assert.ErrorIs(t, DecideToolApproval(100, "2", true), ErrApprovalNotOwner)
+	assert.ErrorIs(t, DecideToolApproval(100, "", true), ErrApprovalNotOwner)
+	assert.NoError(t, DecideToolApproval(100, "1", false))
+	assert.ErrorIs(t, DecideToolApproval(100, "1", true), ErrApprovalNotExist)
+	decision := <-approval.decision
+	assert.Equal(t, db.McpAuditRejected, decision.decision)
+	assert.Equal(t, "1", decision.userId)
+
+	// approval without requesting user can't be decided
+	pendingApprovals.Store(int64(102), &toolApproval{decision: make(chan *approvalDecision, 1)})
+	defer pendingApprovals.Delete(int64(102))
+	assert.ErrorIs(t, DecideToolApproval(102, "", true), ErrApprovalNotOwner)
+}
+
+func TestCheckMcpPolicyAllow(t *testing.T) {
+	defer func() {
+		conf.ToolsConfInfo.McpPolicies = ""
+		conf.ToolsConfInfo.Policies = nil
+	}()
+
+	conf.ToolsConfInfo.McpPolicies = `[{"server":"mysql","action":"deny"}]`
+	assert.NoError(t, conf.ParseMcpPolicies())
+
+	l := &LLM{UserId: "1"}
+	assert.NoError(t, l.checkMcpPolicy(context.Background(), "playwright", "browser_navigate", nil))
+}
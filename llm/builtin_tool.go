@@ -0,0 +1,328 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	httpFetchMaxSize     = 1 << 20
+	httpFetchMaxLen      = 8000
+	httpFetchTimeout     = 30 * time.Second
+	searchRecordsLimit   = 5
+	searchRecordsShowLen = 300
+)
+
+// currentTimeTool current_time get time in timezone, llm doesn't know the time by itself.
+type currentTimeTool struct{}
+
+func (t *currentTimeTool) Tool() mcp.Tool {
+	return mcp.NewTool("current_time",
+		mcp.WithDescription("Get the current date, time and weekday."),
+		mcp.WithString("timezone",
+			mcp.Description("IANA timezone such as Asia/Shanghai or America/New_York, local timezone of the bot if empty"),
+		),
+	)
+}
+
+func (t *currentTimeTool) Call(ctx context.Context, l *LLM, arguments map[string]interface{}) (string, error) {
+	now := time.Now()
+	if timezone, _ := arguments["timezone"].(string); timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return "", fmt.Errorf("invalid timezone %s: %w", timezone, err)
+		}
+		now = now.In(loc)
+	}
+	return now.Format(time.RFC3339) + " " + now.Weekday().String(), nil
+}
+
+// calculatorTool calculator evaluate arithmetic expression, llm is bad at exact arithmetic.
+type calculatorTool struct{}
+
+func (t *calculatorTool) Tool() mcp.Tool {
+	return mcp.NewTool("calculator",
+		mcp.WithDescription("Evaluate an arithmetic expression exactly. Supports + - * / %, parentheses, "+
+			"constants pi and e, and functions sqrt, abs, pow, exp, log, log2, log10, sin, cos, tan, floor, ceil, round, min, max."),
+		mcp.WithString("expression",
+			mcp.Required(),
+			mcp.Description("expression such as (1 + 2) * sqrt(16) / pow(3, 2)"),
+		),
+	)
+}
+
+func (t *calculatorTool) Call(ctx context.Context, l *LLM, arguments map[string]interface{}) (string, error) {
+	expression, _ := arguments["expression"].(string)
+	res, err := Calculate(expression)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(res, 'f', -1, 64), nil
+}
+
+var (
+	calculatorConsts = map[string]float64{
+		"pi": math.Pi,
+		"e":  math.E,
+	}
+
+	calculatorFuncs = map[string]func(args ...float64) (float64, error){
+		"sqrt":  unaryFunc(math.Sqrt),
+		"abs":   unaryFunc(math.Abs),
+		"exp":   unaryFunc(math.Exp),
+		"log":   unaryFunc(math.Log),
+		"log2":  unaryFunc(math.Log2),
+		"log10": unaryFunc(math.Log10),
+		"sin":   unaryFunc(math.Sin),
+		"cos":   unaryFunc(math.Cos),
+		"tan":   unaryFunc(math.Tan),
+		"floor": unaryFunc(math.Floor),
+		"ceil":  unaryFunc(math.Ceil),
+		"round": unaryFunc(math.Round),
+		"pow": func(args ...float64) (float64, error) {
+			if len(args) != 2 {
+				return 0, errors.New("pow needs 2 arguments")
+			}
+			return math.Pow(args[0], args[1]), nil
+		},
+		"min": func(args ...float64) (float64, error) {
+			if len(args) == 0 {
+				return 0, errors.New("min needs at least 1 argument")
+			}
+			res := args[0]
+			for _, arg := range args[1:] {
+				res = math.Min(res, arg)
+			}
+			return res, nil
+		},
+		"max": func(args ...float64) (float64, error) {
+			if len(args) == 0 {
+				return 0, errors.New("max needs at least 1 argument")
+			}
+			res := args[0]
+			for _, arg := range args[1:] {
+				res = math.Max(res, arg)
+			}
+			return res, nil
+		},
+	}
+)
+
+func unaryFunc(f func(float64) float64) func(args ...float64) (float64, error) {
+	return func(args ...float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, errors.New("function needs 1 argument")
+		}
+		return f(args[0]), nil
+	}
+}
+
+// Calculate evaluate arithmetic expression, it is parsed as go expression.
+func Calculate(expression string) (float64, error) {
+	expr, err := parser.ParseExpr(expression)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expression %s: %w", expression, err)
+	}
+
+	res, err := evalExpr(expr)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(res) || math.IsInf(res, 0) {
+		return 0, fmt.Errorf("result of %s is not a finite number", expression)
+	}
+	return res, nil
+}
+
+func evalExpr(expr ast.Expr) (float64, error) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.INT && e.Kind != token.FLOAT {
+			return 0, fmt.Errorf("unsupported literal %s", e.Value)
+		}
+		return strconv.ParseFloat(e.Value, 64)
+	case *ast.Ident:
+		if value, ok := calculatorConsts[e.Name]; ok {
+			return value, nil
+		}
+		return 0, fmt.Errorf("unknown constant %s", e.Name)
+	case *ast.ParenExpr:
+		return evalExpr(e.X)
+	case *ast.UnaryExpr:
+		x, err := evalExpr(e.X)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.ADD:
+			return x, nil
+		case token.SUB:
+			return -x, nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", e.Op)
+	case *ast.BinaryExpr:
+		x, err := evalExpr(e.X)
+		if err != nil {
+			return 0, err
+		}
+		y, err := evalExpr(e.Y)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.ADD:
+			return x + y, nil
+		case token.SUB:
+			return x - y, nil
+		case token.MUL:
+			return x * y, nil
+		case token.QUO:
+			if y == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return x / y, nil
+		case token.REM:
+			if y == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return math.Mod(x, y), nil
+		case token.XOR:
+			// ^ has the same precedence as + in go, power is only supported by pow
+			return 0, errors.New("unsupported operator ^, use pow(x, y) for power")
+		}
+		return 0, fmt.Errorf("unsupported operator %s", e.Op)
+	case *ast.CallExpr:
+		name, ok := e.Fun.(*ast.Ident)
+		if !ok {
+			return 0, errors.New("unsupported function call")
+		}
+		f, ok := calculatorFuncs[name.Name]
+		if !ok {
+			return 0, fmt.Errorf("unknown function %s", name.Name)
+		}
+		args := make([]float64, 0, len(e.Args))
+		for _, arg := range e.Args {
+			value, err := evalExpr(arg)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, value)
+		}
+		res, err := f(args...)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name.Name, err)
+		}
+		return res, nil
+	}
+	return 0, fmt.Errorf("unsupported expression %T", expr)
+}
+
+// httpFetchTool http_fetch get content of web page or api by GET, content is truncated.
+// loopback, private and link-local addresses are refused, llm can't reach services inside the network.
+type httpFetchTool struct{}
+
+func (t *httpFetchTool) Tool() mcp.Tool {
+	return mcp.NewTool("http_fetch",
+		mcp.WithDescription("Fetch a web page or HTTP API with GET and return the status and raw response body. "+
+			"Long bodies are truncated."),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("http or https url"),
+		),
+	)
+}
+
+func (t *httpFetchTool) Call(ctx context.Context, l *LLM, arguments map[string]interface{}) (string, error) {
+	rawURL, _ := arguments["url"].(string)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid url: %s", rawURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := utils.GetPublicClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpFetchMaxSize))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("status: %d\ncontent-type: %s\n\n%s", resp.StatusCode, resp.Header.Get("Content-Type"),
+		truncateRunes(string(body), httpFetchMaxLen)), nil
+}
+
+// searchRecordsTool search_records search past conversations of the requesting user.
+type searchRecordsTool struct{}
+
+func (t *searchRecordsTool) Tool() mcp.Tool {
+	return mcp.NewTool("search_records",
+		mcp.WithDescription("Search past conversations between the user and this bot by keyword, "+
+			"use it when the user refers to something discussed before."),
+		mcp.WithString("keyword",
+			mcp.Required(),
+			mcp.Description("keyword contained in the question or answer"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("max number of records, default 5"),
+		),
+	)
+}
+
+func (t *searchRecordsTool) Call(ctx context.Context, l *LLM, arguments map[string]interface{}) (string, error) {
+	keyword, _ := arguments["keyword"].(string)
+	limit := searchRecordsLimit
+	if value, ok := arguments["limit"].(float64); ok && value > 0 {
+		limit = int(value)
+	}
+
+	records, err := db.SearchRecords(l.UserId, strings.TrimSpace(keyword), limit)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "no record contains " + keyword, nil
+	}
+
+	var sb strings.Builder
+	for _, record := range records {
+		sb.WriteString(time.Unix(record.CreateTime, 0).Format(time.DateTime) + "\n")
+		sb.WriteString("Q: " + truncateRunes(record.Question, searchRecordsShowLen) + "\n")
+		sb.WriteString("A: " + truncateRunes(record.Answer, searchRecordsShowLen) + "\n\n")
+	}
+	return sb.String(), nil
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
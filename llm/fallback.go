@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/metrics"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+// stopFallback mark error which should not be retried with another provider, such as answer is partly sent.
+type stopFallback struct {
+	err error
+}
+
+func (e *stopFallback) Error() string {
+	return e.err.Error()
+}
+
+// Fallback call fn with provider t first, then with providers of fallback chain in order while error is retryable.
+// ctx passed to fn carry the llm config of the provider, it returns the ctx of last call and the providers failed before it.
+func Fallback(ctx context.Context, c provider.Capability, t string,
+	fn func(ctx context.Context, t string) error) (context.Context, []string, error) {
+	fallbacks := make([]string, 0)
+	tried := map[string]bool{t: true}
+
+	// provider out of rate limit is skipped like a provider returning 429,
+	// text clients check rate limit by themselves on every request
+	call := func(ctx context.Context, t string) error {
+		if c != provider.Text {
+			if err := utils.AllowProvider(t); err != nil {
+				return err
+			}
+		}
+		return fn(ctx, t)
+	}
+
+	err := call(ctx, t)
+	for _, candidate := range provider.FallbackChain(c) {
+		var sf *stopFallback
+		if errors.As(err, &sf) {
+			return ctx, fallbacks, sf.err
+		}
+		if !provider.IsRetryable(err) {
+			break
+		}
+		if tried[candidate.Name] {
+			continue
+		}
+
+		logger.WarnCtx(ctx, "provider fail, use fallback provider", "capability", c, "from", t,
+			"to", candidate.Name, "model", candidate.Model, "err", err)
+		metrics.ProviderFallbackCount.WithLabelValues(string(c), t, candidate.Name).Inc()
+
+		var llmConf *param.LLMConfig
+		if userInfo := db.GetCtxUserInfo(ctx); userInfo != nil {
+			llmConf = userInfo.LLMConfigRaw
+		}
+
+		fallbacks = append(fallbacks, t)
+		tried[candidate.Name] = true
+		ctx = db.WithLLMConfig(ctx, utils.SwitchLLMType(llmConf, c, candidate.Name, candidate.Model))
+		t = candidate.Name
+		err = call(ctx, t)
+	}
+
+	var sf *stopFallback
+	if errors.As(err, &sf) {
+		err = sf.err
+	}
+	return ctx, fallbacks, err
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"html/template"
 	"regexp"
 	"strings"
@@ -20,7 +21,9 @@ import (
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/metrics"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
 	"github.com/yincongcyincong/MuseBot/utils"
+	"github.com/yincongcyincong/langchaingo/schema"
 	"github.com/yincongcyincong/mcp-client-go/clients"
 	"google.golang.org/genai"
 )
@@ -61,10 +64,29 @@ type LLM struct {
 	GeminiTools     []*genai.Tool
 	OpenRouterTools []openrouter.Tool
 
+	// KnowledgeBase name of knowledge base searched by search_knowledge_base tool
+	KnowledgeBase string
+	// Sources documents returned by search_knowledge_base tool
+	Sources []schema.Document
+
+	// ResponseFormat json schema of response, it is set by SyncSendStructured
+	ResponseFormat *ResponseFormat
+
+	// ToolCalls tools called while answering, saved into steps of task run
+	ToolCalls []*ToolCall
+
 	WholeContent string // whole answer from llm
 	LoopNum      int
 }
 
+// ToolCall tool called by llm and its result.
+type ToolCall struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Result    string                 `json:"result"`
+	Error     string                 `json:"error,omitempty"`
+}
+
 type LLMClient interface {
 	Send(ctx context.Context, l *LLM) error
 
@@ -84,41 +106,77 @@ type LLMClient interface {
 func (l *LLM) CallLLM() error {
 
 	totalContent := l.GetContent(l.Content)
-	l.GetMessages(l.UserId, totalContent)
-	l.InsertCharacter(l.Ctx)
-	l.LLMClient.GetModel(l)
+	txtType := utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw)
+	ctx, fallbacks, err := Fallback(l.Ctx, provider.Text, txtType, func(ctx context.Context, t string) error {
+		if t != txtType {
+			l.Ctx = ctx
+			l.LLMClient = newLLMClient(t)
+			l.LoopNum = 0
+		}
 
-	logger.InfoCtx(l.Ctx, "msg receive", "userID", l.UserId, "prompt", totalContent, "type",
-		utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw), "model", l.Model)
+		l.GetMessages(l.Cs.SessionId, totalContent)
+		l.InsertCharacter(l.Ctx)
+		l.LLMClient.GetModel(l)
 
+		logger.InfoCtx(l.Ctx, "msg receive", "userID", l.UserId, "prompt", totalContent, "type", t, "model", l.Model)
+
+		if t != txtType {
+			l.DirectSendMsg(i18n.GetMessage("fallback_provider_notice", map[string]interface{}{
+				"from": txtType,
+				"to":   t + "(" + l.Model + ")",
+			}), true)
+		}
+
+		err := l.send()
+		// answer is partly sent, another provider would answer it again
+		if err != nil && l.WholeContent != "" {
+			return &stopFallback{err: err}
+		}
+		return err
+	})
+	l.Ctx = ctx
+	l.Cs.Fallbacks = fallbacks
+	if err != nil {
+		return err
+	}
+
+	err = l.InsertOrUpdate()
+	if err != nil {
+		logger.ErrorCtx(l.Ctx, "insert or update record", "err", err)
+		return err
+	}
+
+	go func() {
+		if err := l.SummarizeHistory(); err != nil {
+			logger.ErrorCtx(l.Ctx, "summarize conversation fail", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+func (l *LLM) send() error {
 	metrics.APIRequestCount.WithLabelValues(l.Model).Inc()
 
-	var err error
 	if conf.BaseConfInfo.IsStreaming {
-		err = l.LLMClient.Send(l.Ctx, l)
-		if err != nil {
-			logger.ErrorCtx(l.Ctx, "Error calling LLM API", "err", err)
-			return err
-		}
-	} else {
-		content, err := l.LLMClient.SyncSend(l.Ctx, l)
+		err := l.LLMClient.Send(l.Ctx, l)
 		if err != nil {
 			logger.ErrorCtx(l.Ctx, "Error calling LLM API", "err", err)
 			return err
 		}
-
-		l.MessageChan <- &param.MsgInfo{
-			Content: content,
-		}
-		l.WholeContent = content
+		return nil
 	}
 
-	err = l.InsertOrUpdate()
+	content, err := l.LLMClient.SyncSend(l.Ctx, l)
 	if err != nil {
-		logger.ErrorCtx(l.Ctx, "insert or update record", "err", err)
+		logger.ErrorCtx(l.Ctx, "Error calling LLM API", "err", err)
 		return err
 	}
 
+	l.MessageChan <- &param.MsgInfo{
+		Content: content,
+	}
+	l.WholeContent = content
 	return nil
 }
 
@@ -155,22 +213,72 @@ func NewLLM(opts ...Option) *LLM {
 		opt(l)
 	}
 
-	switch utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw) {
-	case param.Ollama:
-		l.LLMClient = &OllamaReq{
-			ToolCall:           []godeepseek.ToolCall{},
-			ToolMessage:        []godeepseek.ChatCompletionMessage{},
-			CurrentToolMessage: []godeepseek.ChatCompletionMessage{},
-		}
-	default:
-		l.LLMClient = &OpenAIReq{
+	l.LLMClient = newLLMClient(utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw))
+
+	return l
+}
+
+// newLLMClient build chat client by protocol of provider t.
+func newLLMClient(t string) LLMClient {
+	protocol := provider.OpenAIProtocol
+	if p := provider.Get(t); p != nil && p.Protocol != "" {
+		protocol = p.Protocol
+	}
+	newClient, ok := clientFactories[protocol]
+	if !ok {
+		newClient = clientFactories[provider.OpenAIProtocol]
+	}
+	return &rateLimitClient{LLMClient: newClient(), t: t}
+}
+
+// rateLimitClient check rate limit of provider before each request, so every caller of the client is limited.
+type rateLimitClient struct {
+	LLMClient
+	t string
+}
+
+func (c *rateLimitClient) Send(ctx context.Context, l *LLM) error {
+	if err := utils.AllowProvider(c.t); err != nil {
+		return err
+	}
+	return c.LLMClient.Send(ctx, l)
+}
+
+func (c *rateLimitClient) SyncSend(ctx context.Context, l *LLM) (string, error) {
+	if err := utils.AllowProvider(c.t); err != nil {
+		return "", err
+	}
+	return c.LLMClient.SyncSend(ctx, l)
+}
+
+func (c *rateLimitClient) AppendMessages(client LLMClient) {
+	if rc, ok := client.(*rateLimitClient); ok {
+		client = rc.LLMClient
+	}
+	c.LLMClient.AppendMessages(client)
+}
+
+// clientFactories build chat client by provider protocol.
+var clientFactories = map[string]func() LLMClient{
+	provider.OpenAIProtocol: func() LLMClient {
+		return &OpenAIReq{
 			ToolCall:           []openai.ToolCall{},
 			ToolMessage:        []openai.ChatCompletionMessage{},
 			CurrentToolMessage: []openai.ChatCompletionMessage{},
 		}
-	}
+	},
+	provider.OllamaProtocol: func() LLMClient {
+		return &OllamaReq{
+			ToolCall:           []godeepseek.ToolCall{},
+			ToolMessage:        []godeepseek.ChatCompletionMessage{},
+			CurrentToolMessage: []godeepseek.ChatCompletionMessage{},
+		}
+	},
+}
 
-	return l
+// RegisterClientFactory add chat client for provider protocol.
+func RegisterClientFactory(protocol string, newClient func() LLMClient) {
+	clientFactories[protocol] = newClient
 }
 
 func (l *LLM) DirectSendMsg(content string, ignoreLen bool) {
@@ -229,28 +337,38 @@ func (l *LLM) OverLoop() bool {
 }
 
 func (l *LLM) InsertOrUpdate() error {
+	record := &db.Record{
+		ID:              l.Cs.RecordID,
+		UserId:          l.UserId,
+		SessionId:       l.Cs.SessionId,
+		Question:        l.Content,
+		Answer:          l.WholeContent,
+		Token:           l.Cs.Token,
+		RecordType:      param.TextRecordType,
+		Mode:            utils.GetFallbackMode(l.Cs.Fallbacks, utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw)),
+		PromptToken:     l.Cs.PromptToken,
+		CompletionToken: l.Cs.CompletionToken,
+		Cost:            l.Cost(),
+		DocIds:          strings.Join(l.Cs.DocIds, ","),
+	}
+
 	if l.Cs.RecordID == 0 {
-		db.InsertMsgRecord(l.Ctx, l.UserId, &db.AQ{
+		db.InsertMsgRecord(l.Ctx, l.Cs.SessionId, l.UserId, &db.AQ{
 			Question:   l.Content,
 			Answer:     l.WholeContent,
 			Token:      l.Cs.Token,
 			CreateTime: time.Now().Unix(),
-		}, true)
+		}, false)
+		go db.InsertRecordInfo(l.Ctx, record)
 		return nil
 	}
 
-	db.InsertMsgRecord(l.Ctx, l.UserId, &db.AQ{
+	db.InsertMsgRecord(l.Ctx, l.Cs.SessionId, l.UserId, &db.AQ{
 		Question:   l.Content,
 		Answer:     l.WholeContent,
 		CreateTime: time.Now().Unix(),
 	}, false)
-	err := db.UpdateRecordInfo(&db.Record{
-		ID:     l.Cs.RecordID,
-		Answer: l.WholeContent,
-		Token:  l.Cs.Token,
-		UserId: l.UserId,
-		Mode:   utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw),
-	})
+	err := db.UpdateRecordInfo(record)
 	if err != nil {
 		logger.ErrorCtx(l.Ctx, "update record fail", "err", err)
 		return err
@@ -259,10 +377,58 @@ func (l *LLM) InsertOrUpdate() error {
 	return nil
 }
 
-func (l *LLM) GetMessages(userId string, prompt string) {
-	msgRecords := db.GetMsgRecord(userId)
-	if msgRecords != nil && l.Cs.UseRecord {
-		aqs := db.FilterByMaxContextFromLatest(msgRecords.AQs, param.DefaultContextToken)
+// InsertUsageRecord save token and cost of llm call made by bot itself, like summary and rerank,
+// so spend report and quota of the session count it.
+func (l *LLM) InsertUsageRecord(sessionId, mode string) {
+	_, err := db.InsertRecordInfo(l.Ctx, &db.Record{
+		UserId:          l.UserId,
+		SessionId:       sessionId,
+		Token:           l.Cs.Token,
+		RecordType:      param.InternalRecordType,
+		Mode:            mode,
+		PromptToken:     l.Cs.PromptToken,
+		CompletionToken: l.Cs.CompletionToken,
+		Cost:            l.Cost(),
+	})
+	if err != nil {
+		logger.ErrorCtx(l.Ctx, "insert usage record fail", "mode", mode, "err", err)
+	}
+}
+
+// Cost calculate spend of tokens in context state by price of using model.
+func (l *LLM) Cost() float64 {
+	usage := provider.Usage{
+		PromptToken:     l.Cs.PromptToken,
+		CompletionToken: l.Cs.CompletionToken,
+	}
+	// some providers only return total token
+	if usage.PromptToken == 0 && usage.CompletionToken == 0 {
+		usage.PromptToken = l.Cs.Token
+	}
+	return provider.Cost(utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw), l.Model, usage)
+}
+
+func (l *LLM) GetMessages(sessionId string, prompt string) {
+	var msgRecords *db.MsgRecordInfo
+	var summary *db.Summary
+	if l.Cs.UseRecord {
+		msgRecords = db.GetMsgRecord(l.Ctx, sessionId)
+
+		var err error
+		summary, err = db.GetSummary(sessionId)
+		if err != nil {
+			logger.ErrorCtx(l.Ctx, "get summary fail", "err", err)
+		}
+	}
+	if summary != nil {
+		l.LLMClient.GetMessage(openai.ChatMessageRoleSystem, i18n.GetMessage("summary_context_prompt",
+			map[string]interface{}{
+				"summary": summary.Content,
+			}))
+	}
+	if msgRecords != nil {
+		aqs := db.FilterByMaxContextFromLatest(db.FilterBySummary(msgRecords.AQs, summary), param.DefaultContextToken,
+			l.CountTokens)
 		for i, record := range aqs {
 			if record.Question != "" && record.Answer != "" && record.CreateTime > time.Now().Unix()-int64(conf.BaseConfInfo.ContextExpireTime) {
 				logger.InfoCtx(l.Ctx, "context content", "dialog", i, "question:", record.Question, "answer:", record.Answer)
@@ -280,6 +446,26 @@ func (l *LLM) GetMessages(userId string, prompt string) {
 
 }
 
+// getTxtModel get the text model user choose, default model of provider if it is not supported.
+func getTxtModel(l *LLM) string {
+	var llmConf *param.LLMConfig
+	model := ""
+	if userInfo := db.GetCtxUserInfo(l.Ctx); userInfo != nil && userInfo.LLMConfigRaw != nil {
+		llmConf = userInfo.LLMConfigRaw
+		model = llmConf.TxtModel
+	}
+
+	return utils.GetUsingTxtModel(utils.GetTxtType(llmConf), model)
+}
+
+// CountTokens count token by the tokenizer of user's text provider.
+func (l *LLM) CountTokens(text string) int {
+	if p := provider.Get(utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw)); p != nil {
+		return p.Tokens(text)
+	}
+	return provider.EstimateTokens(text)
+}
+
 type Option func(p *LLM)
 
 func WithModel(model string) Option {
@@ -360,6 +546,12 @@ func WithTaskTools(taskTool *conf.AgentInfo) Option {
 	}
 }
 
+func WithKnowledgeBase(kb string) Option {
+	return func(p *LLM) {
+		p.KnowledgeBase = kb
+	}
+}
+
 func WithContext(ctx context.Context) Option {
 	return func(p *LLM) {
 		p.Ctx = ctx
@@ -372,13 +564,50 @@ func WithContentParameter(contentParameter map[string]string) Option {
 	}
 }
 
+// allowMCP check role of user can use mcp server.
+func allowMCP(ctx context.Context, name string) bool {
+	userInfo := db.GetCtxUserInfo(ctx)
+	return userInfo == nil || conf.RBACConfInfo.Allow(userInfo.Role, param.RoleResourceMCP, name)
+}
+
+// allowCommand check role of user can use command.
+func allowCommand(ctx context.Context, name string) bool {
+	userInfo := db.GetCtxUserInfo(ctx)
+	return userInfo == nil || conf.RBACConfInfo.Allow(userInfo.Role, param.RoleResourceCommand, name)
+}
+
+// ExecMcpReq exec tool called by llm and record the call.
 func (l *LLM) ExecMcpReq(ctx context.Context, funcName string, property map[string]interface{}) (string, error) {
+	res, err := l.execMcpReq(ctx, funcName, property)
+	toolCall := &ToolCall{Name: funcName, Arguments: property, Result: res}
+	if err != nil {
+		toolCall.Error = err.Error()
+	}
+	l.ToolCalls = append(l.ToolCalls, toolCall)
+	return res, err
+}
+
+func (l *LLM) execMcpReq(ctx context.Context, funcName string, property map[string]interface{}) (string, error) {
+	if tool, ok := GetNativeTool(funcName); ok {
+		return l.execNativeTool(ctx, tool, funcName, property)
+	}
+
 	mc, err := clients.GetMCPClientByToolName(funcName)
 	if err != nil {
 		logger.ErrorCtx(ctx, "get mcp fail", "err", err, "function", funcName, "argument", property)
 		return "", err
 	}
 
+	if !allowMCP(ctx, mc.Conf.Name) {
+		logger.WarnCtx(ctx, "mcp permission denied", "mcp", mc.Conf.Name, "function", funcName)
+		return "", fmt.Errorf("permission denied: role can't use mcp server %s", mc.Conf.Name)
+	}
+
+	err = l.checkMcpPolicy(ctx, mc.Conf.Name, funcName, property)
+	if err != nil {
+		return "", err
+	}
+
 	metrics.MCPRequestCount.WithLabelValues(mc.Conf.Name, funcName).Inc()
 	startTime := time.Now()
 
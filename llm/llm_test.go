@@ -2,12 +2,16 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
+	"github.com/yincongcyincong/MuseBot/utils"
 )
 
 func TestSendMsg_WithMessageChan(t *testing.T) {
@@ -55,3 +59,71 @@ func TestNewLLM_DefaultsToClient(t *testing.T) {
 	assert.Equal(t, "ask", l.Content)
 	assert.Equal(t, "m1", l.Model)
 }
+
+func TestFallback(t *testing.T) {
+	conf.BaseConfInfo.GeminiToken = "gemini"
+	conf.BaseConfInfo.OpenAIToken = "openai"
+	conf.ProviderConfInfo.TxtFallback = "gemini:" + param.ModelGemini25Pro + ",openai"
+	defer func() {
+		conf.BaseConfInfo.GeminiToken = ""
+		conf.BaseConfInfo.OpenAIToken = ""
+		conf.ProviderConfInfo.TxtFallback = ""
+	}()
+
+	ctx := context.WithValue(context.Background(), "user_info", &db.User{
+		UserId:       "u1",
+		LLMConfigRaw: &param.LLMConfig{TxtType: param.DeepSeek},
+	})
+
+	tried := make([]string, 0)
+	ctx, fallbacks, err := Fallback(ctx, provider.Text, param.DeepSeek, func(ctx context.Context, ty string) error {
+		tried = append(tried, ty)
+		if ty == param.DeepSeek {
+			return errors.New("error, status code: 503, message: service unavailable")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{param.DeepSeek, param.Gemini}, tried)
+	assert.Equal(t, []string{param.DeepSeek}, fallbacks)
+	assert.Equal(t, param.Gemini, db.GetCtxUserInfo(ctx).LLMConfigRaw.TxtType)
+	assert.Equal(t, param.ModelGemini25Pro, db.GetCtxUserInfo(ctx).LLMConfigRaw.TxtModel)
+	assert.Equal(t, "u1", db.GetCtxUserInfo(ctx).UserId)
+
+	// not retryable error
+	tried = tried[:0]
+	_, _, err = Fallback(ctx, provider.Text, param.DeepSeek, func(ctx context.Context, ty string) error {
+		tried = append(tried, ty)
+		return errors.New("invalid api key")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{param.DeepSeek}, tried)
+
+	// answer is partly sent
+	tried = tried[:0]
+	_, _, err = Fallback(ctx, provider.Text, param.DeepSeek, func(ctx context.Context, ty string) error {
+		tried = append(tried, ty)
+		return &stopFallback{err: context.DeadlineExceeded}
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{param.DeepSeek}, tried)
+}
+
+func TestRateLimitClient(t *testing.T) {
+	conf.RateLimitConfInfo.ProviderRateLimits = "limited=1/h"
+	assert.NoError(t, conf.ParseRateLimits())
+	defer func() {
+		conf.RateLimitConfInfo.ProviderRateLimits = ""
+		assert.NoError(t, conf.ParseRateLimits())
+	}()
+
+	client := &rateLimitClient{LLMClient: &fakeClient{responses: []string{"ok", "ok"}}, t: "limited"}
+	content, err := client.SyncSend(context.Background(), new(LLM))
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", content)
+
+	var rateErr *utils.RateLimitError
+	_, err = client.SyncSend(context.Background(), new(LLM))
+	assert.ErrorAs(t, err, &rateErr)
+	assert.True(t, provider.IsRetryable(err))
+}
@@ -1,8 +1,7 @@
 package llm
 
 import (
-	"encoding/json"
-	"regexp"
+	"errors"
 
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/i18n"
@@ -10,14 +9,32 @@ import (
 	"github.com/yincongcyincong/MuseBot/metrics"
 )
 
-var (
-	mcpRe = regexp.MustCompile(`\{\s*"agent"\s*:\s*"[^\"]*"\s*\}`)
-)
-
 type McpResult struct {
 	Agent string `json:"agent"`
 }
 
+func (m *McpResult) ResponseFormat() *ResponseFormat {
+	return &ResponseFormat{
+		Name:   "mcp_agent",
+		Strict: true,
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"agent": map[string]any{"type": "string", "description": "agent name of the task"},
+			},
+			"required":             []string{"agent"},
+			"additionalProperties": false,
+		},
+	}
+}
+
+func (m *McpResult) Validate() error {
+	if m.Agent == "" {
+		return errors.New("agent is empty")
+	}
+	return nil
+}
+
 // ExecuteMcp execute mcp request
 func (d *LLMTaskReq) ExecuteMcp() error {
 	logger.InfoCtx(d.Ctx, "mcp content", "content", d.Content)
@@ -25,6 +42,9 @@ func (d *LLMTaskReq) ExecuteMcp() error {
 	taskParam["assign_param"] = make([]map[string]string, 0)
 	taskParam["user_task"] = d.Content
 	conf.TaskTools.Range(func(name, value any) bool {
+		if !allowMCP(d.Ctx, name.(string)) {
+			return true
+		}
 		tool := value.(*conf.AgentInfo)
 		taskParam["assign_param"] = append(taskParam["assign_param"].([]map[string]string), map[string]string{
 			"tool_name": name.(string),
@@ -39,24 +59,18 @@ func (d *LLMTaskReq) ExecuteMcp() error {
 		WithPerMsgLen(d.PerMsgLen), WithContext(d.Ctx), WithCS(d.Cs))
 
 	prompt := i18n.GetMessage("mcp_prompt", taskParam)
-	llm.GetMessages(d.UserId, prompt)
+	llm.GetMessages(d.Cs.SessionId, prompt)
 	llm.Content = prompt
 	llm.LLMClient.GetModel(llm)
 
-	metrics.APIRequestCount.WithLabelValues(llm.Model).Inc()
-	c, err := llm.LLMClient.SyncSend(d.Ctx, llm)
-	if err != nil {
+	mcpResult := new(McpResult)
+	c, err := llm.SyncSendStructured(d.Ctx, mcpResult)
+	if c == "" && err != nil {
 		logger.ErrorCtx(d.Ctx, "get message fail", "err", err)
 		return err
 	}
-
-	matches := mcpRe.FindAllString(c, -1)
-	mcpResult := new(McpResult)
-	for _, match := range matches {
-		err := json.Unmarshal([]byte(match), mcpResult)
-		if err != nil {
-			logger.ErrorCtx(d.Ctx, "json umarshal fail", "err", err)
-		}
+	if err != nil {
+		logger.WarnCtx(d.Ctx, "parse mcp agent fail", "err", err)
 	}
 
 	llm.DirectSendMsg(c, false)
@@ -73,7 +87,7 @@ func (d *LLMTaskReq) ExecuteMcp() error {
 		WithPerMsgLen(d.PerMsgLen), WithContext(d.Ctx), WithCS(d.Cs))
 	mcpLLM.Cs.Token += llm.Cs.Token
 	mcpLLM.Content = d.Content
-	mcpLLM.GetMessages(d.UserId, d.Content)
+	mcpLLM.GetMessages(d.Cs.SessionId, d.Content)
 	mcpLLM.LLMClient.GetModel(mcpLLM)
 
 	metrics.APIRequestCount.WithLabelValues(mcpLLM.Model).Inc()
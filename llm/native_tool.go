@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/metrics"
+	"github.com/yincongcyincong/mcp-client-go/utils"
+)
+
+const (
+	// NativeToolServer server name of native tools in mcp policies, audits and metrics
+	NativeToolServer = "native"
+)
+
+// NativeTool tool implemented in go and called in process, without mcp server.
+type NativeTool interface {
+	// Tool name, description and json schema of arguments
+	Tool() mcp.Tool
+	// Call handle tool call, the result is sent back to llm
+	Call(ctx context.Context, l *LLM, arguments map[string]interface{}) (string, error)
+}
+
+// CommandTool native tool doing the same thing as a bot command, it is checked by role permission of the command
+// instead of mcp server "native".
+type CommandTool interface {
+	Command() string
+}
+
+var (
+	// nativeTools tool name -> NativeTool
+	nativeTools = sync.Map{}
+
+	// builtinTools tools shipped with bot, registered by InitNativeTools if enabled by native_tools
+	builtinTools = []NativeTool{
+		new(currentTimeTool),
+		new(calculatorTool),
+		new(httpFetchTool),
+		new(searchRecordsTool),
+	}
+)
+
+// AddBuiltinTool add tool implemented by other package into built-in tools, call it in init.
+func AddBuiltinTool(tool NativeTool) {
+	builtinTools = append(builtinTools, tool)
+}
+
+// InitNativeTools register built-in tools enabled by native_tools when use_tools is on.
+func InitNativeTools() {
+	if !conf.BaseConfInfo.UseTools {
+		return
+	}
+
+	for _, tool := range builtinTools {
+		if conf.ToolsConfInfo.NativeToolEnabled(tool.Tool().Name) {
+			RegisterNativeTool(tool)
+		}
+	}
+}
+
+// RegisterNativeTool add tools into tools of every llm, tool with registered name replaces the old handler.
+func RegisterNativeTool(tools ...NativeTool) {
+	newTools := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		mcpTool := tool.Tool()
+		if _, loaded := nativeTools.Swap(mcpTool.Name, tool); !loaded {
+			newTools = append(newTools, mcpTool)
+		}
+		logger.Info("register native tool", "name", mcpTool.Name)
+	}
+
+	conf.DeepseekTools = append(conf.DeepseekTools, utils.TransToolsToDPFunctionCall(newTools)...)
+	conf.VolTools = append(conf.VolTools, utils.TransToolsToVolFunctionCall(newTools)...)
+	conf.OpenAITools = append(conf.OpenAITools, utils.TransToolsToChatGPTFunctionCall(newTools)...)
+	conf.GeminiTools = append(conf.GeminiTools, utils.TransToolsToGeminiFunctionCall(newTools)...)
+}
+
+// GetNativeTool get registered native tool by name.
+func GetNativeTool(name string) (NativeTool, bool) {
+	value, ok := nativeTools.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return value.(NativeTool), true
+}
+
+// execNativeTool call native tool, it is checked by role and mcp policies of server "native" like mcp tools,
+// role of command tool is checked by its command.
+func (l *LLM) execNativeTool(ctx context.Context, tool NativeTool, funcName string, property map[string]interface{}) (string, error) {
+	if commandTool, ok := tool.(CommandTool); ok {
+		if !allowCommand(ctx, commandTool.Command()) {
+			logger.WarnCtx(ctx, "command permission denied", "command", commandTool.Command(), "function", funcName)
+			return "", fmt.Errorf("permission denied: role can't use command %s", commandTool.Command())
+		}
+	} else if !allowMCP(ctx, NativeToolServer) {
+		logger.WarnCtx(ctx, "mcp permission denied", "mcp", NativeToolServer, "function", funcName)
+		return "", fmt.Errorf("permission denied: role can't use mcp server %s", NativeToolServer)
+	}
+
+	err := l.checkMcpPolicy(ctx, NativeToolServer, funcName, property)
+	if err != nil {
+		return "", err
+	}
+
+	metrics.MCPRequestCount.WithLabelValues(NativeToolServer, funcName).Inc()
+	startTime := time.Now()
+
+	res, err := tool.Call(ctx, l, property)
+	if err != nil {
+		logger.ErrorCtx(ctx, "call native tool fail", "err", err, "function", funcName, "argument", property)
+		return "", err
+	}
+
+	metrics.MCPRequestDuration.WithLabelValues(NativeToolServer, funcName).Observe(time.Since(startTime).Seconds())
+	logger.InfoCtx(ctx, "call native tool", "function", funcName, "argument", property, "res", res)
+	return res, nil
+}
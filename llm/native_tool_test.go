@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+type echoTool struct{}
+
+func (t *echoTool) Tool() mcp.Tool {
+	return mcp.NewTool("echo_test", mcp.WithString("text", mcp.Required()))
+}
+
+func (t *echoTool) Call(ctx context.Context, l *LLM, arguments map[string]interface{}) (string, error) {
+	text, _ := arguments["text"].(string)
+	return l.UserId + ":" + text, nil
+}
+
+func TestRegisterNativeTool(t *testing.T) {
+	defer nativeTools.Delete("echo_test")
+
+	openAIToolsLen := len(conf.OpenAITools)
+	RegisterNativeTool(new(echoTool))
+	RegisterNativeTool(new(echoTool))
+	assert.Len(t, conf.OpenAITools, openAIToolsLen+1)
+	assert.Equal(t, "echo_test", conf.OpenAITools[openAIToolsLen].Function.Name)
+
+	l := &LLM{UserId: "1"}
+	res, err := l.ExecMcpReq(context.Background(), "echo_test", map[string]interface{}{"text": "hi"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1:hi", res)
+	assert.Len(t, l.ToolCalls, 1)
+}
+
+func TestCalculate(t *testing.T) {
+	cases := map[string]float64{
+		"(1 + 2) * sqrt(16) / pow(3, 2)": 12.0 / 9,
+		"-pow(2, 10) % 1000":             -24,
+		"max(1, pi, 3) - min(2, e)":      3.141592653589793 - 2,
+		"round(2.5) + floor(-1.5)":       1,
+	}
+	for expression, want := range cases {
+		res, err := Calculate(expression)
+		assert.NoError(t, err, expression)
+		assert.InDelta(t, want, res, 1e-9, expression)
+	}
+
+	for _, expression := range []string{"1 / 0", "os.Exit(1)", "x + 1", `"a" + 1`, "sqrt(1, 2)", "2 ^ 3"} {
+		_, err := Calculate(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestCurrentTimeTool(t *testing.T) {
+	res, err := new(currentTimeTool).Call(context.Background(), nil, map[string]interface{}{"timezone": "Asia/Shanghai"})
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(res, "+08:00"))
+
+	_, err = new(currentTimeTool).Call(context.Background(), nil, map[string]interface{}{"timezone": "Mars/Base"})
+	assert.Error(t, err)
+}
+
+type commandEchoTool struct {
+	echoTool
+}
+
+func (t *commandEchoTool) Command() string {
+	return "cron"
+}
+
+func TestNativeToolRole(t *testing.T) {
+	defer func() {
+		nativeTools.Delete("echo_test")
+		conf.RBACConfInfo.RolePolicies = ""
+		_ = conf.ParseRolePolicies()
+	}()
+	RegisterNativeTool(new(commandEchoTool))
+
+	conf.RBACConfInfo.RolePolicies = `[{"name":"guest","commands":["chat"]},
+		{"name":"tool","commands":["chat"],"mcp_servers":["native"]},
+		{"name":"cron","commands":["cron"]},
+		{"name":"admin","commands":["*"],"mcp_servers":["*"]}]`
+	assert.NoError(t, conf.ParseRolePolicies())
+
+	// command tool is checked by command only
+	cases := map[string]bool{"guest": false, "tool": false, "cron": true, "admin": true}
+	for role, allowed := range cases {
+		ctx := context.WithValue(context.Background(), "user_info", &db.User{UserId: "1", Role: role})
+		_, err := (&LLM{UserId: "1"}).ExecMcpReq(ctx, "echo_test", map[string]interface{}{"text": "hi"})
+		assert.Equal(t, allowed, err == nil, role)
+	}
+}
+
+func TestHttpFetchPrivateAddress(t *testing.T) {
+	_, err := new(httpFetchTool).Call(context.Background(), nil, map[string]interface{}{"url": "http://127.0.0.1:1/"})
+	assert.ErrorIs(t, err, utils.ErrNotPublicAddress)
+}
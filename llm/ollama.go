@@ -32,19 +32,7 @@ type OllamaReq struct {
 }
 
 func (o OllamaReq) GetModel(l *LLM) {
-	userInfo := db.GetCtxUserInfo(l.Ctx)
-	model := ""
-	if userInfo != nil && userInfo.LLMConfigRaw != nil {
-		model = userInfo.LLMConfigRaw.TxtModel
-	}
-	switch utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw) {
-	case param.Ollama:
-		l.Model = "deepseek-r1"
-		if userInfo != nil && model != "" {
-			logger.InfoCtx(l.Ctx, "User info", "userID", userInfo.UserId, "mode", model)
-			l.Model = model
-		}
-	}
+	l.Model = getTxtModel(l)
 }
 
 func (o OllamaReq) Send(ctx context.Context, l *LLM) error {
@@ -129,6 +117,8 @@ func (o OllamaReq) Send(ctx context.Context, l *LLM) error {
 
 		if response.Usage != nil {
 			l.Cs.Token += response.Usage.TotalTokens
+			l.Cs.PromptToken += response.Usage.PromptTokens
+			l.Cs.CompletionToken += response.Usage.CompletionTokens
 		}
 	}
 
@@ -209,6 +199,9 @@ func (o OllamaReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 		Messages: o.OllamaMsgs,
 		Tools:    l.DeepseekTools,
 	}
+	if structuredFormat(ctx, l.ResponseFormat) != nil {
+		request.ResponseFormat = &deepseek.ResponseFormat{Type: "json_object"}
+	}
 
 	if conf.BaseConfInfo.LLMOptionParam {
 		request.MaxTokens = conf.LLMConfInfo.MaxTokens
@@ -244,6 +237,8 @@ func (o OllamaReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 	}
 
 	l.Cs.Token += response.Usage.TotalTokens
+	l.Cs.PromptToken += response.Usage.PromptTokens
+	l.Cs.CompletionToken += response.Usage.CompletionTokens
 	if len(response.Choices[0].Message.ToolCalls) > 0 {
 		o.GetAssistantMessage("")
 		o.OllamaMsgs[len(o.OllamaMsgs)-1].ToolCalls = response.Choices[0].Message.ToolCalls
@@ -15,13 +15,13 @@ import (
 
 	"github.com/cohesion-org/deepseek-go"
 	"github.com/cohesion-org/deepseek-go/constants"
-	"github.com/devinyf/dashscopego/qwen"
 	"github.com/sashabaranov/go-openai"
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/metrics"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
 	"github.com/yincongcyincong/MuseBot/utils"
 )
 
@@ -34,51 +34,7 @@ type OpenAIReq struct {
 }
 
 func (d *OpenAIReq) GetModel(l *LLM) {
-	userInfo := db.GetCtxUserInfo(l.Ctx)
-	model := ""
-	if userInfo != nil && userInfo.LLMConfigRaw != nil {
-		model = userInfo.LLMConfigRaw.TxtModel
-	}
-
-	switch utils.GetTxtType(db.GetCtxUserInfo(l.Ctx).LLMConfigRaw) {
-	case param.OpenAi, param.ChatAnyWhere:
-		l.Model = openai.GPT3Dot5Turbo0125
-		if userInfo != nil && model != "" {
-			l.Model = model
-		}
-	case param.Aliyun:
-		l.Model = qwen.QwenMax
-		if userInfo != nil && model != "" && param.AliyunModel[model] {
-			l.Model = model
-		}
-	case param.DeepSeek:
-		l.Model = deepseek.DeepSeekChat
-		if userInfo != nil && model != "" && param.DeepseekModels[model] {
-			logger.InfoCtx(l.Ctx, "User info", "userID", userInfo.UserId, "mode", model)
-			l.Model = model
-		}
-	case param.Vol:
-		l.Model = param.ModelDoubao15VisionPro328
-		if userInfo != nil && model != "" && param.VolModels[model] {
-			logger.InfoCtx(l.Ctx, "User info", "userID", userInfo.UserId, "mode", model)
-			l.Model = model
-		}
-	case param.AI302:
-		l.Model = openai.GPT3Dot5Turbo
-		if userInfo != nil && model != "" {
-			l.Model = model
-		}
-	case param.OpenRouter:
-		l.Model = param.DeepseekDeepseekR1_0528Free
-		if userInfo != nil && model != "" {
-			l.Model = model
-		}
-	case param.Gemini:
-		l.Model = param.ModelGemini25Flash
-		if userInfo != nil && model != "" && param.GeminiModels[model] {
-			l.Model = model
-		}
-	}
+	l.Model = getTxtModel(l)
 }
 
 func (d *OpenAIReq) Send(ctx context.Context, l *LLM) error {
@@ -163,6 +119,8 @@ func (d *OpenAIReq) Send(ctx context.Context, l *LLM) error {
 
 		if response.Usage != nil {
 			l.Cs.Token += response.Usage.TotalTokens
+			l.Cs.PromptToken += response.Usage.PromptTokens
+			l.Cs.CompletionToken += response.Usage.CompletionTokens
 		}
 	}
 
@@ -262,9 +220,10 @@ func (d *OpenAIReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 	client := GetOpenAIClient(ctx, "txt")
 
 	request := openai.ChatCompletionRequest{
-		Model:    l.Model,
-		Tools:    l.OpenAITools,
-		Messages: d.OpenAIMsgs,
+		Model:          l.Model,
+		Tools:          l.OpenAITools,
+		Messages:       d.OpenAIMsgs,
+		ResponseFormat: structuredFormat(ctx, l.ResponseFormat),
 	}
 
 	if conf.BaseConfInfo.LLMOptionParam {
@@ -302,6 +261,8 @@ func (d *OpenAIReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 	}
 
 	l.Cs.Token += response.Usage.TotalTokens
+	l.Cs.PromptToken += response.Usage.PromptTokens
+	l.Cs.CompletionToken += response.Usage.CompletionTokens
 	if len(response.Choices[0].Message.ToolCalls) > 0 {
 		d.GetMessage(openai.ChatMessageRoleAssistant, "")
 		d.OpenAIMsgs[len(d.OpenAIMsgs)-1].ToolCalls = response.Choices[0].Message.ToolCalls
@@ -567,30 +528,15 @@ func GetOpenAIClient(ctx context.Context, clientType string) *openai.Client {
 
 	var token string
 	var specialLLMUrl string
-	switch t {
-	case param.OpenAi:
-		token = conf.BaseConfInfo.OpenAIToken
-	case param.Aliyun:
-		token = conf.BaseConfInfo.AliyunToken
-		specialLLMUrl = "https://dashscope.aliyuncs.com/compatible-mode/v1"
-	case param.ChatAnyWhere:
-		token = conf.BaseConfInfo.ChatAnyWhereToken
-		specialLLMUrl = "https://api.chatanywhere.tech/v1"
-	case param.DeepSeek:
-		token = conf.BaseConfInfo.DeepseekToken
-		specialLLMUrl = "https://api.deepseek.com/v1"
-	case param.Vol:
-		token = conf.BaseConfInfo.VolToken
-		specialLLMUrl = "https://ark.cn-beijing.volces.com/api/v3"
-	case param.OpenRouter:
-		token = conf.BaseConfInfo.OpenRouterToken
-		specialLLMUrl = "https://openrouter.ai/api/v1"
-	case param.AI302:
-		token = conf.BaseConfInfo.AI302Token
-		specialLLMUrl = "https://api.302.ai/v1"
-	case param.Gemini:
-		token = conf.BaseConfInfo.GeminiToken
-		specialLLMUrl = "https://generativelanguage.googleapis.com/v1beta/openai"
+	custom := false
+	p := provider.Get(t)
+	if p != nil {
+		token = p.GetToken()
+		specialLLMUrl = p.BaseURL
+		custom = p.Custom
+		if p.Proxy != "" {
+			httpClient = utils.GetProxyClient(p.Proxy)
+		}
 	}
 
 	openaiConfig := openai.DefaultConfig(token)
@@ -598,7 +544,8 @@ func GetOpenAIClient(ctx context.Context, clientType string) *openai.Client {
 		openaiConfig.BaseURL = specialLLMUrl
 	}
 
-	if conf.BaseConfInfo.CustomUrl != "" {
+	// custom provider has its own url
+	if conf.BaseConfInfo.CustomUrl != "" && !custom {
 		openaiConfig.BaseURL = conf.BaseConfInfo.CustomUrl
 	}
 	openaiConfig.HTTPClient = httpClient
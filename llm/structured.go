@@ -0,0 +1,227 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/metrics"
+	"github.com/yincongcyincong/MuseBot/provider"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	// StructuredRetryTimes times of asking model again with validation error
+	StructuredRetryTimes = 2
+)
+
+var (
+	ErrNoJsonObject = errors.New("no json object found in response")
+)
+
+// ResponseFormat json schema which response of llm must follow.
+type ResponseFormat struct {
+	Name   string
+	Schema map[string]any
+	// Strict every property is required and no additional property is allowed
+	Strict bool
+}
+
+// StructuredOutput is json output of llm, Validate tells the model what to fix.
+type StructuredOutput interface {
+	ResponseFormat() *ResponseFormat
+	Validate() error
+}
+
+// SyncSendStructured request llm and parse response into v. response_format is requested from providers
+// which support json_schema or json_object, json is extracted from text for other providers.
+// the model is asked again with the validation error, the last response and error are returned if it never succeeds.
+func (l *LLM) SyncSendStructured(ctx context.Context, v StructuredOutput) (string, error) {
+	format := v.ResponseFormat()
+	l.ResponseFormat = format
+	defer func() {
+		l.ResponseFormat = nil
+	}()
+
+	var content string
+	var err error
+	for i := 0; ; i++ {
+		metrics.APIRequestCount.WithLabelValues(l.Model).Inc()
+		content, err = l.LLMClient.SyncSend(ctx, l)
+		if err != nil {
+			return "", err
+		}
+
+		err = ParseStructured(content, v)
+		if err == nil {
+			return content, nil
+		}
+		logger.WarnCtx(l.Ctx, "structured output invalid", "name", format.Name, "retry", i, "err", err, "content", content)
+		if i >= StructuredRetryTimes {
+			break
+		}
+
+		l.LLMClient.GetMessage(openai.ChatMessageRoleAssistant, content)
+		l.LLMClient.GetMessage(openai.ChatMessageRoleUser, i18n.GetMessage("structured_output_retry", map[string]interface{}{
+			"err": err.Error(),
+		}))
+	}
+
+	return content, err
+}
+
+// ParseStructured decode json of content into v and validate it. the whole content is tried first,
+// then json objects in content from the last one, object without required fields of schema is skipped.
+func ParseStructured(content string, v StructuredOutput) error {
+	format := v.ResponseFormat()
+	candidates := append([]string{trimCodeFence(content)}, reverse(jsonObjects(content))...)
+
+	err := ErrNoJsonObject
+	for _, candidate := range candidates {
+		fields := make(map[string]json.RawMessage)
+		if json.Unmarshal([]byte(candidate), &fields) != nil {
+			continue
+		}
+		if missing := missingFields(format, fields); missing != "" {
+			err = fmt.Errorf("field %s is required", missing)
+			continue
+		}
+
+		resetValue(v)
+		err = json.Unmarshal([]byte(candidate), v)
+		if err != nil {
+			continue
+		}
+		err = v.Validate()
+		if err == nil {
+			return nil
+		}
+	}
+
+	resetValue(v)
+	return err
+}
+
+// structuredFormat get response format supported by provider of text, nil if provider has no native support.
+func structuredFormat(ctx context.Context, format *ResponseFormat) *openai.ChatCompletionResponseFormat {
+	if format == nil {
+		return nil
+	}
+
+	var p *provider.Provider
+	if userInfo := db.GetCtxUserInfo(ctx); userInfo != nil {
+		p = provider.Get(utils.GetTxtType(userInfo.LLMConfigRaw))
+	} else {
+		p = provider.Get(utils.GetTxtType(nil))
+	}
+	switch {
+	case p == nil:
+		return nil
+	case p.Has(provider.JSONSchema):
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   format.Name,
+				Schema: jsonSchema(format.Schema),
+				Strict: format.Strict,
+			},
+		}
+	case p.Has(provider.JSONObject):
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+	return nil
+}
+
+type jsonSchema map[string]any
+
+func (s jsonSchema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any(s))
+}
+
+func missingFields(format *ResponseFormat, fields map[string]json.RawMessage) string {
+	required, _ := format.Schema["required"].([]string)
+	for _, name := range required {
+		if _, ok := fields[name]; !ok {
+			return name
+		}
+	}
+	return ""
+}
+
+func resetValue(v any) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	}
+}
+
+// trimCodeFence remove ```json fence around content.
+func trimCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	if idx := strings.Index(content, "\n"); idx >= 0 {
+		content = content[idx+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
+
+// jsonObjects find top level {...} in text, braces in json strings are ignored.
+func jsonObjects(text string) []string {
+	res := make([]string, 0)
+	depth, start := 0, -1
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			if depth > 0 {
+				inString = true
+			}
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				res = append(res, text[start:i+1])
+			}
+		}
+	}
+	return res
+}
+
+func reverse(s []string) []string {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+// fakeClient answer SyncSend with responses in order.
+type fakeClient struct {
+	OpenAIReq
+	responses []string
+	formats   []*openai.ChatCompletionResponseFormat
+}
+
+func (f *fakeClient) SyncSend(ctx context.Context, l *LLM) (string, error) {
+	f.formats = append(f.formats, structuredFormat(ctx, l.ResponseFormat))
+	res := f.responses[0]
+	f.responses = f.responses[1:]
+	return res, nil
+}
+
+func TestParseStructured(t *testing.T) {
+	plans := new(TaskInfo)
+	assert.NoError(t, ParseStructured(`{"plan":[{"name":"search","description":"find news"}]}`, plans))
+	assert.Len(t, plans.Plan, 1)
+
+	assert.NoError(t, ParseStructured("```json\n{\"plan\": []}\n```", plans))
+	assert.Empty(t, plans.Plan)
+
+	// example in prose is skipped, the last object is used
+	content := `format is {"name": "x"}, my plan: {"plan": [{"name": "llm_tool", "description": "write {code}"}]} done`
+	assert.NoError(t, ParseStructured(content, plans))
+	assert.Equal(t, "write {code}", plans.Plan[0].Description)
+
+	assert.ErrorIs(t, ParseStructured("no json here", plans), ErrNoJsonObject)
+	assert.EqualError(t, ParseStructured(`{"steps": []}`, plans), "field plan is required")
+	assert.EqualError(t, ParseStructured(`{"plan": [{"name": "search"}]}`, plans), "description of plan[0] is empty")
+	assert.Empty(t, plans.Plan)
+
+	mcpResult := new(McpResult)
+	assert.NoError(t, ParseStructured(`{ "agent" : "github" }`, mcpResult))
+	assert.Equal(t, "github", mcpResult.Agent)
+	assert.Error(t, ParseStructured(`{"agent": ""}`, mcpResult))
+}
+
+func TestStructuredFormat(t *testing.T) {
+	conf.BaseConfInfo.OpenAIToken = "openai"
+	conf.BaseConfInfo.DeepseekToken = "deepseek"
+	defer func() {
+		conf.BaseConfInfo.OpenAIToken = ""
+		conf.BaseConfInfo.DeepseekToken = ""
+	}()
+	format := new(TaskInfo).ResponseFormat()
+	ctxOf := func(t string) context.Context {
+		return context.WithValue(context.Background(), "user_info", &db.User{
+			LLMConfigRaw: &param.LLMConfig{TxtType: t},
+		})
+	}
+
+	f := structuredFormat(ctxOf(param.OpenAi), format)
+	assert.Equal(t, openai.ChatCompletionResponseFormatTypeJSONSchema, f.Type)
+	assert.Equal(t, "task_plan", f.JSONSchema.Name)
+	assert.True(t, f.JSONSchema.Strict)
+
+	f = structuredFormat(ctxOf(param.DeepSeek), format)
+	assert.Equal(t, openai.ChatCompletionResponseFormatTypeJSONObject, f.Type)
+
+	conf.BaseConfInfo.OpenAIToken = ""
+	conf.BaseConfInfo.DeepseekToken = ""
+	txtType := conf.BaseConfInfo.Type
+	conf.BaseConfInfo.Type = param.Ollama
+	defer func() {
+		conf.BaseConfInfo.Type = txtType
+	}()
+	assert.Nil(t, structuredFormat(ctxOf(param.Ollama), format))
+	assert.Nil(t, structuredFormat(ctxOf(param.Ollama), nil))
+}
+
+func TestSyncSendStructured(t *testing.T) {
+	i18n.InitI18n()
+	conf.BaseConfInfo.OpenAIToken = "openai"
+	defer func() {
+		conf.BaseConfInfo.OpenAIToken = ""
+	}()
+	ctx := context.WithValue(context.Background(), "user_info", &db.User{
+		LLMConfigRaw: &param.LLMConfig{TxtType: param.OpenAi},
+	})
+	client := &fakeClient{responses: []string{
+		`{"plan": [{"name": "search"}]}`,
+		`{"plan": [{"name": "search", "description": "find news"}]}`,
+	}}
+	l := &LLM{Ctx: ctx, Cs: new(param.ContextState), LLMClient: client}
+
+	plans := new(TaskInfo)
+	content, err := l.SyncSendStructured(ctx, plans)
+	assert.NoError(t, err)
+	assert.Contains(t, content, "find news")
+	assert.Len(t, plans.Plan, 1)
+	assert.Nil(t, l.ResponseFormat)
+
+	// invalid response and retry prompt are appended
+	assert.Len(t, client.OpenAIMsgs, 2)
+	assert.Equal(t, openai.ChatMessageRoleAssistant, client.OpenAIMsgs[0].Role)
+	assert.Equal(t, openai.ChatMessageRoleUser, client.OpenAIMsgs[1].Role)
+	assert.Len(t, client.formats, 2)
+	assert.Equal(t, openai.ChatCompletionResponseFormatTypeJSONSchema, client.formats[0].Type)
+
+	// the last response is returned after retries
+	client.responses = []string{"a", "b", "c"}
+	content, err = l.SyncSendStructured(ctx, plans)
+	assert.ErrorIs(t, err, ErrNoJsonObject)
+	assert.Equal(t, "c", content)
+}
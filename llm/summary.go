@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+// summaryGroup summaries of the same session run one by one, reading and saving summary is not atomic.
+var summaryGroup singleflight.Group
+
+// SummarizeHistory condense older conversation of session into summary when context exceed summary_threshold,
+// or when the oldest conversation not summarized is going to be dropped from the latest max_qa_pari records.
+func (l *LLM) SummarizeHistory() error {
+	if conf.BaseConfInfo.SummaryThreshold <= 0 || !l.Cs.UseRecord || l.Cs.SessionId == "" {
+		return nil
+	}
+
+	// summary called during a running one is merged into it
+	_, err, _ := summaryGroup.Do(l.Cs.SessionId, func() (interface{}, error) {
+		return nil, l.summarizeHistory()
+	})
+	return err
+}
+
+func (l *LLM) summarizeHistory() error {
+	// answer is already sent, summary should not be cancelled with the request
+	ctx := context.WithoutCancel(l.Ctx)
+	msgRecords := db.GetMsgRecord(ctx, l.Cs.SessionId)
+	if msgRecords == nil {
+		return nil
+	}
+
+	summary, err := db.GetSummary(l.Cs.SessionId)
+	if err != nil {
+		return err
+	}
+
+	aqs := db.FilterBySummary(msgRecords.AQs, summary)
+	token := 0
+	for _, aq := range aqs {
+		token += l.CountTokens(aq.Question + " " + aq.Answer)
+	}
+
+	older := summaryDialogs(msgRecords.AQs, aqs, token)
+	if len(older) == 0 {
+		return nil
+	}
+	lastSummary := ""
+	if summary != nil {
+		lastSummary = summary.Content
+	}
+
+	summaryLLM := NewLLM(WithContext(ctx), WithUserId(l.UserId), WithChatId(l.ChatId))
+	summaryLLM.LLMClient.GetModel(summaryLLM)
+	summaryLLM.LLMClient.GetMessage(openai.ChatMessageRoleUser, i18n.GetMessage("summary_conversation_prompt",
+		map[string]interface{}{
+			"summary": lastSummary,
+			"dialogs": older,
+		}))
+
+	metrics.APIRequestCount.WithLabelValues(summaryLLM.Model).Inc()
+	content, err := summaryLLM.LLMClient.SyncSend(ctx, summaryLLM)
+	if err != nil {
+		return err
+	}
+	if content == "" {
+		return errors.New("summary is empty")
+	}
+
+	err = db.UpsertSummary(&db.Summary{
+		SessionId: l.Cs.SessionId,
+		Content:   content,
+		LastTime:  older[len(older)-1].CreateTime,
+		Token:     summaryLLM.Cs.Token,
+	})
+	if err != nil {
+		return err
+	}
+
+	summaryLLM.InsertUsageRecord(l.Cs.SessionId, "summary")
+
+	logger.InfoCtx(ctx, "summarize conversation", "sessionId", l.Cs.SessionId, "dialogs", len(older),
+		"token", summaryLLM.Cs.Token)
+	return nil
+}
+
+// summaryDialogs dialogs going to be summarized, records are the latest max_qa_pari dialogs and aqs are
+// the ones not summarized yet, nil if summary is not needed.
+func summaryDialogs(records, aqs []*db.AQ, token int) []*db.AQ {
+	keepPairs := conf.BaseConfInfo.SummaryKeepPairs
+	// the next record evicts the oldest one, which would never be summarized
+	evicting := conf.BaseConfInfo.MaxQAPair > 0 && len(records) >= conf.BaseConfInfo.MaxQAPair &&
+		len(aqs) > 0 && aqs[0] == records[0]
+	if evicting && keepPairs >= conf.BaseConfInfo.MaxQAPair {
+		keepPairs = conf.BaseConfInfo.MaxQAPair - 1
+	}
+	if (token <= conf.BaseConfInfo.SummaryThreshold && !evicting) || len(aqs) <= keepPairs {
+		return nil
+	}
+	return aqs[:len(aqs)-keepPairs]
+}
@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+)
+
+func TestSummaryDialogs(t *testing.T) {
+	threshold, keepPairs, maxQAPair := conf.BaseConfInfo.SummaryThreshold, conf.BaseConfInfo.SummaryKeepPairs, conf.BaseConfInfo.MaxQAPair
+	defer func() {
+		conf.BaseConfInfo.SummaryThreshold, conf.BaseConfInfo.SummaryKeepPairs, conf.BaseConfInfo.MaxQAPair = threshold, keepPairs, maxQAPair
+	}()
+	conf.BaseConfInfo.SummaryThreshold, conf.BaseConfInfo.SummaryKeepPairs, conf.BaseConfInfo.MaxQAPair = 1000, 2, 5
+
+	records := make([]*db.AQ, 0)
+	for i := 0; i < 4; i++ {
+		records = append(records, &db.AQ{CreateTime: int64(i)})
+	}
+
+	// under threshold and window is not full
+	assert.Nil(t, summaryDialogs(records, records, 100))
+	assert.Equal(t, records[:2], summaryDialogs(records, records, 2000))
+
+	// oldest record is going to be evicted
+	records = append(records, &db.AQ{CreateTime: 4})
+	assert.Equal(t, records[:3], summaryDialogs(records, records, 100))
+	// oldest record is already summarized
+	assert.Nil(t, summaryDialogs(records, records[3:], 100))
+
+	// keep pairs can't keep the whole window
+	conf.BaseConfInfo.SummaryKeepPairs = 5
+	assert.Equal(t, records[:1], summaryDialogs(records, records, 100))
+}
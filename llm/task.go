@@ -2,22 +2,20 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"regexp"
+	"fmt"
+	"strconv"
+	"sync"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/i18n"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/metrics"
 	"github.com/yincongcyincong/MuseBot/param"
 )
 
-var (
-	jsonRe = regexp.MustCompile(`(?s)\{[\s\r\n]*"plan"\s*:\s*\[.*?][\s\r\n]*}`)
-)
-
 type LLMTaskReq struct {
 	MessageChan chan *param.MsgInfo
 	HTTPMsgChan chan string
@@ -32,29 +30,165 @@ type LLMTaskReq struct {
 
 	Cs  *param.ContextState
 	Ctx context.Context
+
+	// RunId id of task run to resume, a new run is created if it is 0
+	RunId int64
+
+	run   *db.TaskRun
+	steps map[string]*db.TaskStep
 }
 
 type Task struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	DependsOn   []string `json:"depends_on"`
 }
 
 type TaskInfo struct {
 	Plan []*Task `json:"plan"`
 }
 
+func (t *TaskInfo) ResponseFormat() *ResponseFormat {
+	return &ResponseFormat{
+		Name:   "task_plan",
+		Strict: true,
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"plan": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"id":          map[string]any{"type": "string", "description": "unique id of the task"},
+							"name":        map[string]any{"type": "string", "description": "agent name of the task"},
+							"description": map[string]any{"type": "string", "description": "how to execute the task"},
+							"depends_on": map[string]any{
+								"type":        "array",
+								"items":       map[string]any{"type": "string"},
+								"description": "ids of tasks whose results this task needs, empty if it can run at once",
+							},
+						},
+						"required":             []string{"id", "name", "description", "depends_on"},
+						"additionalProperties": false,
+					},
+				},
+			},
+			"required":             []string{"plan"},
+			"additionalProperties": false,
+		},
+	}
+}
+
+// Validate empty plan means all tasks are completed. task without id gets its position as id,
+// dependencies must exist and have no cycle.
+func (t *TaskInfo) Validate() error {
+	tasks := make(map[string]*Task, len(t.Plan))
+	for i, task := range t.Plan {
+		if task == nil || task.Name == "" {
+			return fmt.Errorf("name of plan[%d] is empty", i)
+		}
+		if task.Description == "" {
+			return fmt.Errorf("description of plan[%d] is empty", i)
+		}
+		if task.Id == "" {
+			task.Id = strconv.Itoa(i + 1)
+		}
+		if _, ok := tasks[task.Id]; ok {
+			return fmt.Errorf("id %s of plan[%d] is duplicated", task.Id, i)
+		}
+		tasks[task.Id] = task
+	}
+
+	for i, task := range t.Plan {
+		for _, dep := range task.DependsOn {
+			if _, ok := tasks[dep]; !ok || dep == task.Id {
+				return fmt.Errorf("depends_on %s of plan[%d] is not another task id", dep, i)
+			}
+		}
+	}
+
+	// visit state: 1 visiting, 2 visited
+	state := make(map[string]int, len(t.Plan))
+	var visit func(task *Task) error
+	visit = func(task *Task) error {
+		switch state[task.Id] {
+		case 1:
+			return fmt.Errorf("depends_on of task %s has a cycle", task.Id)
+		case 2:
+			return nil
+		}
+		state[task.Id] = 1
+		for _, dep := range task.DependsOn {
+			if err := visit(tasks[dep]); err != nil {
+				return err
+			}
+		}
+		state[task.Id] = 2
+		return nil
+	}
+	for _, task := range t.Plan {
+		if err := visit(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type TaskResult struct {
 	TaskName   string
+	TaskDesc   string
 	TaskResult string
+	Token      int
+	ToolCalls  []*ToolCall
 }
 
-// ExecuteTask execute task command
+// ExecuteTask execute task command, the run is saved so that it can be resumed after failure or restart.
 func (d *LLMTaskReq) ExecuteTask() error {
-	logger.InfoCtx(d.Ctx, "task content", "content", d.Content)
+	ctx, cancel := context.WithCancel(d.Ctx)
+	defer cancel()
+	d.Ctx = ctx
+
+	prevResults, finished, err := d.startRun(cancel)
+	if err != nil {
+		logger.ErrorCtx(d.Ctx, "start task run fail", "err", err)
+		return err
+	}
+	defer runningTasks.Delete(d.run.ID)
+	go d.touchRun(ctx, cancel)
+
+	err = d.executeTask(prevResults, finished)
+	// error of llm client may not wrap context error
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		err = ctx.Err()
+	}
+	d.finishRun(err)
+	if errors.Is(err, context.Canceled) {
+		d.sendMsg(i18n.GetMessage("task_run_cancelled", map[string]interface{}{
+			"id": d.run.ID,
+		}))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, i18n.GetMessage("task_run_fail", map[string]interface{}{
+			"id": d.run.ID,
+		}))
+	}
+	return nil
+}
+
+// executeTask plan, execute and summarize task. plan saved in run is used when resuming,
+// prevResults are results of former loops and finished are completed steps of the current plan.
+func (d *LLMTaskReq) executeTask(prevResults []*TaskResult, finished map[string]*TaskResult) error {
+	logger.InfoCtx(d.Ctx, "task content", "content", d.Content, "run", d.run.ID)
 	taskParam := make(map[string]interface{})
 	taskParam["assign_param"] = make([]map[string]string, 0)
 	taskParam["user_task"] = d.Content
 	conf.TaskTools.Range(func(name, value any) bool {
+		if !allowMCP(d.Ctx, name.(string)) {
+			return true
+		}
 		tool := value.(*conf.AgentInfo)
 		taskParam["assign_param"] = append(taskParam["assign_param"].([]map[string]string), map[string]string{
 			"tool_name": name.(string),
@@ -67,49 +201,61 @@ func (d *LLMTaskReq) ExecuteTask() error {
 	llm := NewLLM(WithUserId(d.UserId), WithChatId(d.ChatId), WithMsgId(d.MsgId),
 		WithMessageChan(d.MessageChan), WithContent(prompt), WithHTTPMsgChan(d.HTTPMsgChan),
 		WithPerMsgLen(d.PerMsgLen), WithContext(d.Ctx), WithCS(d.Cs))
-	llm.GetMessages(d.UserId, prompt)
+	llm.GetMessages(d.Cs.SessionId, prompt)
 	llm.LLMClient.GetModel(llm)
 
-	metrics.APIRequestCount.WithLabelValues(llm.Model).Inc()
-	c, err := llm.LLMClient.SyncSend(d.Ctx, llm)
-	if err != nil {
-		logger.ErrorCtx(d.Ctx, "get message fail", "err", err)
-		return err
-	}
-
-	d.Token += llm.Cs.Token
-
-	matches := jsonRe.FindAllString(c, -1)
 	plans := new(TaskInfo)
-	for _, match := range matches {
-		err = json.Unmarshal([]byte(match), &plans)
+	c := d.run.Plan
+	if c != "" {
+		err := ParseStructured(c, plans)
 		if err != nil {
-			logger.WarnCtx(d.Ctx, "json umarshal fail", "err", err)
+			logger.ErrorCtx(d.Ctx, "parse saved task plan fail", "err", err)
+			return err
+		}
+	} else {
+		var err error
+		c, err = llm.SyncSendStructured(d.Ctx, plans)
+		if c == "" && err != nil {
+			logger.ErrorCtx(d.Ctx, "get message fail", "err", err)
+			return err
+		}
+		if err != nil {
+			logger.WarnCtx(d.Ctx, "parse task plan fail", "err", err)
 		}
-	}
 
-	logger.InfoCtx(d.Ctx, "task plan", "plan", plans)
+		d.Token += llm.Cs.Token
 
-	if len(plans.Plan) == 0 {
-		logger.InfoCtx(d.Ctx, "no plan created!")
+		logger.InfoCtx(d.Ctx, "task plan", "plan", plans)
 
-		finalLLM := NewLLM(WithUserId(d.UserId), WithChatId(d.ChatId), WithMsgId(d.MsgId),
-			WithMessageChan(d.MessageChan), WithContent(d.Content), WithHTTPMsgChan(d.HTTPMsgChan),
-			WithPerMsgLen(d.PerMsgLen), WithContext(d.Ctx))
-		finalLLM.LLMClient.GetMessage(openai.ChatMessageRoleUser, c)
-		finalLLM.LLMClient.GetModel(finalLLM)
+		if len(plans.Plan) == 0 {
+			logger.InfoCtx(d.Ctx, "no plan created!")
 
-		metrics.APIRequestCount.WithLabelValues(finalLLM.Model).Inc()
-		err = finalLLM.LLMClient.Send(d.Ctx, finalLLM)
-		if err != nil {
-			logger.ErrorCtx(d.Ctx, "request summary fail", "err", err)
+			finalLLM := NewLLM(WithUserId(d.UserId), WithChatId(d.ChatId), WithMsgId(d.MsgId),
+				WithMessageChan(d.MessageChan), WithContent(d.Content), WithHTTPMsgChan(d.HTTPMsgChan),
+				WithPerMsgLen(d.PerMsgLen), WithContext(d.Ctx))
+			finalLLM.LLMClient.GetMessage(openai.ChatMessageRoleUser, c)
+			finalLLM.LLMClient.GetModel(finalLLM)
+
+			metrics.APIRequestCount.WithLabelValues(finalLLM.Model).Inc()
+			err = finalLLM.LLMClient.Send(d.Ctx, finalLLM)
+			if err != nil {
+				logger.ErrorCtx(d.Ctx, "request summary fail", "err", err)
+			}
+			d.Token += finalLLM.Cs.Token
+			d.run.Result = finalLLM.WholeContent
+			return err
 		}
-		return err
+
+		d.savePlan(0, plans, c)
 	}
 
 	llm.DirectSendMsg(c, false)
 	llm.LLMClient.GetMessage(openai.ChatMessageRoleAssistant, c)
-	err = d.loopTask(d.Ctx, plans, c, llm, 0)
+	for _, res := range prevResults {
+		llm.LLMClient.GetMessage(openai.ChatMessageRoleUser, res.TaskDesc)
+		llm.LLMClient.GetMessage(openai.ChatMessageRoleAssistant, res.TaskResult)
+	}
+	err := d.loopTask(d.Ctx, plans, c, llm, d.run.Loop, finished)
 	if err != nil {
 		logger.ErrorCtx(d.Ctx, "loopTask fail", "err", err)
 		return err
@@ -128,6 +274,7 @@ func (d *LLMTaskReq) ExecuteTask() error {
 		logger.ErrorCtx(d.Ctx, "request summary fail", "err", err)
 		return err
 	}
+	d.run.Result = llm.WholeContent
 
 	err = llm.InsertOrUpdate()
 	if err != nil {
@@ -136,37 +283,27 @@ func (d *LLMTaskReq) ExecuteTask() error {
 	return err
 }
 
-// loopTask loop task
-func (d *LLMTaskReq) loopTask(ctx context.Context, plans *TaskInfo, lastPlan string, llm *LLM, loop int) error {
+// loopTask loop task, finished are steps of plans completed before resuming.
+func (d *LLMTaskReq) loopTask(ctx context.Context, plans *TaskInfo, lastPlan string, llm *LLM, loop int,
+	finished map[string]*TaskResult) error {
 	if loop > MostLoop {
 		return errors.New("too many loops")
 	}
 
+	results, err := d.runPlan(ctx, plans, finished)
+	if err != nil {
+		return err
+	}
+
+	// results are merged in order of plan, whatever order tasks are finished in
 	completeTasks := map[string]bool{}
-	taskLLM := NewLLM(WithUserId(d.UserId), WithChatId(d.ChatId), WithMsgId(d.MsgId),
-		WithMessageChan(d.MessageChan), WithHTTPMsgChan(d.HTTPMsgChan), WithPerMsgLen(d.PerMsgLen),
-		WithContext(d.Ctx))
-	for _, plan := range plans.Plan {
-		toolInter, ok := conf.TaskTools.Load(plan.Name)
-		var tool *conf.AgentInfo
-		if ok {
-			tool = toolInter.(*conf.AgentInfo)
-		}
-		WithTaskTools(tool)(taskLLM)
-		taskLLM.LLMClient.GetMessage(openai.ChatMessageRoleUser, plan.Description)
-		taskLLM.Content = plan.Description
-		taskLLM.LLMClient.GetModel(taskLLM)
-		logger.InfoCtx(d.Ctx, "execute task", "task", plan.Name, "task desc", plan.Description)
-		err := d.requestTask(ctx, taskLLM, plan)
-		if err != nil {
-			return err
-		}
-		d.Token += taskLLM.Cs.Token
+	for i, plan := range plans.Plan {
+		llm.LLMClient.GetMessage(openai.ChatMessageRoleUser, results[i].TaskDesc)
+		llm.LLMClient.GetMessage(openai.ChatMessageRoleAssistant, results[i].TaskResult)
+		d.Token += results[i].Token
 		completeTasks[plan.Description] = true
 	}
 
-	llm.LLMClient.AppendMessages(taskLLM.LLMClient)
-
 	taskParam := map[string]interface{}{
 		"user_task":      d.Content,
 		"complete_tasks": completeTasks,
@@ -176,50 +313,159 @@ func (d *LLMTaskReq) loopTask(ctx context.Context, plans *TaskInfo, lastPlan str
 	llm.LLMClient.GetMessage(openai.ChatMessageRoleUser, i18n.GetMessage("loop_task_prompt", taskParam))
 	llm.LLMClient.GetModel(llm)
 
-	metrics.APIRequestCount.WithLabelValues(llm.Model).Inc()
-	c, err := llm.LLMClient.SyncSend(ctx, llm)
-	if err != nil {
+	plans = new(TaskInfo)
+	c, err := llm.SyncSendStructured(ctx, plans)
+	if len(c) == 0 {
+		if err == nil {
+			err = errors.New("response is emtpy")
+		}
 		logger.ErrorCtx(d.Ctx, "ChatCompletionStream error", "err", err)
 		return err
 	}
-
-	if len(c) == 0 {
-		logger.ErrorCtx(d.Ctx, "response is emtpy", "response", c)
-		return errors.New("response is emtpy")
+	if err != nil {
+		logger.WarnCtx(d.Ctx, "parse task plan fail", "err", err)
 	}
 
 	d.Token += llm.Cs.Token
 
-	matches := jsonRe.FindAllString(c, -1)
-	plans = new(TaskInfo)
-	for _, match := range matches {
-		err := json.Unmarshal([]byte(match), &plans)
-		if err != nil {
-			logger.ErrorCtx(d.Ctx, "json umarshal fail", "err", err)
-		}
-	}
-
 	llm.LLMClient.GetMessage(openai.ChatMessageRoleAssistant, c)
 
 	if len(plans.Plan) == 0 {
 		return nil
 	}
 
-	return d.loopTask(ctx, plans, c, llm, loop+1)
+	d.savePlan(loop+1, plans, c)
+	return d.loopTask(ctx, plans, c, llm, loop+1, nil)
 }
 
-// requestTask request task
-func (d *LLMTaskReq) requestTask(ctx context.Context, llm *LLM, plan *Task) error {
-	metrics.APIRequestCount.WithLabelValues(llm.Model).Inc()
-	c, err := llm.LLMClient.SyncSend(ctx, llm)
+// runPlan execute tasks of plan, a task starts after tasks it depends on are completed and
+// at most TaskParallelism tasks run at the same time. results are in order of plan.
+func (d *LLMTaskReq) runPlan(ctx context.Context, plans *TaskInfo, finished map[string]*TaskResult) ([]*TaskResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parallelism := conf.ToolsConfInfo.TaskParallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	sem := make(chan struct{}, parallelism)
+
+	results := make([]*TaskResult, len(plans.Plan))
+	done := make(map[string]chan struct{}, len(plans.Plan))
+	index := make(map[string]int, len(plans.Plan))
+	for i, plan := range plans.Plan {
+		done[plan.Id] = make(chan struct{})
+		index[plan.Id] = i
+		if res, ok := finished[plan.Id]; ok {
+			results[i] = res
+			close(done[plan.Id])
+		}
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i, plan := range plans.Plan {
+		if results[i] != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, plan *Task) {
+			defer wg.Done()
+
+			deps := make([]*TaskResult, 0, len(plan.DependsOn))
+			for _, dep := range plan.DependsOn {
+				select {
+				case <-done[dep]:
+					deps = append(deps, results[index[dep]])
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			step := d.steps[plan.Id]
+			d.saveStep(step, db.TaskStepStatusRunning, nil, nil)
+			res, err := d.requestTask(ctx, plan, deps)
+			if err != nil {
+				d.saveStep(step, db.TaskStepStatusFailed, nil, err)
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			d.saveStep(step, db.TaskStepStatusDone, res, nil)
+			results[i] = res
+			close(done[plan.Id])
+		}(i, plan)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// requestTask request task with its own messages, results of tasks it depends on are the context.
+func (d *LLMTaskReq) requestTask(ctx context.Context, plan *Task, deps []*TaskResult) (*TaskResult, error) {
+	toolInter, ok := conf.TaskTools.Load(plan.Name)
+	var tool *conf.AgentInfo
+	if ok {
+		tool = toolInter.(*conf.AgentInfo)
+	}
+	taskLLM := NewLLM(WithUserId(d.UserId), WithChatId(d.ChatId), WithMsgId(d.MsgId),
+		WithMessageChan(d.MessageChan), WithHTTPMsgChan(d.HTTPMsgChan), WithPerMsgLen(d.PerMsgLen),
+		WithContext(ctx), WithTaskTools(tool), WithContent(plan.Description))
+	for _, dep := range deps {
+		taskLLM.LLMClient.GetMessage(openai.ChatMessageRoleUser, dep.TaskDesc)
+		taskLLM.LLMClient.GetMessage(openai.ChatMessageRoleAssistant, dep.TaskResult)
+	}
+	taskLLM.LLMClient.GetMessage(openai.ChatMessageRoleUser, plan.Description)
+	taskLLM.LLMClient.GetModel(taskLLM)
+
+	logger.InfoCtx(d.Ctx, "execute task", "id", plan.Id, "task", plan.Name, "task desc", plan.Description)
+	taskLLM.DirectSendMsg(i18n.GetMessage("task_step_start", map[string]interface{}{
+		"id":   plan.Id,
+		"name": plan.Name,
+	}), true)
+
+	metrics.APIRequestCount.WithLabelValues(taskLLM.Model).Inc()
+	c, err := taskLLM.LLMClient.SyncSend(ctx, taskLLM)
 	if err != nil {
-		logger.ErrorCtx(d.Ctx, "ChatCompletionStream error", "err", err)
-		return err
+		logger.ErrorCtx(d.Ctx, "ChatCompletionStream error", "id", plan.Id, "err", err)
+		if ctx.Err() == nil {
+			taskLLM.DirectSendMsg(i18n.GetMessage("task_step_fail", map[string]interface{}{
+				"id":   plan.Id,
+				"name": plan.Name,
+				"err":  err.Error(),
+			}), true)
+		}
+		return nil, err
 	}
 
-	// llm response merge into msg
-	c += "\n\n" + plan.Name + " is completed"
-	llm.LLMClient.GetMessage(openai.ChatMessageRoleAssistant, c)
+	taskLLM.DirectSendMsg(i18n.GetMessage("task_step_done", map[string]interface{}{
+		"id":   plan.Id,
+		"name": plan.Name,
+	}), true)
 
-	return nil
+	// llm response merge into msg
+	return &TaskResult{
+		TaskName:   plan.Name,
+		TaskDesc:   plan.Description,
+		TaskResult: c + "\n\n" + plan.Name + " is completed",
+		Token:      taskLLM.Cs.Token,
+		ToolCalls:  taskLLM.ToolCalls,
+	}, nil
 }
@@ -0,0 +1,249 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+const (
+	// taskRunTouchInterval process executing run touches it every interval
+	taskRunTouchInterval = 30 * time.Second
+	// taskRunStaleTimeout running run not touched in timeout was interrupted
+	taskRunStaleTimeout = 3 * taskRunTouchInterval
+)
+
+// runningTasks run id -> cancel func of task runs executing in this process
+var runningTasks = sync.Map{}
+
+// CancelTaskRun cancel task run executing in this process or other ones, false if it is not running.
+func CancelTaskRun(id int64) bool {
+	if cancel, ok := runningTasks.Load(id); ok {
+		cancel.(context.CancelFunc)()
+		return true
+	}
+
+	cancelled, err := db.CancelTaskRun(id, taskRunStaleTime())
+	if err != nil {
+		logger.Error("cancel task run fail", "run", id, "err", err)
+	}
+	return cancelled
+}
+
+// TaskRunStatus status of run, running run which is not touched by any process was interrupted by restart.
+func TaskRunStatus(run *db.TaskRun) string {
+	if run.Status == db.TaskRunStatusRunning && run.UpdateTime < taskRunStaleTime() {
+		if _, ok := runningTasks.Load(run.ID); !ok {
+			return db.TaskRunStatusInterrupted
+		}
+	}
+	return run.Status
+}
+
+func taskRunStaleTime() int64 {
+	return time.Now().Add(-taskRunStaleTimeout).Unix()
+}
+
+// touchRun keep run claimed by this process, run cancelled by other process is cancelled here.
+func (d *LLMTaskReq) touchRun(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(taskRunTouchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			running, err := db.TouchTaskRun(d.run.ID)
+			if err != nil {
+				logger.ErrorCtx(ctx, "touch task run fail", "run", d.run.ID, "err", err)
+				continue
+			}
+			if !running {
+				logger.InfoCtx(ctx, "task run is cancelled by other process", "run", d.run.ID)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// startRun create task run, or load run of RunId with its completed steps to resume it.
+func (d *LLMTaskReq) startRun(cancel context.CancelFunc) ([]*TaskResult, map[string]*TaskResult, error) {
+	if d.RunId == 0 {
+		d.run = &db.TaskRun{
+			UserId:    d.UserId,
+			ChatId:    d.ChatId,
+			SessionId: d.Cs.SessionId,
+			Content:   d.Content,
+			Status:    db.TaskRunStatusRunning,
+		}
+		_, err := db.InsertTaskRun(d.run)
+		if err != nil {
+			return nil, nil, err
+		}
+		runningTasks.Store(d.run.ID, cancel)
+		d.sendMsg(i18n.GetMessage("task_run_start", map[string]interface{}{
+			"id": d.run.ID,
+		}))
+		return nil, nil, nil
+	}
+
+	run, err := db.GetTaskRun(d.RunId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if run == nil {
+		return nil, nil, fmt.Errorf("task run %d not found", d.RunId)
+	}
+	if run.Status == db.TaskRunStatusDone {
+		return nil, nil, fmt.Errorf("task run %d is already done", d.RunId)
+	}
+	if _, loaded := runningTasks.LoadOrStore(run.ID, cancel); loaded {
+		return nil, nil, fmt.Errorf("task run %d is running", d.RunId)
+	}
+	// other process may resume it at the same time
+	claimed, err := db.ClaimTaskRun(run.ID, taskRunStaleTime())
+	if err != nil || !claimed {
+		runningTasks.Delete(run.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("task run %d is running", d.RunId)
+	}
+
+	steps, err := db.GetTaskSteps(run.ID)
+	if err != nil {
+		runningTasks.Delete(run.ID)
+		return nil, nil, err
+	}
+
+	d.run = run
+	d.Content = run.Content
+	d.Token = run.Token
+	d.steps = make(map[string]*db.TaskStep)
+	prevResults := make([]*TaskResult, 0)
+	finished := make(map[string]*TaskResult)
+	for _, step := range steps {
+		if step.Loop == run.Loop {
+			d.steps[step.StepId] = step
+		}
+		if step.Status != db.TaskStepStatusDone || step.Loop > run.Loop {
+			continue
+		}
+
+		res := &TaskResult{TaskName: step.Name, TaskDesc: step.Description, TaskResult: step.Result}
+		if step.Loop == run.Loop {
+			finished[step.StepId] = res
+		} else {
+			prevResults = append(prevResults, res)
+		}
+	}
+
+	run.Status = db.TaskRunStatusRunning
+	run.ErrorMsg = ""
+
+	d.sendMsg(i18n.GetMessage("task_run_resume", map[string]interface{}{
+		"id":       run.ID,
+		"finished": len(finished),
+		"total":    len(d.steps),
+	}))
+	return prevResults, finished, nil
+}
+
+// savePlan save plan of loop and its steps, task keeps running if saving fail, but it can't be resumed from this plan.
+func (d *LLMTaskReq) savePlan(loop int, plans *TaskInfo, content string) {
+	d.run.Plan = content
+	d.run.Loop = loop
+	d.run.Token = d.Token
+	err := db.UpdateTaskRun(d.run)
+	if err != nil {
+		logger.ErrorCtx(d.Ctx, "update task run fail", "run", d.run.ID, "err", err)
+	}
+
+	d.steps = make(map[string]*db.TaskStep, len(plans.Plan))
+	for _, plan := range plans.Plan {
+		step := &db.TaskStep{
+			RunId:       d.run.ID,
+			Loop:        loop,
+			StepId:      plan.Id,
+			Name:        plan.Name,
+			Description: plan.Description,
+			DependsOn:   plan.DependsOn,
+			Status:      db.TaskStepStatusPending,
+		}
+		_, err = db.InsertTaskStep(step)
+		if err != nil {
+			logger.ErrorCtx(d.Ctx, "insert task step fail", "run", d.run.ID, "step", plan.Id, "err", err)
+			continue
+		}
+		d.steps[plan.Id] = step
+	}
+}
+
+// saveStep save status of step, result and tool calls are saved when step is done.
+func (d *LLMTaskReq) saveStep(step *db.TaskStep, status string, res *TaskResult, err error) {
+	if step == nil {
+		return
+	}
+
+	step.Status = status
+	step.ErrorMsg = ""
+	if err != nil {
+		step.ErrorMsg = err.Error()
+	}
+	if res != nil {
+		step.Result = res.TaskResult
+		step.Token = res.Token
+		toolCalls, err := json.Marshal(res.ToolCalls)
+		if err != nil {
+			logger.ErrorCtx(d.Ctx, "marshal tool calls fail", "err", err)
+		} else {
+			step.ToolCalls = string(toolCalls)
+		}
+	}
+
+	if err := db.UpdateTaskStep(step); err != nil {
+		logger.ErrorCtx(d.Ctx, "update task step fail", "run", step.RunId, "step", step.StepId, "err", err)
+	}
+}
+
+// finishRun save result of run, canceled run can be resumed like failed one.
+func (d *LLMTaskReq) finishRun(err error) {
+	d.run.Token = d.Token
+	d.run.ErrorMsg = ""
+	switch {
+	case err == nil:
+		d.run.Status = db.TaskRunStatusDone
+	case errors.Is(err, context.Canceled):
+		d.run.Status = db.TaskRunStatusCancelled
+		d.run.ErrorMsg = err.Error()
+	default:
+		d.run.Status = db.TaskRunStatusFailed
+		d.run.ErrorMsg = err.Error()
+	}
+
+	if err := db.UpdateTaskRun(d.run); err != nil {
+		logger.ErrorCtx(d.Ctx, "update task run fail", "run", d.run.ID, "err", err)
+	}
+}
+
+func (d *LLMTaskReq) sendMsg(content string) {
+	if d.MessageChan != nil {
+		d.MessageChan <- &param.MsgInfo{
+			Content:  content,
+			Finished: true,
+		}
+	}
+
+	if d.HTTPMsgChan != nil {
+		d.HTTPMsgChan <- content
+	}
+}
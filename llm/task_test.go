@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
+)
+
+// stepClient answer a task with its messages, running and maxRunning count concurrent requests.
+type stepClient struct {
+	OpenAIReq
+	running    *int32
+	maxRunning *int32
+	mu         *sync.Mutex
+	seen       map[string][]string
+}
+
+func (s *stepClient) SyncSend(ctx context.Context, l *LLM) (string, error) {
+	n := atomic.AddInt32(s.running, 1)
+	defer atomic.AddInt32(s.running, -1)
+	for {
+		m := atomic.LoadInt32(s.maxRunning)
+		if n <= m || atomic.CompareAndSwapInt32(s.maxRunning, m, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	msgs := make([]string, 0, len(s.OpenAIMsgs))
+	for _, msg := range s.OpenAIMsgs {
+		msgs = append(msgs, msg.Content)
+	}
+	s.mu.Lock()
+	s.seen[l.Content] = msgs
+	s.mu.Unlock()
+
+	if l.Content == "fail" {
+		return "", errors.New("step fail")
+	}
+	return "result of " + l.Content, nil
+}
+
+func TestTaskInfoValidate(t *testing.T) {
+	plans := &TaskInfo{Plan: []*Task{
+		{Name: "search", Description: "a"},
+		{Name: "search", Description: "b", DependsOn: []string{"1"}},
+	}}
+	assert.NoError(t, plans.Validate())
+	assert.Equal(t, "2", plans.Plan[1].Id)
+
+	plans = &TaskInfo{Plan: []*Task{
+		{Id: "a", Name: "search", Description: "a"},
+		{Id: "a", Name: "search", Description: "b"},
+	}}
+	assert.EqualError(t, plans.Validate(), "id a of plan[1] is duplicated")
+
+	plans = &TaskInfo{Plan: []*Task{
+		{Id: "a", Name: "search", Description: "a", DependsOn: []string{"c"}},
+	}}
+	assert.EqualError(t, plans.Validate(), "depends_on c of plan[0] is not another task id")
+
+	plans = &TaskInfo{Plan: []*Task{
+		{Id: "a", Name: "search", Description: "a", DependsOn: []string{"b"}},
+		{Id: "b", Name: "search", Description: "b", DependsOn: []string{"a"}},
+	}}
+	assert.EqualError(t, plans.Validate(), "depends_on of task a has a cycle")
+}
+
+func TestRunPlan(t *testing.T) {
+	i18n.InitI18n()
+
+	var running, maxRunning int32
+	client := &stepClient{running: &running, maxRunning: &maxRunning, mu: new(sync.Mutex), seen: map[string][]string{}}
+	factories := clientFactories
+	clientFactories = map[string]func() LLMClient{
+		provider.OpenAIProtocol: func() LLMClient {
+			return &stepClient{running: client.running, maxRunning: client.maxRunning, mu: client.mu, seen: client.seen}
+		},
+	}
+	parallelism := conf.ToolsConfInfo.TaskParallelism
+	conf.ToolsConfInfo.TaskParallelism = 2
+	defer func() {
+		clientFactories = factories
+		conf.ToolsConfInfo.TaskParallelism = parallelism
+	}()
+
+	ctx := context.WithValue(context.Background(), "user_info", &db.User{LLMConfigRaw: new(param.LLMConfig)})
+	d := &LLMTaskReq{Ctx: ctx, Cs: new(param.ContextState)}
+	plans := &TaskInfo{Plan: []*Task{
+		{Id: "1", Name: "search", Description: "a"},
+		{Id: "2", Name: "search", Description: "b"},
+		{Id: "3", Name: "search", Description: "c"},
+		{Id: "4", Name: "search", Description: "d", DependsOn: []string{"1", "3"}},
+	}}
+	assert.NoError(t, plans.Validate())
+
+	results, err := d.runPlan(d.Ctx, plans, nil)
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	for i, desc := range []string{"a", "b", "c", "d"} {
+		assert.Equal(t, desc, results[i].TaskDesc)
+		assert.True(t, strings.HasPrefix(results[i].TaskResult, "result of "+desc))
+	}
+	assert.Equal(t, int32(2), maxRunning)
+
+	// task only sees results of tasks it depends on
+	seen := client.seen["d"]
+	assert.Equal(t, "a", seen[0])
+	assert.True(t, strings.HasPrefix(seen[1], "result of a"))
+	assert.Equal(t, "c", seen[2])
+	assert.True(t, strings.HasPrefix(seen[3], "result of c"))
+	assert.Equal(t, "d", seen[4])
+
+	plans = &TaskInfo{Plan: []*Task{
+		{Id: "1", Name: "search", Description: "fail"},
+		{Id: "2", Name: "search", Description: "e", DependsOn: []string{"1"}},
+	}}
+	_, err = d.runPlan(d.Ctx, plans, nil)
+	assert.EqualError(t, err, "step fail")
+	assert.NotContains(t, client.seen, "e")
+
+	// finished steps of resumed run are not executed again
+	plans.Plan[0].Description = "f"
+	results, err = d.runPlan(d.Ctx, plans, map[string]*TaskResult{
+		"1": {TaskDesc: "f", TaskResult: "saved result of f"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "saved result of f", results[0].TaskResult)
+	assert.NotContains(t, client.seen, "f")
+	assert.Equal(t, "saved result of f", client.seen["e"][1])
+}
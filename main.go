@@ -11,8 +11,10 @@ import (
 	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/http"
 	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/llm"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/metrics"
+	"github.com/yincongcyincong/MuseBot/provider"
 	"github.com/yincongcyincong/MuseBot/rag"
 	"github.com/yincongcyincong/MuseBot/register"
 	"github.com/yincongcyincong/MuseBot/robot"
@@ -21,9 +23,11 @@ import (
 func main() {
 	logger.InitLogger()
 	conf.InitConf()
+	provider.LoadCustomProviders()
 	i18n.InitI18n()
 	db.InitTable()
 	conf.InitTools()
+	llm.InitNativeTools()
 	rag.InitRag()
 	http.InitHTTP()
 	metrics.RegisterMetrics()
@@ -71,6 +71,22 @@ var (
 		},
 		[]string{"mcp_service", "mcp_func"},
 	)
+
+	RateLimitHitCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limit_hit_total",
+			Help: "Total number of requests rejected by rate limit, labeled by scope and command class or provider.",
+		},
+		[]string{"scope", "name"},
+	)
+
+	ProviderFallbackCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "provider_fallback_total",
+			Help: "Total number of switches to fallback provider, labeled by capability and providers.",
+		},
+		[]string{"capability", "from", "to"},
+	)
 )
 
 // RegisterMetrics 注册指标
@@ -83,4 +99,6 @@ func RegisterMetrics() {
 	prometheus.MustRegister(HTTPResponseCount)
 	prometheus.MustRegister(HTTPResponseDuration)
 	prometheus.MustRegister(MCPRequestDuration)
+	prometheus.MustRegister(ProviderFallbackCount)
+	prometheus.MustRegister(RateLimitHitCount)
 }
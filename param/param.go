@@ -1,10 +1,5 @@
 package param
 
-import (
-	"github.com/cohesion-org/deepseek-go"
-	"github.com/devinyf/dashscopego/qwen"
-)
-
 const (
 	DeepSeek     = "deepseek"
 	Ollama       = "ollama"
@@ -73,9 +68,34 @@ const (
 	VideoRecordType = 2
 	WEBRecordType   = 3
 	TalkRecordType  = 4
+	// InternalRecordType llm calls made by bot itself, like summary and rerank, only for accounting
+	InternalRecordType = 5
 
 	DefaultContextToken = 128000
 
+	DefaultThread = "default"
+
+	QuotaText  = "text"
+	QuotaImage = "image"
+	QuotaVideo = "video"
+	QuotaCost  = "cost"
+
+	QuotaDay   = "day"
+	QuotaWeek  = "week"
+	QuotaMonth = "month"
+
+	RoleSubjectUser  = "user"
+	RoleSubjectGroup = "group"
+
+	RoleResourceCommand  = "command"
+	RoleResourceMCP      = "mcp"
+	RoleResourceProvider = "provider"
+	RoleResourceModel    = "model"
+
+	McpActionAllow   = "allow"
+	McpActionDeny    = "deny"
+	McpActionApprove = "approve"
+
 	GeminiImageGenV2_5       = "gemini-2.5-flash-image"
 	GeminiImage3Pro          = "gemini-3-pro-image-preview"
 	Imagen3_0Generate002     = "imagen-3.0-generate-002"
@@ -122,6 +142,7 @@ const (
 	Slack      = "slack"
 	Telegram   = "telegram"
 	Wechat     = "wechat"
+	Web        = "web"
 
 	State      = "state"
 	Clear      = "clear"
@@ -148,146 +169,25 @@ const (
 	CronList   = "cron_list"
 	CronDel    = "cron_del"
 	CronClear  = "cron_clear"
+	Cron       = "cron" // created by smart mode and create_cron tool, not a command handler
+	NewThread  = "new"
+	Threads    = "threads"
+	SwitchTo   = "switch"
+	KB         = "kb"
+	Sources    = "sources"
+	Learn      = "learn"
+	TaskStatus = "task_status"
+	TaskCancel = "task_cancel"
+	TaskResume = "task_resume"
+	McpApprove = "mcp_approve"
+	McpReject  = "mcp_reject"
 )
 
-var (
-	DeepseekLocalModels = map[string]bool{
-		LLAVA:                         true,
-		deepseek.AzureDeepSeekR1:      true,
-		deepseek.OpenRouterDeepSeekR1: true,
-		deepseek.OpenRouterDeepSeekR1DistillLlama70B: true,
-		deepseek.OpenRouterDeepSeekR1DistillLlama8B:  true,
-		deepseek.OpenRouterDeepSeekR1DistillQwen14B:  true,
-		deepseek.OpenRouterDeepSeekR1DistillQwen1_5B: true,
-		deepseek.OpenRouterDeepSeekR1DistillQwen32B:  true,
-	}
-
-	GeminiModels = map[string]bool{
-		ModelGemini25Pro:   true,
-		ModelGemini25Flash: true,
-		ModelGemini30Flash: true,
-		ModelGemini30Pro:   true,
-	}
-
-	GeminiRecModels = map[string]bool{
-		ModelGemini25Pro:   true,
-		ModelGemini25Flash: true,
-	}
-
-	GeminiTTSModels = map[string]bool{
-		Gemini2_5FlashPreviewTTS: true,
-	}
-
-	VolImageModels = map[string]bool{
-		DoubaoSeed16VisionPro: true,
-	}
-
-	VolVideoModels = map[string]bool{
-		DoubaoSeedance1_0Pro: true,
-	}
-
-	VolRecModels = map[string]bool{
-		DoubaoSeed16VisionPro: true,
-	}
-
-	VolTTSModels = map[string]bool{
-		VolTTS: true,
-		VolIcl: true,
-	}
-
-	AliyunImageModels = map[string]bool{
-		QwenImagePlus: true,
-	}
-
-	AliyunVideoModels = map[string]bool{
-		Wan2_5T2VPreview: true,
-	}
-
-	AliyunRecModels = map[string]bool{
-		QwenVlMax:       true,
-		QwenAudioTurbo:  true,
-		QwenVlMaxLatest: true,
-		QVQMax:          true,
-		QVQMaxLatest:    true,
-	}
-
-	AliyunTTSModels = map[string]bool{
-		Qwen3TTSFlash: true,
-	}
-
-	//OpenAIImageModels = map[string]bool{
-	//	ModelImageGPT: true,
-	//}
-	//
-	//OpenAiRecModels = map[string]bool{
-	//	openai.Whisper1: true,
-	//	ChatGPT4_0:      true,
-	//}
-	//
-	GeminiVideoModels = map[string]bool{
-		GeminiVideoVeo3_1Preview:     true,
-		GeminiVideoVeo3_1FastPreview: true,
-		GeminiVideoFastVeo3:          true,
-		GeminiVideoVeo3:              true,
-		GeminiVideoVeo2:              true,
-	}
-
-	GeminiImageModels = map[string]bool{
-		Imagen4_0FastGenerate002: true,
-		GeminiImageGenV2_5:       true,
-		Imagen3_0Generate002:     true,
-		Imagen4_0Generate001:     true,
-		GeminiImage3Pro:          true,
-	}
-
-	DeepseekModels = map[string]bool{
-		deepseek.DeepSeekChat:     true,
-		deepseek.DeepSeekReasoner: true,
-		deepseek.DeepSeekCoder:    true,
-	}
-
-	VolModels = map[string]bool{
-		// doubao Seed 1.6
-		ModelDoubaoSeed16:         true,
-		ModelDoubaoSeed16Flash:    true,
-		ModelDoubaoSeed16Thinking: true,
-
-		// doubao 1.5 系列
-		ModelDoubao15ThinkingPro:     true,
-		ModelDoubao15ThinkingProM428: true,
-		ModelDoubao15ThinkingProM415: true,
-		ModelDoubao15VisionPro428:    true,
-		ModelDoubao15VisionPro328:    true,
-
-		// DeepSeek R1
-		ModelDeepSeekR1_528:    true,
-		ModelDeepSeekR1_120:    true,
-		ModelDeepSeekR1Qwen32b: true,
-		ModelDeepSeekR1Qwen7b:  true,
-
-		// doubao-1.5
-		ModelDoubao15VisionPro32k: true,
-		ModelDoubao15VisionLite:   true,
-	}
-
-	AliyunModel = map[string]bool{
-		qwen.QwenLong:           true,
-		qwen.QwenTurbo:          true,
-		qwen.QwenPlus:           true,
-		qwen.QwenMax:            true,
-		qwen.QwenMax1201:        true,
-		qwen.QwenMaxLongContext: true,
-
-		// multi-modal model.
-		qwen.QwenVLPlus:     true,
-		qwen.QwenVLMax:      true,
-		qwen.QwenAudioTurbo: true,
-
-		ModelDeepSeekR1_528: true,
-		ModelDeepSeekR1:     true,
-		ModelDeepseekV3:     true,
-	}
-)
+// Commands all commands handled by bot.
+var Commands = []string{State, Clear, Retry, Chat, Photo, EditPhoto, Video, Help, Task, Mcp, Mode, TxtType, TxtModel,
+	PhotoType, PhotoModel, VideoType, VideoModel, RecType, RecModel, TtsType, TtsModel, RecPhoto, CronList, CronDel,
+	CronClear, NewThread, Threads, SwitchTo, KB, Sources, Learn, TaskStatus, TaskCancel, TaskResume,
+	McpApprove, McpReject}
 
 type MsgInfo struct {
 	MsgId       string
@@ -329,10 +229,17 @@ type LLMConfig struct {
 }
 
 type ContextState struct {
-	Token     int
-	RecordID  int64
-	SkipCheck bool
-	UseRecord bool
+	Token           int
+	PromptToken     int
+	CompletionToken int
+	RecordID        int64
+	SkipCheck       bool
+	UseRecord       bool
+	SessionId       string
+	// Fallbacks providers failed before the one which answer
+	Fallbacks []string
+	// DocIds rag documents used by answer
+	DocIds []string
 }
 
 type MCPResp struct {
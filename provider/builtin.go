@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/devinyf/dashscopego/qwen"
+	"github.com/sashabaranov/go-openai"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func init() {
+	SetPreference(Image, param.Gemini, param.OpenAi, param.OpenRouter, param.Aliyun, param.AI302, param.Vol, param.ChatAnyWhere)
+	SetPreference(Video, param.Gemini, param.Aliyun, param.AI302, param.Vol)
+	SetPreference(Rec, param.Gemini, param.OpenAi, param.Aliyun, param.AI302, param.Vol)
+	SetPreference(TTS, param.Gemini, param.Aliyun, param.Vol, param.OpenAi)
+
+	Register(&Provider{
+		Name:         param.DeepSeek,
+		Capabilities: []Capability{Text, Tools, JSONObject},
+		Models: map[Capability][]string{
+			Text: {deepseek.DeepSeekChat, deepseek.DeepSeekReasoner, deepseek.DeepSeekCoder},
+		},
+		DefaultModels: map[Capability]string{
+			Text: deepseek.DeepSeekChat,
+		},
+		Protocol: OpenAIProtocol,
+		BaseURL:  "https://api.deepseek.com/v1",
+		Token:    func() string { return conf.BaseConfInfo.DeepseekToken },
+	})
+
+	Register(&Provider{
+		Name:         param.Gemini,
+		Capabilities: []Capability{Text, Image, Video, Rec, TTS, Tools, Vision, JSONSchema},
+		Models: map[Capability][]string{
+			Text: {param.ModelGemini25Pro, param.ModelGemini25Flash, param.ModelGemini30Flash, param.ModelGemini30Pro},
+			Image: {param.Imagen4_0FastGenerate002, param.GeminiImageGenV2_5, param.Imagen3_0Generate002,
+				param.Imagen4_0Generate001, param.GeminiImage3Pro},
+			Video: {param.GeminiVideoVeo3_1Preview, param.GeminiVideoVeo3_1FastPreview, param.GeminiVideoFastVeo3,
+				param.GeminiVideoVeo3, param.GeminiVideoVeo2},
+			Rec: {param.ModelGemini25Pro, param.ModelGemini25Flash},
+			TTS: {param.Gemini2_5FlashPreviewTTS},
+		},
+		DefaultModels: map[Capability]string{
+			Text:  param.ModelGemini25Flash,
+			Image: param.GeminiImageGenV2_5,
+			Video: param.GeminiVideoVeo3_1FastPreview,
+			Rec:   param.ModelGemini25Flash,
+			TTS:   param.Gemini2_5FlashPreviewTTS,
+		},
+		Protocol: OpenAIProtocol,
+		BaseURL:  "https://generativelanguage.googleapis.com/v1beta/openai",
+		Token:    func() string { return conf.BaseConfInfo.GeminiToken },
+	})
+
+	Register(&Provider{
+		Name:         param.OpenAi,
+		Capabilities: []Capability{Text, Image, Rec, TTS, Tools, Vision, JSONSchema},
+		DefaultModels: map[Capability]string{
+			Text: openai.GPT3Dot5Turbo0125,
+		},
+		ConfigModels: map[Capability]string{
+			Text: openai.GPT3Dot5Turbo,
+		},
+		Link:     "https://platform.openai.com/",
+		Protocol: OpenAIProtocol,
+		Token:    func() string { return conf.BaseConfInfo.OpenAIToken },
+	})
+
+	Register(&Provider{
+		Name:         param.Aliyun,
+		Capabilities: []Capability{Text, Image, Video, Rec, TTS, Tools, Vision, JSONObject},
+		Models: map[Capability][]string{
+			Text: {qwen.QwenLong, qwen.QwenTurbo, qwen.QwenPlus, qwen.QwenMax, qwen.QwenMax1201, qwen.QwenMaxLongContext,
+				// multi-modal model.
+				qwen.QwenVLPlus, qwen.QwenVLMax, qwen.QwenAudioTurbo,
+				param.ModelDeepSeekR1_528, param.ModelDeepSeekR1, param.ModelDeepseekV3},
+			Image: {param.QwenImagePlus},
+			Video: {param.Wan2_5T2VPreview},
+			Rec:   {param.QwenVlMax, param.QwenAudioTurbo, param.QwenVlMaxLatest, param.QVQMax, param.QVQMaxLatest},
+			TTS:   {param.Qwen3TTSFlash},
+		},
+		DefaultModels: map[Capability]string{
+			Text:  qwen.QwenMax,
+			Image: param.QwenImagePlus,
+			Video: param.Wan2_5T2VPreview,
+			Rec:   param.QwenVlMax,
+			TTS:   param.Qwen3TTSFlash,
+		},
+		Protocol: OpenAIProtocol,
+		BaseURL:  "https://dashscope.aliyuncs.com/compatible-mode/v1",
+		Token:    func() string { return conf.BaseConfInfo.AliyunToken },
+	})
+
+	Register(&Provider{
+		Name:         param.Vol,
+		Capabilities: []Capability{Text, Image, Video, Rec, TTS, Tools, Vision, JSONObject},
+		Models: map[Capability][]string{
+			Text: {
+				// doubao Seed 1.6
+				param.ModelDoubaoSeed16, param.ModelDoubaoSeed16Flash, param.ModelDoubaoSeed16Thinking,
+				// doubao 1.5 系列
+				param.ModelDoubao15ThinkingPro, param.ModelDoubao15ThinkingProM428, param.ModelDoubao15ThinkingProM415,
+				param.ModelDoubao15VisionPro428, param.ModelDoubao15VisionPro328,
+				// DeepSeek R1
+				param.ModelDeepSeekR1_528, param.ModelDeepSeekR1_120, param.ModelDeepSeekR1Qwen32b, param.ModelDeepSeekR1Qwen7b,
+				// doubao-1.5
+				param.ModelDoubao15VisionPro32k, param.ModelDoubao15VisionLite,
+			},
+			Image: {param.DoubaoSeed16VisionPro},
+			Video: {param.DoubaoSeedance1_0Pro},
+			Rec:   {param.DoubaoSeed16VisionPro},
+			TTS:   {param.VolTTS, param.VolIcl},
+		},
+		DefaultModels: map[Capability]string{
+			Text:  param.ModelDoubao15VisionPro328,
+			Image: param.DoubaoSeed16VisionPro,
+			Video: param.DoubaoSeedance1_0Pro,
+			Rec:   param.DoubaoSeed16VisionPro,
+			TTS:   param.VolTTS,
+		},
+		ConfigModels: map[Capability]string{
+			Text: param.ModelDeepSeekR1_528,
+		},
+		Protocol: OpenAIProtocol,
+		BaseURL:  "https://ark.cn-beijing.volces.com/api/v3",
+		Token:    func() string { return conf.BaseConfInfo.VolToken },
+		Enabled: func(c Capability) bool {
+			// tts use volcengine speech service, which has its own access key
+			if c == TTS {
+				return conf.BaseConfInfo.VolcAK != ""
+			}
+			return conf.BaseConfInfo.VolToken != ""
+		},
+	})
+
+	Register(&Provider{
+		Name:         param.ChatAnyWhere,
+		Capabilities: []Capability{Text, Image, Tools, Vision},
+		DefaultModels: map[Capability]string{
+			Text: openai.GPT3Dot5Turbo0125,
+		},
+		ConfigModels: map[Capability]string{
+			Text: openai.GPT3Dot5Turbo,
+		},
+		Protocol: OpenAIProtocol,
+		BaseURL:  "https://api.chatanywhere.tech/v1",
+		Token:    func() string { return conf.BaseConfInfo.ChatAnyWhereToken },
+	})
+
+	Register(&Provider{
+		Name:         param.AI302,
+		Capabilities: []Capability{Text, Image, Video, Rec, Tools, Vision},
+		DefaultModels: map[Capability]string{
+			Text: openai.GPT3Dot5Turbo,
+		},
+		ConfigModels: map[Capability]string{
+			Text: param.DeepseekDeepseekR1_0528,
+		},
+		Link:     "https://302.ai/",
+		Protocol: OpenAIProtocol,
+		BaseURL:  "https://api.302.ai/v1",
+		Token:    func() string { return conf.BaseConfInfo.AI302Token },
+	})
+
+	Register(&Provider{
+		Name:         param.OpenRouter,
+		Capabilities: []Capability{Text, Image, Tools, Vision, JSONSchema},
+		DefaultModels: map[Capability]string{
+			Text: param.DeepseekDeepseekR1_0528Free,
+		},
+		Link:     "https://openrouter.ai/",
+		Protocol: OpenAIProtocol,
+		BaseURL:  "https://openrouter.ai/api/v1",
+		Token:    func() string { return conf.BaseConfInfo.OpenRouterToken },
+	})
+
+	Register(&Provider{
+		Name:         param.Ollama,
+		Capabilities: []Capability{Text, Tools, Vision},
+		DefaultModels: map[Capability]string{
+			Text: param.ModelDeepSeekR1,
+		},
+		Link:     "https://ollama.com/",
+		Protocol: OllamaProtocol,
+		// ollama run locally, enable it by type
+		Enabled: func(c Capability) bool {
+			return conf.BaseConfInfo.Type == param.Ollama
+		},
+	})
+}
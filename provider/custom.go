@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+// LoadCustomProviders register endpoints of custom_providers conf, custom providers loaded before are replaced.
+func LoadCustomProviders() {
+	lock.Lock()
+	builtin := make([]*Provider, 0, len(providers))
+	for _, p := range providers {
+		if !p.Custom {
+			builtin = append(builtin, p)
+		}
+	}
+	providers = builtin
+	lock.Unlock()
+
+	for _, c := range conf.ProviderConfInfo.Providers {
+		if c.Name == "" || c.BaseURL == "" {
+			logger.Warn("custom provider need name and base_url", "name", c.Name)
+			continue
+		}
+		if p := Get(c.Name); p != nil {
+			if p.Custom {
+				logger.Warn("custom provider name is duplicated", "name", c.Name)
+			} else {
+				logger.Warn("custom provider name is used by builtin provider", "name", c.Name)
+			}
+			continue
+		}
+
+		Register(newCustomProvider(c))
+		logger.Info("register custom provider", "name", c.Name, "baseURL", c.BaseURL)
+	}
+}
+
+func newCustomProvider(c *conf.CustomProvider) *Provider {
+	capabilities := make([]Capability, 0, len(c.Capabilities))
+	for _, capability := range c.Capabilities {
+		capabilities = append(capabilities, Capability(capability))
+	}
+	if len(capabilities) == 0 {
+		capabilities = append(capabilities, Text)
+	}
+
+	defaultModel := c.DefaultModel
+	if defaultModel == "" && len(c.Models) > 0 {
+		defaultModel = c.Models[0]
+	}
+
+	// endpoint declare one model list, it is shared by all capabilities
+	models := make(map[Capability][]string, len(capabilities))
+	defaultModels := make(map[Capability]string, len(capabilities))
+	for _, capability := range capabilities {
+		models[capability] = c.Models
+		defaultModels[capability] = defaultModel
+	}
+
+	token := c.Token
+	return &Provider{
+		Name:          c.Name,
+		Capabilities:  capabilities,
+		Models:        models,
+		DefaultModels: defaultModels,
+		Protocol:      OpenAIProtocol,
+		BaseURL:       c.BaseURL,
+		Proxy:         c.Proxy,
+		Token:         func() string { return token },
+		// local endpoint such as vllm may not need token
+		Enabled: func(Capability) bool {
+			return true
+		},
+		Custom: true,
+	}
+}
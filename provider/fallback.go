@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"google.golang.org/genai"
+)
+
+// Candidate is one provider of fallback chain, empty Model means default model of provider.
+type Candidate struct {
+	Name  string
+	Model string
+}
+
+var retryableMsgRegex = regexp.MustCompile(`(?i)\b(429|500|502|503|504)\b|timeout|timed out|too many requests|rate limit|overloaded|unavailable|bad gateway|connection reset|connection refused`)
+
+// FallbackChain get available providers of capability in fallback order.
+func FallbackChain(c Capability) []Candidate {
+	raw := ""
+	switch c {
+	case Text:
+		raw = conf.ProviderConfInfo.TxtFallback
+	case Image:
+		raw = conf.ProviderConfInfo.ImgFallback
+	case Video:
+		raw = conf.ProviderConfInfo.VideoFallback
+	case Rec:
+		raw = conf.ProviderConfInfo.RecFallback
+	case TTS:
+		raw = conf.ProviderConfInfo.TTSFallback
+	}
+
+	res := make([]Candidate, 0)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		candidate := Candidate{Name: item}
+		if idx := strings.Index(item, ":"); idx > 0 {
+			candidate.Name, candidate.Model = item[:idx], item[idx+1:]
+		}
+
+		if p := Get(candidate.Name); p != nil && p.Available(c) {
+			res = append(res, candidate)
+		}
+	}
+
+	return res
+}
+
+// IsRetryable check whether error is temporary, such as timeout, 429 and 5xx, which is worth trying another provider.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 0 {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+
+	var genaiErr genai.APIError
+	if errors.As(err, &genaiErr) && genaiErr.Code != 0 {
+		return retryableStatus(genaiErr.Code)
+	}
+
+	return retryableMsgRegex.MatchString(err.Error())
+}
+
+func retryableStatus(code int) bool {
+	return code == 429 || code >= 500
+}
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+// Usage is what one llm call consume.
+type Usage struct {
+	PromptToken     int
+	CompletionToken int
+	Image           int
+	VideoSecond     int
+	TTSChar         int
+}
+
+// GetPrice get price of model, price of provider is used if model has no price, return nil if not configured.
+func GetPrice(name, model string) *conf.ModelPrice {
+	var res *conf.ModelPrice
+	for _, price := range conf.ProviderConfInfo.Prices {
+		if price.Provider != name {
+			continue
+		}
+		if price.Model == model {
+			return price
+		}
+		if price.Model == "" && res == nil {
+			res = price
+		}
+	}
+	return res
+}
+
+// Cost calculate cost of usage in currency of pricing conf.
+func Cost(name, model string, usage Usage) float64 {
+	price := GetPrice(name, model)
+	if price == nil {
+		return 0
+	}
+
+	return float64(usage.PromptToken)*price.Input/1e6 +
+		float64(usage.CompletionToken)*price.Output/1e6 +
+		float64(usage.Image)*price.Image +
+		float64(usage.VideoSecond)*price.VideoSecond +
+		float64(usage.TTSChar)*price.TTSChar/1e6
+}
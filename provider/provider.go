@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"slices"
+	"sync"
+	"unicode"
+)
+
+// Capability is one kind of job a provider can do.
+type Capability string
+
+const (
+	Text   Capability = "text"
+	Image  Capability = "image"
+	Video  Capability = "video"
+	Rec    Capability = "rec"
+	TTS    Capability = "tts"
+	Tools  Capability = "tools"
+	Vision Capability = "vision"
+
+	// JSONSchema provider accept response_format json_schema, JSONObject only accept json_object.
+	JSONSchema Capability = "json_schema"
+	JSONObject Capability = "json_object"
+)
+
+// Protocol decide which chat client talk with provider, client factories are registered by llm package.
+const (
+	OpenAIProtocol = "openai"
+	OllamaProtocol = "ollama"
+)
+
+// Provider describe one llm backend.
+type Provider struct {
+	Name         string
+	Capabilities []Capability
+
+	// Models selectable models of each capability, empty means any model name is accepted.
+	Models map[Capability][]string
+	// DefaultModels used when user never choose model or choose an unknown one.
+	DefaultModels map[Capability]string
+	// ConfigModels written into llm config of new user, DefaultModels is used if empty.
+	ConfigModels map[Capability]string
+	// Link where user can find model names when Models is empty.
+	Link string
+
+	Protocol string
+	BaseURL  string
+	// Proxy of provider, use llm_proxy if empty.
+	Proxy string
+	Token func() string
+	// Enabled check whether capability is configured, provider is enabled when token is not empty by default.
+	Enabled func(c Capability) bool
+	// CountTokens count token of text, EstimateTokens by default.
+	CountTokens func(text string) int
+
+	// Custom provider is loaded from custom_providers conf.
+	Custom bool
+}
+
+var (
+	providers []*Provider
+	lock      sync.RWMutex
+
+	// preferences provider names of capability in preferred order, providers not listed follow in register order.
+	preferences = map[Capability][]string{}
+)
+
+// SetPreference set preferred order of providers for capability, the first available one is used by default.
+func SetPreference(c Capability, names ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+	preferences[c] = names
+}
+
+// Register add provider, provider with the same name is replaced.
+func Register(p *Provider) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for i, old := range providers {
+		if old.Name == p.Name {
+			providers[i] = p
+			return
+		}
+	}
+	providers = append(providers, p)
+}
+
+// Get get provider by name, return nil if not exist.
+func Get(name string) *Provider {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	for _, p := range providers {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// All get providers in register order.
+func All() []*Provider {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return append([]*Provider{}, providers...)
+}
+
+// Available get name of providers which can do capability, in preferred order of capability.
+func Available(c Capability) []string {
+	lock.RLock()
+	names := preferences[c]
+	lock.RUnlock()
+
+	res := []string{}
+	for _, name := range names {
+		if p := Get(name); p != nil && p.Available(c) {
+			res = append(res, p.Name)
+		}
+	}
+	for _, p := range All() {
+		if !slices.Contains(names, p.Name) && p.Available(c) {
+			res = append(res, p.Name)
+		}
+	}
+	return res
+}
+
+func (p *Provider) Has(c Capability) bool {
+	for _, capability := range p.Capabilities {
+		if capability == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) Available(c Capability) bool {
+	if !p.Has(c) {
+		return false
+	}
+	if p.Enabled != nil {
+		return p.Enabled(c)
+	}
+	return p.GetToken() != ""
+}
+
+func (p *Provider) GetToken() string {
+	if p.Token == nil {
+		return ""
+	}
+	return p.Token()
+}
+
+// UsingModel get the model which is really used for capability.
+func (p *Provider) UsingModel(c Capability, model string) string {
+	models := p.Models[c]
+	if len(models) == 0 {
+		if model != "" {
+			return model
+		}
+		return p.DefaultModels[c]
+	}
+
+	for _, m := range models {
+		if m == model {
+			return model
+		}
+	}
+	return p.DefaultModels[c]
+}
+
+func (p *Provider) Tokens(text string) int {
+	if p.CountTokens != nil {
+		return p.CountTokens(text)
+	}
+	return EstimateTokens(text)
+}
+
+// EstimateTokens calculate token
+func EstimateTokens(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			count++
+		} else if unicode.IsSpace(r) {
+			continue
+		} else {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func TestRegister(t *testing.T) {
+	token := ""
+	Register(&Provider{
+		Name:         "gateway",
+		Capabilities: []Capability{Text, Tools},
+		Models: map[Capability][]string{
+			Text: {"gateway-large", "gateway-small"},
+		},
+		DefaultModels: map[Capability]string{
+			Text: "gateway-small",
+		},
+		Protocol:    OpenAIProtocol,
+		BaseURL:     "http://127.0.0.1:8080/v1",
+		Token:       func() string { return token },
+		CountTokens: func(text string) int { return len(text) },
+	})
+
+	p := Get("gateway")
+	assert.NotNil(t, p)
+	assert.NotContains(t, Available(Text), "gateway")
+
+	token = "secret"
+	assert.Contains(t, Available(Text), "gateway")
+	assert.NotContains(t, Available(Image), "gateway")
+
+	assert.Equal(t, "gateway-large", p.UsingModel(Text, "gateway-large"))
+	assert.Equal(t, "gateway-small", p.UsingModel(Text, "unknown"))
+	assert.Equal(t, 5, p.Tokens("hello"))
+}
+
+func TestUsingModel_AnyModel(t *testing.T) {
+	p := &Provider{
+		Name:          "free",
+		Capabilities:  []Capability{Text},
+		DefaultModels: map[Capability]string{Text: "default"},
+	}
+
+	assert.Equal(t, "my-model", p.UsingModel(Text, "my-model"))
+	assert.Equal(t, "default", p.UsingModel(Text, ""))
+	assert.Equal(t, 2, p.Tokens("你好"))
+}
+
+func TestLoadCustomProviders(t *testing.T) {
+	conf.ProviderConfInfo.CustomProviders = `[
+		{"name":"vllm","base_url":"http://127.0.0.1:8000/v1","default_model":"qwen2.5","capabilities":["text","tools"]},
+		{"name":"lmstudio","base_url":"http://127.0.0.1:1234/v1","models":["llama-3","phi-4"],"proxy":"http://127.0.0.1:7890"},
+		{"name":"openai","base_url":"http://127.0.0.1:9000/v1"},
+		{"name":"sdxl","base_url":"http://127.0.0.1:7860/v1","models":["sdxl-turbo"],"capabilities":["image"]},
+		{"name":"vllm","base_url":"http://127.0.0.1:8001/v1"}
+	]`
+	assert.NoError(t, conf.ParseCustomProviders())
+	LoadCustomProviders()
+
+	assert.Contains(t, Available(Text), "vllm")
+	assert.Contains(t, Available(Text), "lmstudio")
+	assert.True(t, Get("vllm").Has(Tools))
+	assert.Equal(t, "qwen2.5", Get("vllm").UsingModel(Text, ""))
+	assert.Equal(t, "llama-3", Get("lmstudio").UsingModel(Text, "gpt-4o"))
+	assert.Equal(t, "http://127.0.0.1:7890", Get("lmstudio").Proxy)
+	assert.Contains(t, Available(Image), "sdxl")
+	assert.Equal(t, "sdxl-turbo", Get("sdxl").UsingModel(Image, ""))
+	assert.Equal(t, []string{"sdxl-turbo"}, Get("sdxl").Models[Image])
+	// first one of duplicated custom providers is kept
+	assert.Equal(t, "http://127.0.0.1:8000/v1", Get("vllm").BaseURL)
+	// builtin provider can't be replaced
+	assert.False(t, Get(param.OpenAi).Custom)
+
+	conf.ProviderConfInfo.CustomProviders = `[{"name":"vllm","base_url":"http://127.0.0.1:8000/v1"}]`
+	assert.NoError(t, conf.ParseCustomProviders())
+	LoadCustomProviders()
+	assert.NotNil(t, Get("vllm"))
+	assert.Nil(t, Get("lmstudio"))
+}
+
+func TestFallbackChain(t *testing.T) {
+	conf.BaseConfInfo.GeminiToken = "gemini"
+	conf.ProviderConfInfo.ImgFallback = " gemini:" + param.GeminiImageGenV2_5 + ", openai ,unknown"
+	defer func() {
+		conf.BaseConfInfo.GeminiToken = ""
+		conf.ProviderConfInfo.ImgFallback = ""
+	}()
+
+	// openai has no token, unknown is not registered
+	assert.Equal(t, []Candidate{{Name: param.Gemini, Model: param.GeminiImageGenV2_5}}, FallbackChain(Image))
+	assert.Empty(t, FallbackChain(Text))
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(context.Canceled))
+	assert.True(t, IsRetryable(fmt.Errorf("send fail: %w", context.DeadlineExceeded)))
+	assert.True(t, IsRetryable(&openai.APIError{HTTPStatusCode: 429}))
+	assert.True(t, IsRetryable(&openai.RequestError{HTTPStatusCode: 502}))
+	assert.False(t, IsRetryable(&openai.APIError{HTTPStatusCode: 401, Message: "invalid token"}))
+	assert.True(t, IsRetryable(errors.New("error, status code: 503, status: 503 Service Unavailable")))
+	assert.False(t, IsRetryable(errors.New("model not found")))
+}
+
+func TestCost(t *testing.T) {
+	conf.ProviderConfInfo.Pricing = `[
+		{"provider":"openai","input":1,"output":2,"image":0.04,"tts_char":15},
+		{"provider":"openai","model":"gpt-4o","input":2.5,"output":10},
+		{"provider":"gemini","model":"veo","video_second":0.5}
+	]`
+	assert.NoError(t, conf.ParsePricing())
+	defer func() {
+		conf.ProviderConfInfo.Pricing = ""
+		conf.ProviderConfInfo.Prices = nil
+	}()
+
+	assert.InDelta(t, 2.5+5, Cost(param.OpenAi, "gpt-4o", Usage{PromptToken: 1e6, CompletionToken: 5e5}), 1e-9)
+	// price of provider is used for unknown model
+	assert.InDelta(t, 1+0.04, Cost(param.OpenAi, "gpt-3.5", Usage{PromptToken: 1e6, Image: 1}), 1e-9)
+	assert.InDelta(t, 0.015, Cost(param.OpenAi, "tts-1", Usage{TTSChar: 1000}), 1e-9)
+	assert.InDelta(t, 2.5, Cost(param.Gemini, "veo", Usage{VideoSecond: 5}), 1e-9)
+	assert.Zero(t, Cost(param.Gemini, "gemini-2.5-flash", Usage{PromptToken: 1e6}))
+	assert.Zero(t, Cost(param.DeepSeek, "", Usage{PromptToken: 1e6}))
+}
+
+func TestBuiltinPreference(t *testing.T) {
+	conf.BaseConfInfo.OpenAIToken = "openai"
+	conf.BaseConfInfo.AliyunToken = "aliyun"
+	defer func() {
+		conf.BaseConfInfo.OpenAIToken = ""
+		conf.BaseConfInfo.AliyunToken = ""
+	}()
+
+	assert.Equal(t, []string{param.Aliyun, param.OpenAi}, Available(TTS))
+	assert.Equal(t, []string{param.OpenAi, param.Aliyun}, Available(Image))
+	// custom providers loaded by other tests follow builtin providers
+	assert.Equal(t, []string{param.OpenAi, param.Aliyun}, Available(Text)[:2])
+
+	assert.Equal(t, param.ModelDoubao15VisionPro328, Get(param.Vol).UsingModel(Text, ""))
+	assert.Equal(t, openai.GPT3Dot5Turbo, Get(param.AI302).UsingModel(Text, ""))
+	assert.Equal(t, openai.GPT3Dot5Turbo0125, Get(param.ChatAnyWhere).UsingModel(Text, ""))
+	assert.Equal(t, openai.GPT3Dot5Turbo, Get(param.OpenAi).ConfigModels[Text])
+}
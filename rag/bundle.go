@@ -0,0 +1,345 @@
+package rag
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	bundleVersion = 1
+
+	bundleManifestName = "manifest.json"
+	bundleChunksName   = "chunks.jsonl"
+	bundleFilesDir     = "files/"
+)
+
+// bundleManifest describe knowledge base in bundle, vectors are reused if embedder is the same.
+type bundleManifest struct {
+	Version        int            `json:"version"`
+	KB             string         `json:"kb"`
+	EmbeddingType  string         `json:"embedding_type"`
+	EmbeddingModel string         `json:"embedding_model"`
+	Dimension      int            `json:"dimension"`
+	CreateTime     int64          `json:"create_time"`
+	Files          []*db.RagFiles `json:"files"`
+}
+
+// ImportResult result of importing bundle.
+type ImportResult struct {
+	Files      int  `json:"files"`
+	Chunks     int  `json:"chunks"`
+	Reembedded bool `json:"reembedded"`
+}
+
+// ExportKnowledgeBase write indexed files of knowledge base into zip bundle, which contains
+// manifest.json, chunks.jsonl with embeddings and source files under files/.
+func ExportKnowledgeBase(ctx context.Context, kb *conf.KnowledgeBase, w io.Writer) error {
+	files, err := db.GetRagFilesByKB(kb.Name, db.RagFileStatusDone)
+	if err != nil {
+		return err
+	}
+
+	manifest := &bundleManifest{
+		Version:        bundleVersion,
+		KB:             kb.Name,
+		EmbeddingType:  conf.RagConfInfo.EmbeddingType,
+		EmbeddingModel: conf.RagConfInfo.EmbeddingModel,
+		CreateTime:     time.Now().Unix(),
+		Files:          files,
+	}
+
+	zw := zip.NewWriter(w)
+	chunkWriter, err := zw.Create(bundleChunksName)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(chunkWriter)
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		chunks, err := db.GetRagChunksByFileName(kb.Name, f.FileName)
+		if err != nil {
+			return err
+		}
+		for _, c := range chunks {
+			if manifest.Dimension == 0 {
+				manifest.Dimension = len(c.Embedding)
+			}
+			err = encoder.Encode(c)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		err = addBundleFile(zw, kb, f.FileName)
+		if err != nil {
+			return err
+		}
+	}
+
+	manifestWriter, err := zw.Create(bundleManifestName)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(manifestWriter).Encode(manifest)
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// addBundleFile copy source file into bundle, file removed from disk is skipped.
+func addBundleFile(zw *zip.Writer, kb *conf.KnowledgeBase, fileName string) error {
+	path, err := KnowledgeFilePath(kb, fileName)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		logger.Warn("knowledge file not exist", "kb", kb.Name, "file", fileName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fw, err := zw.Create(bundleFilesDir + fileName)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
+
+// ImportKnowledgeBase restore bundle into knowledge base, exported vectors are saved directly when
+// embedder type, model and dimension are the same, otherwise chunks are embedded again.
+func ImportKnowledgeBase(ctx context.Context, kb *conf.KnowledgeBase, r io.ReaderAt, size int64) (*ImportResult, error) {
+	if kb == nil || kb.Store == nil {
+		return nil, errors.New("vector store of knowledge base is not ready")
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := new(bundleManifest)
+	err = readBundleJson(zr, bundleManifestName, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Version != bundleVersion {
+		return nil, fmt.Errorf("bundle version %d is not supported", manifest.Version)
+	}
+
+	chunks, err := readBundleChunks(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	sameEmbedder, err := isSameEmbedder(ctx, manifest)
+	if err != nil {
+		return nil, err
+	}
+	res := &ImportResult{Reembedded: !sameEmbedder}
+	for _, f := range manifest.Files {
+		n, err := importBundleFile(ctx, kb, zr, f, chunks[f.FileName], sameEmbedder, manifest.Dimension)
+		if err != nil {
+			return res, fmt.Errorf("import %s fail: %w", f.FileName, err)
+		}
+		res.Files++
+		res.Chunks += n
+	}
+
+	return res, nil
+}
+
+// isSameEmbedder check embedder of bundle, dimension is checked by embedding a short text.
+func isSameEmbedder(ctx context.Context, manifest *bundleManifest) (bool, error) {
+	if manifest.EmbeddingType != conf.RagConfInfo.EmbeddingType || manifest.EmbeddingModel != conf.RagConfInfo.EmbeddingModel ||
+		manifest.Dimension == 0 {
+		return false, nil
+	}
+	if conf.RagConfInfo.Embedder == nil {
+		return false, errors.New("embedder is not ready")
+	}
+
+	vec, err := conf.RagConfInfo.Embedder.EmbedQuery(ctx, "dimension")
+	if err != nil {
+		return false, err
+	}
+	return len(vec) == manifest.Dimension, nil
+}
+
+// importBundleFile replace data of one file with data in bundle, returns chunk count.
+func importBundleFile(ctx context.Context, kb *conf.KnowledgeBase, zr *zip.Reader, f *db.RagFiles,
+	chunks []*db.RagChunk, sameEmbedder bool, dimension int) (int, error) {
+	path, err := KnowledgeFilePath(kb, f.FileName)
+	if err != nil {
+		return 0, err
+	}
+
+	// watcher would enqueue the file again without bundle vectors
+	learningFiles.Store(path, true)
+	defer learningFiles.Delete(path)
+
+	err = DeleteFileData(ctx, kb, f.FileName)
+	if err != nil {
+		return 0, err
+	}
+
+	fileMd5 := f.FileMd5
+	zf, err := openBundleFile(zr, bundleFilesDir+f.FileName)
+	if err == nil {
+		err = writeBundleFile(zf, path)
+		if err != nil {
+			return 0, err
+		}
+		// bundle has no chunks of file, it is indexed from the file body
+		if len(chunks) == 0 {
+			return 0, enqueueFile(ctx, kb, path, true, f.Source, nil)
+		}
+		fileMd5, err = utils.FileToMd5(path)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	id, err := db.InsertRagFileWithStatus(kb.Name, f.FileName, fileMd5, db.RagFileStatusIndexing, f.Source)
+	if err != nil {
+		return 0, err
+	}
+	ragFile := &db.RagFiles{ID: id, Status: db.RagFileStatusDone}
+
+	docs := make([]schema.Document, len(chunks))
+	var vectors [][]float32
+	if sameEmbedder {
+		vectors = make([][]float32, len(chunks))
+	}
+	for i, c := range chunks {
+		metadata := c.Metadata
+		if metadata == nil {
+			metadata = make(map[string]any)
+		}
+		metadata["kb"] = kb.Name
+		metadata["doc_id"] = DocId(kb.Name, f.FileName, c.ChunkIndex)
+		docs[i] = schema.Document{PageContent: c.Content, Metadata: metadata}
+
+		if vectors != nil {
+			if len(c.Embedding) != dimension {
+				vectors = nil
+				continue
+			}
+			vectors[i] = c.Embedding
+		}
+	}
+
+	if len(docs) > 0 {
+		ids, err := insertVectorDb(ctx, kb, docs, vectors)
+		if err != nil {
+			ragFile.Status = db.RagFileStatusFailed
+			ragFile.ErrorMsg = err.Error()
+			if _, updateErr := db.UpdateRagFileStatus(ragFile); updateErr != nil {
+				logger.Error("update rag file status fail", "file", f.FileName, "err", updateErr)
+			}
+			return 0, err
+		}
+		ragFile.VectorId = strings.Join(ids, ",")
+	}
+
+	_, err = db.UpdateRagFileStatus(ragFile)
+	return len(docs), err
+}
+
+func openBundleFile(zr *zip.Reader, name string) (*zip.File, error) {
+	for _, zf := range zr.File {
+		if zf.Name == name {
+			return zf, nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found in bundle", name)
+}
+
+func readBundleJson(zr *zip.Reader, name string, v any) error {
+	zf, err := openBundleFile(zr, name)
+	if err != nil {
+		return err
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(v)
+}
+
+// readBundleChunks read chunks in bundle, grouped by file name.
+func readBundleChunks(zr *zip.Reader) (map[string][]*db.RagChunk, error) {
+	zf, err := openBundleFile(zr, bundleChunksName)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	chunks := make(map[string][]*db.RagChunk)
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		c := new(db.RagChunk)
+		err = json.Unmarshal(scanner.Bytes(), c)
+		if err != nil {
+			return nil, err
+		}
+		chunks[c.FileName] = append(chunks[c.FileName], c)
+	}
+	return chunks, scanner.Err()
+}
+
+func writeBundleFile(zf *zip.File, path string) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, rc)
+	return err
+}
@@ -0,0 +1,81 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+)
+
+func TestExportImportKnowledgeBase(t *testing.T) {
+	src := newTestKB(t, "bundle_src")
+	ctx := context.Background()
+
+	_, err := LearnFile(ctx, src, "learn/faq.md", []byte("# FAQ\n\nerror E3003 means the token expired"), "https://example.com/faq.md")
+	assert.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, ExportKnowledgeBase(ctx, src, buf))
+
+	dst := newTestKB(t, "bundle_dst")
+	res, err := ImportKnowledgeBase(ctx, dst, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Files)
+	assert.Equal(t, 1, res.Chunks)
+	assert.False(t, res.Reembedded)
+
+	content, err := os.ReadFile(filepath.Join(dst.Path, "learn", "faq.md"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "E3003")
+
+	files, err := db.GetRagFileByFileName(dst.Name, "learn/faq.md")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, db.RagFileStatusDone, files[0].Status)
+	assert.Equal(t, "https://example.com/faq.md", files[0].Source)
+
+	docs, err := dst.Store.SimilaritySearch(ctx, "E3003 token expired", 1)
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, dst.Name, docs[0].Metadata["kb"])
+	assert.Len(t, keywordSearch(dst, "E3003", 3), 1)
+
+	// other embedder embeds chunks again
+	embeddingType := conf.RagConfInfo.EmbeddingType
+	conf.RagConfInfo.EmbeddingType = "other"
+	defer func() {
+		conf.RagConfInfo.EmbeddingType = embeddingType
+	}()
+	res, err = ImportKnowledgeBase(ctx, dst, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.True(t, res.Reembedded)
+	assert.Len(t, keywordSearch(dst, "E3003", 3), 1)
+}
+
+func TestImportBundleFileWithoutChunks(t *testing.T) {
+	src := newTestKB(t, "bundle_empty_src")
+	ctx := context.Background()
+
+	// file is exported before its chunks are saved
+	assert.NoError(t, os.WriteFile(filepath.Join(src.Path, "empty.txt"), []byte("error E5005 means the bundle is empty"), 0644))
+	_, err := db.InsertRagFile(src.Name, "empty.txt", "empty123")
+	assert.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, ExportKnowledgeBase(ctx, src, buf))
+
+	dst := newTestKB(t, "bundle_empty_dst")
+	res, err := ImportKnowledgeBase(ctx, dst, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Files)
+	assert.Equal(t, 0, res.Chunks)
+
+	ragFile := waitRagFileStatus(t, dst.Name, "empty.txt")
+	assert.Equal(t, db.RagFileStatusDone, ragFile.Status)
+	assert.Len(t, keywordSearch(dst, "E5005", 3), 1)
+}
@@ -0,0 +1,128 @@
+package rag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	sourceSnippetLen = 500
+)
+
+// DocId identify a chunk of knowledge file, chunk index starts from 1.
+func DocId(kb, fileName string, chunkIndex int) string {
+	return fmt.Sprintf("%s/%s#%d", kb, fileName, chunkIndex)
+}
+
+// DocIds get ids of documents, duplicated ids are removed.
+func DocIds(docs []schema.Document) []string {
+	ids := make([]string, 0, len(docs))
+	seen := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		id := getDocId(doc)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// FormatCitations numbered file name and chunk of documents, empty if no document.
+func FormatCitations(docs []schema.Document) string {
+	if len(docs) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.GetMessage("rag_citation_header", nil))
+	for i, doc := range uniqueDocs(docs) {
+		sb.WriteString(i18n.GetMessage("rag_citation_item", map[string]interface{}{
+			"index":     i + 1,
+			"file_name": metaString(doc.Metadata, "file_name"),
+			"chunk":     metaInt(doc.Metadata, "chunk_index"),
+		}))
+	}
+	return sb.String()
+}
+
+// FormatSources numbered documents with snippet of content.
+func FormatSources(docs []schema.Document) string {
+	var sb strings.Builder
+	sb.WriteString(i18n.GetMessage("rag_sources_header", nil))
+	for i, doc := range uniqueDocs(docs) {
+		snippet := []rune(strings.TrimSpace(doc.PageContent))
+		if len(snippet) > sourceSnippetLen {
+			snippet = append(snippet[:sourceSnippetLen], []rune("...")...)
+		}
+		sb.WriteString(i18n.GetMessage("rag_sources_item", map[string]interface{}{
+			"index":     i + 1,
+			"file_name": metaString(doc.Metadata, "file_name"),
+			"chunk":     metaInt(doc.Metadata, "chunk_index"),
+			"content":   string(snippet),
+		}))
+	}
+	return sb.String()
+}
+
+func uniqueDocs(docs []schema.Document) []schema.Document {
+	res := make([]schema.Document, 0, len(docs))
+	seen := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		id := getDocId(doc)
+		if id != "" && seen[id] {
+			continue
+		}
+		seen[id] = true
+		res = append(res, doc)
+	}
+	return res
+}
+
+// getDocId get doc id from metadata, documents indexed before doc_id exists are built from file name.
+func getDocId(doc schema.Document) string {
+	if id := metaString(doc.Metadata, "doc_id"); id != "" {
+		return id
+	}
+
+	fileName := metaString(doc.Metadata, "file_name")
+	if fileName == "" {
+		return ""
+	}
+	kb := metaString(doc.Metadata, "kb")
+	if kb == "" {
+		kb = conf.DefaultKnowledgeBase
+	}
+	return DocId(kb, fileName, metaInt(doc.Metadata, "chunk_index"))
+}
+
+func metaString(metadata map[string]any, key string) string {
+	if v, ok := metadata[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// metaInt number in metadata may be float64 or string after stored in vector db.
+func metaInt(metadata map[string]any, key string) int {
+	switch v := metadata[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case float32:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,30 @@
+package rag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestDocIds(t *testing.T) {
+	docs := []schema.Document{
+		{Metadata: map[string]any{"doc_id": "default/a.txt#1", "file_name": "a.txt", "chunk_index": 1}},
+		{Metadata: map[string]any{"doc_id": "default/a.txt#1", "file_name": "a.txt", "chunk_index": 1}},
+		{Metadata: map[string]any{"kb": "support", "file_name": "faq/b.md", "chunk_index": float64(3)}},
+		{Metadata: map[string]any{"file_name": "c.json", "chunk_index": "2"}},
+		{Metadata: map[string]any{}},
+	}
+
+	assert.Equal(t, []string{"default/a.txt#1", "support/faq/b.md#3", "default/c.json#2"}, DocIds(docs))
+	assert.Len(t, uniqueDocs(docs), 4)
+	assert.Empty(t, FormatCitations(nil))
+}
+
+func TestMetaInt(t *testing.T) {
+	assert.Equal(t, 2, metaInt(map[string]any{"n": 2}, "n"))
+	assert.Equal(t, 2, metaInt(map[string]any{"n": int64(2)}, "n"))
+	assert.Equal(t, 2, metaInt(map[string]any{"n": float64(2)}, "n"))
+	assert.Equal(t, 2, metaInt(map[string]any{"n": "2"}, "n"))
+	assert.Equal(t, 0, metaInt(map[string]any{}, "n"))
+}
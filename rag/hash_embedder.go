@@ -0,0 +1,95 @@
+package rag
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/yincongcyincong/langchaingo/embeddings"
+)
+
+const (
+	hashEmbeddingDim = 256
+)
+
+// HashEmbedder is a deterministic embedder that hashes tokens into a fixed size vector.
+// it needs no network, so it is useful for tests and air-gapped hosts.
+type HashEmbedder struct {
+	dim int
+}
+
+var _ embeddings.Embedder = (*HashEmbedder)(nil)
+
+func NewHashEmbedder(dim int) *HashEmbedder {
+	if dim <= 0 {
+		dim = hashEmbeddingDim
+	}
+	return &HashEmbedder{dim: dim}
+}
+
+func (h *HashEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	res := make([][]float32, len(texts))
+	for i, text := range texts {
+		res[i] = h.embed(text)
+	}
+	return res, nil
+}
+
+func (h *HashEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return h.embed(text), nil
+}
+
+func (h *HashEmbedder) embed(text string) []float32 {
+	vec := make([]float32, h.dim)
+	for _, token := range hashTokens(text) {
+		hs := fnv.New64a()
+		hs.Write([]byte(token))
+		sum := hs.Sum64()
+		// use the highest bit as sign, so unrelated tokens cancel out instead of piling up
+		if sum>>63 == 1 {
+			vec[sum%uint64(h.dim)] -= 1
+		} else {
+			vec[sum%uint64(h.dim)] += 1
+		}
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vec
+	}
+	norm = math.Sqrt(norm)
+	for i := range vec {
+		vec[i] = float32(float64(vec[i]) / norm)
+	}
+	return vec
+}
+
+// hashTokens split text into lower case words, every han character is a single token.
+func hashTokens(text string) []string {
+	tokens := make([]string, 0)
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			tokens = append(tokens, sb.String())
+			sb.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,28 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	embedder := NewHashEmbedder(64)
+	ctx := context.Background()
+
+	docs, err := embedder.EmbedDocuments(ctx, []string{"golang is fun", "the weather is sunny"})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+	assert.Len(t, docs[0], 64)
+
+	query, err := embedder.EmbedQuery(ctx, "golang is fun")
+	assert.NoError(t, err)
+	assert.Equal(t, docs[0], query)
+
+	assert.Greater(t, cosineSimilarity(query, docs[0]), cosineSimilarity(query, docs[1]))
+
+	empty, err := embedder.EmbedQuery(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, empty, 64)
+}
@@ -0,0 +1,209 @@
+package rag
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// keywordIndexes knowledge base name -> *keywordIndex
+var keywordIndexes = sync.Map{}
+
+// keywordIndex is a bm25 index over chunks of one knowledge base.
+type keywordIndex struct {
+	count int64
+	maxId int64
+
+	docs   []schema.Document
+	terms  []map[string]int
+	lens   []int
+	df     map[string]int
+	avgLen float64
+}
+
+func newKeywordIndex(docs []schema.Document) *keywordIndex {
+	k := &keywordIndex{
+		docs:  docs,
+		terms: make([]map[string]int, len(docs)),
+		lens:  make([]int, len(docs)),
+		df:    make(map[string]int),
+	}
+
+	total := 0
+	for i, doc := range docs {
+		tokens := keywordTokens(doc.PageContent)
+		tf := make(map[string]int, len(tokens))
+		for _, token := range tokens {
+			tf[token]++
+		}
+		for token := range tf {
+			k.df[token]++
+		}
+		k.terms[i] = tf
+		k.lens[i] = len(tokens)
+		total += len(tokens)
+	}
+	if len(docs) > 0 {
+		k.avgLen = float64(total) / float64(len(docs))
+	}
+	return k
+}
+
+// search top num documents by bm25 score, documents without any query term are skipped.
+// Score of result is bm25 score divided by score of a document of average length containing every
+// query term once, it is capped at 1 like vector similarity, documents below minScore are skipped.
+func (k *keywordIndex) search(query string, num int, minScore float64) []schema.Document {
+	queryTokens := uniqueTokens(keywordTokens(query))
+	if len(queryTokens) == 0 || len(k.docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(k.docs))
+	idfs := make(map[string]float64, len(queryTokens))
+	maxScore := 0.0
+	for _, token := range queryTokens {
+		df := float64(k.df[token])
+		idfs[token] = math.Log(1 + (n-df+0.5)/(df+0.5))
+		maxScore += idfs[token]
+	}
+
+	res := make([]schema.Document, 0)
+	for i, tf := range k.terms {
+		score := 0.0
+		for _, token := range queryTokens {
+			f := float64(tf[token])
+			if f == 0 {
+				continue
+			}
+			score += idfs[token] * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(k.lens[i])/k.avgLen))
+		}
+		score = math.Min(score/maxScore, 1)
+		if score <= 0 || score < minScore {
+			continue
+		}
+		doc := k.docs[i]
+		doc.Score = float32(score)
+		res = append(res, doc)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	if len(res) > num {
+		res = res[:num]
+	}
+	return res
+}
+
+// keywordSearch bm25 search chunks of knowledge base, index is rebuilt when chunks changed.
+// rag_min_score is applied to normalized bm25 score as to vector similarity.
+func keywordSearch(kb *conf.KnowledgeBase, query string, num int) []schema.Document {
+	if kb == nil {
+		return nil
+	}
+
+	count, maxId, err := db.GetRagChunksVersion(kb.Name)
+	if err != nil {
+		logger.Error("get rag chunks version fail", "err", err)
+		return nil
+	}
+
+	index, ok := keywordIndexes.Load(kb.Name)
+	if !ok || index.(*keywordIndex).count != count || index.(*keywordIndex).maxId != maxId {
+		chunks, err := db.GetRagChunksByKB(kb.Name)
+		if err != nil {
+			logger.Error("get rag chunks fail", "err", err)
+			return nil
+		}
+		docs := make([]schema.Document, 0, len(chunks))
+		for _, chunk := range chunks {
+			docs = append(docs, schema.Document{
+				PageContent: chunk.Content,
+				Metadata:    chunk.Metadata,
+			})
+		}
+		newIndex := newKeywordIndex(docs)
+		newIndex.count, newIndex.maxId = count, maxId
+		keywordIndexes.Store(kb.Name, newIndex)
+		index = newIndex
+	}
+
+	return index.(*keywordIndex).search(query, num, conf.RagConfInfo.MinScore)
+}
+
+// saveChunks keep chunks of documents for keyword search, vectors are kept for export.
+func saveChunks(kb *conf.KnowledgeBase, docs []schema.Document, vectors [][]float32) {
+	chunks := make([]*db.RagChunk, 0, len(docs))
+	for i, doc := range docs {
+		chunks = append(chunks, &db.RagChunk{
+			KB:         kb.Name,
+			FileName:   metaString(doc.Metadata, "file_name"),
+			ChunkIndex: metaInt(doc.Metadata, "chunk_index"),
+			Content:    doc.PageContent,
+			Metadata:   doc.Metadata,
+			Embedding:  vectors[i],
+		})
+	}
+
+	err := db.InsertRagChunks(chunks)
+	if err != nil {
+		logger.Error("save rag chunks fail", "err", err)
+	}
+}
+
+// keywordTokens words of text plus identifiers such as error codes and config keys,
+// "ERR_CONN_42" gives err, conn, 42 and err_conn_42.
+func keywordTokens(text string) []string {
+	tokens := hashTokens(text)
+
+	var sb strings.Builder
+	compound := false
+	flush := func() {
+		identifier := strings.Trim(sb.String(), "_-.")
+		if compound && strings.ContainsAny(identifier, "_-.") {
+			tokens = append(tokens, identifier)
+		}
+		sb.Reset()
+		compound = false
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		case r == '_' || r == '-' || r == '.':
+			sb.WriteRune(r)
+			compound = true
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+func uniqueTokens(tokens []string) []string {
+	res := make([]string, 0, len(tokens))
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		res = append(res, token)
+	}
+	return res
+}
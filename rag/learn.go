@@ -0,0 +1,172 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	// learnDir sub dir of knowledge base for fetched urls
+	learnDir = "learn"
+
+	learnMaxSize = 20 << 20
+)
+
+var (
+	learnNameReg = regexp.MustCompile(`[^a-zA-Z0-9_\-.]+`)
+
+	learnContentTypeExt = map[string]string{
+		"text/html":             ".html",
+		"application/xhtml+xml": ".html",
+		"application/pdf":       ".pdf",
+		"text/markdown":         ".md",
+		"text/x-markdown":       ".md",
+		"application/json":      ".json",
+		"text/csv":              ".csv",
+		"application/yaml":      ".yaml",
+		"application/x-yaml":    ".yaml",
+		"text/yaml":             ".yaml",
+		"text/plain":            ".txt",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	}
+)
+
+// LearnFile save content under the knowledge base dir and index it at once, returns chunk count.
+func LearnFile(ctx context.Context, kb *conf.KnowledgeBase, fileName string, content []byte, source string) (int, error) {
+	if kb == nil || kb.Store == nil {
+		return 0, errors.New("vector store of knowledge base is not ready")
+	}
+	if getDocHandler(fileName) == nil {
+		return 0, fmt.Errorf("file type is not supported: %s", fileName)
+	}
+
+	path, err := KnowledgeFilePath(kb, fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	// watcher would enqueue the file again without source
+	learningFiles.Store(path, true)
+	defer learningFiles.Delete(path)
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return 0, err
+	}
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	done := make(chan *ingestResult, 1)
+	err = enqueueFile(ctx, kb, path, true, source, done)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case res := <-done:
+		return res.chunks, res.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// LearnURL fetch url and learn it as a file under learn dir, returns file name and chunk count.
+func LearnURL(ctx context.Context, kb *conf.KnowledgeBase, rawURL string) (string, int, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", 0, fmt.Errorf("invalid url: %s", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := utils.GetPublicClient().Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("fetch url fail, status: %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, learnMaxSize+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if len(content) > learnMaxSize {
+		return "", 0, fmt.Errorf("file is larger than %d bytes", learnMaxSize)
+	}
+
+	fileName := learnFileName(u, learnFileExt(u, resp.Header.Get("Content-Type")))
+	chunks, err := LearnFile(ctx, kb, fileName, content, u.String())
+	return fileName, chunks, err
+}
+
+// learnFileExt get extension by content type, extension of url path is used if content type is unknown.
+func learnFileExt(u *url.URL, contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		if ext, ok := learnContentTypeExt[mediaType]; ok {
+			return ext
+		}
+	}
+
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext != "" && getDocHandler("a"+ext) != nil {
+		return ext
+	}
+	return ".txt"
+}
+
+// learnFileName build file name from host and path of url, such as learn/example.com_docs_intro.html.
+func learnFileName(u *url.URL, ext string) string {
+	p := strings.TrimSuffix(u.Path, path.Ext(u.Path))
+	name := learnNameReg.ReplaceAllString(u.Host+"_"+strings.Trim(p, "/"), "_")
+	name = strings.Trim(name, "_.")
+	if len(name) > 128 {
+		name = name[:128]
+	}
+	if name == "" {
+		name = "page"
+	}
+	return learnDir + "/" + name + ext
+}
+
+// LearnUploadName build file name under learn dir for uploaded file, files of uploader are kept in its own dir,
+// so uploading file with the same name only replaces file of the same uploader.
+func LearnUploadName(name, uploader string) string {
+	ext := strings.ToLower(path.Ext(name))
+	base := cleanLearnName(strings.TrimSuffix(filepath.Base(name), path.Ext(name)), "file")
+	if uploader == "" {
+		return learnDir + "/" + base + ext
+	}
+	return learnDir + "/" + cleanLearnName(uploader, "user") + "/" + base + ext
+}
+
+func cleanLearnName(name, defaultName string) string {
+	name = strings.Trim(learnNameReg.ReplaceAllString(name, "_"), "_.")
+	if name == "" {
+		return defaultName
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package rag
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func TestLearnFileName(t *testing.T) {
+	u, _ := url.Parse("https://example.com/docs/intro.html?a=1")
+	assert.Equal(t, ".html", learnFileExt(u, "text/html; charset=utf-8"))
+	assert.Equal(t, ".pdf", learnFileExt(u, "application/pdf"))
+	assert.Equal(t, ".html", learnFileExt(u, "application/octet-stream"))
+	assert.Equal(t, "learn/example.com_docs_intro.html", learnFileName(u, ".html"))
+
+	u, _ = url.Parse("https://example.com/")
+	assert.Equal(t, ".txt", learnFileExt(u, ""))
+	assert.Equal(t, "learn/example.com.txt", learnFileName(u, ".txt"))
+}
+
+func TestLearnFile(t *testing.T) {
+	kb := newTestKB(t, "learn_test")
+	ctx := context.Background()
+
+	chunks, err := LearnFile(ctx, kb, "learn/manual.md", []byte("# Manual\n\nerror E2002 means the fan is broken"), "https://example.com/manual.md")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, chunks)
+
+	files, err := db.GetRagFileByFileName(kb.Name, "learn/manual.md")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, db.RagFileStatusDone, files[0].Status)
+	assert.Equal(t, "https://example.com/manual.md", files[0].Source)
+
+	_, err = LearnFile(ctx, kb, "learn/manual.exe", []byte("x"), "")
+	assert.Error(t, err)
+	_, err = LearnFile(ctx, kb, "../manual.md", []byte("x"), "")
+	assert.Error(t, err)
+}
+
+func TestLearnURLPrivateAddress(t *testing.T) {
+	kb := newTestKB(t, "learn_private_test")
+	_, _, err := LearnURL(context.Background(), kb, "http://127.0.0.1:1/admin")
+	assert.ErrorIs(t, err, utils.ErrNotPublicAddress)
+}
+
+func TestLearnUploadName(t *testing.T) {
+	assert.Equal(t, "learn/user_guide_v2.pdf", LearnUploadName("user guide v2.PDF", ""))
+	assert.Equal(t, "learn/passwd.txt", LearnUploadName("../../etc/passwd.txt", ""))
+	assert.Equal(t, "learn/file.md", LearnUploadName(".md", ""))
+	assert.Equal(t, "learn/telegram_1/guide.md", LearnUploadName("guide.md", "telegram:1"))
+	assert.NotEqual(t, LearnUploadName("guide.md", "telegram:1"), LearnUploadName("guide.md", "telegram:2"))
+	assert.Equal(t, "learn/user/guide.md", LearnUploadName("guide.md", "../"))
+}
@@ -0,0 +1,207 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/langchaingo/documentloaders"
+	"github.com/yincongcyincong/langchaingo/schema"
+	"github.com/yincongcyincong/langchaingo/textsplitter"
+)
+
+var (
+	// jsonSeparators split indented json by top level keys and array items first.
+	jsonSeparators = []string{"\n  \"", "\n  {", "\n  [", "\n\n", "\n", " ", ""}
+	// yamlSeparators split yaml by documents and top level list items first.
+	yamlSeparators = []string{"\n---", "\n- ", "\n\n", "\n", " ", ""}
+
+	// codeSeparators split source code by top level declarations first.
+	codeSeparators = map[string][]string{
+		".go": {"\nfunc ", "\ntype ", "\nvar ", "\nconst ", "\n\n", "\n", " ", ""},
+		".py": {"\nclass ", "\ndef ", "\n    def ", "\n\n", "\n", " ", ""},
+		".js": {"\nfunction ", "\nclass ", "\nexport ", "\nconst ", "\n\n", "\n", " ", ""},
+		".ts": {"\nfunction ", "\nclass ", "\nexport ", "\ninterface ", "\nconst ", "\n\n", "\n", " ", ""},
+	}
+)
+
+func defaultSplitter() textsplitter.TextSplitter {
+	return textsplitter.NewRecursiveCharacter(
+		textsplitter.WithChunkSize(conf.RagConfInfo.ChunkSize),
+		textsplitter.WithChunkOverlap(conf.RagConfInfo.ChunkOverlap),
+		textsplitter.WithSeparators(conf.DefaultSpliter),
+	)
+}
+
+// markdownSplitter splits by headings and keeps the heading hierarchy in every chunk.
+func markdownSplitter() textsplitter.TextSplitter {
+	return textsplitter.NewMarkdownTextSplitter(
+		textsplitter.WithChunkSize(conf.RagConfInfo.ChunkSize),
+		textsplitter.WithChunkOverlap(conf.RagConfInfo.ChunkOverlap),
+		textsplitter.WithHeadingHierarchy(true),
+		textsplitter.WithCodeBlocks(true),
+	)
+}
+
+func structSplitter(separators []string) textsplitter.TextSplitter {
+	return textsplitter.NewRecursiveCharacter(
+		textsplitter.WithChunkSize(conf.RagConfInfo.ChunkSize),
+		textsplitter.WithChunkOverlap(conf.RagConfInfo.ChunkOverlap),
+		textsplitter.WithSeparators(separators),
+		textsplitter.WithKeepSeparator(true),
+	)
+}
+
+func codeSplitter(ext string) textsplitter.TextSplitter {
+	separators, ok := codeSeparators[ext]
+	if !ok {
+		separators = conf.DefaultSpliter
+	}
+	return structSplitter(separators)
+}
+
+// Docx loads the text of a docx file.
+type Docx struct {
+	r    io.ReaderAt
+	size int64
+}
+
+var _ documentloaders.Loader = Docx{}
+
+func NewDocx(r io.ReaderAt, size int64) Docx {
+	return Docx{
+		r:    r,
+		size: size,
+	}
+}
+
+// Load reads word/document.xml and returns a single document, one line per paragraph.
+func (l Docx) Load(_ context.Context) ([]schema.Document, error) {
+	zr, err := zip.NewReader(l.r, l.size)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		content, err := parseDocxXML(rc)
+		if err != nil {
+			return nil, err
+		}
+
+		return []schema.Document{
+			{
+				PageContent: content,
+				Metadata:    map[string]any{},
+			},
+		}, nil
+	}
+
+	return nil, errors.New("word/document.xml not found in docx")
+}
+
+func (l Docx) LoadAndSplit(ctx context.Context, splitter textsplitter.TextSplitter) ([]schema.Document, error) {
+	docs, err := l.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return textsplitter.SplitDocuments(splitter, docs)
+}
+
+func parseDocxXML(r io.Reader) (string, error) {
+	var sb strings.Builder
+	decoder := xml.NewDecoder(r)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// JSON loads a json file, it is indented first so the splitter can find its structure.
+type JSON struct {
+	r io.Reader
+}
+
+var _ documentloaders.Loader = JSON{}
+
+func NewJSON(r io.Reader) JSON {
+	return JSON{
+		r: r,
+	}
+}
+
+func (l JSON) Load(_ context.Context) ([]schema.Document, error) {
+	data, err := io.ReadAll(l.r)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err = json.Indent(buf, data, "", "  "); err != nil {
+		buf.Reset()
+		buf.Write(data)
+	}
+
+	return []schema.Document{
+		{
+			PageContent: buf.String(),
+			Metadata:    map[string]any{},
+		},
+	}, nil
+}
+
+func (l JSON) LoadAndSplit(ctx context.Context, splitter textsplitter.TextSplitter) ([]schema.Document, error) {
+	docs, err := l.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return textsplitter.SplitDocuments(splitter, docs)
+}
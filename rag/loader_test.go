@@ -0,0 +1,97 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+func TestDocxLoader(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("word/document.xml")
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:t xml:space="preserve"> world</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Second</w:t><w:tab/><w:t>line</w:t></w:r></w:p>` +
+		`</w:body></w:document>`))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	docs, err := NewDocx(bytes.NewReader(buf.Bytes()), int64(buf.Len())).Load(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "Hello world\nSecond\tline", docs[0].PageContent)
+}
+
+func TestJSONLoader(t *testing.T) {
+	docs, err := NewJSON(strings.NewReader(`{"a":1,"b":{"c":2}}`)).Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": 2\n  }\n}", docs[0].PageContent)
+
+	docs, err = NewJSON(strings.NewReader(`not json`)).Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "not json", docs[0].PageContent)
+}
+
+func TestCodeSplitter(t *testing.T) {
+	conf.RagConfInfo.ChunkSize = 40
+	conf.RagConfInfo.ChunkOverlap = 0
+
+	code := "package main\n\nfunc a() {\n\treturn\n}\n\nfunc b() {\n\treturn\n}\n"
+	chunks, err := codeSplitter(".go").SplitText(code)
+	assert.NoError(t, err)
+	for _, chunk := range chunks {
+		assert.False(t, strings.Contains(chunk, "func a()") && strings.Contains(chunk, "func b()"))
+	}
+	assert.True(t, strings.HasPrefix(chunks[len(chunks)-1], "func b()"))
+}
+
+func TestKnowledgeFiles(t *testing.T) {
+	dir := t.TempDir()
+	conf.RagConfInfo.KnowledgePath = dir
+	conf.RagConfInfo.KnowledgeBases = `[{"name":"support","path":"` + filepath.ToSlash(filepath.Join(dir, "support")) + `"}]`
+	assert.NoError(t, conf.ParseKnowledgeBases())
+	kb := conf.RagConfInfo.GetKnowledgeBase("")
+	support := conf.RagConfInfo.GetKnowledgeBase("support")
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "docs", "api"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "support"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "api", "b.md"), []byte("b"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "support", "c.txt"), []byte("c"), 0644))
+
+	// files of support are not part of default knowledge base
+	paths, err := walkKnowledgeFiles(kb, dir)
+	assert.NoError(t, err)
+	assert.Len(t, paths, 2)
+
+	names := []string{KnowledgeFileName(kb, paths[0]), KnowledgeFileName(kb, paths[1])}
+	assert.ElementsMatch(t, []string{"a.txt", "docs/api/b.md"}, names)
+
+	paths, err = walkKnowledgeFiles(support, support.Path)
+	assert.NoError(t, err)
+	assert.Len(t, paths, 1)
+	assert.Equal(t, "c.txt", KnowledgeFileName(support, paths[0]))
+
+	assert.Equal(t, support, findKnowledgeBase(filepath.Join(dir, "support", "c.txt")))
+	assert.Equal(t, kb, findKnowledgeBase(filepath.Join(dir, "docs", "api", "b.md")))
+	assert.Nil(t, findKnowledgeBase(filepath.Join(os.TempDir(), "other.txt")))
+
+	path, err := KnowledgeFilePath(kb, "docs/api/b.md")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "docs", "api", "b.md"), path)
+
+	_, err = KnowledgeFilePath(kb, "../outside.txt")
+	assert.Error(t, err)
+
+	assert.Equal(t, "MuseBot", weaviateClassName(kb))
+	assert.Equal(t, "MuseBot_support", weaviateClassName(support))
+}
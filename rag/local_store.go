@@ -0,0 +1,244 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/langchaingo/embeddings"
+	"github.com/yincongcyincong/langchaingo/schema"
+	"github.com/yincongcyincong/langchaingo/vectorstores"
+)
+
+var ErrLocalStoreEmbedder = errors.New("local store: embedder is required")
+
+// LocalStore is a vector store persisted in bot database, vectors of space are kept in memory
+// and searched by brute-force cosine similarity, it is enough for small knowledge base.
+type LocalStore struct {
+	embedder embeddings.Embedder
+	space    string
+
+	mu      sync.RWMutex
+	vectors []*db.Vector
+}
+
+var _ vectorstores.VectorStore = (*LocalStore)(nil)
+
+// NewLocalStore load vectors of space from database.
+func NewLocalStore(embedder embeddings.Embedder, space string) (*LocalStore, error) {
+	if embedder == nil {
+		return nil, ErrLocalStoreEmbedder
+	}
+
+	vectors, err := db.GetVectorsBySpace(space)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{
+		embedder: embedder,
+		space:    space,
+		vectors:  vectors,
+	}, nil
+}
+
+// AddDocuments embed documents and save them, it returns ids of vectors.
+func (s *LocalStore) AddDocuments(ctx context.Context, docs []schema.Document, options ...vectorstores.Option) ([]string, error) {
+	opts := s.getOptions(options...)
+
+	texts := make([]string, 0, len(docs))
+	addDocs := make([]schema.Document, 0, len(docs))
+	for _, doc := range docs {
+		if opts.Deduplicater != nil && opts.Deduplicater(ctx, doc) {
+			continue
+		}
+		texts = append(texts, doc.PageContent)
+		addDocs = append(addDocs, doc)
+	}
+	if len(texts) == 0 {
+		return []string{}, nil
+	}
+
+	vectors, err := opts.Embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, errors.New("local store: number of vectors from embedder does not match number of documents")
+	}
+
+	space := s.getSpace(opts)
+	records := make([]*db.Vector, len(addDocs))
+	for i, doc := range addDocs {
+		records[i] = &db.Vector{
+			Space:     space,
+			Content:   doc.PageContent,
+			Metadata:  doc.Metadata,
+			Embedding: vectors[i],
+		}
+	}
+
+	err = db.InsertVectors(records)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = strconv.FormatInt(record.ID, 10)
+	}
+
+	if space == s.space {
+		s.mu.Lock()
+		s.vectors = append(s.vectors, records...)
+		s.mu.Unlock()
+	}
+
+	return ids, nil
+}
+
+// SimilaritySearch find the most similar documents of query, Filters of option is a map
+// which metadata of document must be equal to.
+func (s *LocalStore) SimilaritySearch(ctx context.Context, query string, numDocuments int, options ...vectorstores.Option) ([]schema.Document, error) {
+	opts := s.getOptions(options...)
+
+	queryVector, err := opts.Embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var vectors []*db.Vector
+	if space := s.getSpace(opts); space != s.space {
+		vectors, err = db.GetVectorsBySpace(space)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		s.mu.RLock()
+		vectors = append([]*db.Vector(nil), s.vectors...)
+		s.mu.RUnlock()
+	}
+
+	filters, _ := opts.Filters.(map[string]any)
+	docs := make([]schema.Document, 0)
+	for _, v := range vectors {
+		if !matchFilters(v.Metadata, filters) {
+			continue
+		}
+
+		score := cosineSimilarity(queryVector, v.Embedding)
+		if score < opts.ScoreThreshold {
+			continue
+		}
+		docs = append(docs, schema.Document{
+			PageContent: v.Content,
+			Metadata:    v.Metadata,
+			Score:       score,
+		})
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].Score > docs[j].Score
+	})
+	if numDocuments > 0 && len(docs) > numDocuments {
+		docs = docs[:numDocuments]
+	}
+
+	return docs, nil
+}
+
+// Delete remove vectors by ids which are joined by comma, it is the format saved in rag_files.
+func (s *LocalStore) Delete(ctx context.Context, vectorIds string) error {
+	deleted := make(map[int64]bool)
+	ids := make([]int64, 0)
+	for _, idStr := range strings.Split(vectorIds, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		deleted[id] = true
+	}
+
+	err := db.DeleteVectorsByIds(ids)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vectors := s.vectors[:0]
+	for _, v := range s.vectors {
+		if !deleted[v.ID] {
+			vectors = append(vectors, v)
+		}
+	}
+	s.vectors = vectors
+	return nil
+}
+
+// DeleteByFileName delete vectors whose file_name metadata is fileName.
+func (s *LocalStore) DeleteByFileName(ctx context.Context, fileName string) error {
+	s.mu.RLock()
+	ids := make([]string, 0)
+	for _, v := range s.vectors {
+		if name, _ := v.Metadata["file_name"].(string); name == fileName {
+			ids = append(ids, strconv.FormatInt(v.ID, 10))
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.Delete(ctx, strings.Join(ids, ","))
+}
+
+func (s *LocalStore) getOptions(options ...vectorstores.Option) vectorstores.Options {
+	opts := vectorstores.Options{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.Embedder == nil {
+		opts.Embedder = s.embedder
+	}
+	return opts
+}
+
+func (s *LocalStore) getSpace(opts vectorstores.Options) string {
+	if opts.NameSpace != "" {
+		return opts.NameSpace
+	}
+	return s.space
+}
+
+func matchFilters(metadata map[string]any, filters map[string]any) bool {
+	for k, v := range filters {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,66 @@
+package rag
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yincongcyincong/langchaingo/embeddings"
+)
+
+type presetVectorsKey struct{}
+
+// PresetEmbedder returns vectors put into context by withPresetVectors and embeds other texts by the wrapped embedder.
+// vector stores call their own embedder, so vectors computed once can be saved into any store without embedding again.
+type PresetEmbedder struct {
+	embedder embeddings.Embedder
+}
+
+var _ embeddings.Embedder = (*PresetEmbedder)(nil)
+
+func NewPresetEmbedder(embedder embeddings.Embedder) *PresetEmbedder {
+	return &PresetEmbedder{embedder: embedder}
+}
+
+func (p *PresetEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	preset, _ := ctx.Value(presetVectorsKey{}).(map[string][]float32)
+
+	res := make([][]float32, len(texts))
+	missTexts := make([]string, 0)
+	missIdx := make([]int, 0)
+	for i, text := range texts {
+		if vec, ok := preset[text]; ok {
+			res[i] = vec
+			continue
+		}
+		missTexts = append(missTexts, text)
+		missIdx = append(missIdx, i)
+	}
+	if len(missTexts) == 0 {
+		return res, nil
+	}
+
+	vectors, err := p.embedder.EmbedDocuments(ctx, missTexts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(missTexts) {
+		return nil, errors.New("number of vectors from embedder does not match number of texts")
+	}
+	for i, idx := range missIdx {
+		res[idx] = vectors[i]
+	}
+	return res, nil
+}
+
+func (p *PresetEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.embedder.EmbedQuery(ctx, text)
+}
+
+// withPresetVectors put vectors of texts into context for PresetEmbedder.
+func withPresetVectors(ctx context.Context, texts []string, vectors [][]float32) context.Context {
+	preset := make(map[string][]float32, len(texts))
+	for i, text := range texts {
+		preset[text] = vectors[i]
+	}
+	return context.WithValue(ctx, presetVectorsKey{}, preset)
+}
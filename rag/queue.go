@@ -0,0 +1,313 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	ingestQueueSize     = 1024
+	ingestRetryInterval = 5 * time.Second
+)
+
+// ingestJob index one knowledge file, id is the pending record in rag_files.
+type ingestJob struct {
+	kb     *conf.KnowledgeBase
+	path   string
+	id     int64
+	source string
+
+	// done receives result if it is not nil
+	done chan *ingestResult
+}
+
+type ingestResult struct {
+	chunks int
+	err    error
+}
+
+var (
+	ingestQueue = make(chan *ingestJob, ingestQueueSize)
+	ingestOnce  sync.Once
+
+	// learningFiles path -> true, files written by learn are enqueued by learn instead of watcher
+	learningFiles = sync.Map{}
+)
+
+// EnqueueFile add knowledge file into ingestion queue with a pending record, data of the old version is deleted.
+// files with the same md5 are skipped unless force is set.
+func EnqueueFile(ctx context.Context, kb *conf.KnowledgeBase, path string, force bool) error {
+	return enqueueFile(ctx, kb, path, force, "", nil)
+}
+
+// enqueueFile source of the old version is kept if source is empty.
+func enqueueFile(ctx context.Context, kb *conf.KnowledgeBase, path string, force bool, source string, done chan *ingestResult) error {
+	if getDocHandler(path) == nil {
+		return errors.New("file type is not supported")
+	}
+	if kb.Store == nil {
+		return errors.New("vector store of knowledge base is not ready")
+	}
+
+	fileName := KnowledgeFileName(kb, path)
+	fileMd5, err := utils.FileToMd5(path)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		fileInfos, err := db.GetRagFileByFileMd5(kb.Name, fileMd5)
+		if err != nil {
+			return err
+		}
+		if len(fileInfos) > 0 {
+			logger.Info("file exist", "path", path, "status", fileInfos[0].Status)
+			return nil
+		}
+	}
+
+	if source == "" {
+		oldFiles, err := db.GetRagFileByFileName(kb.Name, fileName)
+		if err != nil {
+			return err
+		}
+		if len(oldFiles) > 0 {
+			source = oldFiles[0].Source
+		}
+	}
+
+	err = DeleteFileData(ctx, kb, fileName)
+	if err != nil {
+		return err
+	}
+
+	id, err := db.InsertRagFileWithStatus(kb.Name, fileName, fileMd5, db.RagFileStatusPending, source)
+	if err != nil {
+		return err
+	}
+
+	ingestOnce.Do(func() {
+		go ingestWorker()
+	})
+	select {
+	case ingestQueue <- &ingestJob{
+		kb:     kb,
+		path:   path,
+		id:     id,
+		source: source,
+		done:   done,
+	}:
+	case <-ctx.Done():
+		// pending record is dropped, the file is enqueued again by next scan
+		if err := db.DeleteRagFileByFileName(kb.Name, fileName); err != nil {
+			logger.Error("delete pending rag file fail", "file", fileName, "err", err)
+		}
+		return ctx.Err()
+	}
+	logger.Info("enqueue knowledge file", "kb", kb.Name, "file", fileName)
+	return nil
+}
+
+// Reindex enqueue file of knowledge base again, all files of it if fileName is empty.
+func Reindex(ctx context.Context, kb *conf.KnowledgeBase, fileName string) (int, error) {
+	if fileName != "" {
+		path, err := KnowledgeFilePath(kb, fileName)
+		if err != nil {
+			return 0, err
+		}
+		return 1, EnqueueFile(ctx, kb, path, true)
+	}
+
+	paths, err := walkKnowledgeFiles(kb, kb.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	num := 0
+	for _, path := range paths {
+		if getDocHandler(path) == nil {
+			continue
+		}
+		err = EnqueueFile(ctx, kb, path, true)
+		if err != nil {
+			return num, err
+		}
+		num++
+	}
+	return num, nil
+}
+
+// resetInterruptedFiles files left pending or indexing by last process are enqueued again,
+// chunks they already wrote are deleted first because no record tracks them.
+func resetInterruptedFiles(ctx context.Context, kb *conf.KnowledgeBase) {
+	ragFiles, err := db.GetRagFilesByStatus(db.RagFileStatusPending, db.RagFileStatusIndexing)
+	if err != nil {
+		logger.Error("get interrupted rag files fail", "err", err)
+		return
+	}
+
+	for _, ragFile := range ragFiles {
+		if ragFile.KB != kb.Name {
+			continue
+		}
+		err = deleteFileVectors(ctx, kb, ragFile.FileName)
+		if err != nil {
+			logger.Error("delete interrupted rag file vectors fail", "file", ragFile.FileName, "err", err)
+			continue
+		}
+
+		path, err := KnowledgeFilePath(kb, ragFile.FileName)
+		if err == nil {
+			_, err = os.Stat(path)
+		}
+		if err != nil {
+			// file is gone, the record is dropped
+			if err := db.DeleteRagFileByFileName(kb.Name, ragFile.FileName); err != nil {
+				logger.Error("reset interrupted rag file fail", "file", ragFile.FileName, "err", err)
+			}
+			continue
+		}
+
+		err = enqueueFile(ctx, kb, path, true, ragFile.Source, nil)
+		if err != nil {
+			logger.Error("enqueue interrupted rag file fail", "file", ragFile.FileName, "err", err)
+		}
+	}
+}
+
+func ingestWorker() {
+	for job := range ingestQueue {
+		ingestFile(job)
+	}
+}
+
+// ingestFile index file of job, it is retried rag_retry_times with backoff and the last error is kept in rag_files.
+// panic of one job is recovered here, so the worker keeps running and waiter of job is always notified.
+func ingestFile(job *ingestJob) {
+	res := new(ingestResult)
+	var ragFile *db.RagFiles
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("ingest file panic", "path", job.path, "err", err, "stack", string(debug.Stack()))
+			res.err = fmt.Errorf("ingest file panic: %v", err)
+			if ragFile != nil {
+				ragFile.Status = db.RagFileStatusFailed
+				ragFile.ErrorMsg = res.err.Error()
+				if _, err := db.UpdateRagFileStatus(ragFile); err != nil {
+					logger.Error("update rag file status fail", "file", ragFile.FileName, "err", err)
+				}
+			}
+		}
+		if job.done != nil {
+			job.done <- res
+		}
+	}()
+
+	ragFile, err := db.GetRagFileById(job.id)
+	if err != nil {
+		logger.Error("get rag file fail", "id", job.id, "err", err)
+		res.err = err
+		return
+	}
+	// file is changed or deleted after enqueued
+	if ragFile == nil {
+		res.err = errors.New("file is changed or deleted")
+		return
+	}
+
+	var affected int64
+	for i := 0; ; i++ {
+		ragFile.Status = db.RagFileStatusIndexing
+		ragFile.RetryTimes = i
+		affected, err = db.UpdateRagFileStatus(ragFile)
+		if err != nil {
+			logger.Error("update rag file status fail", "file", ragFile.FileName, "err", err)
+		} else if affected == 0 {
+			res.err = errors.New("file is changed or deleted")
+			return
+		}
+
+		ragFile.VectorId, res.chunks, err = indexFile(job.kb, job.path, job.source)
+		if err == nil {
+			ragFile.Status = db.RagFileStatusDone
+			ragFile.ErrorMsg = ""
+			affected, err = db.UpdateRagFileStatus(ragFile)
+			if err != nil {
+				logger.Error("update rag file status fail", "file", ragFile.FileName, "err", err)
+			} else if affected == 0 {
+				// record is deleted or replaced while indexing, vectors of this job are not tracked by any record
+				logger.Info("knowledge file is changed while indexing", "kb", job.kb.Name, "file", ragFile.FileName)
+				if cleanErr := deleteFileVectors(context.Background(), job.kb, ragFile.FileName); cleanErr != nil {
+					logger.Error("delete stale vectors fail", "file", ragFile.FileName, "err", cleanErr)
+				}
+				res.err = errors.New("file is changed or deleted")
+				return
+			}
+			logger.Info("index knowledge file", "kb", job.kb.Name, "file", ragFile.FileName, "chunks", res.chunks, "retry", i)
+			return
+		}
+
+		logger.Error("index knowledge file fail", "kb", job.kb.Name, "file", ragFile.FileName, "retry", i, "err", err)
+		// vectors inserted before failure have no recorded id, delete them by file name
+		if cleanErr := deleteFileVectors(context.Background(), job.kb, ragFile.FileName); cleanErr != nil {
+			logger.Error("delete partial vectors fail", "file", ragFile.FileName, "err", cleanErr)
+		}
+		if i >= conf.RagConfInfo.RetryTimes {
+			break
+		}
+		time.Sleep(time.Duration(i+1) * ingestRetryInterval)
+	}
+
+	res.err = err
+	ragFile.Status = db.RagFileStatusFailed
+	ragFile.ErrorMsg = err.Error()
+	_, err = db.UpdateRagFileStatus(ragFile)
+	if err != nil {
+		logger.Error("update rag file status fail", "file", ragFile.FileName, "err", err)
+	}
+}
+
+// indexFile load, split and save file into vector store, returns vector ids and chunk count.
+func indexFile(kb *conf.KnowledgeBase, path, source string) (string, int, error) {
+	handler := getDocHandler(path)
+	if handler == nil {
+		return "", 0, errors.New("file type is not supported")
+	}
+
+	timeout := conf.RagConfInfo.IngestTimeout
+	if timeout <= 0 {
+		timeout = 600
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	docs, err := handler(ctx, kb, path)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(docs) == 0 {
+		return "", 0, nil
+	}
+	if source != "" {
+		for _, doc := range docs {
+			doc.Metadata["source"] = source
+		}
+	}
+
+	ids, err := insertVectorDb(ctx, kb, docs, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	return strings.Join(ids, ","), len(docs), nil
+}
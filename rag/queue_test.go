@@ -0,0 +1,250 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/langchaingo/embeddings"
+	"gopkg.in/fsnotify.v1"
+)
+
+func TestMain(m *testing.M) {
+	conf.InitConf()
+	db.InitTable()
+
+	os.Exit(m.Run())
+}
+
+// newTestKB knowledge base with local store and hash embedder.
+func newTestKB(t *testing.T, name string) *conf.KnowledgeBase {
+	conf.RagConfInfo.VectorDBType = "local"
+	conf.RagConfInfo.Embedder = NewPresetEmbedder(NewHashEmbedder(64))
+	store, err := NewLocalStore(conf.RagConfInfo.Embedder, name)
+	assert.NoError(t, err)
+	return &conf.KnowledgeBase{Name: name, Path: t.TempDir(), Space: name, Store: store}
+}
+
+func waitRagFileStatus(t *testing.T, kb, fileName string) *db.RagFiles {
+	for i := 0; i < 100; i++ {
+		files, err := db.GetRagFileByFileName(kb, fileName)
+		assert.NoError(t, err)
+		if len(files) > 0 && files[0].Status != db.RagFileStatusPending && files[0].Status != db.RagFileStatusIndexing {
+			return files[0]
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("wait %s timeout", fileName)
+	return nil
+}
+
+func TestEnqueueFile(t *testing.T) {
+	kb := newTestKB(t, "queue_test")
+	ctx := context.Background()
+
+	path := filepath.Join(kb.Path, "faq.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("error E1001 means the disk is full"), 0644))
+	assert.NoError(t, EnqueueFile(ctx, kb, path, false))
+
+	ragFile := waitRagFileStatus(t, kb.Name, "faq.txt")
+	assert.Equal(t, db.RagFileStatusDone, ragFile.Status)
+	assert.NotEmpty(t, ragFile.VectorId)
+	assert.Len(t, keywordSearch(kb, "E1001", 3), 1)
+
+	// unchanged file is skipped
+	assert.NoError(t, EnqueueFile(ctx, kb, path, false))
+	files, err := db.GetRagFileByFileName(kb.Name, "faq.txt")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, ragFile.ID, files[0].ID)
+
+	num, err := Reindex(ctx, kb, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, num)
+	reindexed := waitRagFileStatus(t, kb.Name, "faq.txt")
+	assert.NotEqual(t, ragFile.ID, reindexed.ID)
+	assert.Equal(t, db.RagFileStatusDone, reindexed.Status)
+	assert.Len(t, keywordSearch(kb, "E1001", 3), 1)
+
+	_, err = Reindex(ctx, kb, "../faq.txt")
+	assert.Error(t, err)
+}
+
+type panicEmbedder struct{}
+
+func (e *panicEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	panic("embedder panic")
+}
+
+func (e *panicEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	panic("embedder panic")
+}
+
+func TestIngestFilePanic(t *testing.T) {
+	kb := newTestKB(t, "queue_panic_test")
+	embedder := conf.RagConfInfo.Embedder
+	conf.RagConfInfo.Embedder = new(panicEmbedder)
+	defer func() {
+		conf.RagConfInfo.Embedder = embedder
+	}()
+
+	path := filepath.Join(kb.Path, "panic.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+	id, err := db.InsertRagFileWithStatus(kb.Name, "panic.txt", "md5", db.RagFileStatusPending, "")
+	assert.NoError(t, err)
+
+	done := make(chan *ingestResult, 1)
+	ingestFile(&ingestJob{kb: kb, path: path, id: id, done: done})
+	res := <-done
+	assert.ErrorContains(t, res.err, "embedder panic")
+
+	ragFile, err := db.GetRagFileById(id)
+	assert.NoError(t, err)
+	assert.Equal(t, db.RagFileStatusFailed, ragFile.Status)
+}
+
+func TestDeleteFileVectors(t *testing.T) {
+	kb := newTestKB(t, "queue_delete_test")
+	ctx := context.Background()
+
+	path := filepath.Join(kb.Path, "partial.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("error E2002 means the network is down"), 0644))
+	_, _, err := indexFile(kb, path, "")
+	assert.NoError(t, err)
+	assert.Len(t, keywordSearch(kb, "E2002", 3), 1)
+
+	assert.NoError(t, deleteFileVectors(ctx, kb, "partial.txt"))
+	assert.Empty(t, keywordSearch(kb, "E2002", 3))
+	docs, err := kb.Store.SimilaritySearch(ctx, "E2002 network", 3)
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func TestEnqueueFileCanceled(t *testing.T) {
+	kb := newTestKB(t, "queue_cancel_test")
+	path := filepath.Join(kb.Path, "cancel.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+
+	// no worker reads the new queue, running worker keeps reading the old one
+	noWorker := false
+	ingestOnce.Do(func() { noWorker = true })
+	queue := ingestQueue
+	ingestQueue = make(chan *ingestJob)
+	defer func() {
+		ingestQueue = queue
+		if noWorker {
+			ingestOnce = sync.Once{}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, enqueueFile(ctx, kb, path, false, "", nil), context.Canceled)
+	files, err := db.GetRagFileByFileName(kb.Name, "cancel.txt")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestDirEvents(t *testing.T) {
+	kb := newTestKB(t, "queue_dir_test")
+	kbs := conf.RagConfInfo.KBs
+	conf.RagConfInfo.KBs = map[string]*conf.KnowledgeBase{kb.Name: kb}
+	defer func() {
+		conf.RagConfInfo.KBs = kbs
+	}()
+	ctx := context.Background()
+
+	dir := filepath.Join(kb.Path, "docs")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("file a"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("file b"), 0644))
+
+	// files of new dir are enqueued
+	InsertDoc(ctx, fsnotify.Event{Name: dir, Op: fsnotify.Create})
+	assert.Equal(t, db.RagFileStatusDone, waitRagFileStatus(t, kb.Name, "docs/a.txt").Status)
+	assert.Equal(t, db.RagFileStatusDone, waitRagFileStatus(t, kb.Name, "docs/sub/b.txt").Status)
+
+	// files of renamed dir are deleted and indexed under the new name
+	newDir := filepath.Join(kb.Path, "guide")
+	assert.NoError(t, os.Rename(dir, newDir))
+	DeleteDoc(ctx, fsnotify.Event{Name: dir, Op: fsnotify.Rename})
+	files, err := db.GetRagFilesByDir(kb.Name, "docs")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	InsertDoc(ctx, fsnotify.Event{Name: newDir, Op: fsnotify.Create})
+	assert.Equal(t, db.RagFileStatusDone, waitRagFileStatus(t, kb.Name, "guide/sub/b.txt").Status)
+
+	assert.NoError(t, os.RemoveAll(newDir))
+	DeleteDoc(ctx, fsnotify.Event{Name: newDir, Op: fsnotify.Remove})
+	files, err = db.GetRagFilesByDir(kb.Name, "guide")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+// replaceEmbedder delete record of file while the file is indexing.
+type replaceEmbedder struct {
+	embeddings.Embedder
+	kb, fileName string
+}
+
+func (e *replaceEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := db.DeleteRagFileByFileName(e.kb, e.fileName); err != nil {
+		return nil, err
+	}
+	return e.Embedder.EmbedDocuments(ctx, texts)
+}
+
+func TestIngestFileReplaced(t *testing.T) {
+	kb := newTestKB(t, "queue_replaced_test")
+	presetEmbedder := conf.RagConfInfo.Embedder
+	conf.RagConfInfo.Embedder = &replaceEmbedder{Embedder: presetEmbedder, kb: kb.Name, fileName: "stale.txt"}
+	defer func() {
+		conf.RagConfInfo.Embedder = presetEmbedder
+	}()
+
+	path := filepath.Join(kb.Path, "stale.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("error E3003 means the cache is stale"), 0644))
+	id, err := db.InsertRagFileWithStatus(kb.Name, "stale.txt", "md5", db.RagFileStatusPending, "")
+	assert.NoError(t, err)
+
+	// vectors written by the job of replaced record are dropped
+	done := make(chan *ingestResult, 1)
+	ingestFile(&ingestJob{kb: kb, path: path, id: id, done: done})
+	res := <-done
+	assert.Error(t, res.err)
+	assert.Empty(t, keywordSearch(kb, "E3003", 3))
+	docs, err := kb.Store.SimilaritySearch(context.Background(), "E3003 cache", 3)
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func TestResetInterruptedFiles(t *testing.T) {
+	kb := newTestKB(t, "queue_reset_test")
+	ctx := context.Background()
+
+	path := filepath.Join(kb.Path, "interrupted.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("error E4004 means the job is interrupted"), 0644))
+	_, _, err := indexFile(kb, path, "https://example.com/interrupted.txt")
+	assert.NoError(t, err)
+	_, err = db.InsertRagFileWithStatus(kb.Name, "interrupted.txt", "md5", db.RagFileStatusIndexing, "https://example.com/interrupted.txt")
+	assert.NoError(t, err)
+	_, err = db.InsertRagFileWithStatus(kb.Name, "gone.txt", "md5", db.RagFileStatusPending, "")
+	assert.NoError(t, err)
+
+	resetInterruptedFiles(ctx, kb)
+	ragFile := waitRagFileStatus(t, kb.Name, "interrupted.txt")
+	assert.Equal(t, db.RagFileStatusDone, ragFile.Status)
+	assert.Equal(t, "https://example.com/interrupted.txt", ragFile.Source)
+	assert.Len(t, keywordSearch(kb, "E4004", 3), 1)
+
+	files, err := db.GetRagFileByFileName(kb.Name, "gone.txt")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	db_weaviate "github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/llm"
@@ -25,6 +28,7 @@ import (
 	"github.com/yincongcyincong/langchaingo/llms/openai"
 	"github.com/yincongcyincong/langchaingo/schema"
 	"github.com/yincongcyincong/langchaingo/textsplitter"
+	"github.com/yincongcyincong/langchaingo/vectorstores"
 	"github.com/yincongcyincong/langchaingo/vectorstores/milvus"
 	"github.com/yincongcyincong/langchaingo/vectorstores/weaviate"
 	"gopkg.in/fsnotify.v1"
@@ -35,12 +39,20 @@ const (
 )
 
 type Rag struct {
-	LLM *llm.LLM
+	LLM   *llm.LLM
+	KB    *conf.KnowledgeBase
+	Store vectorstores.VectorStore
+
+	// Sources documents retrieved for current question, used for citations
+	Sources   []schema.Document
+	retrieved bool
 }
 
 func NewRag(options ...llm.Option) *Rag {
 	dp := &Rag{
-		LLM: llm.NewLLM(options...),
+		LLM:   llm.NewLLM(options...),
+		KB:    conf.RagConfInfo.GetKnowledgeBase(""),
+		Store: conf.RagConfInfo.Store,
 	}
 
 	for _, o := range options {
@@ -59,9 +71,9 @@ func (l *Rag) GenerateContent(ctx context.Context, messages []llms.MessageConten
 		opt(opts)
 	}
 
-	doc, err := conf.RagConfInfo.Store.SimilaritySearch(ctx, l.LLM.Content, 3)
+	doc, err := l.Retrieve(ctx, l.LLM.Content)
 	if err != nil {
-		logger.Error("request vector db fail", "err", err)
+		logger.Error("retrieve doc fail", "err", err)
 	}
 	if len(doc) != 0 {
 		tmpContent := ""
@@ -71,6 +83,7 @@ func (l *Rag) GenerateContent(ctx context.Context, messages []llms.MessageConten
 			}
 		}
 		llm.WithContent(tmpContent)(l.LLM)
+		l.LLM.Cs.DocIds = DocIds(doc)
 	}
 
 	err = l.LLM.CallLLM()
@@ -79,6 +92,10 @@ func (l *Rag) GenerateContent(ctx context.Context, messages []llms.MessageConten
 		return nil, errors.New("error calling DeepSeek API")
 	}
 
+	if citations := FormatCitations(doc); citations != "" {
+		l.LLM.DirectSendMsg(citations, true)
+	}
+
 	resp := &llms.ContentResponse{
 		Choices: []*llms.ContentChoice{
 			{
@@ -106,6 +123,10 @@ func InitRag() {
 		conf.RagConfInfo.Embedder, err = initGeminiEmbedding(ctx)
 	case "ernie":
 		conf.RagConfInfo.Embedder, err = initErnieEmbedding()
+	case "ollama":
+		conf.RagConfInfo.Embedder, err = initOllamaEmbedding()
+	case "hash":
+		conf.RagConfInfo.Embedder = NewHashEmbedder(hashEmbeddingDim)
 	default:
 		logger.Error("embedding type not exist", "embedding type", conf.RagConfInfo.EmbeddingType)
 		return
@@ -115,7 +136,55 @@ func InitRag() {
 		logger.Error("init embedding fail", "err", err)
 		return
 	}
+	conf.RagConfInfo.Embedder = NewPresetEmbedder(conf.RagConfInfo.Embedder)
+
+	switch conf.RagConfInfo.VectorDBType {
+	case "milvus":
+		conf.RagConfInfo.MilvusClient, _ = client.NewClient(ctx, client.Config{
+			Address: conf.RagConfInfo.MilvusURL,
+		})
+	case "weaviate":
+		conf.RagConfInfo.WeaviateClient, _ = db_weaviate.NewClient(db_weaviate.Config{
+			Scheme: conf.RagConfInfo.WeaviateScheme,
+			Host:   conf.RagConfInfo.WeaviateURL,
+		})
+	}
+
+	if conf.RagConfInfo.KBs == nil {
+		err = conf.ParseKnowledgeBases()
+		if err != nil {
+			logger.Error("parse knowledge bases fail", "err", err)
+		}
+	}
+
+	for _, kb := range conf.RagConfInfo.KBs {
+		kb.Store, err = newStore(ctx, kb)
+		if err != nil {
+			logger.Error("get rag store fail", "kb", kb.Name, "err", err)
+			continue
+		}
+		if kb.Name == conf.DefaultKnowledgeBase {
+			conf.RagConfInfo.Store = kb.Store
+		}
+		resetInterruptedFiles(ctx, kb)
+
+		err = handleKnowledgeBase(ctx, kb)
+		if err != nil {
+			logger.Error("get doc fail", "kb", kb.Name, "err", err)
+			continue
+		}
+	}
 
+	if conf.RagConfInfo.Mode == conf.RagModeTool {
+		registerKnowledgeTool()
+	}
+
+	go CheckDirChange()
+
+}
+
+// newStore create vector store of knowledge base, every knowledge base has its own collection.
+func newStore(ctx context.Context, kb *conf.KnowledgeBase) (vectorstores.VectorStore, error) {
 	switch conf.RagConfInfo.VectorDBType {
 	//case "chroma":
 	//	conf.RagConfInfo.Store, err = chroma.NewV2(
@@ -126,119 +195,178 @@ func InitRag() {
 	case "milvus":
 		idx, err := entity.NewIndexAUTOINDEX(entity.L2)
 		if err != nil {
-			logger.Error("get index fail", "err", err)
-			return
+			return nil, err
 		}
 		clientConf := client.Config{
 			Address: conf.RagConfInfo.MilvusURL,
 		}
-		conf.RagConfInfo.Store, err = milvus.New(ctx, clientConf,
-			milvus.WithCollectionName(conf.RagConfInfo.Space),
+		return milvus.New(ctx, clientConf,
+			milvus.WithCollectionName(kb.Space),
 			milvus.WithEmbedder(conf.RagConfInfo.Embedder),
 			milvus.WithIndex(idx),
 			milvus.WithDropOld())
-		conf.RagConfInfo.MilvusClient, _ = client.NewClient(ctx, clientConf)
 	case "weaviate":
-		conf.RagConfInfo.Store, err = weaviate.New(
+		return weaviate.New(
 			weaviate.WithEmbedder(conf.RagConfInfo.Embedder),
 			weaviate.WithScheme(conf.RagConfInfo.WeaviateScheme),
 			weaviate.WithHost(conf.RagConfInfo.WeaviateURL),
-			weaviate.WithIndexName(weaviateIndexName))
-
-		conf.RagConfInfo.WeaviateClient, _ = db_weaviate.NewClient(db_weaviate.Config{
-			Scheme: conf.RagConfInfo.WeaviateScheme,
-			Host:   conf.RagConfInfo.WeaviateURL,
-		})
+			weaviate.WithIndexName(weaviateClassName(kb)))
+	case "local":
+		return NewLocalStore(conf.RagConfInfo.Embedder, kb.Space)
 	default:
-		logger.Error("vector db not exist", "VectorDBTypee", conf.RagConfInfo.VectorDBType)
-		return
+		return nil, fmt.Errorf("vector db not exist: %s", conf.RagConfInfo.VectorDBType)
 	}
+}
 
-	if err != nil {
-		logger.Error("get rag store fail", "err", err)
-		return
+// weaviateClassName weaviate class name must start with upper case letter and only contain letters, digits and _.
+func weaviateClassName(kb *conf.KnowledgeBase) string {
+	if kb == nil || kb.Name == conf.DefaultKnowledgeBase {
+		return weaviateIndexName
 	}
 
-	docs, err := handleKnowledgeBase(ctx)
-	if err != nil {
-		logger.Error("get doc fail", "err", err)
-		return
+	name := []rune(weaviateIndexName + "_")
+	for _, r := range kb.Name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			name = append(name, r)
+		} else {
+			name = append(name, '_')
+		}
 	}
+	return string(name)
+}
 
-	if len(docs) > 0 {
-		insertVectorDb(ctx, docs)
+// GetStore get vector store of knowledge base, store of default knowledge base if name is empty.
+func GetStore(name string) vectorstores.VectorStore {
+	kb := conf.RagConfInfo.GetKnowledgeBase(name)
+	if kb == nil {
+		return nil
 	}
-
-	go CheckDirChange()
-
+	return kb.Store
 }
 
-func insertVectorDb(ctx context.Context, docs []schema.Document) {
-	ids, err := conf.RagConfInfo.Store.AddDocuments(ctx, docs)
-	if err != nil {
-		logger.Error("get save doc fail", "err", err)
-		return
+// insertVectorDb save docs into vector store, docs are embedded first if vectors is nil.
+// vectors are kept with chunks so knowledge base can be exported without embedding again.
+func insertVectorDb(ctx context.Context, kb *conf.KnowledgeBase, docs []schema.Document, vectors [][]float32) ([]string, error) {
+	if conf.RagConfInfo.Embedder == nil {
+		return nil, errors.New("embedder is not ready")
 	}
 
-	fileVectorIds := make(map[string]string)
-	for i := range docs {
-		fileMd5 := docs[i].Metadata["file_md5"].(string)
-		fileVectorIds[fileMd5] += ids[i] + ","
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.PageContent
 	}
 
-	for fileMd5, vectorIds := range fileVectorIds {
-		err = db.UpdateVectorIdByFileMd5(fileMd5, strings.TrimRight(vectorIds, ","))
+	var err error
+	if vectors == nil {
+		vectors, err = conf.RagConfInfo.Embedder.EmbedDocuments(ctx, texts)
 		if err != nil {
-			logger.Error("update vector id fail", "err", err)
+			return nil, err
 		}
 	}
-}
+	if len(vectors) != len(docs) {
+		return nil, errors.New("number of vectors does not match number of documents")
+	}
 
-func handleKnowledgeBase(ctx context.Context) ([]schema.Document, error) {
-	entries, err := os.ReadDir(conf.RagConfInfo.KnowledgePath)
+	ids, err := kb.Store.AddDocuments(withPresetVectors(ctx, texts, vectors), docs)
 	if err != nil {
 		return nil, err
 	}
+	saveChunks(kb, docs, vectors)
 
-	return handleEntry(ctx, entries)
+	return ids, nil
+}
 
+// handleKnowledgeBase enqueue all files of knowledge base, unchanged files are skipped.
+func handleKnowledgeBase(ctx context.Context, kb *conf.KnowledgeBase) error {
+	err := os.MkdirAll(kb.Path, 0755)
+	if err != nil {
+		return err
+	}
+
+	paths, err := walkKnowledgeFiles(kb, kb.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if getDocHandler(path) == nil {
+			continue
+		}
+		err = EnqueueFile(ctx, kb, path, false)
+		if err != nil {
+			logger.Error("enqueue file fail", "path", path, "err", err)
+		}
+	}
+	return nil
 }
 
-func handleEntry(ctx context.Context, entries []os.DirEntry) ([]schema.Document, error) {
-	var err error
-	res := make([]schema.Document, 0)
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			var docs []schema.Document
-			switch {
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".txt"):
-				docs, err = handleTextDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle text doc fail", "err", err)
-				}
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".pdf"):
-				docs, err = handlePDFDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle pdf doc fail", "err", err)
-				}
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".csv"):
-				docs, err = handleCSVDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle csv doc fail", "err", err)
-				}
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".html"):
-				docs, err = handleHTMLDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle html doc fail", "err", err)
-				}
-			}
-			if len(docs) > 0 {
-				res = append(res, docs...)
+// walkKnowledgeFiles returns all files under dir, nested directories included.
+// directories of other knowledge bases are skipped.
+func walkKnowledgeFiles(kb *conf.KnowledgeBase, dir string) ([]string, error) {
+	paths := make([]string, 0)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if other := findKnowledgeBase(path); other != nil && other != kb {
+				return filepath.SkipDir
 			}
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, err
+}
+
+// findKnowledgeBase find knowledge base which path contains file, the deepest one wins.
+func findKnowledgeBase(path string) *conf.KnowledgeBase {
+	var (
+		res    *conf.KnowledgeBase
+		resLen int
+	)
+	path = filepath.Clean(path)
+	for _, kb := range conf.RagConfInfo.KBs {
+		kbPath := filepath.Clean(kb.Path)
+		if path != kbPath && !strings.HasPrefix(path, kbPath+string(filepath.Separator)) {
+			continue
+		}
+		if res == nil || len(kbPath) > resLen {
+			res = kb
+			resLen = len(kbPath)
 		}
 	}
+	return res
+}
+
+// docHandler load and split knowledge file into documents.
+type docHandler func(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error)
 
-	return res, nil
+// getDocHandler get handler by file extension, nil if file type is not supported.
+func getDocHandler(path string) docHandler {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case ext == ".txt":
+		return handleTextDoc
+	case ext == ".pdf":
+		return handlePDFDoc
+	case ext == ".csv":
+		return handleCSVDoc
+	case ext == ".html":
+		return handleHTMLDoc
+	case ext == ".md" || ext == ".markdown":
+		return handleMarkdownDoc
+	case ext == ".docx":
+		return handleDocxDoc
+	case ext == ".json":
+		return handleJSONDoc
+	case ext == ".yaml" || ext == ".yml":
+		return handleYAMLDoc
+	case codeSeparators[ext] != nil:
+		return handleCodeDoc
+	}
+	return nil
 }
 
 func initOpenAIEmbedding() (embeddings.Embedder, error) {
@@ -257,6 +385,30 @@ func initOpenAIEmbedding() (embeddings.Embedder, error) {
 	return embedder, err
 }
 
+// initOllamaEmbedding uses any local openai compatible /embeddings endpoint, such as ollama.
+func initOllamaEmbedding() (embeddings.Embedder, error) {
+	llmEmbedder, err := openai.New(
+		openai.WithBaseURL(conf.RagConfInfo.EmbeddingURL),
+		openai.WithEmbeddingModel(conf.RagConfInfo.EmbeddingModel),
+		openai.WithToken("ollama"),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts := make([]embeddings.Option, 0)
+	if conf.RagConfInfo.EmbeddingBatchSize > 0 {
+		opts = append(opts, embeddings.WithBatchSize(conf.RagConfInfo.EmbeddingBatchSize))
+	}
+	embedder, err := embeddings.NewEmbedder(llmEmbedder, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return embedder, err
+}
+
 func initErnieEmbedding() (embeddings.Embedder, error) {
 	llmEmbedder, err := ernie.New(
 		ernie.WithModelName(ernie.ModelNameERNIEBot),
@@ -290,64 +442,106 @@ func initGeminiEmbedding(ctx context.Context) (embeddings.Embedder, error) {
 	return embedder, err
 }
 
-func getFileResource(entry os.DirEntry) (*os.File, string, error) {
-	fullPath := filepath.Join(conf.RagConfInfo.KnowledgePath, entry.Name())
+// KnowledgeFileName returns the path relative to the knowledge base dir, it is the key of rag_files.
+func KnowledgeFileName(kb *conf.KnowledgeBase, path string) string {
+	rel, err := filepath.Rel(kb.Path, path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// KnowledgeFilePath joins file name with the knowledge base dir and refuses names out of it.
+func KnowledgeFilePath(kb *conf.KnowledgeBase, fileName string) (string, error) {
+	path := filepath.Join(kb.Path, filepath.FromSlash(fileName))
+	rel, err := filepath.Rel(kb.Path, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file name: %s", fileName)
+	}
+	return path, nil
+}
 
-	fileMd5, err := utils.FileToMd5(fullPath)
+func getFileResource(path string) (*os.File, string, error) {
+	fileMd5, err := utils.FileToMd5(path)
 	if err != nil {
-		logger.Error("file to md5 fail", "err", err)
 		return nil, "", err
 	}
 
-	fileInfos, err := db.GetRagFileByFileMd5(fileMd5)
+	f, err := os.Open(path)
+	return f, fileMd5, err
+}
+
+func handleTextDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
 	if err != nil {
-		logger.Error("get file from db fail", "err", err)
-		return nil, "", err
+		logger.Error("read file fail", "err", err)
+		return nil, err
 	}
+	defer f.Close()
 
-	if len(fileInfos) > 0 {
-		logger.Info("file exist", "path", fullPath)
-		return nil, "", nil
+	loader := documentloaders.NewText(f)
+	return saveDocIntoStore(ctx, loader, defaultSplitter(), kb, fMd5, path)
+}
+
+func handlePDFDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
+	if err != nil {
+		logger.Error("read file fail", "err", err)
+		return nil, err
 	}
+	defer f.Close()
 
-	err = db.DeleteRagFileByFileName(entry.Name())
+	finfo, err := f.Stat()
 	if err != nil {
-		logger.Error("delete file from db fail", "err", err)
+		logger.Error("get file stat fail", "err", err)
+		return nil, err
 	}
+	loader := documentloaders.NewPDF(f, finfo.Size())
+	return saveDocIntoStore(ctx, loader, defaultSplitter(), kb, fMd5, path)
+}
 
-	_, err = db.InsertRagFile(entry.Name(), fileMd5)
+func handleCSVDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
 	if err != nil {
-		logger.Error("insert rag file fail", "err", err)
+		logger.Error("read file fail", "err", err)
+		return nil, err
 	}
+	defer f.Close()
 
-	f, err := os.Open(fullPath)
-	return f, fileMd5, err
+	loader := documentloaders.NewCSV(f)
+	return saveDocIntoStore(ctx, loader, defaultSplitter(), kb, fMd5, path)
 }
 
-func handleTextDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
+func handleHTMLDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
 	if err != nil {
 		logger.Error("read file fail", "err", err)
 		return nil, err
 	}
-	if f == nil {
-		return nil, nil
+	defer f.Close()
+
+	loader := documentloaders.NewHTML(f)
+	return saveDocIntoStore(ctx, loader, defaultSplitter(), kb, fMd5, path)
+}
+
+func handleMarkdownDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
+	if err != nil {
+		logger.Error("read file fail", "err", err)
+		return nil, err
 	}
 	defer f.Close()
 
 	loader := documentloaders.NewText(f)
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
+	return saveDocIntoStore(ctx, loader, markdownSplitter(), kb, fMd5, path)
 }
 
-func handlePDFDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
+func handleDocxDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
 	if err != nil {
 		logger.Error("read file fail", "err", err)
 		return nil, err
 	}
-	if f == nil {
-		return nil, nil
-	}
 	defer f.Close()
 
 	finfo, err := f.Stat()
@@ -355,56 +549,60 @@ func handlePDFDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, er
 		logger.Error("get file stat fail", "err", err)
 		return nil, err
 	}
-	loader := documentloaders.NewPDF(f, finfo.Size())
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
+	loader := NewDocx(f, finfo.Size())
+	return saveDocIntoStore(ctx, loader, defaultSplitter(), kb, fMd5, path)
 }
 
-func handleCSVDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
+func handleJSONDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
 	if err != nil {
 		logger.Error("read file fail", "err", err)
 		return nil, err
 	}
-	if f == nil {
-		return nil, nil
-	}
 	defer f.Close()
 
-	loader := documentloaders.NewCSV(f)
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
+	loader := NewJSON(f)
+	return saveDocIntoStore(ctx, loader, structSplitter(jsonSeparators), kb, fMd5, path)
 }
 
-func handleHTMLDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
+func handleYAMLDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
 	if err != nil {
 		logger.Error("read file fail", "err", err)
 		return nil, err
 	}
-	if f == nil {
-		return nil, nil
-	}
 	defer f.Close()
 
-	loader := documentloaders.NewHTML(f)
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
+	loader := documentloaders.NewText(f)
+	return saveDocIntoStore(ctx, loader, structSplitter(yamlSeparators), kb, fMd5, path)
 }
 
-func saveDocIntoStore(ctx context.Context, loader documentloaders.Loader, fMd5 string, entry os.DirEntry) ([]schema.Document, error) {
-	splitter := textsplitter.NewRecursiveCharacter(
-		textsplitter.WithChunkSize(conf.RagConfInfo.ChunkSize),
-		textsplitter.WithChunkOverlap(conf.RagConfInfo.ChunkOverlap),
-		textsplitter.WithSeparators(conf.DefaultSpliter),
-	)
+func handleCodeDoc(ctx context.Context, kb *conf.KnowledgeBase, path string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(path)
+	if err != nil {
+		logger.Error("read file fail", "err", err)
+		return nil, err
+	}
+	defer f.Close()
+
+	loader := documentloaders.NewText(f)
+	return saveDocIntoStore(ctx, loader, codeSplitter(strings.ToLower(filepath.Ext(path))), kb, fMd5, path)
+}
 
+func saveDocIntoStore(ctx context.Context, loader documentloaders.Loader, splitter textsplitter.TextSplitter, kb *conf.KnowledgeBase, fMd5 string, path string) ([]schema.Document, error) {
 	docs, err := loader.LoadAndSplit(ctx, splitter)
 	if err != nil {
 		logger.Error("get rag docs fail: %v", err)
 		return nil, err
 	}
 
-	for _, doc := range docs {
-		doc.Metadata["file_name"] = entry.Name()
+	fileName := KnowledgeFileName(kb, path)
+	for i, doc := range docs {
+		doc.Metadata["kb"] = kb.Name
+		doc.Metadata["file_name"] = fileName
 		doc.Metadata["file_md5"] = fMd5
+		doc.Metadata["chunk_index"] = i + 1
+		doc.Metadata["doc_id"] = DocId(kb.Name, fileName, i+1)
 	}
 
 	return docs, nil
@@ -424,11 +622,15 @@ func CheckDirChange() {
 	}
 	defer watcher.Close()
 
-	// 监控当前目录
-	err = watcher.Add(conf.RagConfInfo.KnowledgePath)
-	if err != nil {
-		logger.Error("add watcher fail", "err", err)
-		return
+	// 监控所有知识库目录及子目录
+	for _, kb := range conf.RagConfInfo.KBs {
+		if kb.Store == nil {
+			continue
+		}
+		err = watchDir(watcher, kb.Path)
+		if err != nil {
+			logger.Error("add watcher fail", "kb", kb.Name, "err", err)
+		}
 	}
 
 	for {
@@ -437,7 +639,7 @@ func CheckDirChange() {
 			if !ok {
 				return
 			}
-			insertNewDoc(event)
+			insertNewDoc(watcher, event)
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				logger.Error("watcher channel closed")
@@ -449,87 +651,160 @@ func CheckDirChange() {
 
 }
 
-func insertNewDoc(event fsnotify.Event) {
+// watchDir adds dir and all its sub dirs into watcher, fsnotify is not recursive.
+func watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+func insertNewDoc(watcher *fsnotify.Watcher, event fsnotify.Event) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 	switch {
 	case event.Op&fsnotify.Create == fsnotify.Create:
 		logger.Info("rag dir changed", "event", event.Name, "op", "create")
+		if fileInfo, err := os.Stat(event.Name); err == nil && fileInfo.IsDir() {
+			err = watchDir(watcher, event.Name)
+			if err != nil {
+				logger.Error("add watcher fail", "err", err)
+			}
+		}
 		InsertDoc(ctx, event)
 	case event.Op&fsnotify.Write == fsnotify.Write:
 		logger.Info("rag dir changed", "event", event.Name, "op", "write")
-		DeleteDoc(ctx, event)
+		// data of the old version is deleted when the new one is enqueued
 		InsertDoc(ctx, event)
 	case event.Op&fsnotify.Remove == fsnotify.Remove:
 		logger.Info("rag dir changed", "event", event.Name, "op", "remove")
 		DeleteDoc(ctx, event)
+	case event.Op&fsnotify.Rename == fsnotify.Rename:
+		// event of the old name, the new name comes with create event
+		logger.Info("rag dir changed", "event", event.Name, "op", "rename")
+		_ = watcher.Remove(event.Name)
+		DeleteDoc(ctx, event)
 	}
 
 }
 
+// DeleteDoc delete data of removed or renamed file, the path may be a dir which can't be stat any more,
+// so files recorded under it are deleted too.
 func DeleteDoc(ctx context.Context, event fsnotify.Event) {
-	fileName := filepath.Base(event.Name)
-	fileDbInfo, err := db.GetRagFileByFileName(fileName)
+	kb := findKnowledgeBase(event.Name)
+	if kb == nil || kb.Store == nil {
+		return
+	}
+	fileName := KnowledgeFileName(kb, event.Name)
+	err := DeleteFileData(ctx, kb, fileName)
+	if err != nil {
+		logger.Error("delete doc fail", "err", err)
+	}
+
+	ragFiles, err := db.GetRagFilesByDir(kb.Name, fileName)
 	if err != nil {
-		logger.Error("get file db info fail", "err", err)
+		logger.Error("get rag files of dir fail", "dir", fileName, "err", err)
 		return
 	}
-	if fileDbInfo != nil && len(fileDbInfo) > 0 {
-		err = DeleteStoreData(ctx, fileDbInfo[0].VectorId)
+	for _, ragFile := range ragFiles {
+		err = DeleteFileData(ctx, kb, ragFile.FileName)
 		if err != nil {
-			logger.Error("delete doc fail", "err", err)
-			return
+			logger.Error("delete doc fail", "file", ragFile.FileName, "err", err)
 		}
-		err = db.DeleteRagFileByFileName(fileDbInfo[0].FileName)
+	}
+}
+
+// DeleteFileData delete vectors, chunks and records of knowledge file.
+func DeleteFileData(ctx context.Context, kb *conf.KnowledgeBase, fileName string) error {
+	fileDbInfos, err := db.GetRagFileByFileName(kb.Name, fileName)
+	if err != nil {
+		return err
+	}
+	for _, fileDbInfo := range fileDbInfos {
+		if fileDbInfo.VectorId == "" {
+			continue
+		}
+		err = DeleteStoreData(ctx, kb, fileDbInfo.VectorId)
 		if err != nil {
-			logger.Error("delete doc in db fail", "err", err)
-			return
+			return err
 		}
 	}
+	return db.DeleteRagFileByFileName(kb.Name, fileName)
 }
 
 func InsertDoc(ctx context.Context, event fsnotify.Event) {
-	fileInfo, err := os.Stat(event.Name)
-	if err != nil {
-		logger.Error("stat file fail", "err", err)
+	kb := findKnowledgeBase(event.Name)
+	if kb == nil || kb.Store == nil {
 		return
 	}
-	entry, err := findDirEntry(conf.RagConfInfo.KnowledgePath, fileInfo.Name())
-	if err != nil {
-		logger.Error("find dir entry fail", "err", err)
+	if _, ok := learningFiles.Load(event.Name); ok {
 		return
 	}
-	docs, err := handleEntry(ctx, []os.DirEntry{entry})
+	fileInfo, err := os.Stat(event.Name)
 	if err != nil {
-		logger.Error("handle entry fail", "err", err)
+		logger.Error("stat file fail", "err", err)
 		return
 	}
-	if len(docs) > 0 {
-		insertVectorDb(ctx, docs)
+
+	paths := []string{event.Name}
+	if fileInfo.IsDir() {
+		paths, err = walkKnowledgeFiles(kb, event.Name)
+		if err != nil {
+			logger.Error("walk dir fail", "err", err)
+			return
+		}
 	}
-}
 
-func findDirEntry(path string, filename string) (os.DirEntry, error) {
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return nil, err
+	for _, path := range paths {
+		if getDocHandler(path) == nil {
+			continue
+		}
+		err = EnqueueFile(ctx, kb, path, false)
+		if err != nil {
+			logger.Error("enqueue file fail", "path", path, "err", err)
+		}
 	}
+}
 
-	for _, entry := range entries {
-		if entry.Name() == filename {
-			return entry, nil
+// deleteFileVectors delete vectors and chunks of file by file_name metadata, it cleans data left by
+// failed insertion whose vector ids are unknown.
+func deleteFileVectors(ctx context.Context, kb *conf.KnowledgeBase, fileName string) error {
+	var err error
+	switch conf.RagConfInfo.VectorDBType {
+	case "weaviate":
+		_, err = conf.RagConfInfo.WeaviateClient.Batch().ObjectsBatchDeleter().
+			WithClassName(weaviateClassName(kb)).
+			WithWhere(filters.Where().
+				WithPath([]string{"file_name"}).
+				WithOperator(filters.Equal).
+				WithValueText(fileName)).
+			Do(ctx)
+	case "milvus":
+		err = conf.RagConfInfo.MilvusClient.Delete(ctx, kb.Space, "", fmt.Sprintf(`meta["file_name"] == %s`, strconv.Quote(fileName)))
+	case "local":
+		if store, ok := kb.Store.(*LocalStore); ok {
+			err = store.DeleteByFileName(ctx, fileName)
 		}
 	}
-	return nil, fmt.Errorf("file not exist: %s", filename)
+	if err != nil {
+		return err
+	}
+
+	return db.DeleteRagChunksByFileName(kb.Name, fileName)
 }
 
-func DeleteStoreData(ctx context.Context, vectorIds string) error {
+func DeleteStoreData(ctx context.Context, kb *conf.KnowledgeBase, vectorIds string) error {
 	var err error
 	switch conf.RagConfInfo.VectorDBType {
 	case "weaviate":
 		for _, vectorId := range strings.Split(vectorIds, ",") {
 			err = conf.RagConfInfo.WeaviateClient.Data().Deleter().
-				WithClassName(weaviateIndexName).
+				WithClassName(weaviateClassName(kb)).
 				WithID(vectorId).
 				Do(ctx)
 			if err != nil {
@@ -540,7 +815,15 @@ func DeleteStoreData(ctx context.Context, vectorIds string) error {
 	case "milvus":
 		for _, vectorId := range strings.Split(vectorIds, ",") {
 			expr := fmt.Sprintf(`pk == %s`, vectorId)
-			err = conf.RagConfInfo.MilvusClient.Delete(ctx, conf.RagConfInfo.Space, "", expr)
+			err = conf.RagConfInfo.MilvusClient.Delete(ctx, kb.Space, "", expr)
+		}
+
+	case "local":
+		if store, ok := kb.Store.(*LocalStore); ok {
+			err = store.Delete(ctx, vectorIds)
+			if err != nil {
+				logger.Error("delete store data fail", "err", err)
+			}
 		}
 	}
 
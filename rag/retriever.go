@@ -0,0 +1,168 @@
+package rag
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/metrics"
+	"github.com/yincongcyincong/langchaingo/schema"
+	"github.com/yincongcyincong/langchaingo/vectorstores"
+)
+
+const (
+	// rrfK constant of reciprocal rank fusion, damps the weight of top ranks.
+	rrfK = 60
+
+	// candidateFactor each search returns top_k * candidateFactor candidates before fusion.
+	candidateFactor = 3
+
+	defaultTopK = 3
+)
+
+var rerankNumReg = regexp.MustCompile(`\d+`)
+
+// hybridRetriever retrieve documents by Rag.Retrieve, documents are kept in Sources for citations.
+type hybridRetriever struct {
+	rag *Rag
+}
+
+var _ schema.Retriever = (*hybridRetriever)(nil)
+
+// Retriever returns a hybrid retriever of Rag knowledge base.
+func (l *Rag) Retriever() schema.Retriever {
+	return &hybridRetriever{
+		rag: l,
+	}
+}
+
+func (h *hybridRetriever) GetRelevantDocuments(ctx context.Context, query string) ([]schema.Document, error) {
+	return h.rag.Retrieve(ctx, query)
+}
+
+// Retrieve fuse vector search and bm25 keyword search by reciprocal rank, then rerank by llm if rag_rerank is set.
+// documents are retrieved once per question, empty result means nothing is relevant.
+func (l *Rag) Retrieve(ctx context.Context, query string) ([]schema.Document, error) {
+	if l.retrieved {
+		return l.Sources, nil
+	}
+
+	topK := conf.RagConfInfo.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+	candidates := topK * candidateFactor
+
+	var vectorDocs []schema.Document
+	if l.Store != nil {
+		options := make([]vectorstores.Option, 0)
+		if conf.RagConfInfo.MinScore > 0 {
+			options = append(options, vectorstores.WithScoreThreshold(float32(conf.RagConfInfo.MinScore)))
+		}
+		var err error
+		vectorDocs, err = l.Store.SimilaritySearch(ctx, query, candidates, options...)
+		if err != nil {
+			logger.ErrorCtx(ctx, "request vector db fail", "err", err)
+		}
+	}
+	keywordDocs := keywordSearch(l.KB, query, candidates)
+
+	docs := fuseRanks(vectorDocs, keywordDocs)
+	if conf.RagConfInfo.Rerank && len(docs) > 0 {
+		docs = l.rerank(ctx, query, docs)
+	}
+	if len(docs) > topK {
+		docs = docs[:topK]
+	}
+
+	logger.InfoCtx(ctx, "rag retrieve", "vector", len(vectorDocs), "keyword", len(keywordDocs), "docs", DocIds(docs))
+	l.Sources = docs
+	l.retrieved = true
+	return docs, nil
+}
+
+// fuseRanks reciprocal rank fusion of ranked lists, Score of result is the fused score.
+func fuseRanks(lists ...[]schema.Document) []schema.Document {
+	scores := make(map[string]float64)
+	docs := make(map[string]schema.Document)
+	order := make([]string, 0)
+	for _, list := range lists {
+		for rank, doc := range list {
+			key := getDocId(doc)
+			if key == "" {
+				key = doc.PageContent
+			}
+			if _, ok := docs[key]; !ok {
+				docs[key] = doc
+				order = append(order, key)
+			}
+			scores[key] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	res := make([]schema.Document, 0, len(order))
+	for _, key := range order {
+		doc := docs[key]
+		doc.Score = float32(scores[key])
+		res = append(res, doc)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	return res
+}
+
+// rerank ask llm which documents answer the query, documents are kept as they are if llm fails.
+func (l *Rag) rerank(ctx context.Context, query string, docs []schema.Document) []schema.Document {
+	var sb strings.Builder
+	for i, doc := range docs {
+		sb.WriteString("[" + strconv.Itoa(i+1) + "] " + strings.TrimSpace(doc.PageContent) + "\n\n")
+	}
+
+	rerankLLM := llm.NewLLM(llm.WithContext(ctx), llm.WithUserId(l.LLM.UserId), llm.WithChatId(l.LLM.ChatId))
+	rerankLLM.LLMClient.GetModel(rerankLLM)
+	rerankLLM.LLMClient.GetMessage(openai.ChatMessageRoleUser, i18n.GetMessage("rag_rerank_prompt",
+		map[string]interface{}{
+			"question": query,
+			"chunks":   sb.String(),
+		}))
+
+	metrics.APIRequestCount.WithLabelValues(rerankLLM.Model).Inc()
+	content, err := rerankLLM.LLMClient.SyncSend(ctx, rerankLLM)
+	if err != nil {
+		logger.ErrorCtx(ctx, "rerank fail", "err", err)
+		return docs
+	}
+
+	rerankLLM.InsertUsageRecord(l.LLM.Cs.SessionId, "rerank")
+
+	return rerankResult(content, docs)
+}
+
+// rerankResult pick documents by numbers in llm reply, "0" means no document is relevant.
+// the reply is ignored if it has no number.
+func rerankResult(content string, docs []schema.Document) []schema.Document {
+	nums := rerankNumReg.FindAllString(content, -1)
+	if len(nums) == 0 {
+		return docs
+	}
+
+	res := make([]schema.Document, 0, len(nums))
+	picked := make(map[int]bool, len(nums))
+	for _, num := range nums {
+		i, _ := strconv.Atoi(num)
+		if i < 1 || i > len(docs) || picked[i] {
+			continue
+		}
+		picked[i] = true
+		res = append(res, docs[i-1])
+	}
+	return res
+}
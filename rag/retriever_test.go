@@ -0,0 +1,99 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestKeywordTokens(t *testing.T) {
+	tokens := keywordTokens("Set chunk_size, got ERR-42. 知识库")
+	assert.Contains(t, tokens, "chunk")
+	assert.Contains(t, tokens, "chunk_size")
+	assert.Contains(t, tokens, "err-42")
+	assert.Contains(t, tokens, "知")
+	assert.NotContains(t, tokens, "err-42.")
+}
+
+func TestKeywordIndexSearch(t *testing.T) {
+	index := newKeywordIndex([]schema.Document{
+		{PageContent: "the weather is sunny today", Metadata: map[string]any{"doc_id": "1"}},
+		{PageContent: "error E1001 means the disk is full", Metadata: map[string]any{"doc_id": "2"}},
+		{PageContent: "set chunk_size to change split size", Metadata: map[string]any{"doc_id": "3"}},
+	})
+
+	docs := index.search("what is E1001", 3, 0)
+	assert.Len(t, docs, 2)
+	assert.Equal(t, "2", docs[0].Metadata["doc_id"])
+
+	docs = index.search("chunk_size", 3, 0)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "3", docs[0].Metadata["doc_id"])
+
+	assert.Empty(t, index.search("golang", 3, 0))
+
+	// "is" alone doesn't make the weather document relevant
+	docs = index.search("what is E1001", 3, 0.2)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "2", docs[0].Metadata["doc_id"])
+	assert.LessOrEqual(t, docs[0].Score, float32(1))
+}
+
+func TestFuseRanks(t *testing.T) {
+	a := schema.Document{PageContent: "a", Metadata: map[string]any{"doc_id": "a"}}
+	b := schema.Document{PageContent: "b", Metadata: map[string]any{"doc_id": "b"}}
+	c := schema.Document{PageContent: "c", Metadata: map[string]any{"doc_id": "c"}}
+
+	docs := fuseRanks([]schema.Document{a, b}, []schema.Document{b, c})
+	assert.Len(t, docs, 3)
+	assert.Equal(t, "b", docs[0].PageContent)
+	assert.Equal(t, "a", docs[1].PageContent)
+	assert.Equal(t, "c", docs[2].PageContent)
+}
+
+func TestRerankResult(t *testing.T) {
+	docs := []schema.Document{{PageContent: "a"}, {PageContent: "b"}, {PageContent: "c"}}
+
+	res := rerankResult("3, 1, 3, 9", docs)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "c", res[0].PageContent)
+	assert.Equal(t, "a", res[1].PageContent)
+
+	assert.Empty(t, rerankResult("0", docs))
+	assert.Len(t, rerankResult("no idea", docs), 3)
+}
+
+func TestRetrieveMinScore(t *testing.T) {
+	kb := newTestKB(t, "retrieve_min_score_test")
+	chunkSize, chunkOverlap := conf.RagConfInfo.ChunkSize, conf.RagConfInfo.ChunkOverlap
+	conf.RagConfInfo.MinScore, conf.RagConfInfo.ChunkSize, conf.RagConfInfo.ChunkOverlap = 0.3, 500, 0
+	defer func() {
+		conf.RagConfInfo.MinScore, conf.RagConfInfo.ChunkSize, conf.RagConfInfo.ChunkOverlap = 0, chunkSize, chunkOverlap
+	}()
+
+	for name, content := range map[string]string{
+		"disk.txt":    "error E1001 means the disk is full, clean the disk and retry",
+		"network.txt": "error E2002 means the network is down",
+		"config.txt":  "set chunk_size to change split size",
+	} {
+		path := filepath.Join(kb.Path, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, _, err := indexFile(kb, path, "")
+		assert.NoError(t, err)
+	}
+
+	// vector search is limited by score threshold of store, keyword search alone is checked here
+	docs, err := (&Rag{KB: kb}).Retrieve(context.Background(), "what is E1001")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, docs)
+	assert.Contains(t, docs[0].PageContent, "E1001")
+
+	docs, err = (&Rag{KB: kb}).Retrieve(context.Background(), "how is the weather in paris today")
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+}
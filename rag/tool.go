@@ -0,0 +1,98 @@
+package rag
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	knowledgeToolName = "search_knowledge_base"
+	noKnowledgeResult = "no relevant document in knowledge base"
+)
+
+// registerKnowledgeTool add search_knowledge_base into tools of every llm, so llm decides when to retrieve.
+func registerKnowledgeTool() {
+	llm.RegisterNativeTool(new(knowledgeTool))
+}
+
+// knowledgeTool search_knowledge_base native tool.
+type knowledgeTool struct{}
+
+func (t *knowledgeTool) Tool() mcp.Tool {
+	return mcp.NewTool(knowledgeToolName,
+		mcp.WithDescription("Search the knowledge base of this bot for documents relevant to the question. "+
+			"Use it when the question is about documents, products, configs, error codes or anything the bot may have been taught. "+
+			"Results are numbered snippets with file name and chunk, cite them as [n] in the answer."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("search query, keep exact identifiers such as error codes and config keys"),
+		),
+	)
+}
+
+// Command role allowed to use /kb can search knowledge base.
+func (t *knowledgeTool) Command() string {
+	return param.KB
+}
+
+func (t *knowledgeTool) Call(ctx context.Context, l *llm.LLM, arguments map[string]interface{}) (string, error) {
+	query, _ := arguments["query"].(string)
+	return searchKnowledgeBase(ctx, l, query)
+}
+
+// searchKnowledgeBase retrieve knowledge base bound to the chat, documents are kept in llm for citations.
+func searchKnowledgeBase(ctx context.Context, l *llm.LLM, query string) (string, error) {
+	kb := conf.RagConfInfo.GetKnowledgeBase(l.KnowledgeBase)
+	if kb == nil || kb.Store == nil {
+		kb = conf.RagConfInfo.GetKnowledgeBase("")
+	}
+	if kb == nil || kb.Store == nil || query == "" {
+		return noKnowledgeResult, nil
+	}
+
+	r := &Rag{
+		LLM:   l,
+		KB:    kb,
+		Store: kb.Store,
+	}
+	docs, err := r.Retrieve(ctx, query)
+	if err != nil {
+		return "", err
+	}
+
+	logger.InfoCtx(ctx, "search knowledge base", "kb", kb.Name, "query", query, "docs", DocIds(docs))
+	l.Sources = uniqueDocs(append(l.Sources, docs...))
+	if l.Cs != nil {
+		l.Cs.DocIds = DocIds(l.Sources)
+	}
+
+	return formatToolResult(l.Sources, docs), nil
+}
+
+// formatToolResult numbered snippets for llm, numbers follow the order of all sources in this answer.
+func formatToolResult(sources, docs []schema.Document) string {
+	if len(docs) == 0 {
+		return noKnowledgeResult
+	}
+
+	index := make(map[string]int, len(sources))
+	for i, doc := range sources {
+		index[getDocId(doc)] = i + 1
+	}
+
+	var sb strings.Builder
+	for _, doc := range docs {
+		sb.WriteString("[" + strconv.Itoa(index[getDocId(doc)]) + "] " + metaString(doc.Metadata, "file_name") +
+			" #" + strconv.Itoa(metaInt(doc.Metadata, "chunk_index")) + "\n")
+		sb.WriteString(strings.TrimSpace(doc.PageContent) + "\n\n")
+	}
+	return sb.String()
+}
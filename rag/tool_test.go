@@ -0,0 +1,19 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestFormatToolResult(t *testing.T) {
+	a := schema.Document{PageContent: "disk is full", Metadata: map[string]any{"doc_id": "default/a.md#1", "file_name": "a.md", "chunk_index": 1}}
+	b := schema.Document{PageContent: " set chunk_size ", Metadata: map[string]any{"doc_id": "default/b.md#2", "file_name": "b.md", "chunk_index": 2}}
+
+	res := formatToolResult([]schema.Document{a, b}, []schema.Document{b})
+	assert.True(t, strings.HasPrefix(res, "[2] b.md #2\nset chunk_size\n"))
+
+	assert.Equal(t, noKnowledgeResult, formatToolResult(nil, nil))
+}
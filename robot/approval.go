@@ -0,0 +1,184 @@
+package robot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"github.com/slack-go/slack"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func init() {
+	llm.ToolApprover = sendToolApproval
+}
+
+// sendToolApproval post approval prompt with telegram inline buttons, slack blocks or lark card,
+// other platforms get the prompt with reply commands from llm.
+func sendToolApproval(ctx context.Context, audit *db.McpAudit) bool {
+	r, ok := ctx.Value("robot_info").(*RobotInfo)
+	if !ok || r.Robot == nil {
+		return false
+	}
+	_, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	id := strconv.FormatInt(audit.ID, 10)
+	content := llm.ApprovalMessage(audit)
+	approveText := i18n.GetMessage("mcp_approve_button", nil)
+	rejectText := i18n.GetMessage("mcp_reject_button", nil)
+
+	switch robot := r.Robot.(type) {
+	case *TelegramRobot:
+		inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(approveText, param.McpApprove+" "+id),
+				tgbotapi.NewInlineKeyboardButtonData(rejectText, param.McpReject+" "+id),
+			),
+		)
+		return r.SendMsg(audit.ChatId, content, msgId, "", &inlineKeyboard) != ""
+	case *SlackRobot:
+		_, _, err := robot.Client.PostMessage(audit.ChatId,
+			slack.MsgOptionText(content, false),
+			slack.MsgOptionBlocks(
+				slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, content, false, false), nil, nil),
+				slack.NewActionBlock("mcp_approval_"+id,
+					slack.NewButtonBlockElement(param.McpApprove, id,
+						slack.NewTextBlockObject(slack.PlainTextType, approveText, false, false)).WithStyle(slack.StylePrimary),
+					slack.NewButtonBlockElement(param.McpReject, id,
+						slack.NewTextBlockObject(slack.PlainTextType, rejectText, false, false)).WithStyle(slack.StyleDanger),
+				),
+			))
+		if err != nil {
+			logger.WarnCtx(ctx, "send slack approval fail", "err", err)
+			return false
+		}
+		return true
+	case *LarkRobot:
+		card, err := json.Marshal(map[string]interface{}{
+			"config": map[string]interface{}{"wide_screen_mode": true},
+			"elements": []interface{}{
+				map[string]interface{}{
+					"tag":  "div",
+					"text": map[string]interface{}{"tag": "plain_text", "content": content},
+				},
+				map[string]interface{}{
+					"tag": "action",
+					"actions": []interface{}{
+						larkApprovalButton(approveText, "primary", param.McpApprove, id),
+						larkApprovalButton(rejectText, "danger", param.McpReject, id),
+					},
+				},
+			},
+		})
+		if err != nil {
+			logger.WarnCtx(ctx, "marshal lark approval card fail", "err", err)
+			return false
+		}
+
+		resp, err := robot.Client.Im.Message.Create(ctx, larkim.NewCreateMessageReqBuilder().
+			ReceiveIdType(larkim.ReceiveIdTypeChatId).
+			Body(larkim.NewCreateMessageReqBodyBuilder().
+				MsgType(larkim.MsgTypeInteractive).
+				ReceiveId(audit.ChatId).
+				Content(string(card)).
+				Build()).
+			Build())
+		if err != nil || !resp.Success() {
+			logger.WarnCtx(ctx, "send lark approval fail", "err", err, "resp", resp)
+			return false
+		}
+		return true
+	}
+
+	return false
+}
+
+func larkApprovalButton(text, buttonType, command, id string) map[string]interface{} {
+	return map[string]interface{}{
+		"tag":   "button",
+		"text":  map[string]interface{}{"tag": "plain_text", "content": text},
+		"type":  buttonType,
+		"value": map[string]interface{}{"command": command, "id": id},
+	}
+}
+
+// LarkCardActionHandler handle approve and reject buttons of lark approval card, role of operator is checked
+// like /mcp_approve and /mcp_reject.
+func LarkCardActionHandler(ctx context.Context, event *callback.CardActionTriggerEvent) (*callback.CardActionTriggerResponse, error) {
+	if event.Event == nil || event.Event.Action == nil || event.Event.Operator == nil {
+		return nil, nil
+	}
+	command, _ := event.Event.Action.Value["command"].(string)
+	id, _ := event.Event.Action.Value["id"].(string)
+	if command != param.McpApprove && command != param.McpReject {
+		return nil, nil
+	}
+
+	userId := larkcore.StringValue(event.Event.Operator.UserID)
+	chatId := ""
+	if event.Event.Context != nil {
+		chatId = event.Event.Context.OpenChatID
+	}
+	role := getRole(chatId, userId)
+	if !conf.RBACConfInfo.Allow(role, param.RoleResourceCommand, command) {
+		logger.WarnCtx(ctx, "permission denied", "userID", userId, "role", role,
+			"resource", param.RoleResourceCommand, "name", command)
+		return &callback.CardActionTriggerResponse{
+			Toast: &callback.Toast{
+				Type: "error",
+				Content: i18n.GetMessage("permission_denied", map[string]interface{}{
+					"role":     role,
+					"resource": param.RoleResourceCommand,
+					"name":     command,
+				}),
+			},
+		}, nil
+	}
+
+	return &callback.CardActionTriggerResponse{
+		Toast: &callback.Toast{
+			Type:    "info",
+			Content: decideToolApproval(ctx, id, userId, command == param.McpApprove),
+		},
+	}, nil
+}
+
+// decideToolApproval handle /mcp_approve <id> and /mcp_reject <id>.
+func (r *RobotInfo) decideToolApproval(approved bool) {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	r.SendMsg(chatId, decideToolApproval(r.Ctx, r.Robot.getPrompt(), userId, approved), msgId, "", nil)
+}
+
+func decideToolApproval(ctx context.Context, idStr, userId string, approved bool) string {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(idStr), "#"), 10, 64)
+	if err != nil {
+		return i18n.GetMessage("mcp_approval_usage", nil)
+	}
+
+	err = llm.DecideToolApproval(id, userId, approved)
+	switch {
+	case errors.Is(err, llm.ErrApprovalNotExist):
+		return i18n.GetMessage("mcp_approval_not_exist", map[string]interface{}{"id": id})
+	case errors.Is(err, llm.ErrApprovalNotOwner):
+		return i18n.GetMessage("mcp_approval_not_owner", map[string]interface{}{"id": id})
+	case err != nil:
+		logger.ErrorCtx(ctx, "decide tool approval fail", "id", id, "err", err)
+		return err.Error()
+	}
+
+	logger.InfoCtx(ctx, "tool approval decided", "id", id, "user", userId, "approved", approved)
+	if approved {
+		return i18n.GetMessage("mcp_approval_approved", map[string]interface{}{"id": id})
+	}
+	return i18n.GetMessage("mcp_approval_rejected", map[string]interface{}{"id": id})
+}
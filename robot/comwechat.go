@@ -307,7 +307,7 @@ func (c *ComWechatRobot) sendVideo() {
 
 func (c *ComWechatRobot) sendChatMessage() {
 	c.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			c.executeChain()
 		} else {
 			c.executeLLM()
@@ -0,0 +1,62 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/robfig/cron/v3"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func init() {
+	llm.AddBuiltinTool(new(createCronTool))
+}
+
+// createCronTool create_cron schedule prompt sent to the current chat like /cron.
+type createCronTool struct{}
+
+func (t *createCronTool) Tool() mcp.Tool {
+	return mcp.NewTool("create_cron",
+		mcp.WithDescription("Schedule a prompt to be executed repeatedly in the current chat, "+
+			"the answer is sent to the chat every time the cron expression matches."),
+		mcp.WithString("cron",
+			mcp.Required(),
+			mcp.Description("second-level cron expression with 6 fields: second minute hour day month weekday, such as 0 0 9 * * *"),
+		),
+		mcp.WithString("prompt",
+			mcp.Required(),
+			mcp.Description("prompt executed at scheduled time"),
+		),
+	)
+}
+
+// Command create_cron is checked by role like /cron of smart mode.
+func (t *createCronTool) Command() string {
+	return param.Cron
+}
+
+func (t *createCronTool) Call(ctx context.Context, l *llm.LLM, arguments map[string]interface{}) (string, error) {
+	r, ok := ctx.Value("robot_info").(*RobotInfo)
+	if !ok || r.Robot == nil {
+		return "", errors.New("cron can only be created in chat")
+	}
+
+	spec, _ := arguments["cron"].(string)
+	prompt, _ := arguments["prompt"].(string)
+	if spec == "" || prompt == "" {
+		return "", errors.New("cron and prompt are required")
+	}
+	if _, err := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow |
+		cron.Descriptor).Parse(spec); err != nil {
+		return "", fmt.Errorf("invalid cron %s: %w", spec, err)
+	}
+
+	err := r.InsertCron(spec, prompt)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("cron %s is created, prompt: %s", spec, prompt), nil
+}
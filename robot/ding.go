@@ -251,7 +251,7 @@ func (d *DingRobot) sendVideo() {
 
 func (d *DingRobot) sendChatMessage() {
 	d.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			d.executeChain()
 		} else {
 			d.executeLLM()
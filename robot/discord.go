@@ -402,6 +402,35 @@ func registerSlashCommands(s *discordgo.Session) {
 		}},
 		{Name: param.CronClear, Description: i18n.GetMessage("commands.cron.description", nil)},
 		{Name: param.CronDel, Description: i18n.GetMessage("commands.cron.description", nil)},
+		{Name: param.NewThread, Description: i18n.GetMessage("commands.new.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Thread name", Required: false},
+		}},
+		{Name: param.Threads, Description: i18n.GetMessage("commands.threads.description", nil)},
+		{Name: param.SwitchTo, Description: i18n.GetMessage("commands.switch.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Thread name", Required: true},
+		}},
+		{Name: param.KB, Description: i18n.GetMessage("commands.kb.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "args", Description: "list or use <name>", Required: false},
+		}},
+		{Name: param.Sources, Description: i18n.GetMessage("commands.sources.description", nil)},
+		{Name: param.Learn, Description: i18n.GetMessage("commands.learn.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "url", Description: "Url of the document", Required: true},
+		}},
+		{Name: param.TaskStatus, Description: i18n.GetMessage("commands.task_status.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "id", Description: "Task run id", Required: false},
+		}},
+		{Name: param.TaskCancel, Description: i18n.GetMessage("commands.task_cancel.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "id", Description: "Task run id", Required: true},
+		}},
+		{Name: param.TaskResume, Description: i18n.GetMessage("commands.task_resume.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "id", Description: "Task run id", Required: true},
+		}},
+		{Name: param.McpApprove, Description: i18n.GetMessage("commands.mcp_approve.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "id", Description: "Approval id", Required: true},
+		}},
+		{Name: param.McpReject, Description: i18n.GetMessage("commands.mcp_reject.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "id", Description: "Approval id", Required: true},
+		}},
 	}
 
 	for _, cmd := range commands {
@@ -426,7 +455,7 @@ func onInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 func (d *DiscordRobot) sendChatMessage() {
 	d.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			d.executeChain()
 		} else {
 			d.executeLLM()
@@ -0,0 +1,212 @@
+package robot
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/rag"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+// sourcesEvictInterval expired sources are evicted at most once per interval
+const sourcesEvictInterval = time.Minute
+
+var (
+	// lastSources session id -> documents retrieved for the last rag answer, expire with context
+	lastSources      = sync.Map{}
+	lastSourcesEvict atomic.Int64
+)
+
+type ragSources struct {
+	docs       []schema.Document
+	updateTime time.Time
+}
+
+// storeSources save documents of the last rag answer of session, empty documents remove it.
+func storeSources(sessionId string, docs []schema.Document) {
+	now := time.Now()
+	evictSources(now)
+	if len(docs) == 0 {
+		lastSources.Delete(sessionId)
+		return
+	}
+	lastSources.Store(sessionId, &ragSources{docs: docs, updateTime: now})
+}
+
+// loadSources get documents of the last rag answer of session, nil if expired.
+func loadSources(sessionId string) []schema.Document {
+	v, ok := lastSources.Load(sessionId)
+	if !ok || sourcesExpired(v.(*ragSources), time.Now()) {
+		return nil
+	}
+	return v.(*ragSources).docs
+}
+
+func sourcesExpired(sources *ragSources, now time.Time) bool {
+	return now.Sub(sources.updateTime) > time.Duration(conf.BaseConfInfo.ContextExpireTime)*time.Second
+}
+
+// evictSources drop sources expired with context, so sessions never asking again don't keep them.
+func evictSources(now time.Time) {
+	last := lastSourcesEvict.Load()
+	if now.UnixNano()-last < int64(sourcesEvictInterval) || !lastSourcesEvict.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	lastSources.Range(func(key, value any) bool {
+		if sourcesExpired(value.(*ragSources), now) {
+			lastSources.Delete(key)
+		}
+		return true
+	})
+}
+
+// getKnowledgeBase get knowledge base bound to current chat, default knowledge base if not bound.
+func (r *RobotInfo) getKnowledgeBase() *conf.KnowledgeBase {
+	chatId, _, _ := r.GetChatIdAndMsgIdAndUserID()
+	name, err := db.GetKBBinding(db.GetKBChatKey(r.GetPlatform(), chatId))
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "get kb binding fail", "err", err)
+	}
+
+	kb := conf.RagConfInfo.GetKnowledgeBase(name)
+	if kb == nil || kb.Store == nil {
+		kb = conf.RagConfInfo.GetKnowledgeBase(conf.DefaultKnowledgeBase)
+	}
+	if kb == nil {
+		kb = &conf.KnowledgeBase{Name: conf.DefaultKnowledgeBase, Store: conf.RagConfInfo.Store}
+	}
+	return kb
+}
+
+// knowledgeBase handle /kb list and /kb use <name>.
+func (r *RobotInfo) knowledgeBase() {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+
+	args := strings.Fields(r.Robot.getPrompt())
+	switch {
+	case len(args) == 0 || args[0] == "list":
+		r.kbList()
+	case args[0] == "use" && len(args) == 2:
+		r.kbUse(args[1])
+	default:
+		r.SendMsg(chatId, i18n.GetMessage("kb_usage", nil), msgId, "", nil)
+	}
+}
+
+func (r *RobotInfo) kbList() {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	current := r.getKnowledgeBase().Name
+
+	names := make([]string, 0, len(conf.RagConfInfo.KBs))
+	for name, kb := range conf.RagConfInfo.KBs {
+		if kb.Store != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	txt := i18n.GetMessage("kb_list_header", nil)
+	for _, name := range names {
+		mark := ""
+		if name == current {
+			mark = "✅"
+		}
+		txt += i18n.GetMessage("kb_list_item", map[string]interface{}{
+			"name": name,
+			"mark": mark,
+		})
+	}
+	r.SendMsg(chatId, txt, msgId, "", nil)
+}
+
+func (r *RobotInfo) kbUse(name string) {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+
+	kb := conf.RagConfInfo.GetKnowledgeBase(name)
+	if kb == nil || kb.Store == nil {
+		r.SendMsg(chatId, i18n.GetMessage("kb_not_exist", map[string]interface{}{
+			"name": name,
+		}), msgId, "", nil)
+		return
+	}
+
+	err := db.UpdateKBBinding(db.GetKBChatKey(r.GetPlatform(), chatId), kb.Name)
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "update kb binding fail", "kb", name, "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, "", nil)
+		return
+	}
+
+	r.SendMsg(chatId, i18n.GetMessage("kb_use_succ", map[string]interface{}{
+		"name": kb.Name,
+	}), msgId, "", nil)
+}
+
+// showSources show snippets used for the last rag answer in current thread.
+func (r *RobotInfo) showSources() {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+
+	docs := loadSources(r.GetSessionId())
+	if len(docs) == 0 {
+		r.SendMsg(chatId, i18n.GetMessage("rag_sources_empty", nil), msgId, "", nil)
+		return
+	}
+
+	r.SendMsg(chatId, rag.FormatSources(docs), msgId, "", nil)
+}
+
+// learn handle /learn <url>, or /learn with document, content is indexed into current knowledge base.
+func (r *RobotInfo) learn() {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+
+	kb := r.getKnowledgeBase()
+	if kb.Store == nil {
+		r.SendMsg(chatId, i18n.GetMessage("learn_no_store", nil), msgId, "", nil)
+		return
+	}
+
+	var (
+		fileName string
+		content  []byte
+		chunks   int
+		err      error
+	)
+	if dr, ok := r.Robot.(DocumentRobot); ok {
+		fileName, content = dr.getDocument()
+	}
+
+	prompt := strings.TrimSpace(r.Robot.getPrompt())
+	switch {
+	case content != nil:
+		uploader := r.GetPlatform() + ":" + userId
+		fileName = rag.LearnUploadName(fileName, uploader)
+		chunks, err = rag.LearnFile(r.Ctx, kb, fileName, content, uploader)
+	case strings.HasPrefix(prompt, "http://") || strings.HasPrefix(prompt, "https://"):
+		fileName, chunks, err = rag.LearnURL(r.Ctx, kb, prompt)
+	default:
+		r.SendMsg(chatId, i18n.GetMessage("learn_usage", nil), msgId, "", nil)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "learn fail", "kb", kb.Name, "file", fileName, "err", err)
+		r.SendMsg(chatId, i18n.GetMessage("learn_fail", map[string]interface{}{
+			"err": err.Error(),
+		}), msgId, "", nil)
+		return
+	}
+
+	r.SendMsg(chatId, i18n.GetMessage("learn_succ", map[string]interface{}{
+		"file_name": fileName,
+		"chunks":    chunks,
+		"kb":        kb.Name,
+	}), msgId, "", nil)
+}
@@ -0,0 +1,37 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestLastSources(t *testing.T) {
+	expireTime := conf.BaseConfInfo.ContextExpireTime
+	conf.BaseConfInfo.ContextExpireTime = 60
+	defer func() {
+		conf.BaseConfInfo.ContextExpireTime = expireTime
+	}()
+
+	docs := []schema.Document{{PageContent: "error E3003 means the token expired"}}
+	storeSources("sources_a", docs)
+	storeSources("sources_b", docs)
+	assert.Equal(t, docs, loadSources("sources_a"))
+
+	// answer without sources removes them
+	storeSources("sources_b", nil)
+	assert.Nil(t, loadSources("sources_b"))
+
+	// sources expired with context are not shown and evicted later
+	v, _ := lastSources.Load("sources_a")
+	v.(*ragSources).updateTime = time.Now().Add(-2 * time.Minute)
+	assert.Nil(t, loadSources("sources_a"))
+
+	lastSourcesEvict.Store(0)
+	evictSources(time.Now())
+	_, ok := lastSources.Load("sources_a")
+	assert.False(t, ok)
+}
@@ -50,7 +50,8 @@ type LarkRobot struct {
 
 func StartLarkRobot(ctx context.Context) {
 	eventHandler := dispatcher.NewEventDispatcher("", "").
-		OnP2MessageReceiveV1(LarkMessageHandler)
+		OnP2MessageReceiveV1(LarkMessageHandler).
+		OnP2CardActionTrigger(LarkCardActionHandler)
 
 	cli = larkws.NewClient(conf.BaseConfInfo.LarkAPPID, conf.BaseConfInfo.LarkAppSecret,
 		larkws.WithEventHandler(eventHandler),
@@ -255,7 +256,7 @@ func (l *LarkRobot) sendVideo() {
 
 func (l *LarkRobot) sendChatMessage() {
 	l.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			l.executeChain()
 		} else {
 			l.executeLLM()
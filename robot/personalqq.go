@@ -235,7 +235,7 @@ func (q *PersonalQQRobot) sendVideo() {
 
 func (q *PersonalQQRobot) sendChatMessage() {
 	q.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			q.executeChain()
 		} else {
 			q.executeLLM()
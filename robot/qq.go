@@ -328,7 +328,7 @@ func (q *QQRobot) sendVideo() {
 
 func (q *QQRobot) sendChatMessage() {
 	q.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			q.executeChain()
 		} else {
 			q.executeLLM()
@@ -0,0 +1,132 @@
+package robot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+// quotaWarned record warning already sent in current period, key is scope:capability:period:start,
+// value is reset time of the period.
+var quotaWarned sync.Map
+
+// markQuotaWarned return true when warning of the period is not sent yet, warnings of passed periods are dropped.
+func markQuotaWarned(warnKey string, resetTime, now time.Time) bool {
+	if _, warned := quotaWarned.LoadOrStore(warnKey, resetTime); warned {
+		return false
+	}
+
+	quotaWarned.Range(func(k, v interface{}) bool {
+		if !v.(time.Time).After(now) {
+			quotaWarned.Delete(k)
+		}
+		return true
+	})
+	return true
+}
+
+// quotaCapability get capability used by current command.
+func (r *RobotInfo) quotaCapability() string {
+	switch strings.TrimLeft(r.Robot.getCommand(), "/$") {
+	case param.Photo, param.EditPhoto:
+		return param.QuotaImage
+	case param.Video:
+		return param.QuotaVideo
+	default:
+		return param.QuotaText
+	}
+}
+
+// checkQuotaExceed check quota plan of user and group, send message when exceed or near limit.
+func (r *RobotInfo) checkQuotaExceed(chatId string, msgId string, userId string) bool {
+	if len(conf.QuotaConfInfo.Plans) == 0 {
+		return false
+	}
+
+	capability := r.quotaCapability()
+
+	planName := conf.QuotaConfInfo.DefaultQuotaPlan
+	if userInfo := db.GetCtxUserInfo(r.Ctx); userInfo != nil && userInfo.Plan != "" {
+		planName = userInfo.Plan
+	}
+	if r.checkPlanExceed(chatId, msgId, userId, "", planName, capability) {
+		return true
+	}
+
+	if conf.QuotaConfInfo.GroupQuotaPlan != "" && chatId != userId && r.checkGroupAllow(chatId) {
+		groupKey := strings.TrimSuffix(db.GetChatKey(r.GetPlatform(), chatId, ""), ":")
+		return r.checkPlanExceed(chatId, msgId, userId, groupKey, conf.QuotaConfInfo.GroupQuotaPlan, capability)
+	}
+
+	return false
+}
+
+func (r *RobotInfo) checkPlanExceed(chatId, msgId, userId, groupKey, planName, capability string) bool {
+	plan, ok := conf.QuotaConfInfo.Plans[planName]
+	if !ok {
+		return false
+	}
+
+	now := time.Now()
+	for _, limit := range plan.Limits {
+		if limit.Limit <= 0 || (limit.Capability != capability && limit.Capability != param.QuotaCost) {
+			continue
+		}
+
+		start := db.QuotaPeriodStart(limit.Period, now)
+		used, err := db.GetQuotaUsage(userId, groupKey, limit.Capability, start.Unix())
+		if err != nil {
+			logger.WarnCtx(r.Ctx, "get quota usage fail", "err", err, "capability", limit.Capability)
+			continue
+		}
+
+		resetTime := db.QuotaResetTime(limit.Period, now)
+		tplData := map[string]interface{}{
+			"plan":       planName,
+			"capability": limit.Capability,
+			"period":     limit.Period,
+			"used":       formatQuota(used),
+			"limit":      formatQuota(limit.Limit),
+			"reset_time": resetTime.Format(time.DateTime),
+		}
+
+		if used >= limit.Limit {
+			logger.WarnCtx(r.Ctx, "quota exceed", "userID", userId, "group", groupKey, "plan", planName,
+				"capability", limit.Capability, "used", used, "limit", limit.Limit)
+			key := "quota_exceed"
+			if groupKey != "" {
+				key = "group_quota_exceed"
+			}
+			r.SendMsg(chatId, i18n.GetMessage(key, tplData), msgId, tgbotapi.ModeMarkdown, nil)
+			return true
+		}
+
+		if conf.QuotaConfInfo.QuotaWarnRatio > 0 && used >= limit.Limit*conf.QuotaConfInfo.QuotaWarnRatio {
+			scope := userId
+			if groupKey != "" {
+				scope = groupKey
+			}
+			warnKey := fmt.Sprintf("%s:%s:%s:%d", scope, limit.Capability, limit.Period, start.Unix())
+			if markQuotaWarned(warnKey, resetTime, now) {
+				r.SendMsg(chatId, i18n.GetMessage("quota_warning", tplData), msgId, tgbotapi.ModeMarkdown, nil)
+			}
+		}
+	}
+
+	return false
+}
+
+func formatQuota(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%.4f", v)
+}
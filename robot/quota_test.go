@@ -0,0 +1,22 @@
+package robot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkQuotaWarned(t *testing.T) {
+	now := time.Now()
+	assert.True(t, markQuotaWarned("warn_u:text:day:1", now.Add(time.Hour), now))
+	assert.False(t, markQuotaWarned("warn_u:text:day:1", now.Add(time.Hour), now))
+
+	// warning of passed period is dropped when next period is warned
+	later := now.Add(2 * time.Hour)
+	assert.True(t, markQuotaWarned("warn_u:text:day:2", later.Add(time.Hour), later))
+	_, ok := quotaWarned.Load("warn_u:text:day:1")
+	assert.False(t, ok)
+	_, ok = quotaWarned.Load("warn_u:text:day:2")
+	assert.True(t, ok)
+}
@@ -0,0 +1,54 @@
+package robot
+
+import (
+	"errors"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+// commandClass get rate limit class of current command: chat photo video mcp.
+func (r *RobotInfo) commandClass() string {
+	switch strings.TrimLeft(r.Robot.getCommand(), "/$") {
+	case param.Photo, param.EditPhoto:
+		return param.Photo
+	case param.Video:
+		return param.Video
+	case param.Mcp, param.Task:
+		return param.Mcp
+	default:
+		return param.Chat
+	}
+}
+
+// checkRateLimit check rate limit of user, group and command class together, tell user when to retry.
+func (r *RobotInfo) checkRateLimit(chatId string, msgId string, userId string) bool {
+	class := r.commandClass()
+
+	checks := []*utils.RateCheck{{Scope: utils.RateLimitUser, Key: userId, Limit: conf.RateLimitConfInfo.GetUser()}}
+	if chatId != userId {
+		checks = append(checks, &utils.RateCheck{Scope: utils.RateLimitGroup, Key: chatId, Limit: conf.RateLimitConfInfo.GetGroup()})
+	}
+	checks = append(checks, &utils.RateCheck{Scope: utils.RateLimitCommand, Name: class, Key: class + ":" + userId,
+		Limit: conf.RateLimitConfInfo.GetCommand(class)})
+	err := utils.AllowRates(checks...)
+	if err == nil {
+		return false
+	}
+
+	var rateErr *utils.RateLimitError
+	if errors.As(err, &rateErr) {
+		logger.WarnCtx(r.Ctx, "rate limit exceed", "userID", userId, "chatId", chatId, "scope", rateErr.Scope,
+			"class", class, "wait", rateErr.Wait)
+		r.SendMsg(chatId, i18n.GetMessage("rate_limit_exceed", map[string]interface{}{
+			"scope":       rateErr.Scope,
+			"retry_after": rateErr.RetryAfter(),
+		}), msgId, tgbotapi.ModeMarkdown, nil)
+	}
+	return true
+}
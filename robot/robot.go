@@ -7,12 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
-	"regexp"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ArtisanCloud/PowerWeChat/v3/src/kernel/messages"
 	"github.com/ArtisanCloud/PowerWeChat/v3/src/work/message/request"
@@ -31,19 +32,19 @@ import (
 	"github.com/yincongcyincong/MuseBot/llm"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
 	"github.com/yincongcyincong/MuseBot/rag"
 	"github.com/yincongcyincong/MuseBot/utils"
 	"github.com/yincongcyincong/langchaingo/chains"
-	"github.com/yincongcyincong/langchaingo/vectorstores"
 )
 
 const (
-	AudioMsgLen = 600
+	AudioMsgLen      = 600
+	MaxThreadNameLen = 64
 )
 
 var (
-	smartModeReg = regexp.MustCompile(`\{\s*(?:"cron"\s*:\s*".*?"\s*,\s*"prompt"\s*:\s*".*?"\s*,\s*)?"command"\s*:\s*".*?"\s*\}`)
-	Cron         *cron.Cron
+	Cron *cron.Cron
 )
 
 type MsgChan struct {
@@ -114,6 +115,11 @@ type StreamRobot interface {
 	sendTextStream(messageChan *MsgChan)
 }
 
+// DocumentRobot robot which can receive document, content is nil if there is no document.
+type DocumentRobot interface {
+	getDocument() (string, []byte)
+}
+
 type botOption func(r *RobotInfo)
 
 func NewRobot(options ...botOption) *RobotInfo {
@@ -136,6 +142,8 @@ func NewRobot(options ...botOption) *RobotInfo {
 	if ctx.Value("log_id") == nil {
 		ctx = context.WithValue(ctx, "log_id", uuid.New().String())
 	}
+	// robot posts approval prompt of tool calls made in this request
+	ctx = context.WithValue(ctx, "robot_info", r)
 
 	r.Ctx = ctx
 	r.Cancel = cancel
@@ -158,7 +166,7 @@ func (r *RobotInfo) Exec() {
 }
 
 func (r *RobotInfo) AddUserInfo() bool {
-	_, _, userId := r.GetChatIdAndMsgIdAndUserID()
+	chatId, _, userId := r.GetChatIdAndMsgIdAndUserID()
 	userInfo, err := db.GetUserByID(userId)
 	if err != nil {
 		logger.ErrorCtx(r.Ctx, "addUserInfo GetUserByID err", "err", err)
@@ -182,6 +190,7 @@ func (r *RobotInfo) AddUserInfo() bool {
 	if userInfo.LLMConfigRaw == nil {
 		userInfo.LLMConfigRaw = new(param.LLMConfig)
 	}
+	userInfo.Role = getRole(chatId, userId)
 
 	r.Ctx = context.WithValue(r.Ctx, "user_info", userInfo)
 	return true
@@ -317,6 +326,47 @@ func (r *RobotInfo) GetChatIdAndMsgIdAndUserID() (string, string, string) {
 	return chatId, msgId, userId
 }
 
+// GetPlatform get platform name of robot.
+func (r *RobotInfo) GetPlatform() string {
+	switch r.Robot.(type) {
+	case *TelegramRobot:
+		return param.Telegram
+	case *DiscordRobot:
+		return param.Discord
+	case *SlackRobot:
+		return param.Slack
+	case *LarkRobot:
+		return param.Lark
+	case *DingRobot:
+		return param.Ding
+	case *ComWechatRobot:
+		return param.ComWechat
+	case *QQRobot:
+		return param.QQ
+	case *WechatRobot:
+		return param.Wechat
+	case *PersonalQQRobot:
+		return param.PersonalQQ
+	case *Web:
+		return param.Web
+	}
+	return ""
+}
+
+// GetChatKey get key of current chat, conversation is isolated by bot, platform, chat and user.
+func (r *RobotInfo) GetChatKey() string {
+	chatId, _, userId := r.GetChatIdAndMsgIdAndUserID()
+	return db.GetChatKey(r.GetPlatform(), chatId, userId)
+}
+
+// GetSessionId get session id of the active thread in current chat.
+func (r *RobotInfo) GetSessionId() string {
+	if r.cs.SessionId == "" {
+		r.cs.SessionId = db.GetActiveSessionId(r.GetChatKey())
+	}
+	return r.cs.SessionId
+}
+
 func (r *RobotInfo) SendMsg(chatId string, msgContent string, replyToMessageID string,
 	mode string, inlineKeyboard *tgbotapi.InlineKeyboardMarkup) string {
 	switch r.Robot.(type) {
@@ -651,26 +701,36 @@ func (r *RobotInfo) GetAudioContent(audioContent []byte) (string, error) {
 	var answer string
 	var err error
 	var token = param.AudioTokenUsage
-	llmConf := db.GetCtxUserInfo(r.Ctx).LLMConfigRaw
-	t := utils.GetRecType(llmConf)
-	logger.InfoCtx(r.Ctx, "recognize audio", "type", t, "model", utils.GetUsingRecModel(t, llmConf.RecModel))
-	switch t {
-	case param.Vol:
-		answer, err = utils.FileRecognize(audioContent)
-	case param.OpenAi:
-		answer, err = llm.GenerateOpenAIText(r.Ctx, audioContent)
-	case param.Gemini:
-		answer, token, err = llm.GenerateGeminiText(r.Ctx, audioContent)
-	case param.Aliyun:
-		answer, token, err = llm.GenerateAliyunText(r.Ctx, audioContent)
-	}
+	var cost float64
+	_, _, err = r.fallback(provider.Rec, utils.GetRecType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw),
+		func(ctx context.Context, t string) error {
+			model := utils.GetUsingRecModel(t, db.GetCtxUserInfo(ctx).LLMConfigRaw.RecModel)
+			logger.InfoCtx(ctx, "recognize audio", "type", t, "model", model)
+			var err error
+			switch t {
+			case param.Vol:
+				answer, err = utils.FileRecognize(audioContent)
+			case param.OpenAi:
+				answer, err = llm.GenerateOpenAIText(ctx, audioContent)
+			case param.Gemini:
+				answer, token, err = llm.GenerateGeminiText(ctx, audioContent)
+			case param.Aliyun:
+				answer, token, err = llm.GenerateAliyunText(ctx, audioContent)
+			default:
+				if p := provider.Get(t); p != nil && p.Custom {
+					answer, err = llm.GenerateOpenAIText(ctx, audioContent)
+				}
+			}
+			cost = provider.Cost(t, model, provider.Usage{PromptToken: token})
+			return err
+		})
 
 	if err != nil {
 		return "", err
 	}
 
 	_, _, userId := r.GetChatIdAndMsgIdAndUserID()
-	err = db.AddRecordToken(r.Ctx, r.cs.RecordID, userId, token)
+	err = db.AddRecordToken(r.Ctx, r.cs.RecordID, userId, token, cost)
 	if err != nil {
 		logger.WarnCtx(r.Ctx, "addRecordToken err", "err", err)
 	}
@@ -682,6 +742,36 @@ func (r *RobotInfo) GetAudioContent(audioContent []byte) (string, error) {
 	return answer, err
 }
 
+// fallback call fn with provider t and fallback providers of capability, user is told when answer is from fallback provider.
+func (r *RobotInfo) fallback(c provider.Capability, t string,
+	fn func(ctx context.Context, t string) error) (context.Context, []string, error) {
+	ctx, fallbacks, err := llm.Fallback(r.Ctx, c, t, fn)
+	if err == nil && len(fallbacks) > 0 {
+		chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+		r.SendMsg(chatId, i18n.GetMessage("fallback_provider_notice", map[string]interface{}{
+			"from": fallbacks[0],
+			"to":   ctxProvider(ctx, c),
+		}), msgId, "", nil)
+	}
+	return ctx, fallbacks, err
+}
+
+// ctxProvider get provider of capability in ctx llm config.
+func ctxProvider(ctx context.Context, c provider.Capability) string {
+	llmConf := db.GetCtxUserInfo(ctx).LLMConfigRaw
+	switch c {
+	case provider.Image:
+		return utils.GetImgType(llmConf)
+	case provider.Video:
+		return utils.GetVideoType(llmConf)
+	case provider.Rec:
+		return utils.GetRecType(llmConf)
+	case provider.TTS:
+		return utils.GetTTSType(llmConf)
+	}
+	return utils.GetTxtType(llmConf)
+}
+
 func (r *RobotInfo) GetLastImageContent() ([]byte, error) {
 	_, _, userID := r.GetChatIdAndMsgIdAndUserID()
 	imageInfo, err := db.GetLastImageRecord(userID)
@@ -725,7 +815,17 @@ func (r *RobotInfo) TalkingPreCheck(f func()) {
 		return
 	}
 
-	defer utils.DecreaseUserChat(userId)
+	if r.checkQuotaExceed(chatId, msgId, userId) {
+		return
+	}
+
+	if !r.checkModelRole() {
+		return
+	}
+
+	if r.checkRateLimit(chatId, msgId, userId) {
+		return
+	}
 
 	// check user chat exceed max count
 	if utils.CheckUserChatExceed(userId) {
@@ -733,6 +833,7 @@ func (r *RobotInfo) TalkingPreCheck(f func()) {
 			msgId, tgbotapi.ModeMarkdown, nil)
 		return
 	}
+	defer utils.DecreaseUserChat(userId)
 
 	f()
 }
@@ -753,6 +854,10 @@ type RobotModel struct {
 func (r *RobotInfo) handleModelUpdate(rm *RobotModel) {
 	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
 
+	if !r.checkModelUpdateRole(rm) {
+		return
+	}
+
 	userInfo := db.GetCtxUserInfo(r.Ctx)
 	if userInfo != nil && userInfo.ID != 0 {
 		llmConf := userInfo.LLMConfigRaw
@@ -823,6 +928,10 @@ func (r *RobotInfo) ExecCmd(cmd string, defaultFunc func(), modeFunc func(string
 	_, _, userID := r.GetChatIdAndMsgIdAndUserID()
 	logger.InfoCtx(r.Ctx, "command info", "userID", userID, "cmd", cmd)
 
+	if !r.checkCommandRole(cmd) {
+		return
+	}
+
 	switch cmd {
 	case param.State, "/" + param.State, "$" + param.State:
 		r.showStateInfo()
@@ -875,6 +984,28 @@ func (r *RobotInfo) ExecCmd(cmd string, defaultFunc func(), modeFunc func(string
 		r.cronDel()
 	case param.CronClear, "/" + param.CronClear, "$" + param.CronClear:
 		r.cronClear()
+	case param.NewThread, "/" + param.NewThread, "$" + param.NewThread:
+		r.newThread()
+	case param.Threads, "/" + param.Threads, "$" + param.Threads:
+		r.showThreads()
+	case param.SwitchTo, "/" + param.SwitchTo, "$" + param.SwitchTo:
+		r.switchThread()
+	case param.KB, "/" + param.KB, "$" + param.KB:
+		r.knowledgeBase()
+	case param.Sources, "/" + param.Sources, "$" + param.Sources:
+		r.showSources()
+	case param.Learn, "/" + param.Learn, "$" + param.Learn:
+		r.learn()
+	case param.TaskStatus, "/" + param.TaskStatus, "$" + param.TaskStatus:
+		r.taskStatus()
+	case param.TaskCancel, "/" + param.TaskCancel, "$" + param.TaskCancel:
+		r.taskCancel()
+	case param.TaskResume, "/" + param.TaskResume, "$" + param.TaskResume:
+		r.taskResume()
+	case param.McpApprove, "/" + param.McpApprove, "$" + param.McpApprove:
+		r.decideToolApproval(true)
+	case param.McpReject, "/" + param.McpReject, "$" + param.McpReject:
+		r.decideToolApproval(false)
 	default:
 		defaultFunc()
 	}
@@ -1022,253 +1153,59 @@ func (r *RobotInfo) changeModel(ty string) {
 			r.handleModelUpdate(&RobotModel{TxtModel: r.Robot.getPrompt()})
 			return
 		}
-		r.showTxtModel()
+		r.showModels(provider.Text, utils.GetTxtType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw), ty)
 	case param.PhotoModel, "/" + param.PhotoModel, "$" + param.PhotoModel:
 		if r.Robot.getPrompt() != "" {
 			r.handleModelUpdate(&RobotModel{ImgModel: r.Robot.getPrompt()})
 			return
 		}
-		r.showImageModel()
+		r.showModels(provider.Image, utils.GetImgType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw), ty)
 	case param.VideoModel, "/" + param.VideoModel, "$" + param.VideoModel:
 		if r.Robot.getPrompt() != "" {
 			r.handleModelUpdate(&RobotModel{VideoModel: r.Robot.getPrompt()})
 			return
 		}
-		r.showVideoModel()
+		r.showModels(provider.Video, utils.GetVideoType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw), ty)
 	case param.RecModel, "/" + param.RecModel, "$" + param.RecModel:
 		if r.Robot.getPrompt() != "" {
 			r.handleModelUpdate(&RobotModel{RecModel: r.Robot.getPrompt()})
 			return
 		}
-		r.showRecModel()
+		r.showModels(provider.Rec, utils.GetRecType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw), ty)
 	case param.TtsModel, "/" + param.TtsModel, "$" + param.TtsModel:
 		if r.Robot.getPrompt() != "" {
 			r.handleModelUpdate(&RobotModel{TTSModel: r.Robot.getPrompt()})
 			return
 		}
-		r.showTTSModel()
+		r.showModels(provider.TTS, utils.GetTTSType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw), ty)
 	}
 
 }
 
-func (r *RobotInfo) showTxtModel() {
+// showModels send selectable models of provider, or the link to find models if provider accept any model.
+func (r *RobotInfo) showModels(c provider.Capability, ty string, command string) {
 	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
-	var modelList []string
-
-	switch utils.GetTxtType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-	case param.DeepSeek:
-		for k := range param.DeepseekModels {
-			modelList = append(modelList, k)
-		}
-	case param.Gemini:
-		for k := range param.GeminiModels {
-			modelList = append(modelList, k)
-		}
-	case param.Aliyun:
-		for k := range param.AliyunModel {
-			modelList = append(modelList, k)
-		}
-	case param.OpenRouter, param.AI302, param.Ollama, param.OpenAi:
-		switch utils.GetTxtType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-		case param.OpenAi:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://platform.openai.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.AI302:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://302.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.OpenRouter:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://openrouter.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.Ollama:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://ollama.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		}
-
+	p := provider.Get(ty)
+	if p == nil {
 		return
-	case param.Vol:
-		for k := range param.VolModels {
-			modelList = append(modelList, k)
-		}
-	}
-	totalContent := ""
-	for _, model := range modelList {
-		totalContent += fmt.Sprintf(`%s
-
-`, model)
 	}
 
-	r.SendMsg(chatId, totalContent, msgId, "", nil)
-}
-
-func (r *RobotInfo) showImageModel() {
-	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
-	var modelList []string
-
-	switch utils.GetTxtType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiImageModels {
-			modelList = append(modelList, k)
-		}
-	case param.Aliyun:
-		for k := range param.AliyunImageModels {
-			modelList = append(modelList, k)
-		}
-	case param.OpenRouter, param.AI302, param.Ollama, param.OpenAi:
-		switch utils.GetTxtType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-		case param.OpenAi:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://platform.openai.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.AI302:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://302.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.OpenRouter:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://openrouter.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.Ollama:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://ollama.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		}
+	models := p.Models[c]
+	if len(models) == 0 && p.Link != "" {
+		r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
+			"link":    p.Link,
+			"command": command,
+		}),
+			msgId, tgbotapi.ModeMarkdown, nil)
 		return
-	case param.Vol:
-		for k := range param.VolImageModels {
-			modelList = append(modelList, k)
-		}
 	}
-	totalContent := ""
-	for _, model := range modelList {
-		totalContent += fmt.Sprintf(`%s
-
-`, model)
+	// provider accept any model, show the default one
+	if len(models) == 0 && p.DefaultModels[c] != "" {
+		models = []string{p.DefaultModels[c]}
 	}
 
-	r.SendMsg(chatId, totalContent, msgId, "", nil)
-}
-
-func (r *RobotInfo) showVideoModel() {
-	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
-	var modelList []string
-
-	switch utils.GetTxtType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiVideoModels {
-			modelList = append(modelList, k)
-		}
-	case param.Aliyun:
-		for k := range param.AliyunVideoModels {
-			modelList = append(modelList, k)
-		}
-	case param.AI302:
-		switch utils.GetTxtType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-		case param.AI302:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://302.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		case param.Vol:
-			for k := range param.VolVideoModels {
-				modelList = append(modelList, k)
-			}
-		}
-	}
 	totalContent := ""
-	for _, model := range modelList {
-		totalContent += fmt.Sprintf(`%s
-
-`, model)
-	}
-
-	r.SendMsg(chatId, totalContent, msgId, "", nil)
-}
-
-func (r *RobotInfo) showRecModel() {
-	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
-	var modelList []string
-
-	switch utils.GetRecType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiRecModels {
-			modelList = append(modelList, k)
-		}
-	case param.Aliyun:
-		for k := range param.AliyunRecModels {
-			modelList = append(modelList, k)
-		}
-	case param.Vol:
-		for k := range param.VolRecModels {
-			modelList = append(modelList, k)
-		}
-	case param.AI302, param.OpenAi:
-		switch utils.GetRecType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-		case param.AI302:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://302.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		case param.OpenAi:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://platform.openai.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		}
-	}
-	totalContent := ""
-	for _, model := range modelList {
-		totalContent += fmt.Sprintf(`%s
-
-`, model)
-	}
-
-	r.SendMsg(chatId, totalContent, msgId, "", nil)
-}
-
-func (r *RobotInfo) showTTSModel() {
-	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
-	var modelList []string
-
-	switch utils.GetTTSType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiTTSModels {
-			modelList = append(modelList, k)
-		}
-	case param.Aliyun:
-		for k := range param.AliyunTTSModels {
-			modelList = append(modelList, k)
-		}
-	case param.Vol:
-		for k := range param.VolTTSModels {
-			modelList = append(modelList, k)
-		}
-	case param.OpenAi:
-		switch utils.GetTTSType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw) {
-		case param.OpenAi:
-			r.SendMsg(chatId, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://platform.openai.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		}
-	}
-	totalContent := ""
-	for _, model := range modelList {
+	for _, model := range models {
 		totalContent += fmt.Sprintf(`%s
 
 `, model)
@@ -1281,10 +1218,15 @@ func (r *RobotInfo) ExecChain(msgContent string, msgChan *MsgChan) {
 	r.TalkingPreCheck(func() {
 		chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
 
+		fallback := false
 		defer func() {
 			if err := recover(); err != nil {
 				logger.ErrorCtx(r.Ctx, "panic", "err", err, "stack", string(debug.Stack()))
 			}
+			// ExecLLM closes channels itself
+			if fallback {
+				return
+			}
 			if msgChan.NormalMessageChan != nil {
 				close(msgChan.NormalMessageChan)
 			}
@@ -1303,12 +1245,12 @@ func (r *RobotInfo) ExecChain(msgContent string, msgChan *MsgChan) {
 			return
 		}
 
-		r.InsertRecord()
 		perMsgLen := r.Robot.getPerMsgLen()
 		if conf.AudioConfInfo.TTSType != "" {
 			perMsgLen = AudioMsgLen
 		}
 
+		kb := r.getKnowledgeBase()
 		dpLLM := rag.NewRag(
 			llm.WithMessageChan(msgChan.NormalMessageChan),
 			llm.WithHTTPMsgChan(msgChan.StrMessageChan),
@@ -1317,15 +1259,29 @@ func (r *RobotInfo) ExecChain(msgContent string, msgChan *MsgChan) {
 			llm.WithUserId(userId),
 			llm.WithPerMsgLen(perMsgLen),
 			llm.WithCS(r.cs),
+			llm.WithContext(r.Ctx),
 		)
+		dpLLM.KB = kb
+		dpLLM.Store = kb.Store
+
+		docs, err := dpLLM.Retrieve(ctx, content)
+		if err != nil || len(docs) == 0 {
+			logger.InfoCtx(r.Ctx, "no relevant doc, fallback to llm", "kb", kb.Name, "err", err)
+			fallback = true
+			r.ExecLLM(msgContent, msgChan)
+			return
+		}
+
+		r.InsertRecord()
 		qaChain := chains.NewRetrievalQAFromLLM(
 			dpLLM,
-			vectorstores.ToRetriever(conf.RagConfInfo.Store, 3),
+			dpLLM.Retriever(),
 		)
-		_, err := chains.Run(ctx, qaChain, content)
+		_, err = chains.Run(ctx, qaChain, content)
 		if err != nil {
 			r.SendMsg(chatId, err.Error(), msgId, "", nil)
 		}
+		storeSources(r.GetSessionId(), dpLLM.Sources)
 	})
 }
 
@@ -1385,6 +1341,7 @@ func (r *RobotInfo) ExecLLM(msgContent string, msgChan *MsgChan) {
 			GeminiTools:  conf.GeminiTools,
 		}),
 		llm.WithImages(images),
+		llm.WithKnowledgeBase(r.getKnowledgeBase().Name),
 	)
 
 	err := llmClient.CallLLM()
@@ -1393,6 +1350,14 @@ func (r *RobotInfo) ExecLLM(msgContent string, msgChan *MsgChan) {
 		r.SendMsg(chatId, err.Error(), msgId, "", nil)
 	}
 
+	// documents searched by search_knowledge_base tool
+	if len(llmClient.Sources) > 0 {
+		if citations := rag.FormatCitations(llmClient.Sources); citations != "" {
+			llmClient.DirectSendMsg(citations, true)
+		}
+	}
+	storeSources(r.GetSessionId(), llmClient.Sources)
+
 }
 
 func (r *RobotInfo) showStateInfo() {
@@ -1428,13 +1393,46 @@ func (r *RobotInfo) showStateInfo() {
 
 	template := i18n.GetMessage("state_content", nil)
 	msgContent := fmt.Sprintf(template, userInfo.Token, todayTokey, weekToken, monthToken)
+
+	if len(conf.ProviderConfInfo.Prices) > 0 {
+		todayCost, err := db.GetCostByUserIdAndTime(userId, startOfDay.Unix(), endOfDay.Unix())
+		if err != nil {
+			logger.WarnCtx(r.Ctx, "get today cost fail", "err", err)
+		}
+		weekCost, err := db.GetCostByUserIdAndTime(userId, startOf7DaysAgo.Unix(), endOfDay.Unix())
+		if err != nil {
+			logger.WarnCtx(r.Ctx, "get week cost fail", "err", err)
+		}
+		monthCost, err := db.GetCostByUserIdAndTime(userId, startOf30DaysAgo.Unix(), endOfDay.Unix())
+		if err != nil {
+			logger.WarnCtx(r.Ctx, "get month cost fail", "err", err)
+		}
+		msgContent += i18n.GetMessage("state_cost", map[string]interface{}{
+			"total":    fmt.Sprintf("%.4f", userInfo.Cost),
+			"today":    fmt.Sprintf("%.4f", todayCost),
+			"week":     fmt.Sprintf("%.4f", weekCost),
+			"month":    fmt.Sprintf("%.4f", monthCost),
+			"currency": conf.ProviderConfInfo.Currency,
+		})
+	}
+
+	summary, err := db.GetSummary(r.GetSessionId())
+	if err != nil {
+		logger.WarnCtx(r.Ctx, "get summary fail", "err", err)
+	}
+	if summary != nil {
+		msgContent += i18n.GetMessage("state_summary", map[string]interface{}{
+			"time":    time.Unix(summary.UpdateTime, 0).Format(time.DateTime),
+			"summary": summary.Content,
+		})
+	}
 	r.SendMsg(chatId, msgContent, msgId, tgbotapi.ModeMarkdown, nil)
 
 }
 
 func (r *RobotInfo) clearAllRecord() {
-	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
-	db.DeleteMsgRecord(r.Ctx, userId)
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	db.DeleteMsgRecord(r.Ctx, r.GetSessionId())
 	deleteSuccMsg := i18n.GetMessage("delete_succ", nil)
 	r.SendMsg(chatId, deleteSuccMsg,
 		msgId, tgbotapi.ModeMarkdown, nil)
@@ -1443,9 +1441,9 @@ func (r *RobotInfo) clearAllRecord() {
 }
 
 func (r *RobotInfo) retryLastQuestion() {
-	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
 
-	records := db.GetMsgRecord(userId)
+	records := db.GetMsgRecord(r.Ctx, r.GetSessionId())
 	if records != nil && len(records.AQs) > 0 {
 		r.Robot.requestLLM(records.AQs[len(records.AQs)-1].Question)
 	} else {
@@ -1457,6 +1455,115 @@ func (r *RobotInfo) retryLastQuestion() {
 
 }
 
+func (r *RobotInfo) newThread() {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	chatKey := r.GetChatKey()
+
+	name := strings.TrimSpace(r.Robot.getPrompt())
+	if name == "" {
+		name = time.Now().Format("20060102150405")
+	}
+	if len([]rune(name)) > MaxThreadNameLen {
+		name = string([]rune(name)[:MaxThreadNameLen])
+	}
+
+	for _, thread := range []string{param.DefaultThread, name} {
+		err := db.InsertSession(chatKey, thread)
+		if err != nil {
+			logger.ErrorCtx(r.Ctx, "insert session fail", "thread", thread, "err", err)
+			r.SendMsg(chatId, err.Error(), msgId, "", nil)
+			return
+		}
+	}
+
+	r.changeThread(name)
+}
+
+func (r *RobotInfo) switchThread() {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	chatKey := r.GetChatKey()
+
+	name := strings.TrimSpace(r.Robot.getPrompt())
+	if name == "" {
+		r.SendMsg(chatId, i18n.GetMessage("thread_empty_name", nil), msgId, "", nil)
+		return
+	}
+
+	if name == param.DefaultThread {
+		err := db.InsertSession(chatKey, param.DefaultThread)
+		if err != nil {
+			logger.ErrorCtx(r.Ctx, "insert session fail", "thread", name, "err", err)
+			r.SendMsg(chatId, err.Error(), msgId, "", nil)
+			return
+		}
+	}
+
+	session, err := db.GetSessionByName(chatKey, name)
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "get session fail", "thread", name, "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, "", nil)
+		return
+	}
+	if session == nil {
+		r.SendMsg(chatId, i18n.GetMessage("thread_not_exist", map[string]interface{}{
+			"name": name,
+		}), msgId, "", nil)
+		return
+	}
+
+	r.changeThread(name)
+}
+
+func (r *RobotInfo) changeThread(name string) {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	chatKey := r.GetChatKey()
+
+	err := db.SwitchSession(chatKey, name)
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "switch session fail", "thread", name, "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, "", nil)
+		return
+	}
+	r.cs.SessionId = db.GetSessionId(chatKey, name)
+
+	r.SendMsg(chatId, i18n.GetMessage("thread_switch_succ", map[string]interface{}{
+		"name": name,
+	}), msgId, "", nil)
+}
+
+func (r *RobotInfo) showThreads() {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	chatKey := r.GetChatKey()
+
+	sessions, err := db.GetSessionsByChatKey(chatKey)
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "get sessions fail", "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, "", nil)
+		return
+	}
+
+	names := make([]string, 0, len(sessions)+1)
+	if len(sessions) == 0 {
+		names = append(names, param.DefaultThread)
+	}
+	for _, session := range sessions {
+		names = append(names, session.Name)
+	}
+
+	active := db.GetActiveThread(chatKey)
+	txt := i18n.GetMessage("thread_list_header", nil)
+	for _, name := range names {
+		key := "thread_list_item"
+		if name == active {
+			key = "thread_list_active_item"
+		}
+		txt += i18n.GetMessage(key, map[string]interface{}{
+			"name": name,
+		})
+	}
+	r.SendMsg(chatId, txt, msgId, "", nil)
+}
+
 func (r *RobotInfo) sendMultiAgent(agentType string, emptyPromptFunc func()) {
 	r.TalkingPreCheck(func() {
 		chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
@@ -1473,6 +1580,8 @@ func (r *RobotInfo) sendMultiAgent(agentType string, emptyPromptFunc func()) {
 			return
 		}
 
+		// resolve session before agent read conversation history
+		r.GetSessionId()
 		dpReq := &llm.LLMTaskReq{
 			Content:   prompt,
 			UserId:    userId,
@@ -1482,44 +1591,49 @@ func (r *RobotInfo) sendMultiAgent(agentType string, emptyPromptFunc func()) {
 			Cs:        r.cs,
 			Ctx:       r.Ctx,
 		}
+		r.execMultiAgent(dpReq, agentType)
+	})
+}
 
-		if _, ok := r.Robot.(*QQRobot); ok {
-			dpReq.HTTPMsgChan = make(chan string)
-		} else {
-			dpReq.MessageChan = make(chan *param.MsgInfo)
-		}
-
-		go func() {
-			defer func() {
-				if err := recover(); err != nil {
-					logger.ErrorCtx(r.Ctx, "multi agent panic", "err", err, "stack", string(debug.Stack()))
-				}
-				if dpReq.HTTPMsgChan != nil {
-					close(dpReq.HTTPMsgChan)
-				}
-				if dpReq.MessageChan != nil {
-					close(dpReq.MessageChan)
-				}
-			}()
+// execMultiAgent execute task or mcp request and send its messages to chat.
+func (r *RobotInfo) execMultiAgent(dpReq *llm.LLMTaskReq, agentType string) {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	if _, ok := r.Robot.(*QQRobot); ok {
+		dpReq.HTTPMsgChan = make(chan string)
+	} else {
+		dpReq.MessageChan = make(chan *param.MsgInfo)
+	}
 
-			var err error
-			if agentType == "mcp_empty_content" {
-				err = dpReq.ExecuteMcp()
-			} else {
-				err = dpReq.ExecuteTask()
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.ErrorCtx(r.Ctx, "multi agent panic", "err", err, "stack", string(debug.Stack()))
 			}
-			if err != nil {
-				logger.WarnCtx(r.Ctx, "execute task fail", "err", err)
-				r.SendMsg(chatId, err.Error(), msgId, tgbotapi.ModeMarkdown, nil)
-				return
+			if dpReq.HTTPMsgChan != nil {
+				close(dpReq.HTTPMsgChan)
+			}
+			if dpReq.MessageChan != nil {
+				close(dpReq.MessageChan)
 			}
 		}()
 
-		go r.HandleUpdate(&MsgChan{
-			NormalMessageChan: dpReq.MessageChan,
-			StrMessageChan:    dpReq.HTTPMsgChan,
-		}, "")
-	})
+		var err error
+		if agentType == "mcp_empty_content" {
+			err = dpReq.ExecuteMcp()
+		} else {
+			err = dpReq.ExecuteTask()
+		}
+		if err != nil {
+			logger.WarnCtx(r.Ctx, "execute task fail", "err", err)
+			r.SendMsg(chatId, err.Error(), msgId, tgbotapi.ModeMarkdown, nil)
+			return
+		}
+	}()
+
+	go r.HandleUpdate(&MsgChan{
+		NormalMessageChan: dpReq.MessageChan,
+		StrMessageChan:    dpReq.HTTPMsgChan,
+	}, "")
 }
 
 func (r *RobotInfo) CreatePhoto(prompt string, lastImageContent []byte) ([]byte, int, error) {
@@ -1528,24 +1642,34 @@ func (r *RobotInfo) CreatePhoto(prompt string, lastImageContent []byte) ([]byte,
 	var imageContent []byte
 	var totalToken int
 	var err error
-	llmConf := db.GetCtxUserInfo(r.Ctx).LLMConfigRaw
-	mediaType := utils.GetImgType(llmConf)
-	logger.InfoCtx(r.Ctx, "create image", "mediaType", mediaType, "mediaModel",
-		utils.GetUsingRecModel(mediaType, llmConf.ImgModel), "lastImageContent", len(lastImageContent), "prompt", prompt)
-	switch mediaType {
-	case param.Vol:
-		imageUrl, totalToken, err = llm.GenerateVolImg(r.Ctx, prompt, lastImageContent)
-	case param.OpenAi, param.ChatAnyWhere:
-		imageContent, totalToken, err = llm.GenerateOpenAIImg(r.Ctx, prompt, lastImageContent)
-	case param.Gemini:
-		imageContent, totalToken, err = llm.GenerateGeminiImg(r.Ctx, prompt, lastImageContent)
-	case param.AI302, param.OpenRouter:
-		imageContent, totalToken, err = llm.GenerateMixImg(r.Ctx, prompt, lastImageContent)
-	case param.Aliyun:
-		imageUrl, totalToken, err = llm.GenerateAliyunImg(r.Ctx, prompt, lastImageContent)
-	default:
-		err = fmt.Errorf("unsupported media type: %s", conf.BaseConfInfo.MediaType)
-	}
+	ctx, fallbacks, err := r.fallback(provider.Image, utils.GetImgType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw),
+		func(ctx context.Context, mediaType string) error {
+			logger.InfoCtx(ctx, "create image", "mediaType", mediaType, "mediaModel",
+				utils.GetUsingImgModel(mediaType, db.GetCtxUserInfo(ctx).LLMConfigRaw.ImgModel),
+				"lastImageContent", len(lastImageContent), "prompt", prompt)
+			var err error
+			switch mediaType {
+			case param.Vol:
+				imageUrl, totalToken, err = llm.GenerateVolImg(ctx, prompt, lastImageContent)
+			case param.OpenAi, param.ChatAnyWhere:
+				imageContent, totalToken, err = llm.GenerateOpenAIImg(ctx, prompt, lastImageContent)
+			case param.Gemini:
+				imageContent, totalToken, err = llm.GenerateGeminiImg(ctx, prompt, lastImageContent)
+			case param.AI302, param.OpenRouter:
+				imageContent, totalToken, err = llm.GenerateMixImg(ctx, prompt, lastImageContent)
+			case param.Aliyun:
+				imageUrl, totalToken, err = llm.GenerateAliyunImg(ctx, prompt, lastImageContent)
+			default:
+				if p := provider.Get(mediaType); p != nil && p.Custom {
+					imageContent, totalToken, err = llm.GenerateOpenAIImg(ctx, prompt, lastImageContent)
+					break
+				}
+				err = fmt.Errorf("unsupported media type: %s", mediaType)
+			}
+			return err
+		})
+	r.Ctx = ctx
+	r.cs.Fallbacks = fallbacks
 
 	if err != nil {
 		logger.ErrorCtx(r.Ctx, "generate image fail", "err", err)
@@ -1568,22 +1692,29 @@ func (r *RobotInfo) CreateVideo(prompt string, lastImageContent []byte) ([]byte,
 	var videoContent []byte
 	var err error
 	var totalToken int
-	llmConf := db.GetCtxUserInfo(r.Ctx).LLMConfigRaw
-	mediaType := utils.GetVideoType(llmConf)
-	logger.InfoCtx(r.Ctx, "create video", "mediaType", mediaType, "mediaModel",
-		utils.GetUsingVideoModel(mediaType, llmConf.VideoModel), "lastImageContent", len(lastImageContent), "prompt", prompt)
-	switch mediaType {
-	case param.Vol:
-		videoUrl, totalToken, err = llm.GenerateVolVideo(r.Ctx, prompt, lastImageContent)
-	case param.Gemini:
-		videoContent, totalToken, err = llm.GenerateGeminiVideo(r.Ctx, prompt, lastImageContent)
-	case param.AI302:
-		videoUrl, totalToken, err = llm.Generate302AIVideo(r.Ctx, prompt, lastImageContent)
-	case param.Aliyun:
-		videoUrl, totalToken, err = llm.GenerateAliyunVideo(r.Ctx, prompt, lastImageContent)
-	default:
-		err = fmt.Errorf("unsupported type: %s", mediaType)
-	}
+	ctx, fallbacks, err := r.fallback(provider.Video, utils.GetVideoType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw),
+		func(ctx context.Context, mediaType string) error {
+			logger.InfoCtx(ctx, "create video", "mediaType", mediaType, "mediaModel",
+				utils.GetUsingVideoModel(mediaType, db.GetCtxUserInfo(ctx).LLMConfigRaw.VideoModel),
+				"lastImageContent", len(lastImageContent), "prompt", prompt)
+			var err error
+			switch mediaType {
+			case param.Vol:
+				videoUrl, totalToken, err = llm.GenerateVolVideo(ctx, prompt, lastImageContent)
+			case param.Gemini:
+				videoContent, totalToken, err = llm.GenerateGeminiVideo(ctx, prompt, lastImageContent)
+			case param.AI302:
+				videoUrl, totalToken, err = llm.Generate302AIVideo(ctx, prompt, lastImageContent)
+			case param.Aliyun:
+				videoUrl, totalToken, err = llm.GenerateAliyunVideo(ctx, prompt, lastImageContent)
+			default:
+				err = fmt.Errorf("unsupported type: %s", mediaType)
+			}
+			return err
+		})
+	r.Ctx = ctx
+	r.cs.Fallbacks = fallbacks
+
 	if err != nil {
 		logger.WarnCtx(r.Ctx, "generate video fail", "err", err)
 		return nil, 0, err
@@ -1606,18 +1737,25 @@ func (r *RobotInfo) GetVoiceBaseTTS(content, encoding string) ([]byte, int, erro
 	var err error
 	var duration int
 	var token int
-	switch conf.AudioConfInfo.TTSType {
-	case param.Vol:
-		ttsContent, token, duration, err = llm.VolTTS(r.Ctx, content, userId, encoding)
-	case param.Gemini:
-		ttsContent, token, duration, err = llm.GeminiTTS(r.Ctx, content, encoding)
-	case param.OpenAi:
-		ttsContent, token, duration, err = llm.OpenAITTS(r.Ctx, content, encoding)
-	case param.Aliyun:
-		ttsContent, token, duration, err = llm.AliyunTTS(r.Ctx, content, encoding)
-	}
-
-	err = db.AddRecordToken(r.Ctx, r.cs.RecordID, userId, token)
+	var cost float64
+	_, _, err = r.fallback(provider.TTS, conf.AudioConfInfo.TTSType, func(ctx context.Context, t string) error {
+		var err error
+		switch t {
+		case param.Vol:
+			ttsContent, token, duration, err = llm.VolTTS(ctx, content, userId, encoding)
+		case param.Gemini:
+			ttsContent, token, duration, err = llm.GeminiTTS(ctx, content, encoding)
+		case param.OpenAi:
+			ttsContent, token, duration, err = llm.OpenAITTS(ctx, content, encoding)
+		case param.Aliyun:
+			ttsContent, token, duration, err = llm.AliyunTTS(ctx, content, encoding)
+		}
+		cost = provider.Cost(t, utils.GetUsingTTSModel(t, db.GetCtxUserInfo(ctx).LLMConfigRaw.TTSModel),
+			provider.Usage{TTSChar: utf8.RuneCountInString(content)})
+		return err
+	})
+
+	err = db.AddRecordToken(r.Ctx, r.cs.RecordID, userId, token, cost)
 	if err != nil {
 		logger.WarnCtx(r.Ctx, "addRecordToken err", "err", err)
 	}
@@ -1703,6 +1841,7 @@ func (r *RobotInfo) InsertRecord() {
 		RecordType: param.TextRecordType,
 		Token:      r.cs.Token,
 		Content:    content,
+		SessionId:  r.GetSessionId(),
 	})
 	if err != nil {
 		logger.ErrorCtx(r.Ctx, "insert record fail", "err", err)
@@ -1728,6 +1867,33 @@ type SmartModeResult struct {
 	Cron    string `json:"cron"`
 }
 
+var smartModeCommands = []string{"/video", "/photo", "/edit_photo", "/rec_photo", "/cron", "/chat"}
+
+func (s *SmartModeResult) ResponseFormat() *llm.ResponseFormat {
+	return &llm.ResponseFormat{
+		Name: "smart_mode",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "enum": smartModeCommands},
+				"cron":    map[string]any{"type": "string", "description": "second-level cron expression of /cron"},
+				"prompt":  map[string]any{"type": "string", "description": "prompt of /cron"},
+			},
+			"required": []string{"command"},
+		},
+	}
+}
+
+func (s *SmartModeResult) Validate() error {
+	if !slices.Contains(smartModeCommands, s.Command) {
+		return fmt.Errorf("command must be one of %s", strings.Join(smartModeCommands, " "))
+	}
+	if s.Command == "/cron" && (s.Cron == "" || s.Prompt == "") {
+		return errors.New("cron and prompt are required by /cron")
+	}
+	return nil
+}
+
 func (r *RobotInfo) smartMode() bool {
 	if r.Robot.getCommand() != "" || r.Robot.getPrompt() == "" || !conf.BaseConfInfo.SmartMode {
 		return true
@@ -1749,26 +1915,21 @@ func (r *RobotInfo) smartMode() bool {
 	)
 	llmClient.LLMClient.GetModel(llmClient)
 	llmClient.LLMClient.GetMessage(openai.ChatMessageRoleUser, llmClient.Content)
-	content, err := llmClient.LLMClient.SyncSend(r.Ctx, llmClient)
+	smartResult := new(SmartModeResult)
+	_, err := llmClient.SyncSendStructured(r.Ctx, smartResult)
 	if err != nil {
 		logger.ErrorCtx(r.Ctx, "get content fail", "err", err)
 		return true
 	}
 
-	matches := smartModeReg.FindAllString(content, -1)
-	smartResult := new(SmartModeResult)
-	for _, match := range matches {
-		err := json.Unmarshal([]byte(match), smartResult)
-		if err != nil {
-			logger.ErrorCtx(r.Ctx, "json umarshal fail", "err", err)
-		}
-	}
-
 	logger.InfoCtx(r.Ctx, "smart mode result", "result", smartResult)
 
 	switch smartResult.Command {
 	case "/cron":
 		r.cs.Token = llmClient.Cs.Token
+		if !r.checkRole(param.RoleResourceCommand, param.Cron) {
+			return false
+		}
 		err = r.InsertCron(smartResult.Cron, smartResult.Prompt)
 		if err != nil {
 			r.SendMsg(chatId, err.Error(), msgId, "", nil)
@@ -1802,9 +1963,15 @@ func (r *RobotInfo) saveRecord(content, imageContent []byte, recordType, totalTo
 
 	mode := ""
 	if recordType == param.ImageRecordType {
-		mode = utils.GetImgType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw)
+		mode = utils.GetFallbackMode(r.cs.Fallbacks, utils.GetImgType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw))
 	} else {
-		mode = utils.GetVideoType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw)
+		mode = utils.GetFallbackMode(r.cs.Fallbacks, utils.GetVideoType(db.GetCtxUserInfo(r.Ctx).LLMConfigRaw))
+	}
+
+	// image uploaded by user is saved without token
+	var cost float64
+	if totalToken > 0 {
+		cost = r.mediaCost(recordType)
 	}
 
 	// save data record
@@ -1817,12 +1984,27 @@ func (r *RobotInfo) saveRecord(content, imageContent []byte, recordType, totalTo
 		IsDeleted:  0,
 		RecordType: recordType,
 		Mode:       mode,
+		Cost:       cost,
+		SessionId:  r.GetSessionId(),
 	})
 	if err != nil {
 		logger.ErrorCtx(r.Ctx, "insert record fail", "err", err)
 	}
 }
 
+// mediaCost calculate cost of one generated image or video by price of using model.
+func (r *RobotInfo) mediaCost(recordType int) float64 {
+	llmConf := db.GetCtxUserInfo(r.Ctx).LLMConfigRaw
+	if recordType == param.ImageRecordType {
+		t := utils.GetImgType(llmConf)
+		return provider.Cost(t, utils.GetUsingImgModel(t, llmConf.ImgModel), provider.Usage{Image: 1})
+	}
+
+	t := utils.GetVideoType(llmConf)
+	return provider.Cost(t, utils.GetUsingVideoModel(t, llmConf.VideoModel),
+		provider.Usage{VideoSecond: conf.VideoConfInfo.Duration})
+}
+
 func (r *RobotInfo) InsertCron(cron, prompt string) error {
 	var err error
 	var id int64
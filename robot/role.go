@@ -0,0 +1,105 @@
+package robot
+
+import (
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+// getRole get role of user in chat, role of user is preferred to role of group.
+func getRole(chatId, userId string) string {
+	if !conf.RBACConfInfo.HasPolicies() {
+		return ""
+	}
+
+	role, err := db.GetRole(param.RoleSubjectUser, userId)
+	if err != nil {
+		logger.Error("get user role fail", "userID", userId, "err", err)
+	}
+	if role == "" && chatId != "" && chatId != userId {
+		role, err = db.GetRole(param.RoleSubjectGroup, chatId)
+		if err != nil {
+			logger.Error("get group role fail", "chatId", chatId, "err", err)
+		}
+	}
+	if role == "" {
+		role = conf.RBACConfInfo.DefaultRole
+	}
+	return role
+}
+
+// checkRole check role of user can use resource, send message if not.
+func (r *RobotInfo) checkRole(resource, name string) bool {
+	role := ""
+	if userInfo := db.GetCtxUserInfo(r.Ctx); userInfo != nil {
+		role = userInfo.Role
+	}
+	if conf.RBACConfInfo.Allow(role, resource, name) {
+		return true
+	}
+
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	logger.WarnCtx(r.Ctx, "permission denied", "userID", userId, "role", role,
+		"resource", resource, "name", name)
+	r.SendMsg(chatId, i18n.GetMessage("permission_denied", map[string]interface{}{
+		"role":     role,
+		"resource": resource,
+		"name":     name,
+	}), msgId, tgbotapi.ModeMarkdown, nil)
+	return false
+}
+
+// checkCommandRole message without command is regarded as chat command.
+func (r *RobotInfo) checkCommandRole(cmd string) bool {
+	name := strings.TrimLeft(cmd, "/$")
+	if !slices.Contains(param.Commands, name) {
+		name = param.Chat
+	}
+	return r.checkRole(param.RoleResourceCommand, name)
+}
+
+// checkModelRole check provider and model going to be used by current command.
+func (r *RobotInfo) checkModelRole() bool {
+	userInfo := db.GetCtxUserInfo(r.Ctx)
+	if !conf.RBACConfInfo.HasPolicies() || userInfo == nil {
+		return true
+	}
+	llmConf := userInfo.LLMConfigRaw
+
+	var t, model string
+	switch r.quotaCapability() {
+	case param.QuotaImage:
+		t = utils.GetImgType(llmConf)
+		model = utils.GetUsingImgModel(t, llmConf.ImgModel)
+	case param.QuotaVideo:
+		t = utils.GetVideoType(llmConf)
+		model = utils.GetUsingVideoModel(t, llmConf.VideoModel)
+	default:
+		t = utils.GetTxtType(llmConf)
+		model = utils.GetUsingTxtModel(t, llmConf.TxtModel)
+	}
+
+	return r.checkRole(param.RoleResourceProvider, t) && r.checkRole(param.RoleResourceModel, model)
+}
+
+// checkModelUpdateRole check providers and models user is switching to.
+func (r *RobotInfo) checkModelUpdateRole(rm *RobotModel) bool {
+	for _, t := range []string{rm.TxtType, rm.ImgType, rm.VideoType, rm.RecType, rm.TTSType} {
+		if t != "" && !r.checkRole(param.RoleResourceProvider, t) {
+			return false
+		}
+	}
+	for _, model := range []string{rm.TxtModel, rm.ImgModel, rm.VideoModel, rm.RecModel, rm.TTSModel} {
+		if model != "" && !r.checkRole(param.RoleResourceModel, model) {
+			return false
+		}
+	}
+	return true
+}
@@ -140,6 +140,9 @@ func SlackButtonHandler(callback *slack.InteractionCallback) {
 		switch action.ActionID {
 		case "chat", "photo", "video", "mcp", "task":
 			s.openModal(callback.TriggerID, action.ActionID)
+		case param.McpApprove, param.McpReject:
+			s.Prompt = action.Value
+			s.Robot.ExecCmd(s.Command, nil, nil, nil)
 		default:
 			s.Robot.ExecCmd(s.Command, nil, nil, nil)
 
@@ -234,7 +237,7 @@ func (s *SlackRobot) requestLLM(content string) {
 
 func (s *SlackRobot) sendChatMessage() {
 	s.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			s.executeChain()
 		} else {
 			s.executeLLM()
@@ -0,0 +1,167 @@
+package robot
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+const (
+	taskStatusListSize = 10
+	taskContentShowLen = 50
+)
+
+// taskStatus handle /task_status and /task_status <id>, list latest task runs or show steps of one run.
+func (r *RobotInfo) taskStatus() {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+
+	if strings.TrimSpace(r.Robot.getPrompt()) != "" {
+		run := r.getTaskRun()
+		if run == nil {
+			return
+		}
+		r.showTaskRun(run)
+		return
+	}
+
+	runs, err := db.GetTaskRunsByPage(1, taskStatusListSize, userId, "")
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "get task runs fail", "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, "", nil)
+		return
+	}
+	if len(runs) == 0 {
+		r.SendMsg(chatId, i18n.GetMessage("task_run_empty", nil), msgId, "", nil)
+		return
+	}
+
+	txt := i18n.GetMessage("task_run_list_header", nil)
+	for _, run := range runs {
+		txt += i18n.GetMessage("task_run_list_item", map[string]interface{}{
+			"id":      run.ID,
+			"status":  llm.TaskRunStatus(run),
+			"content": shortTaskContent(run.Content),
+			"token":   run.Token,
+		})
+	}
+	r.SendMsg(chatId, txt, msgId, "", nil)
+}
+
+func (r *RobotInfo) showTaskRun(run *db.TaskRun) {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	steps, err := db.GetTaskSteps(run.ID)
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "get task steps fail", "run", run.ID, "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, "", nil)
+		return
+	}
+
+	txt := i18n.GetMessage("task_run_detail", map[string]interface{}{
+		"id":      run.ID,
+		"status":  llm.TaskRunStatus(run),
+		"content": shortTaskContent(run.Content),
+		"loop":    run.Loop,
+		"token":   run.Token,
+		"error":   run.ErrorMsg,
+	})
+	for _, step := range steps {
+		if step.Loop != run.Loop {
+			continue
+		}
+		txt += i18n.GetMessage("task_step_item", map[string]interface{}{
+			"id":     step.StepId,
+			"name":   step.Name,
+			"status": step.Status,
+		})
+	}
+	r.SendMsg(chatId, txt, msgId, "", nil)
+}
+
+// taskCancel handle /task_cancel <id>, the run stops and can be resumed later.
+func (r *RobotInfo) taskCancel() {
+	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
+	run := r.getTaskRun()
+	if run == nil {
+		return
+	}
+
+	if !llm.CancelTaskRun(run.ID) {
+		r.SendMsg(chatId, i18n.GetMessage("task_run_not_running", map[string]interface{}{
+			"id": run.ID,
+		}), msgId, "", nil)
+		return
+	}
+	r.SendMsg(chatId, i18n.GetMessage("task_run_cancelling", map[string]interface{}{
+		"id": run.ID,
+	}), msgId, "", nil)
+}
+
+// taskResume handle /task_resume <id>, completed steps of the run are not executed again.
+func (r *RobotInfo) taskResume() {
+	r.TalkingPreCheck(func() {
+		chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+		run := r.getTaskRun()
+		if run == nil {
+			return
+		}
+
+		status := llm.TaskRunStatus(run)
+		if status == db.TaskRunStatusDone || status == db.TaskRunStatusRunning {
+			r.SendMsg(chatId, i18n.GetMessage("task_run_not_resumable", map[string]interface{}{
+				"id":     run.ID,
+				"status": status,
+			}), msgId, "", nil)
+			return
+		}
+
+		r.GetSessionId()
+		dpReq := &llm.LLMTaskReq{
+			Content:   run.Content,
+			UserId:    userId,
+			ChatId:    chatId,
+			MsgId:     msgId,
+			PerMsgLen: r.Robot.getPerMsgLen(),
+			Cs:        r.cs,
+			Ctx:       r.Ctx,
+			RunId:     run.ID,
+		}
+		r.execMultiAgent(dpReq, "task_empty_content")
+	})
+}
+
+// getTaskRun get task run of id in prompt, run of other user is treated as not exist.
+func (r *RobotInfo) getTaskRun() *db.TaskRun {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	prompt := strings.TrimPrefix(strings.TrimSpace(r.Robot.getPrompt()), "#")
+	id, err := strconv.ParseInt(prompt, 10, 64)
+	if err != nil {
+		r.SendMsg(chatId, i18n.GetMessage("task_run_usage", nil), msgId, "", nil)
+		return nil
+	}
+
+	run, err := db.GetTaskRun(id)
+	if err != nil {
+		logger.ErrorCtx(r.Ctx, "get task run fail", "run", id, "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, "", nil)
+		return nil
+	}
+	if run == nil || run.UserId != userId {
+		r.SendMsg(chatId, i18n.GetMessage("task_run_not_exist", map[string]interface{}{
+			"id": id,
+		}), msgId, "", nil)
+		return nil
+	}
+	return run
+}
+
+func shortTaskContent(content string) string {
+	runes := []rune(strings.TrimSpace(content))
+	if len(runes) > taskContentShowLen {
+		return string(runes[:taskContentShowLen]) + "..."
+	}
+	return string(runes)
+}
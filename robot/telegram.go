@@ -18,6 +18,7 @@ import (
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/metrics"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
 	"github.com/yincongcyincong/MuseBot/utils"
 )
 
@@ -199,6 +200,50 @@ func CreateBot(ctx context.Context) *tgbotapi.BotAPI {
 			Command:     param.CronDel,
 			Description: i18n.GetMessage("commands.cron.description", nil),
 		},
+		tgbotapi.BotCommand{
+			Command:     param.NewThread,
+			Description: i18n.GetMessage("commands.new.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.Threads,
+			Description: i18n.GetMessage("commands.threads.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.SwitchTo,
+			Description: i18n.GetMessage("commands.switch.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.KB,
+			Description: i18n.GetMessage("commands.kb.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.Sources,
+			Description: i18n.GetMessage("commands.sources.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.Learn,
+			Description: i18n.GetMessage("commands.learn.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.TaskStatus,
+			Description: i18n.GetMessage("commands.task_status.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.TaskCancel,
+			Description: i18n.GetMessage("commands.task_cancel.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.TaskResume,
+			Description: i18n.GetMessage("commands.task_resume.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.McpApprove,
+			Description: i18n.GetMessage("commands.mcp_approve.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     param.McpReject,
+			Description: i18n.GetMessage("commands.mcp_reject.description", nil),
+		},
 	)
 	bot.Send(cmdCfg)
 
@@ -263,7 +308,7 @@ func (t *TelegramRobot) requestLLM(content string) {
 		return
 	}
 
-	t.sendChatMessage()
+	t.Robot.ExecCmd(content, t.sendChatMessage, nil, nil)
 }
 
 // executeChain use langchain to interact llm
@@ -403,7 +448,7 @@ func (t *TelegramRobot) sendChatMessage() {
 
 	// Reply to the chat content
 	t.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			t.executeChain()
 		} else {
 			t.executeLLM()
@@ -465,291 +510,66 @@ func (t *TelegramRobot) changeModel(ty string) {
 			t.Robot.handleModelUpdate(&RobotModel{TxtModel: t.Prompt})
 			return
 		}
-		t.showTxtModel(ty)
+		t.showModels(provider.Text, utils.GetTxtType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw), ty)
 
 	case param.PhotoModel, "/" + param.PhotoModel, "$" + param.PhotoModel:
 		if t.getPrompt() != "" {
 			t.Robot.handleModelUpdate(&RobotModel{ImgModel: t.Prompt})
 			return
 		}
-		t.showImageModel()
+		t.showModels(provider.Image, utils.GetImgType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw), ty)
 
 	case param.VideoModel, "/" + param.VideoModel, "$" + param.VideoModel:
 		if t.getPrompt() != "" {
 			t.Robot.handleModelUpdate(&RobotModel{VideoModel: t.Prompt})
 			return
 		}
-		t.showVideoModel()
+		t.showModels(provider.Video, utils.GetVideoType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw), ty)
 	case param.RecModel, "/" + param.RecModel, "$" + param.RecModel:
 		if t.getPrompt() != "" {
 			t.Robot.handleModelUpdate(&RobotModel{RecModel: t.Prompt})
 			return
 		}
-		t.showRecModel()
+		t.showModels(provider.Rec, utils.GetRecType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw), ty)
 	case param.TtsModel, "/" + param.TtsModel, "$" + param.TtsModel:
 		if t.getPrompt() != "" {
 			t.Robot.handleModelUpdate(&RobotModel{TTSModel: t.Prompt})
 			return
 		}
-		t.showTTSModel()
+		t.showModels(provider.TTS, utils.GetTTSType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw), ty)
 	}
 }
 
-func (t *TelegramRobot) showTxtModel(ty string) {
+// showModels send selectable models as inline buttons, or the link to find models if provider accept any model.
+func (t *TelegramRobot) showModels(c provider.Capability, ty string, command string) {
 	chatID, msgId, _ := t.Robot.GetChatIdAndMsgIdAndUserID()
-
-	var inlineKeyboard tgbotapi.InlineKeyboardMarkup
-	inlineButton := make([][]tgbotapi.InlineKeyboardButton, 0)
-	switch utils.GetTxtType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-	case param.DeepSeek:
-		for k := range param.DeepseekModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Gemini:
-		for k := range param.GeminiModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Aliyun:
-		for k := range param.AliyunModel {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.OpenRouter, param.AI302, param.Ollama, param.OpenAi:
-		if t.Prompt != "" {
-			t.Robot.handleModelUpdate(&RobotModel{TxtType: t.Prompt})
-			return
-		}
-		switch utils.GetTxtType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-		case param.OpenAi:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link":    "https://platform.openai.com/",
-				"command": ty,
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.AI302:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link":    "https://302.ai/",
-				"command": ty,
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.OpenRouter:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link":    "https://openrouter.ai/",
-				"command": ty,
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.Ollama:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link":    "https://ollama.com/",
-				"command": ty,
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		}
-
+	p := provider.Get(ty)
+	if p == nil {
 		return
-	case param.Vol:
-		// create inline button
-		for k := range param.VolModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-
 	}
 
-	inlineKeyboard = tgbotapi.NewInlineKeyboardMarkup(inlineButton...)
-
-	t.Robot.SendMsg(chatID, i18n.GetMessage("chat_mode", nil),
-		msgId, tgbotapi.ModeMarkdown, &inlineKeyboard)
-}
-
-func (t *TelegramRobot) showImageModel() {
-	chatID, msgId, _ := t.Robot.GetChatIdAndMsgIdAndUserID()
-	var inlineKeyboard tgbotapi.InlineKeyboardMarkup
-	inlineButton := make([][]tgbotapi.InlineKeyboardButton, 0)
-
-	switch utils.GetImgType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiImageModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Aliyun:
-		for k := range param.AliyunImageModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.OpenRouter, param.AI302, param.Ollama, param.OpenAi:
-		switch utils.GetImgType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-		case param.AI302:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://302.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.OpenRouter:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://openrouter.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.Ollama:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://ollama.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		case param.OpenAi:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://platform.openai.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-		}
-
+	models := p.Models[c]
+	if len(models) == 0 && p.Link != "" {
+		t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
+			"link":    p.Link,
+			"command": command,
+		}),
+			msgId, tgbotapi.ModeMarkdown, nil)
 		return
-	case param.Vol:
-		for k := range param.VolImageModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
 	}
-
-	inlineKeyboard = tgbotapi.NewInlineKeyboardMarkup(inlineButton...)
-
-	t.Robot.SendMsg(chatID, i18n.GetMessage("chat_mode", nil),
-		msgId, tgbotapi.ModeMarkdown, &inlineKeyboard)
-}
-
-func (t *TelegramRobot) showVideoModel() {
-	chatID, msgId, _ := t.Robot.GetChatIdAndMsgIdAndUserID()
-	var inlineKeyboard tgbotapi.InlineKeyboardMarkup
-	inlineButton := make([][]tgbotapi.InlineKeyboardButton, 0)
-
-	switch utils.GetVideoType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiVideoModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Aliyun:
-		for k := range param.AliyunVideoModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Vol:
-		for k := range param.VolVideoModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.AI302:
-		switch utils.GetVideoType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-		case param.AI302:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://302.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		}
+	// provider accept any model, show the default one
+	if len(models) == 0 && p.DefaultModels[c] != "" {
+		models = []string{p.DefaultModels[c]}
 	}
 
-	inlineKeyboard = tgbotapi.NewInlineKeyboardMarkup(inlineButton...)
-
-	t.Robot.SendMsg(chatID, i18n.GetMessage("chat_mode", nil),
-		msgId, tgbotapi.ModeMarkdown, &inlineKeyboard)
-}
-
-func (t *TelegramRobot) showRecModel() {
-	chatID, msgId, _ := t.Robot.GetChatIdAndMsgIdAndUserID()
-	var inlineKeyboard tgbotapi.InlineKeyboardMarkup
 	inlineButton := make([][]tgbotapi.InlineKeyboardButton, 0)
-
-	switch utils.GetRecType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiRecModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Aliyun:
-		for k := range param.AliyunRecModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Vol:
-		for k := range param.VolRecModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.AI302, param.OpenAi:
-		switch utils.GetRecType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-		case param.AI302:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://302.ai/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		case param.OpenAi:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://platform.openai.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		}
-	}
-
-	inlineKeyboard = tgbotapi.NewInlineKeyboardMarkup(inlineButton...)
-
-	t.Robot.SendMsg(chatID, i18n.GetMessage("chat_mode", nil),
-		msgId, tgbotapi.ModeMarkdown, &inlineKeyboard)
-}
-
-func (t *TelegramRobot) showTTSModel() {
-	chatID, msgId, _ := t.Robot.GetChatIdAndMsgIdAndUserID()
-	var inlineKeyboard tgbotapi.InlineKeyboardMarkup
-	inlineButton := make([][]tgbotapi.InlineKeyboardButton, 0)
-
-	switch utils.GetTTSType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-	case param.Gemini:
-		for k := range param.GeminiTTSModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Aliyun:
-		for k := range param.AliyunTTSModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.Vol:
-		for k := range param.VolTTSModels {
-			inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(k, k),
-			))
-		}
-	case param.OpenAi:
-		switch utils.GetTTSType(db.GetCtxUserInfo(t.Robot.Ctx).LLMConfigRaw) {
-		case param.OpenAi:
-			t.Robot.SendMsg(chatID, i18n.GetMessage("mix_mode_choose", map[string]interface{}{
-				"link": "https://platform.openai.com/",
-			}),
-				msgId, tgbotapi.ModeMarkdown, nil)
-			return
-		}
+	for _, k := range models {
+		inlineButton = append(inlineButton, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(k, k),
+		))
 	}
 
-	inlineKeyboard = tgbotapi.NewInlineKeyboardMarkup(inlineButton...)
-
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(inlineButton...)
 	t.Robot.SendMsg(chatID, i18n.GetMessage("chat_mode", nil),
 		msgId, tgbotapi.ModeMarkdown, &inlineKeyboard)
 }
@@ -785,7 +605,18 @@ func (t *TelegramRobot) handleCallbackQuery() {
 			logger.ErrorCtx(t.Robot.Ctx, "handleCommand panic err", "err", err, "stack", string(debug.Stack()))
 		}
 	}()
-	if t.Update.CallbackQuery != nil && t.Update.CallbackQuery.Message.ReplyToMessage != nil {
+	if t.Update.CallbackQuery == nil {
+		return
+	}
+
+	// approval buttons carry command and approval id
+	if cmd, id, ok := strings.Cut(t.Update.CallbackQuery.Data, " "); ok && (cmd == param.McpApprove || cmd == param.McpReject) {
+		t.Prompt = id
+		t.Robot.ExecCmd(cmd, nil, nil, nil)
+		return
+	}
+
+	if t.Update.CallbackQuery.Message != nil && t.Update.CallbackQuery.Message.ReplyToMessage != nil {
 		t.Update.CallbackQuery.Message.MessageID = t.Update.CallbackQuery.Message.ReplyToMessage.MessageID
 		t.Prompt = t.Update.CallbackQuery.Data
 		cmd := strings.TrimSpace(strings.ReplaceAll(t.Update.CallbackQuery.Message.ReplyToMessage.Text, "@"+t.Bot.Self.UserName, ""))
@@ -966,19 +797,41 @@ func (t *TelegramRobot) ExecuteForceReply() {
 		}
 	}()
 
+	// reply to prompt of command is checked like the command
+	var cmd string
 	switch t.getMessage().ReplyToMessage.Text {
 	case i18n.GetMessage("chat_empty_content", nil):
-		t.sendChatMessage()
+		cmd = param.Chat
 	case i18n.GetMessage("photo_empty_content", nil):
-		t.sendImg()
+		cmd = param.Photo
 	case i18n.GetMessage("edit_photo_empty_content", nil):
+		cmd = param.EditPhoto
+	case i18n.GetMessage("video_empty_content", nil):
+		cmd = param.Video
+	case i18n.GetMessage("task_empty_content", nil):
+		cmd = param.Task
+	case i18n.GetMessage("mcp_empty_content", nil):
+		cmd = param.Mcp
+	default:
+		return
+	}
+	if !t.Robot.checkCommandRole(cmd) {
+		return
+	}
+
+	switch cmd {
+	case param.Chat:
+		t.sendChatMessage()
+	case param.Photo:
+		t.sendImg()
+	case param.EditPhoto:
 		t.Command = param.EditPhoto
 		t.sendImg()
-	case i18n.GetMessage("video_empty_content", nil):
+	case param.Video:
 		t.sendVideo()
-	case i18n.GetMessage("task_empty_content", nil):
+	case param.Task:
 		t.Robot.sendMultiAgent("task_empty_content", t.sendForceReply("task_empty_content"))
-	case i18n.GetMessage("mcp_empty_content", nil):
+	case param.Mcp:
 		t.Robot.sendMultiAgent("task_empty_content", t.sendForceReply("mcp_empty_content"))
 	}
 }
@@ -1034,6 +887,34 @@ func (t *TelegramRobot) GetPhotoContent() []byte {
 	return photoContent
 }
 
+// getDocument download document of message, or document of the replied message.
+func (t *TelegramRobot) getDocument() (string, []byte) {
+	msg := t.Update.Message
+	if msg == nil {
+		return "", nil
+	}
+	doc := msg.Document
+	if doc == nil && msg.ReplyToMessage != nil {
+		doc = msg.ReplyToMessage.Document
+	}
+	if doc == nil {
+		return "", nil
+	}
+
+	file, err := t.Bot.GetFile(tgbotapi.FileConfig{FileID: doc.FileID})
+	if err != nil {
+		logger.WarnCtx(t.Robot.Ctx, "get file fail", "err", err)
+		return "", nil
+	}
+
+	content, err := utils.DownloadFile(file.Link(t.Bot.Token))
+	if err != nil {
+		logger.WarnCtx(t.Robot.Ctx, "read response fail", "err", err)
+		return "", nil
+	}
+	return doc.FileName, content
+}
+
 func (t *TelegramRobot) getMessage() *tgbotapi.Message {
 	if t.Update.Message != nil {
 		return t.Update.Message
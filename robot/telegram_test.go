@@ -1,11 +1,17 @@
 package robot
 
 import (
+	"context"
+	"io"
+	"net/http"
 	"strings"
 	"testing"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
 	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/i18n"
 )
 
 func makeFakeUpdateWithText(text string, botUserName string, chatType string) tgbotapi.Update {
@@ -53,3 +59,57 @@ func TestSkipThisMsg(t *testing.T) {
 		t.Error("group message with mention should not be skipped")
 	}
 }
+
+// fakeTelegramClient record methods and params requested to telegram api.
+type fakeTelegramClient struct {
+	methods []string
+	params  []map[string]string
+}
+
+func (c *fakeTelegramClient) Do(req *http.Request) (*http.Response, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	params := make(map[string]string)
+	for k := range req.PostForm {
+		params[k] = req.PostForm.Get(k)
+	}
+	c.methods = append(c.methods, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+	c.params = append(c.params, params)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true,"result":{"message_id":2}}`)),
+	}, nil
+}
+
+func TestTelegramMessageRole(t *testing.T) {
+	i18n.InitI18n()
+	policies := conf.RBACConfInfo.RolePolicies
+	conf.RBACConfInfo.RolePolicies = `[{"name":"guest","commands":["state"]}]`
+	assert.NoError(t, conf.ParseRolePolicies())
+	defer func() {
+		conf.RBACConfInfo.RolePolicies = policies
+		_ = conf.ParseRolePolicies()
+	}()
+
+	client := new(fakeTelegramClient)
+	bot := &tgbotapi.BotAPI{Token: "token", Client: client, Self: tgbotapi.User{UserName: "TestBot"}}
+	bot.SetAPIEndpoint(tgbotapi.APIEndpoint)
+	update := tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			MessageID: 1,
+			Text:      "hello",
+			From:      &tgbotapi.User{ID: 789},
+			Chat:      &tgbotapi.Chat{ID: 789, Type: "private"},
+		},
+	}
+	tel := NewTelegramRobot(update, bot)
+	tel.Robot = NewRobot(WithRobot(tel))
+	tel.Robot.Ctx = context.WithValue(context.Background(), "user_info", &db.User{UserId: "789", Role: "guest"})
+
+	// plain message is chat command, guest can't use it
+	tel.requestLLM(tel.getMsgContent())
+	assert.Equal(t, []string{"sendMessage"}, client.methods)
+	assert.Equal(t, tgbotapi.ModeMarkdown, client.params[0]["parse_mode"])
+	assert.Equal(t, "1", client.params[0]["reply_to_message_id"])
+}
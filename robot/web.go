@@ -124,7 +124,8 @@ func (web *Web) sendImg() {
 		Token:      totalToken,
 		IsDeleted:  0,
 		RecordType: param.ImageRecordType,
-		Mode:       utils.GetImgType(db.GetCtxUserInfo(web.Robot.Ctx).LLMConfigRaw),
+		Mode:       utils.GetFallbackMode(web.Robot.cs.Fallbacks, utils.GetImgType(db.GetCtxUserInfo(web.Robot.Ctx).LLMConfigRaw)),
+		Cost:       web.Robot.mediaCost(param.ImageRecordType),
 	})
 
 }
@@ -167,7 +168,8 @@ func (web *Web) sendVideo() {
 		Token:      totalToken,
 		IsDeleted:  0,
 		RecordType: param.VideoRecordType,
-		Mode:       utils.GetVideoType(db.GetCtxUserInfo(web.Robot.Ctx).LLMConfigRaw),
+		Mode:       utils.GetFallbackMode(web.Robot.cs.Fallbacks, utils.GetVideoType(db.GetCtxUserInfo(web.Robot.Ctx).LLMConfigRaw)),
+		Cost:       web.Robot.mediaCost(param.VideoRecordType),
 	})
 
 }
@@ -175,7 +177,7 @@ func (web *Web) sendVideo() {
 func (web *Web) sendChatMessage() {
 
 	web.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			//web.executeChain()
 		} else {
 			web.executeLLM()
@@ -250,6 +252,8 @@ func (web *Web) AddUserInfo() bool {
 		}
 	}
 
+	userInfo.Role = getRole("", web.RealUserId)
+
 	web.Robot.Ctx = context.WithValue(web.Robot.Ctx, "user_info", userInfo)
 
 	return true
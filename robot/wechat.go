@@ -316,7 +316,7 @@ func (w *WechatRobot) sendVideo() {
 
 func (w *WechatRobot) sendChatMessage() {
 	w.Robot.TalkingPreCheck(func() {
-		if conf.RagConfInfo.Store != nil {
+		if conf.RagConfInfo.UseChain() {
 			w.executeChain()
 		} else {
 			w.executeLLM()
@@ -1,12 +1,13 @@
 package utils
 
 import (
+	"strings"
+
 	godeepseek "github.com/cohesion-org/deepseek-go"
-	"github.com/devinyf/dashscopego/qwen"
 	"github.com/goccy/go-json"
-	"github.com/sashabaranov/go-openai"
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/provider"
 )
 
 func GetDefaultLLMConfig() string {
@@ -28,67 +29,42 @@ func GetTxtType(llmConf *param.LLMConfig) string {
 	if llmConf == nil {
 		return conf.BaseConfInfo.Type
 	}
-
-	aType := GetAvailTxtType()
-	for _, v := range aType {
-		if v == llmConf.TxtType {
-			return v
-		}
-	}
-
-	if len(aType) > 0 {
-		return aType[0]
-	}
-
-	return conf.BaseConfInfo.Type
+	return getType(provider.Text, llmConf.TxtType, conf.BaseConfInfo.Type)
 }
 
 func GetImgType(llmConf *param.LLMConfig) string {
 	if llmConf == nil {
 		return conf.BaseConfInfo.MediaType
 	}
-
-	aType := GetAvailImgType()
-	for _, v := range aType {
-		if v == llmConf.ImgType {
-			return v
-		}
-	}
-
-	if len(aType) > 0 {
-		return aType[0]
-	}
-
-	return conf.BaseConfInfo.MediaType
+	return getType(provider.Image, llmConf.ImgType, conf.BaseConfInfo.MediaType)
 }
 
 func GetVideoType(llmConf *param.LLMConfig) string {
 	if llmConf == nil {
 		return conf.BaseConfInfo.MediaType
 	}
+	return getType(provider.Video, llmConf.VideoType, conf.BaseConfInfo.MediaType)
+}
 
-	aType := GetAvailVideoType()
-	for _, v := range aType {
-		if v == llmConf.VideoType {
-			return v
-		}
-	}
-
-	if len(aType) > 0 {
-		return aType[0]
+func GetTTSType(llmConf *param.LLMConfig) string {
+	if llmConf == nil {
+		return conf.BaseConfInfo.MediaType
 	}
-
-	return conf.BaseConfInfo.MediaType
+	return getType(provider.TTS, llmConf.TTSType, conf.BaseConfInfo.MediaType)
 }
 
-func GetTTSType(llmConf *param.LLMConfig) string {
+func GetRecType(llmConf *param.LLMConfig) string {
 	if llmConf == nil {
 		return conf.BaseConfInfo.MediaType
 	}
+	return getType(provider.Rec, llmConf.RecType, conf.BaseConfInfo.MediaType)
+}
 
-	aType := GetAvailTTSType()
+// getType use the chosen provider if it is available, otherwise the first available one.
+func getType(c provider.Capability, chosen string, defaultType string) string {
+	aType := provider.Available(c)
 	for _, v := range aType {
-		if v == llmConf.TTSType {
+		if v == chosen {
 			return v
 		}
 	}
@@ -97,7 +73,7 @@ func GetTTSType(llmConf *param.LLMConfig) string {
 		return aType[0]
 	}
 
-	return conf.BaseConfInfo.MediaType
+	return defaultType
 }
 
 func GetImgModel(t string) string {
@@ -154,283 +130,89 @@ func GetTxtModel(t string) string {
 		return conf.BaseConfInfo.DefaultModel
 	}
 
-	switch t {
-	case param.DeepSeek:
-		return godeepseek.DeepSeekChat
-	case param.Gemini:
-		return param.ModelGemini25Flash
-	case param.OpenAi:
-		return openai.GPT3Dot5Turbo
-	case param.OpenRouter:
-		return param.DeepseekDeepseekR1_0528Free
-	case param.AI302:
-		return param.DeepseekDeepseekR1_0528
-	case param.Ollama:
-		return "deepseek-r1"
-	case param.Vol:
-		return param.ModelDeepSeekR1_528
-	case param.Aliyun:
-		return qwen.QwenMax
-	case param.ChatAnyWhere:
-		return openai.GPT3Dot5Turbo
+	if p := provider.Get(t); p != nil {
+		if p.ConfigModels[provider.Text] != "" {
+			return p.ConfigModels[provider.Text]
+		}
+		if p.DefaultModels[provider.Text] != "" {
+			return p.DefaultModels[provider.Text]
+		}
 	}
 
 	return godeepseek.DeepSeekChat
 }
 
 func GetAvailTxtType() []string {
-	res := []string{}
-	if conf.BaseConfInfo.DeepseekToken != "" {
-		res = append(res, param.DeepSeek)
-	}
-	if conf.BaseConfInfo.GeminiToken != "" {
-		res = append(res, param.Gemini)
-	}
-	if conf.BaseConfInfo.OpenAIToken != "" {
-		res = append(res, param.OpenAi)
-	}
-	if conf.BaseConfInfo.AliyunToken != "" {
-		res = append(res, param.Aliyun)
-	}
-	if conf.BaseConfInfo.VolToken != "" {
-		res = append(res, param.Vol)
-	}
-	if conf.BaseConfInfo.ChatAnyWhereToken != "" {
-		res = append(res, param.ChatAnyWhere)
-	}
-	if conf.BaseConfInfo.AI302Token != "" {
-		res = append(res, param.AI302)
-	}
-	if conf.BaseConfInfo.OpenRouterToken != "" {
-		res = append(res, param.OpenRouter)
-	}
-	if conf.BaseConfInfo.Type == param.Ollama {
-		res = append(res, param.Ollama)
-	}
-	return res
+	return provider.Available(provider.Text)
 }
 
 func GetAvailImgType() []string {
-	res := []string{}
-
-	if conf.BaseConfInfo.GeminiToken != "" {
-		res = append(res, param.Gemini)
-	}
-	if conf.BaseConfInfo.OpenAIToken != "" {
-		res = append(res, param.OpenAi)
-	}
-	if conf.BaseConfInfo.OpenRouterToken != "" {
-		res = append(res, param.OpenRouter)
-	}
-	if conf.BaseConfInfo.AliyunToken != "" {
-		res = append(res, param.Aliyun)
-	}
-	if conf.BaseConfInfo.AI302Token != "" {
-		res = append(res, param.AI302)
-	}
-	if conf.BaseConfInfo.VolToken != "" {
-		res = append(res, param.Vol)
-	}
-	if conf.BaseConfInfo.ChatAnyWhereToken != "" {
-		res = append(res, param.ChatAnyWhere)
-	}
-
-	return res
+	return provider.Available(provider.Image)
 }
 
 func GetAvailVideoType() []string {
-	res := []string{}
-	if conf.BaseConfInfo.GeminiToken != "" {
-		res = append(res, param.Gemini)
-	}
-	if conf.BaseConfInfo.AliyunToken != "" {
-		res = append(res, param.Aliyun)
-	}
-	if conf.BaseConfInfo.AI302Token != "" {
-		res = append(res, param.AI302)
-	}
-	if conf.BaseConfInfo.VolToken != "" {
-		res = append(res, param.Vol)
-	}
-
-	return res
+	return provider.Available(provider.Video)
 }
 
 func GetAvailTTSType() []string {
-	res := []string{}
-	if conf.BaseConfInfo.GeminiToken != "" {
-		res = append(res, param.Gemini)
-	}
-	if conf.BaseConfInfo.AliyunToken != "" {
-		res = append(res, param.Aliyun)
-	}
-	if conf.BaseConfInfo.VolcAK != "" {
-		res = append(res, param.Vol)
-	}
-	if conf.BaseConfInfo.OpenAIToken != "" {
-		res = append(res, param.OpenAi)
-	}
-
-	return res
+	return provider.Available(provider.TTS)
 }
 
 func GetAvailRecType() []string {
-	res := []string{}
-
-	if conf.BaseConfInfo.GeminiToken != "" {
-		res = append(res, param.Gemini)
-	}
-	if conf.BaseConfInfo.OpenAIToken != "" {
-		res = append(res, param.OpenAi)
-	}
-	if conf.BaseConfInfo.AliyunToken != "" {
-		res = append(res, param.Aliyun)
-	}
-	if conf.BaseConfInfo.AI302Token != "" {
-		res = append(res, param.AI302)
-	}
-	if conf.BaseConfInfo.VolToken != "" {
-		res = append(res, param.Vol)
-	}
-
-	return res
-}
-
-func GetRecType(llmConf *param.LLMConfig) string {
-	if llmConf == nil {
-		return conf.BaseConfInfo.MediaType
-	}
-
-	aType := GetAvailRecType()
-	for _, v := range aType {
-		if v == llmConf.RecType {
-			return v
-		}
-	}
-
-	if len(aType) > 0 {
-		return aType[0]
-	}
-
-	return conf.BaseConfInfo.MediaType
+	return provider.Available(provider.Rec)
 }
 
 func GetUsingImgModel(ty string, model string) string {
-	switch ty {
-	case param.Gemini:
-		if param.GeminiImageModels[model] {
-			return model
-		}
-		return param.GeminiImageGenV2_5
-	case param.Aliyun:
-		if param.AliyunImageModels[model] {
-			return model
-		}
-		return param.QwenImagePlus
-	case param.Vol:
-		if param.VolImageModels[model] {
-			return model
-		}
-		return param.DoubaoSeed16VisionPro
-	default:
-		return model
-	}
+	return getUsingModel(provider.Image, ty, model)
 }
 
 func GetUsingVideoModel(ty string, model string) string {
-	switch ty {
-	case param.Gemini:
-		if param.GeminiVideoModels[model] {
-			return model
-		}
-		return param.GeminiVideoVeo3_1FastPreview
-
-	case param.Aliyun:
-		if param.AliyunVideoModels[model] {
-			return model
-		}
-		return param.Wan2_5T2VPreview
-	case param.AI302:
-		return model
-	case param.Vol:
-		if param.VolVideoModels[model] {
-			return model
-		}
-		return param.DoubaoSeedance1_0Pro
-	}
-
-	return ""
+	return getUsingModel(provider.Video, ty, model)
 }
 
 func GetUsingRecModel(ty string, model string) string {
-	switch ty {
-	case param.Gemini:
-		if param.GeminiRecModels[model] {
-			return model
-		}
-		return param.ModelGemini25Flash
-	case param.Aliyun:
-		if param.AliyunRecModels[model] {
-			return model
-		}
-		return param.QwenVlMax
-	case param.Vol:
-		if param.VolRecModels[model] {
-			return model
-		}
-		return param.DoubaoSeed16VisionPro
-	default:
-		return model
-	}
+	return getUsingModel(provider.Rec, ty, model)
 }
 
 func GetUsingTxtModel(ty string, model string) string {
-	switch ty {
-	case param.DeepSeek:
-		if param.DeepseekModels[model] {
-			return model
-		}
-		return godeepseek.DeepSeekChat
-	case param.Gemini:
-		if param.GeminiModels[model] {
-			return model
-		}
-		return param.ModelGemini25Flash
-	case param.Vol:
-		if param.VolModels[model] {
-			return model
-		}
-		return param.ModelDeepSeekR1_528
-	case param.Aliyun:
-		if param.AliyunModel[model] {
-			return model
-		}
-		return qwen.QwenMax
-	}
-
-	return model
+	return getUsingModel(provider.Text, ty, model)
 }
 
 func GetUsingTTSModel(ty string, model string) string {
-	switch ty {
-	case param.OpenAi:
+	return getUsingModel(provider.TTS, ty, model)
+}
+
+func getUsingModel(c provider.Capability, ty string, model string) string {
+	p := provider.Get(ty)
+	if p == nil {
 		return model
-	case param.Gemini:
-		if param.GeminiTTSModels[model] {
-			return model
-		}
-		return param.Gemini2_5FlashPreviewTTS
-	case param.Vol:
-		if param.VolTTSModels[model] {
-			return model
-		}
-		return param.VolTTS
-	case param.Aliyun:
-		if param.AliyunTTSModels[model] {
-			return model
-		}
-		return param.Qwen3TTSFlash
 	}
+	return p.UsingModel(c, model)
+}
+
+// SwitchLLMType copy llm config with provider and model of capability replaced.
+func SwitchLLMType(llmConf *param.LLMConfig, c provider.Capability, t, model string) *param.LLMConfig {
+	res := new(param.LLMConfig)
+	if llmConf != nil {
+		*res = *llmConf
+	}
+
+	switch c {
+	case provider.Text:
+		res.TxtType, res.TxtModel = t, model
+	case provider.Image:
+		res.ImgType, res.ImgModel = t, model
+	case provider.Video:
+		res.VideoType, res.VideoModel = t, model
+	case provider.Rec:
+		res.RecType, res.RecModel = t, model
+	case provider.TTS:
+		res.TTSType, res.TTSModel = t, model
+	}
+	return res
+}
 
-	return model
+// GetFallbackMode join providers which are tried in order, such as deepseek->gemini.
+func GetFallbackMode(fallbacks []string, t string) string {
+	return strings.Join(append(append([]string{}, fallbacks...), t), "->")
 }
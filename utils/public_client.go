@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+const publicClientMaxRedirects = 5
+
+var (
+	ErrNotPublicAddress = errors.New("address is not public")
+
+	// carrier-grade nat, ip.IsPrivate doesn't include it
+	sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+)
+
+// GetPublicClient client of robot proxy for urls given by users, it only connects to public addresses,
+// so loopback, private and link-local services of bot host can't be reached, redirects included.
+func GetPublicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		// address is resolved ip here, no dns rebinding after the check
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrNotPublicAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+
+	var rt http.RoundTripper = transport
+	if conf.BaseConfInfo.RobotProxy != "" {
+		proxy, err := url.Parse(conf.BaseConfInfo.RobotProxy)
+		if err != nil {
+			logger.Warn("parse proxy url fail", "err", err)
+		}
+		// proxy resolves target host, check it before sending request to proxy
+		transport.Proxy = http.ProxyURL(proxy)
+		transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second}).DialContext
+		rt = &publicProxyTransport{base: transport}
+	}
+
+	return &http.Client{
+		Transport: rt,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= publicClientMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", publicClientMaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme: %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+type publicProxyTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip every request including redirects checks all addresses of host.
+func (p *publicProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := CheckPublicHost(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	return p.base.RoundTrip(req)
+}
+
+// CheckPublicHost resolve host and check all of its addresses are public.
+func CheckPublicHost(ctx context.Context, host string) error {
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrNotPublicAddress, host, ip.IP)
+		}
+	}
+	return nil
+}
+
+// IsPublicIP false for loopback, private, link-local, unspecified, multicast and shared addresses.
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/metrics"
+	"golang.org/x/time/rate"
+)
+
+const (
+	RateLimitUser     = "user"
+	RateLimitGroup    = "group"
+	RateLimitCommand  = "command"
+	RateLimitProvider = "provider"
+
+	// rateLimitEvictInterval idle limiters are evicted at most once per interval
+	rateLimitEvictInterval = time.Minute
+)
+
+type rateLimiter struct {
+	spec    string
+	burst   int
+	limiter *rate.Limiter
+	// lastUse unix nano of last reservation
+	lastUse atomic.Int64
+}
+
+var (
+	rateLimiters  = sync.Map{}
+	rateLimitMu   sync.Mutex
+	lastEvictTime atomic.Int64
+)
+
+// RateLimitError is returned when request is rejected by rate limit.
+type RateLimitError struct {
+	Scope string
+	Name  string
+	Wait  time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s %s, retry after %s", e.Scope, e.Name, e.Wait.Round(time.Second))
+}
+
+// RetryAfter seconds to wait before next request, at least 1.
+func (e *RateLimitError) RetryAfter() int {
+	return int(math.Max(1, math.Ceil(e.Wait.Seconds())))
+}
+
+// RateCheck one bucket checked by AllowRates, name is the label of metrics, it is command class or provider.
+type RateCheck struct {
+	Scope string
+	Name  string
+	Key   string
+	Limit *conf.RateLimit
+}
+
+// AllowRate take one token from bucket of key, return RateLimitError when bucket is empty.
+// name is the label of metrics, it is command class or provider.
+func AllowRate(scope, name, key string, limit *conf.RateLimit) error {
+	return AllowRates(&RateCheck{Scope: scope, Name: name, Key: key, Limit: limit})
+}
+
+// AllowRates take one token from every bucket of checks, or none of them when any bucket is empty,
+// so tokens of a bucket are not spent by requests rejected by another one.
+func AllowRates(checks ...*RateCheck) error {
+	now := time.Now()
+	evictIdleLimiters(now)
+
+	reservations := make([]*rate.Reservation, 0, len(checks))
+	cancel := func() {
+		for _, reservation := range reservations {
+			reservation.CancelAt(now)
+		}
+	}
+
+	for _, check := range checks {
+		if check.Limit == nil {
+			continue
+		}
+
+		reservation := getRateLimiter(check.Scope+":"+check.Key, check.Limit, now).ReserveN(now, 1)
+		if !reservation.OK() {
+			cancel()
+			metrics.RateLimitHitCount.WithLabelValues(check.Scope, check.Name).Inc()
+			return &RateLimitError{Scope: check.Scope, Name: check.Name, Wait: time.Duration(float64(time.Second) / check.Limit.Rate)}
+		}
+
+		reservations = append(reservations, reservation)
+		if wait := reservation.DelayFrom(now); wait > 0 {
+			cancel()
+			metrics.RateLimitHitCount.WithLabelValues(check.Scope, check.Name).Inc()
+			return &RateLimitError{Scope: check.Scope, Name: check.Name, Wait: wait}
+		}
+	}
+
+	return nil
+}
+
+// AllowProvider check rate limit of provider shared by all users.
+func AllowProvider(t string) error {
+	return AllowRate(RateLimitProvider, t, t, conf.RateLimitConfInfo.GetProvider(t))
+}
+
+// getRateLimiter limiter is rebuilt when spec of limit is changed.
+func getRateLimiter(key string, limit *conf.RateLimit, now time.Time) *rate.Limiter {
+	if l, ok := rateLimiters.Load(key); ok && l.(*rateLimiter).spec == limit.Spec {
+		l.(*rateLimiter).lastUse.Store(now.UnixNano())
+		return l.(*rateLimiter).limiter
+	}
+
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	if l, ok := rateLimiters.Load(key); ok && l.(*rateLimiter).spec == limit.Spec {
+		l.(*rateLimiter).lastUse.Store(now.UnixNano())
+		return l.(*rateLimiter).limiter
+	}
+
+	l := &rateLimiter{
+		spec:    limit.Spec,
+		burst:   limit.Burst,
+		limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst),
+	}
+	l.lastUse.Store(now.UnixNano())
+	rateLimiters.Store(key, l)
+	return l.limiter
+}
+
+// evictIdleLimiters drop limiters whose bucket is full again, a new limiter built for the key next time is the same.
+func evictIdleLimiters(now time.Time) {
+	last := lastEvictTime.Load()
+	if now.UnixNano()-last < int64(rateLimitEvictInterval) || !lastEvictTime.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimiters.Range(func(key, value any) bool {
+		l := value.(*rateLimiter)
+		if now.UnixNano()-l.lastUse.Load() >= int64(rateLimitEvictInterval) && l.limiter.TokensAt(now) >= float64(l.burst) {
+			rateLimiters.Delete(key)
+		}
+		return true
+	})
+}
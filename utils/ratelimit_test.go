@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+func TestAllowRate(t *testing.T) {
+	limit, err := conf.ParseRateLimit("1/h:2")
+	if err != nil {
+		t.Fatalf("ParseRateLimit failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := AllowRate(RateLimitUser, "", "rate_user", limit); err != nil {
+			t.Fatalf("request %d should be allowed: %v", i, err)
+		}
+	}
+
+	err = AllowRate(RateLimitUser, "", "rate_user", limit)
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("expected RateLimitError, got %v", err)
+	}
+	if rateErr.RetryAfter() < 3000 || rateErr.RetryAfter() > 3600 {
+		t.Errorf("unexpected retry after: %d", rateErr.RetryAfter())
+	}
+
+	// other user has own bucket
+	if err := AllowRate(RateLimitUser, "", "rate_user_2", limit); err != nil {
+		t.Errorf("other user should be allowed: %v", err)
+	}
+
+	// bucket is rebuilt when limit changed
+	limit, _ = conf.ParseRateLimit("10/s")
+	if err := AllowRate(RateLimitUser, "", "rate_user", limit); err != nil {
+		t.Errorf("request should be allowed after limit changed: %v", err)
+	}
+
+	if err := AllowRate(RateLimitUser, "", "rate_user", nil); err != nil {
+		t.Errorf("nil limit should not limit: %v", err)
+	}
+}
+
+func TestAllowRates(t *testing.T) {
+	user, _ := conf.ParseRateLimit("1/h:2")
+	group, _ := conf.ParseRateLimit("1/h:1")
+
+	checks := []*RateCheck{
+		{Scope: RateLimitUser, Key: "rates_user", Limit: user},
+		{Scope: RateLimitGroup, Key: "rates_group", Limit: group},
+	}
+	if err := AllowRates(checks...); err != nil {
+		t.Fatalf("first request should be allowed: %v", err)
+	}
+
+	// group is empty, token of user is given back
+	for i := 0; i < 3; i++ {
+		var rateErr *RateLimitError
+		if err := AllowRates(checks...); !errors.As(err, &rateErr) || rateErr.Scope != RateLimitGroup {
+			t.Fatalf("expected group RateLimitError, got %v", err)
+		}
+	}
+	if err := AllowRate(RateLimitUser, "", "rates_user", user); err != nil {
+		t.Errorf("user should have one token left: %v", err)
+	}
+}
+
+func TestEvictIdleLimiters(t *testing.T) {
+	limit, _ := conf.ParseRateLimit("10/s:1")
+	if err := AllowRate(RateLimitUser, "", "evict_user", limit); err != nil {
+		t.Fatalf("request should be allowed: %v", err)
+	}
+
+	now := time.Now()
+	lastEvictTime.Store(now.UnixNano())
+	evictIdleLimiters(now.Add(rateLimitEvictInterval / 2))
+	if _, ok := rateLimiters.Load(RateLimitUser + ":evict_user"); !ok {
+		t.Errorf("limiter should be kept before evict interval")
+	}
+
+	evictIdleLimiters(now.Add(2 * rateLimitEvictInterval))
+	if _, ok := rateLimiters.Load(RateLimitUser + ":evict_user"); ok {
+		t.Errorf("idle limiter with full bucket should be evicted")
+	}
+}
@@ -8,9 +8,14 @@ import (
 
 var (
 	userChatMap = sync.Map{}
+	userChatMu  sync.Mutex
 )
 
+// CheckUserChatExceed count concurrent chat of user, count is increased only when it is not exceeded.
 func CheckUserChatExceed(userId string) bool {
+	userChatMu.Lock()
+	defer userChatMu.Unlock()
+
 	times := 1
 	if timeInter, ok := userChatMap.Load(userId); ok {
 		times = timeInter.(int)
@@ -24,9 +29,15 @@ func CheckUserChatExceed(userId string) bool {
 }
 
 func DecreaseUserChat(userId string) {
+	userChatMu.Lock()
+	defer userChatMu.Unlock()
+
 	if timeInter, ok := userChatMap.Load(userId); ok {
 		times := timeInter.(int)
-		times--
-		userChatMap.Store(userId, times)
+		if times <= 1 {
+			userChatMap.Delete(userId)
+			return
+		}
+		userChatMap.Store(userId, times-1)
 	}
 }
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"testing"
+
+	"github.com/yincongcyincong/MuseBot/conf"
 )
 
 func TestDecreaseUserChat(t *testing.T) {
@@ -15,3 +17,21 @@ func TestDecreaseUserChat(t *testing.T) {
 		t.Errorf("Expected times to be 2, got %v", val)
 	}
 }
+
+func TestCheckUserChatExceed(t *testing.T) {
+	conf.BaseConfInfo.MaxUserChat = 2
+	userId := "888888888"
+
+	if CheckUserChatExceed(userId) || CheckUserChatExceed(userId) {
+		t.Fatalf("first two chats should not exceed")
+	}
+	if !CheckUserChatExceed(userId) {
+		t.Fatalf("third chat should exceed")
+	}
+
+	DecreaseUserChat(userId)
+	DecreaseUserChat(userId)
+	if _, ok := userChatMap.Load(userId); ok {
+		t.Errorf("user should be removed when all chats finished")
+	}
+}
@@ -70,10 +70,14 @@ func GetRobotProxyClient() *http.Client {
 }
 
 func GetLLMProxyClient() *http.Client {
+	return GetProxyClient(conf.BaseConfInfo.LLMProxy)
+}
+
+func GetProxyClient(proxyURL string) *http.Client {
 	transport := &http.Transport{}
 
-	if conf.BaseConfInfo.LLMProxy != "" {
-		proxy, err := url.Parse(conf.BaseConfInfo.LLMProxy)
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
 		if err != nil {
 			logger.Warn("parse proxy url fail", "err", err)
 		}